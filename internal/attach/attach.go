@@ -0,0 +1,363 @@
+package attach
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/utils"
+)
+
+const (
+	// RefPrefix marks a path to attach in a prompt, e.g. "explain @main.go"
+	RefPrefix = "@"
+
+	DefaultMaxFileSize = 256 << 10
+	// DefaultMaxTotalSize is the largest request the OpenAI API accepts,
+	// images count towards it
+	DefaultMaxTotalSize = 50 << 20
+	DefaultMaxFiles     = 100
+	// DefaultMaxImageSize is the largest image the OpenAI API accepts
+	DefaultMaxImageSize = 20 << 20
+
+	// binarySniffLen is how much of a file is checked for NUL bytes, the
+	// same heuristic git uses
+	binarySniffLen = 8000
+	ignoreFileName = ".gitignore"
+)
+
+var (
+	ErrTooLarge     = errors.New("attachment too large")
+	ErrTooManyFiles = errors.New("too many files attached")
+	ErrBinary       = errors.New("binary files can not be attached")
+	ErrImageType    = errors.New("only png and jpeg images can be attached")
+
+	// imageTypes are the mime types of the images that can be attached by
+	// their file extension
+	imageTypes = map[string]string{
+		".png":  "image/png",
+		".jpg":  "image/jpeg",
+		".jpeg": "image/jpeg",
+	}
+)
+
+type Limits struct {
+	MaxFileSize  int64
+	MaxTotalSize int64
+	MaxFiles     int
+	MaxImageSize int64
+}
+
+func DefaultLimits() Limits {
+	return Limits{
+		MaxFileSize:  DefaultMaxFileSize,
+		MaxTotalSize: DefaultMaxTotalSize,
+		MaxFiles:     DefaultMaxFiles,
+		MaxImageSize: DefaultMaxImageSize,
+	}
+}
+
+// File is a file read to be attached to a message
+type File struct {
+	// Path is the path as it was referenced, files of a directory are
+	// joined to the referenced directory
+	Path    string
+	Size    int64
+	Content string
+	// MimeType is set for images, their bytes are in Data instead of Content
+	MimeType string
+	Data     []byte
+}
+
+func (f *File) IsImage() bool {
+	return f.MimeType != ""
+}
+
+// explicitRefPrefixes mark a path that is referenced also if it does not
+// exist, so that a typo is reported instead of sent as text
+var explicitRefPrefixes = []string{"./", "../", "~/", "/"}
+
+// Refs returns the paths referenced with @path in the input. Only existing
+// paths and paths starting like @./ are references so that decorators and
+// mentions like @property or @alice are text, so is an @ inside a word like
+// an email address, a word starting with @@ and anything in fenced code
+func Refs(input string) []string {
+	var refs []string
+	seen := make(map[string]struct{})
+	inFence := false
+	for _, line := range strings.Split(input, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		for _, field := range strings.Fields(line) {
+			if !strings.HasPrefix(field, RefPrefix) || strings.HasPrefix(field, RefPrefix+RefPrefix) {
+				continue
+			}
+			// punctuation after a reference belongs to the sentence
+			ref := strings.TrimRight(strings.TrimPrefix(field, RefPrefix), ",;:!?)\"'")
+			if ref == "" || !isRef(ref) {
+				continue
+			}
+			if _, ok := seen[ref]; ok {
+				continue
+			}
+			seen[ref] = struct{}{}
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+func isRef(ref string) bool {
+	for _, prefix := range explicitRefPrefixes {
+		if strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+	path, err := utils.ExpandPath(ref)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+type resolver struct {
+	limits Limits
+	files  []*File
+	total  int64
+}
+
+// Resolve reads the referenced files and the files in referenced
+// directories, files ignored by .gitignore, binary files and files over the
+// size limit are skipped in directories but are an error if referenced
+func Resolve(refs []string, limits Limits) ([]*File, error) {
+	r := &resolver{limits: limits}
+	for _, ref := range refs {
+		path, err := utils.ExpandPath(ref)
+		if err != nil {
+			return nil, fmt.Errorf("utils.ExpandPath: %w", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not attach %s%s: %w", RefPrefix, ref, err)
+		}
+		if !info.IsDir() {
+			add := r.add
+			// images are only attached if referenced, in directories they
+			// are skipped like other binary files
+			if _, ok := imageTypes[strings.ToLower(filepath.Ext(path))]; ok {
+				add = r.addImage
+			}
+			if err := add(path, ref, info, true); err != nil {
+				return nil, fmt.Errorf("could not attach %s%s: %w", RefPrefix, ref, err)
+			}
+			continue
+		}
+		err = Walk(path, func(filePath string, entry fs.DirEntry) error {
+			rel, err := filepath.Rel(path, filePath)
+			if err != nil {
+				return fmt.Errorf("filepath.Rel: %w", err)
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return fmt.Errorf("entry.Info: %w", err)
+			}
+			return r.add(filePath, filepath.Join(ref, rel), info, false)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not attach %s%s: %w", RefPrefix, ref, err)
+		}
+	}
+	return r.files, nil
+}
+
+func (r *resolver) add(path, displayPath string, info os.FileInfo, explicit bool) error {
+	if info.Size() > r.limits.MaxFileSize {
+		if !explicit {
+			return nil
+		}
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrTooLarge, info.Size(), r.limits.MaxFileSize)
+	}
+	if len(r.files) >= r.limits.MaxFiles {
+		return fmt.Errorf("%w, the limit is %d", ErrTooManyFiles, r.limits.MaxFiles)
+	}
+	if r.total+info.Size() > r.limits.MaxTotalSize {
+		return fmt.Errorf("%w: all attachments are over the limit of %d bytes", ErrTooLarge, r.limits.MaxTotalSize)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("os.ReadFile: %w", err)
+	}
+	sniff := content
+	if len(sniff) > binarySniffLen {
+		sniff = sniff[:binarySniffLen]
+	}
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		if !explicit {
+			return nil
+		}
+		return ErrBinary
+	}
+	r.total += int64(len(content))
+	r.files = append(r.files, &File{
+		Path:    displayPath,
+		Size:    int64(len(content)),
+		Content: string(content),
+	})
+	return nil
+}
+
+func (r *resolver) addImage(path, displayPath string, info os.FileInfo, _ bool) error {
+	if info.Size() > r.limits.MaxImageSize {
+		return fmt.Errorf("%w: %d bytes, the limit for images is %d", ErrTooLarge, info.Size(), r.limits.MaxImageSize)
+	}
+	if len(r.files) >= r.limits.MaxFiles {
+		return fmt.Errorf("%w, the limit is %d", ErrTooManyFiles, r.limits.MaxFiles)
+	}
+	if r.total+info.Size() > r.limits.MaxTotalSize {
+		return fmt.Errorf("%w: all attachments are over the limit of %d bytes", ErrTooLarge, r.limits.MaxTotalSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("os.ReadFile: %w", err)
+	}
+	// the extension has to match the content, the API rejects other types
+	mimeType := imageTypes[strings.ToLower(filepath.Ext(path))]
+	if http.DetectContentType(data) != mimeType {
+		return ErrImageType
+	}
+	r.total += int64(len(data))
+	r.files = append(r.files, &File{
+		Path:     displayPath,
+		Size:     int64(len(data)),
+		MimeType: mimeType,
+		Data:     data,
+	})
+	return nil
+}
+
+// Walk calls fn for the regular files below dir that are not ignored by a
+// .gitignore of the directory or its repository, path is joined to dir,
+// symlinks and the .git directory are skipped
+func Walk(dir string, fn func(path string, entry fs.DirEntry) error) error {
+	// ignore files are matched relative to their directory so the walk
+	// uses absolute paths
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("filepath.Abs: %w", err)
+	}
+	return walk(abs, parentIgnoreFiles(abs), func(path string, entry fs.DirEntry) error {
+		rel, err := filepath.Rel(abs, path)
+		if err != nil {
+			return fmt.Errorf("filepath.Rel: %w", err)
+		}
+		return fn(filepath.Join(dir, rel), entry)
+	})
+}
+
+func walk(dir string, ignores []*ignoreFile, fn func(path string, entry fs.DirEntry) error) error {
+	if ignore, err := readIgnoreFile(filepath.Join(dir, ignoreFileName), dir); err == nil {
+		ignores = append(ignores, ignore)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("readIgnoreFile: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("os.ReadDir: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, entry := range entries {
+		// symlinks are skipped so that walking can not loop
+		if entry.Name() == ".git" || entry.Type()&os.ModeSymlink != 0 {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if isIgnored(ignores, path, entry.IsDir()) {
+			continue
+		}
+		if entry.IsDir() {
+			if err := walk(path, ignores, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err := fn(path, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isIgnored(ignores []*ignoreFile, path string, isDir bool) bool {
+	ignored := false
+	// deeper .gitignore files override the ones above them
+	for _, ignore := range ignores {
+		rel, err := filepath.Rel(ignore.dir, path)
+		if err != nil {
+			continue
+		}
+		if i, matched := ignore.match(filepath.ToSlash(rel), isDir); matched {
+			ignored = i
+		}
+	}
+	return ignored
+}
+
+func isRepoRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// parentIgnoreFiles returns the .gitignore files above dir up to the root
+// of its repository outermost first, none if dir is not in a repository
+func parentIgnoreFiles(dir string) []*ignoreFile {
+	var ignores []*ignoreFile
+	for current := dir; !isRepoRoot(current); {
+		parent := filepath.Dir(current)
+		if parent == current {
+			return nil
+		}
+		current = parent
+		if ignore, err := readIgnoreFile(filepath.Join(current, ignoreFileName), current); err == nil {
+			ignores = append([]*ignoreFile{ignore}, ignores...)
+		}
+	}
+	return ignores
+}
+
+// AddToMessage adds the files to message, text files as attachments and
+// images as message parts
+func AddToMessage(message *db.Message, files []*File) {
+	for _, file := range files {
+		if file.IsImage() {
+			message.Parts = append(message.Parts, &db.MessagePart{
+				Type:     db.PartTypeImage,
+				MimeType: file.MimeType,
+				Path:     file.Path,
+				Data:     file.Data,
+			})
+			continue
+		}
+		message.Attachments = append(message.Attachments, &db.Attachment{
+			Path:    file.Path,
+			Size:    file.Size,
+			Content: file.Content,
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package attach
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRefs(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go":       "package main",
+		"internal/a.go": "package internal",
+		"property":      "a file named like a decorator",
+	})
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "explain @main.go and @internal/, mail me@example.com @main.go @", want: []string{"main.go", "internal/"}},
+		{input: "ask @alice about @Override and @missing.go"},
+		{input: "typo in @./mian.go", want: []string{"./mian.go"}},
+		{input: "@@main.go is text"},
+		{input: "explain @main.go\n```python\n@property\ndef name(self):\n```\n```java\n@Override\n```", want: []string{"main.go"}},
+		{input: "~~~\n@main.go\n~~~\n@property", want: []string{"property"}},
+	}
+	for _, tt := range tests {
+		if refs := Refs(tt.input); !reflect.DeepEqual(refs, tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.input, tt.want, refs)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".git/HEAD":          "ref: refs/heads/main",
+		".gitignore":         "*.log\n/build/\n!keep.log\n",
+		"main.go":            "package main",
+		"debug.log":          "ignored",
+		"keep.log":           "kept by negation",
+		"build/out.txt":      "ignored directory",
+		"src/a.go":           "package src",
+		"src/.gitignore":     "generated/\n",
+		"src/generated/b.go": "ignored by the nested .gitignore",
+		"src/binary.bin":     "a\x00b",
+		"src/app/trace.log":  "ignored from the root .gitignore",
+	})
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Resolve([]string{"main.go", "."}, DefaultLimits())
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	expected := []string{"main.go", ".gitignore", "keep.log", "main.go", "src/.gitignore", "src/a.go"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+
+	// the .gitignore of the repository applies to sub directories
+	files, err = Resolve([]string{"src/app"}, DefaultLimits())
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected no files, got %v", files)
+	}
+
+	if _, err := Resolve([]string{"src/binary.bin"}, DefaultLimits()); !errors.Is(err, ErrBinary) {
+		t.Errorf("expected ErrBinary, got %v", err)
+	}
+	if _, err := Resolve([]string{"main.go"}, Limits{MaxFileSize: 4, MaxTotalSize: 100, MaxFiles: 10}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if _, err := Resolve([]string{"src"}, Limits{MaxFileSize: 100, MaxTotalSize: 100, MaxFiles: 1}); !errors.Is(err, ErrTooManyFiles) {
+		t.Errorf("expected ErrTooManyFiles, got %v", err)
+	}
+	if _, err := Resolve([]string{"missing.go"}, DefaultLimits()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go":         "",
+		"main_test.go":    "",
+		"internal/a.go":   "",
+		".hidden/file.go": "",
+	})
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		prefix   string
+		expected []string
+	}{
+		{"ma", []string{"main.go", "main_test.go"}},
+		{"int", []string{"internal/"}},
+		{"internal/", []string{"internal/a.go"}},
+		{"", []string{"internal/", "main.go", "main_test.go"}},
+		{".h", []string{".hidden/"}},
+		{"missing/", nil},
+	}
+	for _, tc := range testCases {
+		if candidates := Complete(tc.prefix); !reflect.DeepEqual(candidates, tc.expected) {
+			t.Errorf("Complete(%q): expected %v, got %v", tc.prefix, tc.expected, candidates)
+		}
+	}
+	if prefix := CommonPrefix([]string{"main.go", "main_test.go"}); prefix != "main" {
+		t.Errorf("expected common prefix main, got %q", prefix)
+	}
+}
+
+func TestResolveImages(t *testing.T) {
+	root := t.TempDir()
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	writeFiles(t, root, map[string]string{
+		"img/a.png":    png,
+		"img/fake.jpg": "not an image",
+		"img/notes.md": "notes",
+	})
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Resolve([]string{"img/a.png"}, DefaultLimits())
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(files) != 1 || !files[0].IsImage() || files[0].MimeType != "image/png" || string(files[0].Data) != png {
+		t.Errorf("expected a png image, got %+v", files)
+	}
+	// images in directories are skipped like other binary files
+	files, err = Resolve([]string{"img"}, DefaultLimits())
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(files) != 2 || files[0].Path != "img/fake.jpg" || files[1].Path != "img/notes.md" {
+		t.Errorf("expected the text files, got %+v", files)
+	}
+	if _, err := Resolve([]string{"img/fake.jpg"}, DefaultLimits()); !errors.Is(err, ErrImageType) {
+		t.Errorf("expected ErrImageType, got %v", err)
+	}
+
+	// images count towards the total like other files
+	limits := Limits{MaxFileSize: 100, MaxTotalSize: int64(len(png)) + 4, MaxFiles: 10, MaxImageSize: 100}
+	if _, err := Resolve([]string{"img/a.png", "img/notes.md"}, limits); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected the text file to be over the total, got %v", err)
+	}
+	if _, err := Resolve([]string{"img/notes.md", "img/a.png"}, limits); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected the image to be over the total, got %v", err)
+	}
+	limits.MaxTotalSize++
+	if files, err := Resolve([]string{"img/a.png", "img/notes.md"}, limits); err != nil || len(files) != 2 {
+		t.Errorf("expected both files within the total, got %+v, %v", files, err)
+	}
+}
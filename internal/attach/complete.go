@@ -0,0 +1,63 @@
+package attach
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aavshr/panda/internal/utils"
+)
+
+// Complete returns the paths that start with prefix, directories end with a
+// slash, hidden files are only completed if prefix names them
+func Complete(prefix string) []string {
+	dirPart := prefix[:strings.LastIndex(prefix, "/")+1]
+	base := prefix[len(dirPart):]
+	dir := dirPart
+	if dir == "" {
+		dir = "."
+	}
+	expanded, err := utils.ExpandPath(dir)
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(expanded)
+	if err != nil {
+		return nil
+	}
+	var candidates []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		candidate := dirPart + name
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(expanded, name)); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		if isDir {
+			candidate += "/"
+		}
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// CommonPrefix returns the longest prefix shared by all candidates
+func CommonPrefix(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,119 @@
+package attach
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ignorePattern is a compiled line of a .gitignore file
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreFile holds the patterns of a .gitignore file, paths are matched
+// relative to the directory of the file
+type ignoreFile struct {
+	dir      string
+	patterns []ignorePattern
+}
+
+func readIgnoreFile(path, dir string) (*ignoreFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ignore := &ignoreFile{dir: dir}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if pattern, ok := compileIgnorePattern(scanner.Text()); ok {
+			ignore.patterns = append(ignore.patterns, pattern)
+		}
+	}
+	return ignore, scanner.Err()
+}
+
+func compileIgnorePattern(line string) (ignorePattern, bool) {
+	var pattern ignorePattern
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern, false
+	}
+	if strings.HasPrefix(line, "!") {
+		pattern.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, "\\")
+	if strings.HasSuffix(line, "/") {
+		pattern.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// patterns with a slash are relative to the .gitignore, others match
+	// at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return pattern, false
+	}
+
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case strings.HasPrefix(line[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "/**") && i+3 == len(line):
+			re.WriteString("(?:/.*)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(line[i:], ']')
+			if end < 0 {
+				re.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := line[i+1 : i+end]
+			class = strings.Replace(class, "!", "^", 1)
+			re.WriteString("[" + class + "]")
+			i += end
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return pattern, false
+	}
+	pattern.re = compiled
+	return pattern, true
+}
+
+// match reports whether the slash separated path relative to the
+// .gitignore directory is ignored, matched is false if no pattern applies
+func (f *ignoreFile) match(relPath string, isDir bool) (ignored, matched bool) {
+	// the last matching pattern wins
+	for _, p := range f.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(relPath) {
+			ignored, matched = !p.negate, true
+		}
+	}
+	return ignored, matched
+}
@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/aavshr/panda/internal/attach"
+	"github.com/aavshr/panda/internal/config"
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/llm"
+	"github.com/aavshr/panda/internal/utils"
+	"golang.org/x/term"
+)
+
+const (
+	timeFormat        = "2006-01-02 15:04:05"
+	roleUser          = "user"
+	roleAssistant     = "assistant"
+	messagesPageLimit = 100
+)
+
+var (
+	ErrEmptyPrompt = errors.New("empty prompt")
+)
+
+type Store interface {
+	GetThread(threadID string) (*db.Thread, error)
+	ListMessagesByThreadIDPaginated(threadID string, offset, limit int) ([]*db.Message, error)
+	UpsertThread(thread *db.Thread) error
+	CreateMessage(message *db.Message) error
+}
+
+type LLM interface {
+	CreateChatCompletionStream(context.Context, string, llm.Params, []*db.Message) (io.ReadCloser, error)
+}
+
+type AskInput struct {
+	// Prompt is the prompt given as arguments
+	Prompt string
+	// Stdin is optional content piped into the command
+	Stdin io.Reader
+	// ThreadID continues an existing thread if set
+	ThreadID string
+	// Model is the default model, overridden by the model of the thread
+	Model string
+	// OverrideModel replaces both the default and the thread model
+	OverrideModel string
+	// Params are the default params, overridden by the params of the thread
+	Params llm.Params
+	// Overrides replace both the default and the thread params
+	Overrides llm.Params
+	Out       io.Writer
+}
+
+// Ask sends the prompt, streams the answer to in.Out and saves both to the
+// store, it returns the thread the messages were saved to
+func Ask(ctx context.Context, store Store, backend LLM, in *AskInput) (*db.Thread, error) {
+	prompt, err := buildPrompt(in.Prompt, in.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	// only the prompt argument is searched for @path, piped content could
+	// be anything
+	files, err := attach.Resolve(attach.Refs(in.Prompt), attach.DefaultLimits())
+	if err != nil {
+		return nil, fmt.Errorf("attach.Resolve: %w", err)
+	}
+
+	var thread *db.Thread
+	var messages []*db.Message
+	if in.ThreadID != "" {
+		thread, err = store.GetThread(in.ThreadID)
+		if err != nil {
+			return nil, fmt.Errorf("store.GetThread: %w", err)
+		}
+		messages, err = listAllMessages(store, thread.ID)
+		if err != nil {
+			return thread, err
+		}
+	} else {
+		thread, err = newThread(utils.ThreadName(prompt))
+		if err != nil {
+			return nil, err
+		}
+		if err := store.UpsertThread(thread); err != nil {
+			return thread, fmt.Errorf("store.UpsertThread: %w", err)
+		}
+	}
+
+	userMessage := &db.Message{
+		Role:      roleUser,
+		ThreadID:  thread.ID,
+		Content:   prompt,
+		CreatedAt: time.Now().Format(timeFormat),
+	}
+	attach.AddToMessage(userMessage, files)
+	if err := store.CreateMessage(userMessage); err != nil {
+		return thread, fmt.Errorf("store.CreateMessage: %w", err)
+	}
+	messages = append(messages, userMessage)
+
+	threadParams, err := llm.ParseParams(thread.Params)
+	if err != nil {
+		return thread, fmt.Errorf("llm.ParseParams: %w", err)
+	}
+	params := in.Params.Merge(threadParams).Merge(in.Overrides)
+	model := in.Model
+	if thread.Model != "" {
+		model = thread.Model
+	}
+	if in.OverrideModel != "" {
+		model = in.OverrideModel
+	}
+	stream, err := backend.CreateChatCompletionStream(ctx, model, params, messages)
+	if err != nil {
+		return thread, fmt.Errorf("llm.CreateChatCompletionStream: %w", err)
+	}
+	defer stream.Close()
+
+	var content strings.Builder
+	if _, err := io.Copy(io.MultiWriter(in.Out, &content), stream); err != nil {
+		return thread, fmt.Errorf("could not read completion stream, io.Copy: %w", err)
+	}
+	if !strings.HasSuffix(content.String(), "\n") {
+		fmt.Fprintln(in.Out)
+	}
+
+	assistantMessage := &db.Message{
+		Role:      roleAssistant,
+		ThreadID:  thread.ID,
+		Content:   content.String(),
+		CreatedAt: time.Now().Format(timeFormat),
+		Params:    params.Encode(),
+		Model:     model,
+	}
+	if err := store.CreateMessage(assistantMessage); err != nil {
+		return thread, fmt.Errorf("store.CreateMessage: %w", err)
+	}
+	return thread, nil
+}
+
+// buildPrompt joins the prompt from the arguments with piped stdin content
+func buildPrompt(prompt string, stdin io.Reader) (string, error) {
+	prompt = strings.TrimSpace(prompt)
+	if stdin != nil {
+		piped, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("could not read stdin, io.ReadAll: %w", err)
+		}
+		if content := strings.TrimSpace(string(piped)); content != "" {
+			if prompt == "" {
+				prompt = content
+			} else {
+				prompt = fmt.Sprintf("%s\n\n%s", prompt, content)
+			}
+		}
+	}
+	if prompt == "" {
+		return "", ErrEmptyPrompt
+	}
+	return prompt, nil
+}
+
+func newThread(name string) (*db.Thread, error) {
+	threadID, err := utils.RandomID()
+	if err != nil {
+		return nil, fmt.Errorf("utils.RandomID: %w", err)
+	}
+	now := time.Now().Format(timeFormat)
+	return &db.Thread{
+		ID:        threadID,
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+func listAllMessages(store Store, threadID string) ([]*db.Message, error) {
+	var messages []*db.Message
+	for offset := 0; ; offset += messagesPageLimit {
+		page, err := store.ListMessagesByThreadIDPaginated(threadID, offset, messagesPageLimit)
+		if err != nil {
+			return messages, fmt.Errorf("store.ListMessagesByThreadIDPaginated: %w", err)
+		}
+		messages = append(messages, page...)
+		if len(page) < messagesPageLimit {
+			return messages, nil
+		}
+	}
+}
+
+func (a *App) runAsk(args []string) error {
+	flags := a.newFlagSet("ask", "[flags] [prompt]", "reads the prompt from the arguments and/or stdin and streams the answer to stdout")
+	threadID := flags.String("thread", "", "continue the thread with this id")
+	model := flags.String("model", "", "model to use (default is the model of the thread or the configured model)")
+	paramsJSON := flags.String("params", "", `generation params as json overriding the config, e.g. '{"temperature":0.2}'`)
+	showThread := flags.Bool("show-thread", false, "print the thread id to stderr when done")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	params, err := llm.ParseParams(*paramsJSON)
+	if err != nil {
+		return err
+	}
+
+	userConfig, err := config.Load()
+	if err != nil {
+		if errors.Is(err, config.ErrConfigNotFound) {
+			return fmt.Errorf("no config found, run panda once to set it up")
+		}
+		return fmt.Errorf("config.Load: %w", err)
+	}
+	store, err := a.getStore()
+	if err != nil {
+		return err
+	}
+	backend, err := a.conf.NewLLM(userConfig)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	input := &AskInput{
+		Prompt:        strings.Join(flags.Args(), " "),
+		ThreadID:      *threadID,
+		Model:         userConfig.LLMModel,
+		OverrideModel: *model,
+		Params:        userConfig.Params,
+		Overrides:     params,
+		Out:           a.conf.Stdout,
+	}
+	if !term.IsTerminal(int(a.conf.Stdin.Fd())) {
+		input.Stdin = a.conf.Stdin
+	}
+	thread, err := Ask(ctx, store, backend, input)
+	if err != nil {
+		return err
+	}
+	if *showThread {
+		fmt.Fprintf(a.conf.Stderr, "thread: %s\n", thread.ID)
+	}
+	return nil
+}
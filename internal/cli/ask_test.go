@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/llm"
+)
+
+type fakeStore struct {
+	threads  map[string]*db.Thread
+	messages []*db.Message
+}
+
+func (f *fakeStore) GetThread(threadID string) (*db.Thread, error) {
+	thread, ok := f.threads[threadID]
+	if !ok {
+		return nil, db.ErrThreadNotFound
+	}
+	return thread, nil
+}
+
+func (f *fakeStore) ListMessagesByThreadIDPaginated(threadID string, offset, limit int) ([]*db.Message, error) {
+	var messages []*db.Message
+	for _, m := range f.messages {
+		if m.ThreadID == threadID {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (f *fakeStore) UpsertThread(thread *db.Thread) error {
+	f.threads[thread.ID] = thread
+	return nil
+}
+
+func (f *fakeStore) CreateMessage(message *db.Message) error {
+	f.messages = append(f.messages, message)
+	return nil
+}
+
+type fakeLLM struct {
+	received []*db.Message
+	model    string
+	params   llm.Params
+}
+
+func (f *fakeLLM) CreateChatCompletionStream(_ context.Context, model string, params llm.Params, messages []*db.Message) (io.ReadCloser, error) {
+	f.received = messages
+	f.model = model
+	f.params = params
+	return io.NopCloser(strings.NewReader("an answer")), nil
+}
+
+func TestAsk(t *testing.T) {
+	store := &fakeStore{threads: map[string]*db.Thread{}}
+	backend := &fakeLLM{}
+
+	var out strings.Builder
+	thread, err := Ask(context.Background(), store, backend, &AskInput{
+		Prompt: "summarize",
+		Stdin:  strings.NewReader("some piped content\n"),
+		Out:    &out,
+	})
+	if err != nil {
+		t.Fatalf("Ask: %v", err)
+	}
+	if out.String() != "an answer\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	if len(store.messages) != 2 {
+		t.Fatalf("expected 2 saved messages, got %d", len(store.messages))
+	}
+	if store.messages[0].Content != "summarize\n\nsome piped content" {
+		t.Errorf("unexpected prompt %q", store.messages[0].Content)
+	}
+
+	// continuing the thread sends the whole history
+	if _, err := Ask(context.Background(), store, backend, &AskInput{
+		Prompt:   "and again",
+		ThreadID: thread.ID,
+		Out:      io.Discard,
+	}); err != nil {
+		t.Fatalf("Ask: %v", err)
+	}
+	if len(backend.received) != 3 {
+		t.Errorf("expected 3 messages sent to llm, got %d", len(backend.received))
+	}
+
+	if _, err := Ask(context.Background(), store, backend, &AskInput{Out: io.Discard}); err != ErrEmptyPrompt {
+		t.Errorf("expected ErrEmptyPrompt, got %v", err)
+	}
+}
+
+func TestAskParams(t *testing.T) {
+	store := &fakeStore{threads: map[string]*db.Thread{
+		"t0": {ID: "t0", Name: "thread", Params: `{"temperature":0.5,"seed":7}`},
+	}}
+	backend := &fakeLLM{}
+	temperature, maxTokens := float32(1), 10
+	if _, err := Ask(context.Background(), store, backend, &AskInput{
+		Prompt:    "hello",
+		ThreadID:  "t0",
+		Params:    llm.Params{Temperature: &temperature, MaxTokens: 100},
+		Overrides: llm.Params{MaxTokens: maxTokens},
+		Out:       io.Discard,
+	}); err != nil {
+		t.Fatalf("Ask: %v", err)
+	}
+	// thread params override the defaults and are overridden by the overrides
+	if backend.params.Temperature == nil || *backend.params.Temperature != 0.5 {
+		t.Errorf("expected thread temperature 0.5, got %v", backend.params.Temperature)
+	}
+	if backend.params.Seed == nil || *backend.params.Seed != 7 {
+		t.Errorf("expected thread seed 7, got %v", backend.params.Seed)
+	}
+	if backend.params.MaxTokens != maxTokens {
+		t.Errorf("expected max tokens %d, got %d", maxTokens, backend.params.MaxTokens)
+	}
+	saved := store.messages[len(store.messages)-1]
+	if saved.Params != backend.params.Encode() {
+		t.Errorf("expected assistant message params %q, got %q", backend.params.Encode(), saved.Params)
+	}
+}
+
+func TestAskModel(t *testing.T) {
+	store := &fakeStore{threads: map[string]*db.Thread{
+		"t0": {ID: "t0", Name: "thread", Model: "gpt-4o"},
+	}}
+	backend := &fakeLLM{}
+	ask := func(in *AskInput) {
+		t.Helper()
+		in.Prompt = "hello"
+		in.Out = io.Discard
+		if _, err := Ask(context.Background(), store, backend, in); err != nil {
+			t.Fatalf("Ask: %v", err)
+		}
+	}
+
+	ask(&AskInput{Model: "o3-mini"})
+	if backend.model != "o3-mini" {
+		t.Errorf("expected the default model for a new thread, got %q", backend.model)
+	}
+	ask(&AskInput{Model: "o3-mini", ThreadID: "t0"})
+	if backend.model != "gpt-4o" {
+		t.Errorf("expected the model of the thread, got %q", backend.model)
+	}
+	ask(&AskInput{Model: "o3-mini", ThreadID: "t0", OverrideModel: "o1"})
+	if backend.model != "o1" {
+		t.Errorf("expected the override model, got %q", backend.model)
+	}
+	if saved := store.messages[len(store.messages)-1]; saved.Model != "o1" {
+		t.Errorf("expected the model to be saved with the answer, got %q", saved.Model)
+	}
+}
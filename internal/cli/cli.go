@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aavshr/panda/internal/config"
+	"github.com/aavshr/panda/internal/db"
+)
+
+var (
+	ErrUnknownCommand = errors.New("unknown command")
+)
+
+type Config struct {
+	// OpenStore opens the database, it is only called by commands that need it
+	OpenStore func() (*db.Store, error)
+	// NewLLM creates the llm backend for the user config
+	NewLLM func(userConfig *config.Config) (LLM, error)
+	// TemplatesDir is the directory of the prompt templates, empty for the
+	// default
+	TemplatesDir string
+
+	Stdin  *os.File
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+type App struct {
+	conf  *Config
+	store *db.Store
+}
+
+type command struct {
+	name        string
+	description string
+	run         func(a *App, args []string) error
+}
+
+var commands []command
+
+func init() {
+	// assigned in init to allow the commands to reference the list for usage
+	commands = []command{
+		{"ask", "send a prompt and stream the answer to stdout", (*App).runAsk},
+		{"threads", "list, show, delete and rename threads", (*App).runThreads},
+		{"config", "get and set config values", (*App).runConfig},
+		{"export", "export threads to markdown, json or html", (*App).runExport},
+		{"import", "import conversations from ChatGPT or panda exports", (*App).runImport},
+		{"db", "show the database path and maintain the database", (*App).runDB},
+		{"templates", "manage prompt templates with variables", (*App).runTemplates},
+		{"mcp", "list the tools, resources and prompts of mcp servers", (*App).runMCP},
+	}
+}
+
+func New(conf *Config) *App {
+	if conf.Stdin == nil {
+		conf.Stdin = os.Stdin
+	}
+	if conf.Stdout == nil {
+		conf.Stdout = os.Stdout
+	}
+	if conf.Stderr == nil {
+		conf.Stderr = os.Stderr
+	}
+	if conf.TemplatesDir == "" {
+		conf.TemplatesDir = config.GetTemplatesDir()
+	}
+	return &App{conf: conf}
+}
+
+// IsCommand reports whether name is a cli command
+func IsCommand(name string) bool {
+	for _, c := range commands {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Usage writes the list of commands to w
+func Usage(w io.Writer) {
+	fmt.Fprintf(w, "commands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(w, "\nrun without a command to start the terminal ui\n")
+}
+
+// Run runs the command named by the first argument
+func (a *App) Run(args []string) error {
+	if len(args) == 0 {
+		Usage(a.conf.Stderr)
+		return ErrUnknownCommand
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(a, args[1:])
+		}
+	}
+	Usage(a.conf.Stderr)
+	return fmt.Errorf("%w: %s", ErrUnknownCommand, args[0])
+}
+
+func (a *App) getStore() (*db.Store, error) {
+	if a.store != nil {
+		return a.store, nil
+	}
+	store, err := a.conf.OpenStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize db: %w", err)
+	}
+	a.store = store
+	return store, nil
+}
+
+func (a *App) newFlagSet(name, args, description string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(a.conf.Stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: panda %s %s\n\n%s\n\n", name, args, description)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// subcommand splits args into the subcommand name and its arguments
+func subcommand(args []string, usage string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("%w, usage: %s", ErrUnknownCommand, usage)
+	}
+	return args[0], args[1:], nil
+}
+
+func (a *App) writeJSON(v interface{}) error {
+	enc := json.NewEncoder(a.conf.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
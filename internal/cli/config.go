@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aavshr/panda/internal/config"
+	"github.com/aavshr/panda/internal/secrets"
+	"golang.org/x/term"
+)
+
+const (
+	configUsage = "panda config get|set|set-key|path"
+	maskedValue = "********"
+)
+
+// secretKeys are never printed in cleartext
+var secretKeys = map[string]struct{}{
+	"llm_api_key": {},
+}
+
+func (a *App) runConfig(args []string) error {
+	name, args, err := subcommand(args, configUsage)
+	if err != nil {
+		return err
+	}
+	switch name {
+	case "get":
+		return a.runConfigGet(args)
+	case "set":
+		return a.runConfigSet(args)
+	case "set-key":
+		return a.runConfigSetKey(args)
+	case "path":
+		fmt.Fprintln(a.conf.Stdout, config.GetFilePath())
+		return nil
+	}
+	return fmt.Errorf("%w: config %s, usage: %s", ErrUnknownCommand, name, configUsage)
+}
+
+func loadConfigOrDefault() (*config.Config, error) {
+	userConfig, err := config.Load()
+	if err != nil {
+		if errors.Is(err, config.ErrConfigNotFound) {
+			return &config.Config{}, nil
+		}
+		return nil, fmt.Errorf("config.Load: %w", err)
+	}
+	return userConfig, nil
+}
+
+func maskSecret(key string, value interface{}) interface{} {
+	if _, ok := secretKeys[key]; ok {
+		if s, ok := value.(string); ok && s != "" {
+			return maskedValue
+		}
+	}
+	return value
+}
+
+func (a *App) runConfigGet(args []string) error {
+	flags := a.newFlagSet("config get", "[flags] [key]", "prints a config value or all config values")
+	asJSON := flags.Bool("json", false, "print as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	userConfig, err := loadConfigOrDefault()
+	if err != nil {
+		return err
+	}
+
+	keys := config.Keys()
+	if flags.NArg() > 0 {
+		keys = flags.Args()
+	}
+	values := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		value, err := userConfig.Get(key)
+		if err != nil {
+			return err
+		}
+		values[key] = maskSecret(key, value)
+	}
+
+	if *asJSON {
+		if flags.NArg() == 1 {
+			return a.writeJSON(values[keys[0]])
+		}
+		return a.writeJSON(values)
+	}
+	if flags.NArg() == 1 {
+		fmt.Fprintln(a.conf.Stdout, values[keys[0]])
+		return nil
+	}
+	for _, key := range keys {
+		fmt.Fprintf(a.conf.Stdout, "%s=%v\n", key, values[key])
+	}
+	return nil
+}
+
+func (a *App) runConfigSet(args []string) error {
+	flags := a.newFlagSet("config set", "[flags] <key> <value>", "sets a config value, non string values are parsed as json")
+	asJSON := flags.Bool("json", false, "print the updated value as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return fmt.Errorf("expected a key and a value, keys: %s", strings.Join(config.Keys(), ", "))
+	}
+	key := flags.Arg(0)
+	userConfig, err := loadConfigOrDefault()
+	if err != nil {
+		return err
+	}
+	if err := userConfig.Set(key, strings.Join(flags.Args()[1:], " ")); err != nil {
+		return err
+	}
+	savedConfig, err := config.Save(*userConfig)
+	if err != nil {
+		return fmt.Errorf("config.Save: %w", err)
+	}
+	value, err := savedConfig.Get(key)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.writeJSON(map[string]interface{}{key: maskSecret(key, value)})
+	}
+	fmt.Fprintf(a.conf.Stdout, "%s=%v\n", key, maskSecret(key, value))
+	return nil
+}
+
+func (a *App) runConfigSetKey(args []string) error {
+	flags := a.newFlagSet("config set-key", "[flags]", "reads the api key from stdin, encrypts it in the secrets file and references it in the config")
+	name := flags.String("name", config.DefaultSecretName, "name of the secret")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var key string
+	if term.IsTerminal(int(a.conf.Stdin.Fd())) {
+		fmt.Fprint(a.conf.Stderr, "api key: ")
+		b, err := term.ReadPassword(int(a.conf.Stdin.Fd()))
+		fmt.Fprintln(a.conf.Stderr)
+		if err != nil {
+			return fmt.Errorf("term.ReadPassword: %w", err)
+		}
+		key = string(b)
+	} else {
+		line, err := bufio.NewReader(a.conf.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("could not read stdin: %w", err)
+		}
+		key = line
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return fmt.Errorf("empty api key")
+	}
+
+	passphrase, err := secrets.Passphrase()
+	if err != nil {
+		return fmt.Errorf("secrets.Passphrase: %w", err)
+	}
+	ref, err := config.StoreSecret(*name, key, passphrase)
+	if err != nil {
+		return fmt.Errorf("config.StoreSecret: %w", err)
+	}
+	userConfig, err := loadConfigOrDefault()
+	if err != nil {
+		return err
+	}
+	userConfig.LLMAPIKeyRef = ref
+	// drop the deprecated plaintext key
+	userConfig.LLMAPIKey = ""
+	if _, err := config.Save(*userConfig); err != nil {
+		return fmt.Errorf("config.Save: %w", err)
+	}
+	fmt.Fprintf(a.conf.Stdout, "llm_api_key_ref=%s\n", ref)
+	return nil
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aavshr/panda/internal/config"
+)
+
+func TestConfigGetSet(t *testing.T) {
+	config.SetFilePath(filepath.Join(t.TempDir(), "config.json"))
+	t.Cleanup(func() { config.SetFilePath("") })
+
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		var out strings.Builder
+		err := New(&Config{Stdout: &out, Stderr: &strings.Builder{}}).Run(args)
+		return out.String(), err
+	}
+
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr error
+	}{
+		{args: []string{"config", "set", "llm_model", "gpt-4o"}, want: "llm_model=gpt-4o\n"},
+		{args: []string{"config", "get", "llm_model"}, want: "gpt-4o\n"},
+		{args: []string{"config", "set", "-json", "disable_tools", "true"}, want: "{\n  \"disable_tools\": true\n}\n"},
+		{args: []string{"config", "get", "-json", "disable_tools"}, want: "true\n"},
+		// the value is everything after the key
+		{args: []string{"config", "set", "llm_base_url", "http://localhost:11434", "/v1"}, want: "llm_base_url=http://localhost:11434 /v1\n"},
+		{args: []string{"config", "get", "nope"}, wantErr: config.ErrUnknownKey},
+		{args: []string{"config", "set", "nope", "1"}, wantErr: config.ErrUnknownKey},
+		{args: []string{"config", "set", "llm_api_key", "sk-test"}, wantErr: config.ErrPlaintextKey},
+	}
+	for _, tt := range tests {
+		out, err := run(tt.args...)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%v: expected %v, got %v", tt.args, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if out != tt.want {
+			t.Errorf("%v: expected %q, got %q", tt.args, tt.want, out)
+		}
+	}
+
+	if _, err := run("config", "set", "params", "{bad"); err == nil {
+		t.Error("expected invalid json to be rejected")
+	}
+	userConfig, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userConfig.LLMModel != "gpt-4o" || !userConfig.DisableTools {
+		t.Errorf("expected the values to be saved, got %+v", userConfig)
+	}
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+)
+
+const dbUsage = "panda db path|vacuum"
+
+func (a *App) runDB(args []string) error {
+	name, args, err := subcommand(args, dbUsage)
+	if err != nil {
+		return err
+	}
+	switch name {
+	case "path":
+		return a.runDBPath(args)
+	case "vacuum":
+		return a.runDBVacuum(args)
+	}
+	return fmt.Errorf("%w: db %s, usage: %s", ErrUnknownCommand, name, dbUsage)
+}
+
+func (a *App) runDBPath(args []string) error {
+	flags := a.newFlagSet("db path", "[flags]", "prints the path of the database file")
+	asJSON := flags.Bool("json", false, "print as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	store, err := a.getStore()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.writeJSON(map[string]string{"path": store.Path()})
+	}
+	fmt.Fprintln(a.conf.Stdout, store.Path())
+	return nil
+}
+
+func (a *App) runDBVacuum(args []string) error {
+	flags := a.newFlagSet("db vacuum", "[flags]", "rebuilds the database file to reclaim unused space")
+	asJSON := flags.Bool("json", false, "print the sizes as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	store, err := a.getStore()
+	if err != nil {
+		return err
+	}
+	before, err := os.Stat(store.Path())
+	if err != nil {
+		return fmt.Errorf("os.Stat: %w", err)
+	}
+	if err := store.Vacuum(); err != nil {
+		return fmt.Errorf("store.Vacuum: %w", err)
+	}
+	after, err := os.Stat(store.Path())
+	if err != nil {
+		return fmt.Errorf("os.Stat: %w", err)
+	}
+	if *asJSON {
+		return a.writeJSON(map[string]int64{
+			"size_before": before.Size(),
+			"size_after":  after.Size(),
+		})
+	}
+	fmt.Fprintf(a.conf.Stdout, "vacuumed %s: %d -> %d bytes\n", store.Path(), before.Size(), after.Size())
+	return nil
+}
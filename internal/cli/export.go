@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aavshr/panda/internal/export"
+)
+
+func (a *App) runExport(args []string) error {
+	flags := a.newFlagSet("export", "[flags]", "exports one or all threads to markdown, json or html")
+	formatFlag := flags.String("format", "", "export format: md, json or html (default is inferred from -o or md)")
+	threadID := flags.String("thread", "", "export only the thread with this id")
+	outPath := flags.String("o", "", "file to write to (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	formatName := *formatFlag
+	if formatName == "" {
+		formatName = string(export.FormatMarkdown)
+		if ext := filepath.Ext(*outPath); ext != "" {
+			formatName = ext
+		}
+	}
+	format, err := export.ParseFormat(formatName)
+	if err != nil {
+		return err
+	}
+	store, err := a.getStore()
+	if err != nil {
+		return err
+	}
+	exporter, err := export.New(store, format)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = a.conf.Stdout
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return fmt.Errorf("os.Create: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if *threadID == "" {
+		return exporter.ExportAll(out)
+	}
+	thread, err := store.GetThread(*threadID)
+	if err != nil {
+		return fmt.Errorf("store.GetThread: %w", err)
+	}
+	return exporter.ExportThread(out, thread)
+}
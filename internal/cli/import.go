@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aavshr/panda/internal/importer"
+	"golang.org/x/term"
+)
+
+func (a *App) runImport(args []string) error {
+	flags := a.newFlagSet("import", "[flags] <file>...", "imports conversations from a ChatGPT conversations.json or a panda json export, importing again skips existing messages")
+	sourceFlag := flags.String("from", string(importer.SourceAuto), "source of the export: auto, chatgpt or panda")
+	asJSON := flags.Bool("json", false, "print the result as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("expected at least one file")
+	}
+	source, err := importer.ParseSource(*sourceFlag)
+	if err != nil {
+		return err
+	}
+	store, err := a.getStore()
+	if err != nil {
+		return err
+	}
+
+	var progress importer.Progress
+	if f, ok := a.conf.Stderr.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		progress = func(done, total int, _ *importer.Conversation) {
+			fmt.Fprintf(a.conf.Stderr, "\rimported %d/%d", done, total)
+			if done == total {
+				fmt.Fprintln(a.conf.Stderr)
+			}
+		}
+	}
+
+	total := &importer.Result{}
+	for _, path := range flags.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("os.Open: %w", err)
+		}
+		conversations, err := importer.Parse(f, source)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", path, err)
+		}
+		result, err := importer.New(store, progress).Import(conversations)
+		if err != nil {
+			return err
+		}
+		total.ThreadsImported += result.ThreadsImported
+		total.ThreadsUpdated += result.ThreadsUpdated
+		total.ThreadsSkipped += result.ThreadsSkipped
+		total.MessagesImported += result.MessagesImported
+	}
+
+	if *asJSON {
+		return a.writeJSON(map[string]int{
+			"threads_imported":  total.ThreadsImported,
+			"threads_updated":   total.ThreadsUpdated,
+			"threads_skipped":   total.ThreadsSkipped,
+			"messages_imported": total.MessagesImported,
+		})
+	}
+	fmt.Fprintf(a.conf.Stdout, "imported %d messages: %d new threads, %d updated, %d unchanged\n",
+		total.MessagesImported, total.ThreadsImported, total.ThreadsUpdated, total.ThreadsSkipped)
+	return nil
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aavshr/panda/internal/mcp"
+)
+
+const (
+	mcpUsage   = "panda mcp ls|prompt"
+	mcpTimeout = 30 * time.Second
+)
+
+type mcpServerInfo struct {
+	Name       string             `json:"name"`
+	ServerInfo mcp.Implementation `json:"server_info"`
+	Tools      []mcp.Tool         `json:"tools"`
+	Resources  []mcp.Resource     `json:"resources"`
+	Prompts    []mcp.Prompt       `json:"prompts"`
+	Error      string             `json:"error,omitempty"`
+}
+
+func (a *App) runMCP(args []string) error {
+	name, args, err := subcommand(args, mcpUsage)
+	if err != nil {
+		return err
+	}
+	switch name {
+	case "ls", "list":
+		return a.runMCPList(args)
+	case "prompt":
+		return a.runMCPPrompt(args)
+	}
+	return fmt.Errorf("%w: mcp %s, usage: %s", ErrUnknownCommand, name, mcpUsage)
+}
+
+// mcpServers returns the servers of the config, or the named ones also if
+// they are disabled
+func mcpServers(names []string) (map[string]mcp.ServerConfig, error) {
+	userConfig, err := loadConfigOrDefault()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return mcp.NewServerConfigs(userConfig.MCPServers), nil
+	}
+	servers := make(map[string]mcp.ServerConfig, len(names))
+	for _, name := range names {
+		server, ok := userConfig.MCPServers[name]
+		if !ok {
+			return nil, fmt.Errorf("no mcp server named %s in the config", name)
+		}
+		server.Disabled = false
+		servers[name] = mcp.NewServerConfig(server)
+	}
+	return servers, nil
+}
+
+func (a *App) runMCPList(args []string) error {
+	flags := a.newFlagSet("mcp ls", "[flags] [server]...", "connects to the configured mcp servers and lists their tools, resources and prompts")
+	asJSON := flags.Bool("json", false, "print as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	servers, err := mcpServers(flags.Args())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mcpTimeout)
+	defer cancel()
+	clients, errs := mcp.ConnectAll(ctx, servers)
+	infos := make([]mcpServerInfo, 0, len(clients))
+	for _, client := range clients {
+		info := mcpServerInfo{Name: client.Name, ServerInfo: client.ServerInfo}
+		err := func() error {
+			var err error
+			if info.Tools, err = client.ListTools(ctx); err != nil {
+				return err
+			}
+			if info.Resources, err = client.ListResources(ctx); err != nil {
+				return err
+			}
+			info.Prompts, err = client.ListPrompts(ctx)
+			return err
+		}()
+		if err != nil {
+			info.Error = err.Error()
+		}
+		_ = client.Close()
+		infos = append(infos, info)
+	}
+	if *asJSON {
+		for _, err := range errs {
+			infos = append(infos, mcpServerInfo{Error: err.Error()})
+		}
+		return a.writeJSON(infos)
+	}
+	for _, info := range infos {
+		fmt.Fprintf(a.conf.Stdout, "%s (%s %s)\n", info.Name, info.ServerInfo.Name, info.ServerInfo.Version)
+		if info.Error != "" {
+			fmt.Fprintf(a.conf.Stdout, "  error: %s\n", info.Error)
+		}
+		for _, tool := range info.Tools {
+			fmt.Fprintf(a.conf.Stdout, "  tool %s: %s\n", mcp.ToolName(info.Name, tool.Name), firstLine(tool.Description))
+		}
+		for _, resource := range info.Resources {
+			fmt.Fprintf(a.conf.Stdout, "  resource %s: %s\n", resource.URI, resource.Name)
+		}
+		for _, prompt := range info.Prompts {
+			fmt.Fprintf(a.conf.Stdout, "  prompt %s: %s\n", prompt.Name, firstLine(prompt.Description))
+		}
+	}
+	for _, err := range errs {
+		fmt.Fprintf(a.conf.Stderr, "error: %v\n", err)
+	}
+	return nil
+}
+
+func (a *App) runMCPPrompt(args []string) error {
+	flags := a.newFlagSet("mcp prompt", "[flags] <server> <prompt> [name=value]...", "prints the messages of a prompt of an mcp server")
+	asJSON := flags.Bool("json", false, "print as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return fmt.Errorf("expected a server and a prompt")
+	}
+	promptArgs := make(map[string]string)
+	for _, arg := range flags.Args()[2:] {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("expected name=value, got %q", arg)
+		}
+		promptArgs[name] = value
+	}
+	servers, err := mcpServers(flags.Args()[:1])
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mcpTimeout)
+	defer cancel()
+	client, err := mcp.Connect(ctx, flags.Arg(0), servers[flags.Arg(0)])
+	if err != nil {
+		return fmt.Errorf("mcp.Connect: %w", err)
+	}
+	defer client.Close()
+	messages, err := client.GetPrompt(ctx, flags.Arg(1), promptArgs)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.writeJSON(messages)
+	}
+	for _, message := range messages {
+		fmt.Fprintf(a.conf.Stdout, "[%s]\n%s\n", message.Role, message.Content)
+	}
+	return nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aavshr/panda/internal/templates"
+)
+
+const templatesUsage = "panda templates ls|show|add|rm|expand"
+
+func (a *App) templateStore() *templates.Store {
+	return templates.NewStore(a.conf.TemplatesDir)
+}
+
+func (a *App) runTemplates(args []string) error {
+	name, args, err := subcommand(args, templatesUsage)
+	if err != nil {
+		return err
+	}
+	switch name {
+	case "ls", "list":
+		return a.runTemplatesList(args)
+	case "show":
+		return a.runTemplatesShow(args)
+	case "add":
+		return a.runTemplatesAdd(args)
+	case "rm", "delete":
+		return a.runTemplatesDelete(args)
+	case "expand":
+		return a.runTemplatesExpand(args)
+	}
+	return fmt.Errorf("%w: templates %s, usage: %s", ErrUnknownCommand, name, templatesUsage)
+}
+
+type templateWithVariables struct {
+	*templates.Template
+	Variables []string `json:"variables"`
+}
+
+func (a *App) runTemplatesList(args []string) error {
+	flags := a.newFlagSet("templates ls", "[flags]", "lists the prompt templates")
+	asJSON := flags.Bool("json", false, "print as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	list, err := a.templateStore().List()
+	if err != nil {
+		return fmt.Errorf("templates.List: %w", err)
+	}
+	if *asJSON {
+		withVariables := make([]templateWithVariables, 0, len(list))
+		for _, t := range list {
+			variables := t.Variables()
+			if variables == nil {
+				variables = []string{}
+			}
+			withVariables = append(withVariables, templateWithVariables{Template: t, Variables: variables})
+		}
+		return a.writeJSON(withVariables)
+	}
+	w := tabwriter.NewWriter(a.conf.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVARIABLES\tCONTENT")
+	for _, t := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, strings.Join(t.Variables(), ","), firstLine(t.Content))
+	}
+	return w.Flush()
+}
+
+func (a *App) runTemplatesShow(args []string) error {
+	flags := a.newFlagSet("templates show", "<name>", "prints a template")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one template name")
+	}
+	t, err := a.templateStore().Get(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.conf.Stdout, strings.TrimRight(t.Content, "\n"))
+	return nil
+}
+
+func (a *App) runTemplatesAdd(args []string) error {
+	flags := a.newFlagSet("templates add", "[flags] <name> [file]",
+		"adds a template from a file or stdin, {{name}} is a variable, {{file:path}} and {{stdin}} are included when it is expanded")
+	force := flags.Bool("f", false, "replace an existing template")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return fmt.Errorf("expected a template name and an optional file")
+	}
+	store := a.templateStore()
+	name := flags.Arg(0)
+	if _, err := store.Get(name); err == nil && !*force {
+		return fmt.Errorf("template %s already exists, use -f to replace it", name)
+	} else if err != nil && !errors.Is(err, templates.ErrNotFound) {
+		return err
+	}
+
+	var content []byte
+	var err error
+	if flags.NArg() == 2 {
+		content, err = os.ReadFile(flags.Arg(1))
+	} else {
+		content, err = io.ReadAll(a.conf.Stdin)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read the template: %w", err)
+	}
+	if strings.TrimSpace(string(content)) == "" {
+		return fmt.Errorf("the template is empty")
+	}
+	return store.Save(&templates.Template{Name: name, Content: string(content)})
+}
+
+func (a *App) runTemplatesDelete(args []string) error {
+	flags := a.newFlagSet("templates rm", "<name>...", "deletes templates")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("expected at least one template name")
+	}
+	store := a.templateStore()
+	for _, name := range flags.Args() {
+		if err := store.Delete(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *App) runTemplatesExpand(args []string) error {
+	flags := a.newFlagSet("templates expand", "<name> [variable=value]...",
+		"prints a template with the values of its variables, stdin is read if the template includes it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return fmt.Errorf("expected a template name")
+	}
+	vars := make(map[string]string)
+	for _, arg := range flags.Args()[1:] {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("expected variable=value, got %q", arg)
+		}
+		vars[name] = value
+	}
+	t, err := a.templateStore().Get(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var stdin io.Reader
+	if t.UsesStdin() {
+		stdin = a.conf.Stdin
+	}
+	expanded, err := t.Expand(vars, stdin)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.conf.Stdout, strings.TrimRight(expanded, "\n"))
+	return nil
+}
@@ -0,0 +1,249 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/llm"
+)
+
+const threadsUsage = "panda threads ls|show|rm|rename|params|model"
+
+type threadWithMessages struct {
+	*db.Thread
+	Messages []*db.Message `json:"messages"`
+}
+
+func (a *App) runThreads(args []string) error {
+	name, args, err := subcommand(args, threadsUsage)
+	if err != nil {
+		return err
+	}
+	switch name {
+	case "ls", "list":
+		return a.runThreadsList(args)
+	case "show":
+		return a.runThreadsShow(args)
+	case "rm", "delete":
+		return a.runThreadsDelete(args)
+	case "rename":
+		return a.runThreadsRename(args)
+	case "params":
+		return a.runThreadsParams(args)
+	case "model":
+		return a.runThreadsModel(args)
+	}
+	return fmt.Errorf("%w: threads %s, usage: %s", ErrUnknownCommand, name, threadsUsage)
+}
+
+func (a *App) runThreadsList(args []string) error {
+	flags := a.newFlagSet("threads ls", "[flags]", "lists the latest threads")
+	limit := flags.Int("limit", 20, "maximum number of threads to list")
+	offset := flags.Int("offset", 0, "number of threads to skip")
+	asJSON := flags.Bool("json", false, "print as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	store, err := a.getStore()
+	if err != nil {
+		return err
+	}
+	threads, err := store.ListLatestThreadsPaginated(*offset, *limit)
+	if err != nil {
+		return fmt.Errorf("store.ListLatestThreadsPaginated: %w", err)
+	}
+	if *asJSON {
+		if threads == nil {
+			threads = []*db.Thread{}
+		}
+		return a.writeJSON(threads)
+	}
+	w := tabwriter.NewWriter(a.conf.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tUPDATED")
+	for _, thread := range threads {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", thread.ID, thread.Name, thread.UpdatedAt)
+	}
+	return w.Flush()
+}
+
+func (a *App) runThreadsShow(args []string) error {
+	flags := a.newFlagSet("threads show", "[flags] <thread id>", "prints a thread with its messages")
+	asJSON := flags.Bool("json", false, "print as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one thread id")
+	}
+	store, err := a.getStore()
+	if err != nil {
+		return err
+	}
+	thread, err := store.GetThread(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("store.GetThread: %w", err)
+	}
+	messages, err := listAllMessages(store, thread.ID)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if messages == nil {
+			messages = []*db.Message{}
+		}
+		return a.writeJSON(threadWithMessages{Thread: thread, Messages: messages})
+	}
+	fmt.Fprintf(a.conf.Stdout, "%s (%s)\ncreated: %s, updated: %s\n", thread.Name, thread.ID, thread.CreatedAt, thread.UpdatedAt)
+	for _, message := range messages {
+		fmt.Fprintf(a.conf.Stdout, "\n[%s] %s\n%s\n", message.Role, message.CreatedAt, strings.TrimRight(message.Content, "\n"))
+		if message.Model != "" {
+			fmt.Fprintf(a.conf.Stdout, "model: %s\n", message.Model)
+		}
+		if message.Params != "" {
+			fmt.Fprintf(a.conf.Stdout, "params: %s\n", message.Params)
+		}
+	}
+	return nil
+}
+
+func (a *App) runThreadsDelete(args []string) error {
+	flags := a.newFlagSet("threads rm", "[flags] <thread id>...", "deletes threads with their messages")
+	asJSON := flags.Bool("json", false, "print the deleted thread ids as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("expected at least one thread id")
+	}
+	store, err := a.getStore()
+	if err != nil {
+		return err
+	}
+	deleted := []string{}
+	for _, threadID := range flags.Args() {
+		// fail on unknown ids instead of silently deleting nothing
+		if _, err := store.GetThread(threadID); err != nil {
+			return fmt.Errorf("store.GetThread %s: %w", threadID, err)
+		}
+		if err := store.DeleteThread(threadID); err != nil {
+			return fmt.Errorf("store.DeleteThread: %w", err)
+		}
+		deleted = append(deleted, threadID)
+		if !*asJSON {
+			fmt.Fprintf(a.conf.Stdout, "deleted %s\n", threadID)
+		}
+	}
+	if *asJSON {
+		return a.writeJSON(deleted)
+	}
+	return nil
+}
+
+func (a *App) runThreadsRename(args []string) error {
+	flags := a.newFlagSet("threads rename", "[flags] <thread id> <name>", "renames a thread")
+	asJSON := flags.Bool("json", false, "print the renamed thread as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return fmt.Errorf("expected a thread id and a name")
+	}
+	name := strings.TrimSpace(strings.Join(flags.Args()[1:], " "))
+	if name == "" {
+		return fmt.Errorf("thread name can not be empty")
+	}
+	store, err := a.getStore()
+	if err != nil {
+		return err
+	}
+	thread, err := store.GetThread(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("store.GetThread: %w", err)
+	}
+	if err := store.UpdateThreadName(thread.ID, name); err != nil {
+		return fmt.Errorf("store.UpdateThreadName: %w", err)
+	}
+	thread.Name = name
+	if *asJSON {
+		return a.writeJSON(thread)
+	}
+	fmt.Fprintf(a.conf.Stdout, "renamed %s to %s\n", thread.ID, thread.Name)
+	return nil
+}
+
+func (a *App) runThreadsParams(args []string) error {
+	flags := a.newFlagSet("threads params", "[flags] <thread id> [params json]",
+		`prints or sets the generation params of a thread overriding the config, e.g. '{"temperature":0.2,"seed":1}'`)
+	clearParams := flags.Bool("clear", false, "remove the params of the thread")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return fmt.Errorf("expected a thread id and optionally params")
+	}
+	store, err := a.getStore()
+	if err != nil {
+		return err
+	}
+	thread, err := store.GetThread(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("store.GetThread: %w", err)
+	}
+	if flags.NArg() == 1 && !*clearParams {
+		params, err := llm.ParseParams(thread.Params)
+		if err != nil {
+			return err
+		}
+		return a.writeJSON(params)
+	}
+	var params llm.Params
+	if !*clearParams {
+		if params, err = llm.ParseParams(flags.Arg(1)); err != nil {
+			return err
+		}
+	}
+	if err := store.UpdateThreadParams(thread.ID, params.Encode()); err != nil {
+		return fmt.Errorf("store.UpdateThreadParams: %w", err)
+	}
+	return a.writeJSON(params)
+}
+
+func (a *App) runThreadsModel(args []string) error {
+	flags := a.newFlagSet("threads model", "[flags] <thread id> [model]",
+		"prints or sets the model of a thread overriding the configured model")
+	clearModel := flags.Bool("clear", false, "use the configured model for the thread")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return fmt.Errorf("expected a thread id and optionally a model")
+	}
+	store, err := a.getStore()
+	if err != nil {
+		return err
+	}
+	thread, err := store.GetThread(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("store.GetThread: %w", err)
+	}
+	if flags.NArg() == 1 && !*clearModel {
+		fmt.Fprintln(a.conf.Stdout, thread.Model)
+		return nil
+	}
+	model := strings.TrimSpace(flags.Arg(1))
+	if *clearModel {
+		model = ""
+	}
+	if err := store.UpdateThreadModel(thread.ID, model); err != nil {
+		return fmt.Errorf("store.UpdateThreadModel: %w", err)
+	}
+	fmt.Fprintln(a.conf.Stdout, model)
+	return nil
+}
@@ -1,26 +1,105 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"github.com/adrg/xdg"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aavshr/panda/internal/llm"
+	"github.com/aavshr/panda/internal/secrets"
+	"github.com/adrg/xdg"
 )
 
 const (
-	appConfigDir   = "aavshr-panda" // to not have name conflicts with other apps
-	configFileName = "config.json"
-	defaultModel   = "o3-mini"
+	appConfigDir    = "aavshr-panda" // to not have name conflicts with other apps
+	configFileName  = "config.json"
+	secretsFileName = "secrets.enc"
+	modelsCacheName = "models.json"
+	templatesDir    = "templates"
+	themesDir       = "themes"
+	keyMapFileName  = "keymap.json"
+	defaultModel    = "o3-mini"
+	configFileMode  = 0600
+
+	// api key references, e.g. "env:OPENAI_API_KEY", "cmd:pass show openai"
+	// or "secrets:llm_api_key"
+	RefPrefixEnv     = "env:"
+	RefPrefixCommand = "cmd:"
+	RefPrefixSecrets = "secrets:"
+
+	DefaultSecretName = "llm_api_key"
+	// plaintextKeyName is the config key of the deprecated plaintext api key
+	plaintextKeyName  = "llm_api_key"
+	keyCommandTimeout = 10 * time.Second
 )
 
 var (
 	ErrConfigNotFound = errors.New("config file not found")
+	ErrUnknownKey     = errors.New("unknown config key")
+	ErrAPIKeyNotFound = errors.New("no api key configured")
+	ErrInvalidRef     = errors.New("invalid api key reference")
+	ErrPlaintextKey   = errors.New("the api key is not stored in plaintext, use panda config set-key or set llm_api_key_ref")
+
+	// defaultAPIKeyEnvs are checked when no api key reference is configured
+	defaultAPIKeyEnvs = []string{"PANDA_API_KEY", "OPENAI_API_KEY"}
+
+	// filePathOverride replaces the default config file path if set
+	filePathOverride string
 )
 
 type Config struct {
-	LLMAPIKey string `json:"llm_api_key"`
-	LLMModel  string `json:"llm_model"`
+	// LLMAPIKey is the api key in plaintext, deprecated in favor of LLMAPIKeyRef
+	LLMAPIKey string `json:"llm_api_key,omitempty"`
+	// LLMAPIKeyRef references where the api key is read from
+	LLMAPIKeyRef string `json:"llm_api_key_ref,omitempty"`
+	LLMModel     string `json:"llm_model"`
+	// LLMBaseURL is the url of an openai compatible api, empty for the default
+	LLMBaseURL string     `json:"llm_base_url,omitempty"`
+	Params     llm.Params `json:"params"`
+	// Theme is a built-in theme, a theme file in the themes dir or auto
+	Theme string `json:"theme,omitempty"`
+	// DisableTools stops offering tools to the model, e.g. for backends
+	// without tool calling
+	DisableTools bool `json:"disable_tools,omitempty"`
+	// Commands configures which commands the run_command tool runs
+	Commands CommandConfig `json:"commands"`
+	// MCPServers are the mcp servers whose tools are offered to the model,
+	// keyed by a name that prefixes their tool names
+	MCPServers map[string]MCPServerConfig `json:"mcp_servers,omitempty"`
+}
+
+// CommandConfig is the config of the run_command tool, commands are matched
+// by their leading words
+type CommandConfig struct {
+	// Allow are commands that run without confirmation
+	Allow []string `json:"allow,omitempty"`
+	// Deny are commands that are refused without confirmation
+	Deny []string `json:"deny,omitempty"`
+	// TimeoutSeconds kills commands running longer, 0 for the default
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// MaxOutput is how many bytes of stdout and of stderr are kept, 0 for
+	// the default
+	MaxOutput int `json:"max_output,omitempty"`
+}
+
+// MCPServerConfig declares an mcp server, either a command that talks over
+// stdin and stdout or the url of a streamable http server
+type MCPServerConfig struct {
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// TimeoutSeconds cancels tool calls, 0 for the default of the tools
+	TimeoutSeconds int  `json:"timeout_seconds,omitempty"`
+	Disabled       bool `json:"disabled,omitempty"`
 }
 
 func GetDir() string {
@@ -39,16 +118,63 @@ func GetDataDir() string {
 	return filepath.Join(dataDir, appConfigDir)
 }
 
+func GetCacheDir() string {
+	cacheDir := xdg.CacheHome
+	if cacheDir == "" {
+		cacheDir = filepath.Join(xdg.Home, ".cache")
+	}
+	return filepath.Join(cacheDir, appConfigDir)
+}
+
+// GetModelsCachePath returns the path of the cache of listed models
+func GetModelsCachePath() string {
+	return filepath.Join(GetCacheDir(), modelsCacheName)
+}
+
+// GetTemplatesDir returns the directory of the prompt templates
+func GetTemplatesDir() string {
+	return filepath.Join(GetDir(), templatesDir)
+}
+
+// GetThemesDir returns the directory of the user themes
+func GetThemesDir() string {
+	return filepath.Join(GetDir(), themesDir)
+}
+
+// GetKeyMapPath returns the path of the key bindings of the terminal ui
+func GetKeyMapPath() string {
+	return filepath.Join(GetDir(), keyMapFileName)
+}
+
+// SetFilePath overrides the path of the config file
+func SetFilePath(path string) {
+	filePathOverride = path
+}
+
 func GetFilePath() string {
+	if filePathOverride != "" {
+		return filePathOverride
+	}
 	configDir := GetDir()
 	return filepath.Join(configDir, configFileName)
 }
 
+func GetSecretsFilePath() string {
+	return filepath.Join(filepath.Dir(GetFilePath()), secretsFileName)
+}
+
 func Load() (*Config, error) {
 	configFilePath := GetFilePath()
-	if _, err := os.Stat(configFilePath); os.IsNotExist(err) {
+	info, err := os.Stat(configFilePath)
+	if os.IsNotExist(err) {
 		return nil, ErrConfigNotFound
 	}
+	// config files of older versions were readable by everyone
+	if err == nil && info.Mode().Perm()&^configFileMode != 0 {
+		if err := os.Chmod(configFilePath, configFileMode); err != nil {
+			return nil, fmt.Errorf("os.Chmod: %w", err)
+		}
+	}
 
 	configFile, err := os.Open(configFilePath)
 	if err != nil {
@@ -67,17 +193,177 @@ func Save(config Config) (*Config, error) {
 	if config.LLMModel == "" {
 		config.LLMModel = defaultModel
 	}
-	configDir := GetDir()
+	configDir := filepath.Dir(GetFilePath())
 	if err := os.MkdirAll(configDir, 0700); err != nil {
 		return &config, err
 	}
 
 	configFilePath := GetFilePath()
-	configFile, err := os.Create(configFilePath)
+	configFile, err := os.OpenFile(configFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, configFileMode)
 	if err != nil {
 		return &config, err
 	}
 	defer configFile.Close()
+	// the mode only applies to new files
+	if err := configFile.Chmod(configFileMode); err != nil {
+		return &config, err
+	}
 
 	return &config, json.NewEncoder(configFile).Encode(config)
 }
+
+// APIKey resolves the api key from the reference, falling back to the
+// deprecated plaintext key and then to the default environment variables
+func (c *Config) APIKey() (string, error) {
+	if c.LLMAPIKeyRef != "" {
+		return ResolveRef(c.LLMAPIKeyRef)
+	}
+	if c.LLMAPIKey != "" {
+		return c.LLMAPIKey, nil
+	}
+	for _, env := range defaultAPIKeyEnvs {
+		if key := os.Getenv(env); key != "" {
+			return key, nil
+		}
+	}
+	return "", ErrAPIKeyNotFound
+}
+
+// ResolveRef returns the secret a reference points to
+func ResolveRef(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, RefPrefixEnv):
+		name := strings.TrimPrefix(ref, RefPrefixEnv)
+		key := os.Getenv(name)
+		if key == "" {
+			return "", fmt.Errorf("%w: environment variable %s is not set", ErrAPIKeyNotFound, name)
+		}
+		return key, nil
+	case strings.HasPrefix(ref, RefPrefixCommand):
+		return runKeyCommand(strings.TrimPrefix(ref, RefPrefixCommand))
+	case strings.HasPrefix(ref, RefPrefixSecrets):
+		passphrase, err := secrets.Passphrase()
+		if err != nil {
+			return "", fmt.Errorf("secrets.Passphrase: %w", err)
+		}
+		return secrets.Open(GetSecretsFilePath()).Get(strings.TrimPrefix(ref, RefPrefixSecrets), passphrase)
+	}
+	return "", fmt.Errorf("%w: %q, must start with %s, %s or %s", ErrInvalidRef, ref, RefPrefixEnv, RefPrefixCommand, RefPrefixSecrets)
+}
+
+// StoreSecret encrypts the api key in the secrets file and returns the
+// reference to it
+func StoreSecret(name, key, passphrase string) (string, error) {
+	if err := secrets.Open(GetSecretsFilePath()).Set(name, key, passphrase); err != nil {
+		return "", err
+	}
+	secrets.SetPassphrase(passphrase)
+	return RefPrefixSecrets + name, nil
+}
+
+// MigrateAPIKey moves the plaintext api key of older versions into the
+// secrets file and saves the config with a reference to it, false if there
+// was no plaintext key
+func MigrateAPIKey(c *Config) (bool, error) {
+	if c.LLMAPIKey == "" {
+		return false, nil
+	}
+	// the reference is used over the plaintext key so the key is only dropped
+	if c.LLMAPIKeyRef == "" {
+		passphrase, err := secrets.Passphrase()
+		if err != nil {
+			return false, fmt.Errorf("secrets.Passphrase: %w", err)
+		}
+		ref, err := StoreSecret(DefaultSecretName, c.LLMAPIKey, passphrase)
+		if err != nil {
+			return false, fmt.Errorf("StoreSecret: %w", err)
+		}
+		c.LLMAPIKeyRef = ref
+	}
+	c.LLMAPIKey = ""
+	if _, err := Save(*c); err != nil {
+		return false, fmt.Errorf("Save: %w", err)
+	}
+	return true, nil
+}
+
+func runKeyCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("could not run key command, cmd.Output: %w", err)
+	}
+	// commands like pass print more lines after the secret
+	key, _, _ := strings.Cut(string(out), "\n")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("%w: key command printed nothing", ErrAPIKeyNotFound)
+	}
+	return key, nil
+}
+
+// Keys returns the keys of the config values as they appear in the config
+// file, without the deprecated plaintext api key
+func Keys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if key := jsonKey(t.Field(i)); key != "" && key != plaintextKeyName {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func jsonKey(field reflect.StructField) string {
+	key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+func fieldByKey(key string) (reflect.StructField, error) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if jsonKey(t.Field(i)) == key {
+			return t.Field(i), nil
+		}
+	}
+	return reflect.StructField{}, fmt.Errorf("%w: %s", ErrUnknownKey, key)
+}
+
+// Get returns the value for a config key
+func (c *Config) Get(key string) (interface{}, error) {
+	field, err := fieldByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.ValueOf(c).Elem().FieldByIndex(field.Index).Interface(), nil
+}
+
+// Set sets the value for a config key, values of non string keys are parsed as json
+func (c *Config) Set(key, value string) error {
+	if key == plaintextKeyName {
+		return ErrPlaintextKey
+	}
+	field, err := fieldByKey(key)
+	if err != nil {
+		return err
+	}
+	raw := json.RawMessage(value)
+	if field.Type.Kind() == reflect.String {
+		if raw, err = json.Marshal(value); err != nil {
+			return fmt.Errorf("json.Marshal: %w", err)
+		}
+	}
+	target := reflect.New(field.Type)
+	if err := json.Unmarshal(raw, target.Interface()); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	reflect.ValueOf(c).Elem().FieldByIndex(field.Index).Set(target.Elem())
+	return nil
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aavshr/panda/internal/llm"
+	"github.com/aavshr/panda/internal/secrets"
+)
+
+func TestSetGet(t *testing.T) {
+	temperature := float32(0.2)
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		want    interface{}
+		wantErr error
+		invalid bool
+	}{
+		{name: "string", key: "llm_model", value: "gpt-4o", want: "gpt-4o"},
+		{name: "string is not parsed as json", key: "llm_base_url", value: `"http://localhost"`, want: `"http://localhost"`},
+		{name: "bool", key: "disable_tools", value: "true", want: true},
+		{name: "numeric", key: "params", value: `{"max_tokens":100}`, want: llm.Params{MaxTokens: 100}},
+		{name: "nested", key: "params", value: `{"temperature":0.2,"stop":["END"]}`, want: llm.Params{Temperature: &temperature, Stop: []string{"END"}}},
+		{name: "unknown key", key: "nope", value: "1", wantErr: ErrUnknownKey},
+		{name: "plaintext api key", key: "llm_api_key", value: "sk-test", wantErr: ErrPlaintextKey},
+		{name: "bad json", key: "params", value: `{"temperature":`, invalid: true},
+		{name: "wrong type", key: "disable_tools", value: `"yes"`, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{}
+			err := c.Set(tt.key, tt.value)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				if !reflect.DeepEqual(c, &Config{}) {
+					t.Errorf("expected the config to be unchanged, got %+v", c)
+				}
+				return
+			case tt.invalid:
+				if err == nil {
+					t.Fatal("expected an invalid value error")
+				}
+				if !reflect.DeepEqual(c, &Config{}) {
+					t.Errorf("expected the config to be unchanged, got %+v", c)
+				}
+				return
+			case err != nil:
+				t.Fatalf("Set: %v", err)
+			}
+
+			got, err := c.Get(tt.key)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMigrateAPIKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	SetFilePath(path)
+	t.Cleanup(func() { SetFilePath("") })
+	t.Setenv(secrets.PassphraseEnv, "passphrase")
+
+	if slices.Contains(Keys(), "llm_api_key") {
+		t.Error("expected the plaintext api key to not be listed")
+	}
+	if _, err := Save(Config{LLMAPIKey: "sk-plain", LLMModel: "gpt-4o"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated, err := MigrateAPIKey(c); err != nil || !migrated {
+		t.Fatalf("expected the key to be migrated, got %v, %v", migrated, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-plain") {
+		t.Errorf("expected the key to be removed from the config file, got %s", data)
+	}
+	c, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LLMAPIKey != "" || c.LLMAPIKeyRef != RefPrefixSecrets+DefaultSecretName || c.LLMModel != "gpt-4o" {
+		t.Errorf("expected a reference to the secret, got %+v", c)
+	}
+	if key, err := c.APIKey(); err != nil || key != "sk-plain" {
+		t.Errorf("expected the key from the secrets file, got %q, %v", key, err)
+	}
+	// the migration only happens once
+	if migrated, err := MigrateAPIKey(c); err != nil || migrated {
+		t.Errorf("expected nothing to migrate, got %v, %v", migrated, err)
+	}
+}
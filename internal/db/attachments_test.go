@@ -0,0 +1,167 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestAttachments(t *testing.T) {
+	store, err := New(Config{
+		DataDirPath:  t.TempDir(),
+		DatabaseName: "test.db",
+	}, &schemaInit, &migrations)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	tx, err := store.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	thread := &Thread{ID: "t0", Name: "files", CreatedAt: "2024-01-01T00:00:00Z", UpdatedAt: "2024-01-01T00:00:00Z"}
+	if err := store.CreateThreadTx(tx, thread); err != nil {
+		t.Fatalf("failed to create thread: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	messages := []*Message{
+		{
+			ID:        "m0",
+			Role:      "user",
+			Content:   "explain @main.go",
+			CreatedAt: "2024-01-01T00:00:01Z",
+			ThreadID:  thread.ID,
+			Attachments: []*Attachment{
+				{Path: "main.go", Size: 12, Content: "package main"},
+			},
+			Parts: []*MessagePart{
+				{Type: PartTypeImage, MimeType: "image/png", Path: "a.png", Data: []byte{0x89, 'P', 'N', 'G'}},
+				{Type: PartTypeImage, MimeType: "image/jpeg", Path: "b.jpg", Data: []byte{0xff, 0xd8}},
+			},
+		},
+		{ID: "m1", Role: "assistant", Content: "it is empty", CreatedAt: "2024-01-01T00:00:02Z", ThreadID: thread.ID},
+	}
+	for _, message := range messages {
+		if err := store.CreateMessage(message); err != nil {
+			t.Fatalf("failed to create message: %v", err)
+		}
+	}
+
+	listed, err := store.ListMessagesByThreadIDPaginated(thread.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("failed to list messages: %v", err)
+	}
+	if len(listed) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(listed))
+	}
+	if len(listed[0].Attachments) != 1 || len(listed[1].Attachments) != 0 {
+		t.Fatalf("expected one attachment on the first message, got %d and %d", len(listed[0].Attachments), len(listed[1].Attachments))
+	}
+	attachment := listed[0].Attachments[0]
+	if attachment.ID == "" || attachment.MessageID != "m0" || attachment.Path != "main.go" ||
+		attachment.Content != "package main" || attachment.CreatedAt != messages[0].CreatedAt {
+		t.Errorf("unexpected attachment %+v", attachment)
+	}
+	parts := listed[0].Parts
+	if len(parts) != 2 || len(listed[1].Parts) != 0 {
+		t.Fatalf("expected two parts on the first message, got %d and %d", len(parts), len(listed[1].Parts))
+	}
+	if parts[0].Path != "a.png" || parts[1].Index != 1 || string(parts[1].Data) != "\xff\xd8" {
+		t.Errorf("unexpected parts %+v %+v", parts[0], parts[1])
+	}
+}
+
+func TestDeleteMessages(t *testing.T) {
+	store, err := New(Config{
+		DataDirPath:  t.TempDir(),
+		DatabaseName: "test.db",
+	}, &schemaInit, &migrations)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	thread := &Thread{ID: "t0", Name: "delete", CreatedAt: "2024-01-01T00:00:00Z", UpdatedAt: "2024-01-01T00:00:00Z"}
+	if err := store.UpsertThread(thread); err != nil {
+		t.Fatalf("failed to create thread: %v", err)
+	}
+	for _, message := range []*Message{
+		{
+			ID: "m0", Role: "user", Content: "explain @main.go", CreatedAt: "2024-01-01T00:00:01Z", ThreadID: thread.ID,
+			Attachments: []*Attachment{{Path: "main.go", Content: "package main"}},
+			Parts:       []*MessagePart{{Type: PartTypeImage, MimeType: "image/png", Path: "a.png", Data: []byte{0x89}}},
+		},
+		{ID: "m1", Role: "assistant", Content: "it is empty", CreatedAt: "2024-01-01T00:00:02Z", ThreadID: thread.ID},
+		{ID: "m2", Role: "assistant", Content: "it is a program", CreatedAt: "2024-01-01T00:00:03Z", ThreadID: thread.ID},
+	} {
+		if err := store.CreateMessage(message); err != nil {
+			t.Fatalf("failed to create message: %v", err)
+		}
+	}
+
+	if err := store.DeleteMessages([]string{"m1"}); err != nil {
+		t.Fatalf("failed to delete messages: %v", err)
+	}
+	listed, err := store.ListMessagesByThreadIDPaginated(thread.ID, 0, 10)
+	if err != nil || len(listed) != 2 || listed[1].ID != "m2" {
+		t.Fatalf("expected m0 and m2 to be left, got %+v (%v)", listed, err)
+	}
+
+	if err := store.DeleteMessagesByThreadID(thread.ID); err != nil {
+		t.Fatalf("failed to delete the messages of the thread: %v", err)
+	}
+	if _, err := store.GetThread(thread.ID); err != nil {
+		t.Errorf("expected the thread to be kept, got %v", err)
+	}
+	for _, table := range []string{"messages", "attachments", "message_parts", "virtual_message_content"} {
+		var count int
+		if err := store.db.Get(&count, "SELECT COUNT(*) FROM "+table); err != nil || count != 0 {
+			t.Errorf("expected %s to be empty, got %d (%v)", table, count, err)
+		}
+	}
+}
+
+func TestDeleteThreadAttachments(t *testing.T) {
+	store, err := New(Config{
+		DataDirPath:  t.TempDir(),
+		DatabaseName: "test.db",
+	}, &schemaInit, &migrations)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	for _, id := range []string{"t0", "t1"} {
+		if err := store.UpsertThread(&Thread{ID: id, Name: id, CreatedAt: "2024-01-01T00:00:00Z", UpdatedAt: "2024-01-01T00:00:00Z"}); err != nil {
+			t.Fatalf("failed to create thread: %v", err)
+		}
+		if err := store.CreateMessage(&Message{
+			ID: "m" + id, Role: "user", Content: "explain @main.go", CreatedAt: "2024-01-01T00:00:01Z", ThreadID: id,
+			Attachments: []*Attachment{{Path: "main.go", Content: "package main"}},
+			Parts:       []*MessagePart{{Type: PartTypeImage, MimeType: "image/png", Path: "a.png", Data: []byte{0x89}}},
+		}); err != nil {
+			t.Fatalf("failed to create message: %v", err)
+		}
+	}
+	count := func(table string) int {
+		t.Helper()
+		var n int
+		if err := store.db.Get(&n, "SELECT COUNT(*) FROM "+table); err != nil {
+			t.Fatalf("failed to count %s: %v", table, err)
+		}
+		return n
+	}
+
+	if err := store.DeleteThread("t0"); err != nil {
+		t.Fatalf("failed to delete thread: %v", err)
+	}
+	for _, table := range []string{"messages", "attachments", "message_parts", "virtual_message_content"} {
+		if n := count(table); n != 1 {
+			t.Errorf("expected only the rows of the other thread in %s, got %d", table, n)
+		}
+	}
+
+	// the cascade removes them when a message is deleted directly as well
+	if _, err := store.db.Exec("DELETE FROM messages WHERE id = 'mt1'"); err != nil {
+		t.Fatalf("failed to delete message: %v", err)
+	}
+	if count("attachments") != 0 || count("message_parts") != 0 {
+		t.Errorf("expected the foreign keys to cascade, got %d and %d", count("attachments"), count("message_parts"))
+	}
+}
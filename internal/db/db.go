@@ -1,6 +1,8 @@
 package db
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,26 +12,34 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+var (
+	ErrThreadNotFound = errors.New("thread not found")
+	ErrMessageExists  = errors.New("message already exists")
+)
+
 type Config struct {
 	DataDirPath  string
 	DatabaseName string
 }
 
 type Store struct {
-	db *sqlx.DB
+	db   *sqlx.DB
+	path string
 }
 
 func New(config Config, schemaInit, migrations *string) (*Store, error) {
 	if err := os.MkdirAll(config.DataDirPath, 0755); err != nil {
 		return nil, fmt.Errorf("could not make data dir, os.MkdirAll: %w", err)
 	}
-	f, err := os.OpenFile(filepath.Join(config.DataDirPath, config.DatabaseName), os.O_RDWR|os.O_CREATE, 0644)
+	path := filepath.Join(config.DataDirPath, config.DatabaseName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("could not create database file, os.OpenFile: %w", err)
 	}
 	defer f.Close()
 
-	db, err := sqlx.Open("sqlite3", filepath.Join(config.DataDirPath, config.DatabaseName))
+	// sqlite only enforces foreign keys and their cascades when asked to
+	db, err := sqlx.Open("sqlite3", path+"?_foreign_keys=on")
 	if err != nil {
 		return nil, fmt.Errorf("sqlx.Open: %w", err)
 	}
@@ -40,12 +50,24 @@ func New(config Config, schemaInit, migrations *string) (*Store, error) {
 		}
 	}
 	if migrations != nil && *migrations != "" {
-		_, err = db.Exec(*migrations)
-		if err != nil {
-			return nil, fmt.Errorf("could not run migrations, db.Exec: %w", err)
+		if err := migrate(db, *migrations); err != nil {
+			return nil, fmt.Errorf("could not run migrations, migrate: %w", err)
 		}
 	}
-	return &Store{db: db}, nil
+	return &Store{db: db, path: path}, nil
+}
+
+// Path returns the path of the database file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Vacuum rebuilds the database file to reclaim unused space
+func (s *Store) Vacuum() error {
+	if _, err := s.db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("db.Exec: %w", err)
+	}
+	return nil
 }
 
 func (s *Store) Begin() (*sqlx.Tx, error) {
@@ -61,10 +83,21 @@ func (s *Store) ListLatestThreadsPaginated(offset, limit int) ([]*Thread, error)
 	return threads, nil
 }
 
+func (s *Store) GetThread(threadID string) (*Thread, error) {
+	thread := &Thread{}
+	if err := s.db.Get(thread, "SELECT * FROM threads WHERE id = $1", threadID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrThreadNotFound
+		}
+		return nil, fmt.Errorf("db.Get: %w", err)
+	}
+	return thread, nil
+}
+
 func (s *Store) CreateThreadTx(tx *sqlx.Tx, thread *Thread) error {
-	query := `INSERT INTO threads (id, t_name, created_at, updated_at, external_message_store) 
-			VALUES (:id, :t_name, :created_at, :updated_at, :external_message_store)`
-	if _, err := tx.Exec(query, thread); err != nil {
+	query := `INSERT INTO threads (id, t_name, created_at, updated_at, external_message_store, metadata, params, model) 
+			VALUES (:id, :t_name, :created_at, :updated_at, :external_message_store, :metadata, :params, :model)`
+	if _, err := tx.NamedExec(query, thread); err != nil {
 		return fmt.Errorf("tx.NamedExec: %w", err)
 	}
 	query = `INSERT INTO virtual_thread_names (thread_id, thread_name) VALUES ($1, $2)`
@@ -74,38 +107,96 @@ func (s *Store) CreateThreadTx(tx *sqlx.Tx, thread *Thread) error {
 	return nil
 }
 
+func (s *Store) UpdateThreadName(threadID, name string) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("could not start transaction, db.Beginx: %w", err)
+	}
+	if err := s.UpdateThreadNameTx(tx, threadID, name); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("could not update thread name, UpdateThreadNameTx: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction, tx.Commit: %w", err)
+	}
+	return nil
+}
+
 func (s *Store) UpdateThreadNameTx(tx *sqlx.Tx, threadID, name string) error {
-	if _, err := tx.Exec("UPDATE threads SET t_name = $1 WHERE id = $2", name, threadID); err != nil {
+	result, err := tx.Exec("UPDATE threads SET t_name = $1 WHERE id = $2", name, threadID)
+	if err != nil {
 		return fmt.Errorf("tx.Exec: %w", err)
 	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrThreadNotFound
+	}
 	if _, err := tx.Exec("UPDATE virtual_thread_names SET thread_name = $1 WHERE thread_id = $2", name, threadID); err != nil {
 		return fmt.Errorf("tx.Exec: %w", err)
 	}
 	return nil
 }
 
+func (s *Store) UpdateThreadParams(threadID, params string) error {
+	result, err := s.db.Exec("UPDATE threads SET params = $1 WHERE id = $2", params, threadID)
+	if err != nil {
+		return fmt.Errorf("db.Exec: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrThreadNotFound
+	}
+	return nil
+}
+
+func (s *Store) UpdateThreadModel(threadID, model string) error {
+	result, err := s.db.Exec("UPDATE threads SET model = $1 WHERE id = $2", model, threadID)
+	if err != nil {
+		return fmt.Errorf("db.Exec: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrThreadNotFound
+	}
+	return nil
+}
+
+// DeleteThreadTx deletes a thread with its messages, the foreign keys
+// delete the messages with their attachments and parts but the search
+// tables have none so their rows are deleted here
 func (s *Store) DeleteThreadTx(tx *sqlx.Tx, threadID string) error {
+	var messageIDs []string
+	if err := tx.Select(&messageIDs, "SELECT id FROM messages WHERE thread_id = $1", threadID); err != nil {
+		return fmt.Errorf("tx.Select: %w", err)
+	}
+	if len(messageIDs) > 0 {
+		query, args, err := sqlx.In("DELETE FROM virtual_message_content WHERE message_id IN (?)", messageIDs)
+		if err != nil {
+			return fmt.Errorf("sqlx.In: %w", err)
+		}
+		if _, err := tx.Exec(tx.Rebind(query), args...); err != nil {
+			return fmt.Errorf("tx.Exec: %w", err)
+		}
+	}
 	if _, err := tx.Exec("DELETE FROM threads WHERE id = $1", threadID); err != nil {
 		return fmt.Errorf("tx.Exec: %w", err)
 	}
 	if _, err := tx.Exec("DELETE FROM virtual_thread_names WHERE thread_id = $1", threadID); err != nil {
 		return fmt.Errorf("tx.Exec: %w", err)
 	}
-	if _, err := tx.Exec("DELETE FROM virtual_message_content WHERE thread_id = $1", threadID); err != nil {
-		return fmt.Errorf("tx.Exec: %w", err)
-	}
 	return nil
 }
 
+// DeleteAllThreadsTx deletes all threads and messages
 func (s *Store) DeleteAllThreadsTx(tx *sqlx.Tx) error {
-	if _, err := tx.Exec("DELETE FROM threads"); err != nil {
-		return fmt.Errorf("tx.Exec: %w", err)
-	}
-	if _, err := tx.Exec("DELETE FROM virtual_thread_names"); err != nil {
-		return fmt.Errorf("tx.Exec: %w", err)
-	}
-	if _, err := tx.Exec("DELETE FROM virtual_message_content"); err != nil {
-		return fmt.Errorf("tx.Exec: %w", err)
+	for _, query := range []string{
+		"DELETE FROM attachments",
+		"DELETE FROM message_parts",
+		"DELETE FROM messages",
+		"DELETE FROM threads",
+		"DELETE FROM virtual_thread_names",
+		"DELETE FROM virtual_message_content",
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("tx.Exec: %w", err)
+		}
 	}
 	return nil
 }
@@ -123,6 +214,20 @@ func (s *Store) CreateMessage(message *Message) error {
 	if err != nil {
 		return fmt.Errorf("could not start transaction, db.Beginx: %w", err)
 	}
+	if ok, err := s.HasThreadTx(tx, message.ThreadID); err != nil || !ok {
+		tx.Rollback()
+		if err != nil {
+			return fmt.Errorf("could not check thread, HasThreadTx: %w", err)
+		}
+		return ErrThreadNotFound
+	}
+	if ok, err := s.HasMessageTx(tx, message.ID); err != nil || ok {
+		tx.Rollback()
+		if err != nil {
+			return fmt.Errorf("could not check message, HasMessageTx: %w", err)
+		}
+		return ErrMessageExists
+	}
 	if err := s.CreateMessageTx(tx, message); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("could not create message, CreateMessageTx: %w", err)
@@ -134,27 +239,210 @@ func (s *Store) CreateMessage(message *Message) error {
 }
 
 func (s *Store) CreateMessageTx(tx *sqlx.Tx, message *Message) error {
-	query := `INSERT INTO messages (id, m_role, content, created_at, thread_id) 
-	VALUES (:id, :m_role, :content, :created_at, :thread_id)`
+	query := `INSERT INTO messages (id, m_role, content, created_at, thread_id, metadata, params, model, tool_calls, tool_call_id) 
+	VALUES (:id, :m_role, :content, :created_at, :thread_id, :metadata, :params, :model, :tool_calls, :tool_call_id)`
 	if _, err := tx.NamedExec(query, message); err != nil {
 		return fmt.Errorf("tx.NamedExec: %w", err)
 	}
+	query = `INSERT INTO virtual_message_content (message_id, thread_id, message_content) VALUES ($1, $2, $3)`
+	if _, err := tx.Exec(query, message.ID, message.ThreadID, message.Content); err != nil {
+		return fmt.Errorf("tx.Exec: %w", err)
+	}
 	query = `UPDATE threads SET updated_at = DATETIME('now') WHERE id = $1`
 	if _, err := tx.Exec(query, message.ThreadID); err != nil {
 		return fmt.Errorf("tx.Exec: %w", err)
 	}
+	for _, attachment := range message.Attachments {
+		if attachment.ID == "" {
+			attachmentID, err := utils.RandomID()
+			if err != nil {
+				return fmt.Errorf("could not generate random id, utils.RandomID: %w", err)
+			}
+			attachment.ID = attachmentID
+		}
+		attachment.MessageID = message.ID
+		if attachment.CreatedAt == "" {
+			attachment.CreatedAt = message.CreatedAt
+		}
+		query = `INSERT INTO attachments (id, message_id, a_path, size, content, created_at)
+		VALUES (:id, :message_id, :a_path, :size, :content, :created_at)`
+		if _, err := tx.NamedExec(query, attachment); err != nil {
+			return fmt.Errorf("tx.NamedExec: %w", err)
+		}
+	}
+	for i, part := range message.Parts {
+		if part.ID == "" {
+			partID, err := utils.RandomID()
+			if err != nil {
+				return fmt.Errorf("could not generate random id, utils.RandomID: %w", err)
+			}
+			part.ID = partID
+		}
+		part.MessageID = message.ID
+		part.Index = i
+		if part.CreatedAt == "" {
+			part.CreatedAt = message.CreatedAt
+		}
+		query = `INSERT INTO message_parts (id, message_id, part_index, p_type, mime_type, p_path, p_data, created_at)
+		VALUES (:id, :message_id, :part_index, :p_type, :mime_type, :p_path, :p_data, :created_at)`
+		if _, err := tx.NamedExec(query, part); err != nil {
+			return fmt.Errorf("tx.NamedExec: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteMessages deletes messages with their attachments and parts
+func (s *Store) DeleteMessages(messageIDs []string) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("could not start transaction, db.Beginx: %w", err)
+	}
+	if err := s.DeleteMessagesTx(tx, messageIDs); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("could not delete messages, DeleteMessagesTx: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction, tx.Commit: %w", err)
+	}
+	return nil
+}
+
+// DeleteMessagesByThreadID deletes all messages of a thread and keeps the
+// thread
+func (s *Store) DeleteMessagesByThreadID(threadID string) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("could not start transaction, db.Beginx: %w", err)
+	}
+	var messageIDs []string
+	if err := tx.Select(&messageIDs, "SELECT id FROM messages WHERE thread_id = $1", threadID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("tx.Select: %w", err)
+	}
+	if err := s.DeleteMessagesTx(tx, messageIDs); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("could not delete messages, DeleteMessagesTx: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction, tx.Commit: %w", err)
+	}
 	return nil
 }
 
+func (s *Store) DeleteMessagesTx(tx *sqlx.Tx, messageIDs []string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	for _, q := range []string{
+		"DELETE FROM attachments WHERE message_id IN (?)",
+		"DELETE FROM message_parts WHERE message_id IN (?)",
+		"DELETE FROM virtual_message_content WHERE message_id IN (?)",
+		"DELETE FROM messages WHERE id IN (?)",
+	} {
+		query, args, err := sqlx.In(q, messageIDs)
+		if err != nil {
+			return fmt.Errorf("sqlx.In: %w", err)
+		}
+		if _, err := tx.Exec(tx.Rebind(query), args...); err != nil {
+			return fmt.Errorf("tx.Exec: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) HasThreadTx(tx *sqlx.Tx, threadID string) (bool, error) {
+	var count int
+	if err := tx.Get(&count, "SELECT COUNT(*) FROM threads WHERE id = $1", threadID); err != nil {
+		return false, fmt.Errorf("tx.Get: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *Store) HasMessageTx(tx *sqlx.Tx, messageID string) (bool, error) {
+	var count int
+	if err := tx.Get(&count, "SELECT COUNT(*) FROM messages WHERE id = $1", messageID); err != nil {
+		return false, fmt.Errorf("tx.Get: %w", err)
+	}
+	return count > 0, nil
+}
+
+// ListMessagesByThreadIDPaginated returns the messages oldest first, the
+// timestamps are in seconds so messages of the same second are listed in
+// the order they were stored, e.g. a tool call before its results
 func (s *Store) ListMessagesByThreadIDPaginated(threadID string, offset, limit int) ([]*Message, error) {
 	var messages []*Message
-	err := s.db.Select(&messages, "SELECT * FROM messages WHERE thread_id = $1 ORDER BY created_at LIMIT $2 OFFSET $3", threadID, limit, offset)
+	err := s.db.Select(&messages, "SELECT * FROM messages WHERE thread_id = $1 ORDER BY created_at, rowid LIMIT $2 OFFSET $3", threadID, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("could not select messages, db.Select: %w", err)
 	}
+	if err := s.loadAttachments(messages); err != nil {
+		return nil, fmt.Errorf("could not load attachments, loadAttachments: %w", err)
+	}
+	if err := s.loadParts(messages); err != nil {
+		return nil, fmt.Errorf("could not load message parts, loadParts: %w", err)
+	}
 	return messages, nil
 }
 
+// selectByMessageIDs selects the rows of table that belong to messages
+func (s *Store) selectByMessageIDs(dest interface{}, table, orderBy string, messages []*Message) error {
+	ids := make([]string, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	query, args, err := sqlx.In(fmt.Sprintf("SELECT * FROM %s WHERE message_id IN (?) ORDER BY %s", table, orderBy), ids)
+	if err != nil {
+		return fmt.Errorf("sqlx.In: %w", err)
+	}
+	if err := s.db.Select(dest, s.db.Rebind(query), args...); err != nil {
+		return fmt.Errorf("db.Select: %w", err)
+	}
+	return nil
+}
+
+func messagesByID(messages []*Message) map[string]*Message {
+	byID := make(map[string]*Message, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+	}
+	return byID
+}
+
+func (s *Store) loadAttachments(messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	var attachments []*Attachment
+	if err := s.selectByMessageIDs(&attachments, "attachments", "created_at, a_path", messages); err != nil {
+		return err
+	}
+	byID := messagesByID(messages)
+	for _, attachment := range attachments {
+		if message, ok := byID[attachment.MessageID]; ok {
+			message.Attachments = append(message.Attachments, attachment)
+		}
+	}
+	return nil
+}
+
+func (s *Store) loadParts(messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	var parts []*MessagePart
+	if err := s.selectByMessageIDs(&parts, "message_parts", "part_index", messages); err != nil {
+		return err
+	}
+	byID := messagesByID(messages)
+	for _, part := range parts {
+		if message, ok := byID[part.MessageID]; ok {
+			message.Parts = append(message.Parts, part)
+		}
+	}
+	return nil
+}
+
 func (s *Store) SearchThreadNamesPaginated(term string, offset, limit int) ([]*Thread, error) {
 	var threads []*Thread
 	query := `SELECT T.* FROM virtual_thread_names VTN INNER JOIN threads T ON VTN.thread_id = T.id WHERE VTN.thread_name MATCH $1 ORDER BY rank LIMIT $2 OFFSET $3`
@@ -189,9 +477,11 @@ func (s *Store) UpsertThread(thread *Thread) error {
 }
 
 func (s *Store) UpsertThreadTx(tx *sqlx.Tx, thread *Thread) error {
-	query := `INSERT INTO threads (id, t_name, created_at, updated_at, external_message_store) 
-			VALUES (:id, :t_name, :created_at, :updated_at, :external_message_store)
-			ON CONFLICT(id) DO UPDATE SET t_name = :t_name, updated_at = :updated_at`
+	// params and model are only changed with UpdateThreadParams and
+	// UpdateThreadModel so that imports keep them
+	query := `INSERT INTO threads (id, t_name, created_at, updated_at, external_message_store, metadata, params, model) 
+			VALUES (:id, :t_name, :created_at, :updated_at, :external_message_store, :metadata, :params, :model)
+			ON CONFLICT(id) DO UPDATE SET t_name = :t_name, updated_at = :updated_at, metadata = :metadata`
 	if _, err := tx.NamedExec(query, thread); err != nil {
 		return fmt.Errorf("tx.NamedExec: %w", err)
 	}
@@ -226,19 +516,9 @@ func (s *Store) DeleteAllThreads() error {
 	if err != nil {
 		return fmt.Errorf("could not start transaction, db.Beginx: %w", err)
 	}
-	query := `DELETE FROM threads`
-	if _, err := tx.Exec(query); err != nil {
-		return fmt.Errorf("could not delete threads, db.Exec: %w", err)
-	}
-	query = `DELETE FROM virtual_thread_names`
-	if _, err := tx.Exec(query); err != nil {
-		tx.Rollback()
-		return fmt.Errorf("could not delete virtual thread names, db.Exec: %w", err)
-	}
-	query = `DELETE FROM virtual_message_content`
-	if _, err := tx.Exec(query); err != nil {
+	if err := s.DeleteAllThreadsTx(tx); err != nil {
 		tx.Rollback()
-		return fmt.Errorf("could not delete virtual message content, db.Exec: %w", err)
+		return fmt.Errorf("could not delete threads, DeleteAllThreadsTx: %w", err)
 	}
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("could not commit transaction, tx.Commit: %w", err)
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// migrationMarker starts a versioned migration in the migrations script,
+// e.g. "-- migration: 1", statements before the first marker run every time
+const migrationMarker = "-- migration:"
+
+type migration struct {
+	version    int
+	statements string
+}
+
+func parseMigrations(script string) (string, []migration, error) {
+	var (
+		unversioned strings.Builder
+		migrations  []migration
+		current     *migration
+	)
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, migrationMarker) {
+			version, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(trimmed, migrationMarker)))
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid migration version in %q: %w", trimmed, err)
+			}
+			if current != nil && version <= current.version {
+				return "", nil, fmt.Errorf("migration versions must increase, got %d after %d", version, current.version)
+			}
+			migrations = append(migrations, migration{version: version})
+			current = &migrations[len(migrations)-1]
+			continue
+		}
+		if current == nil {
+			unversioned.WriteString(line + "\n")
+			continue
+		}
+		current.statements += line + "\n"
+	}
+	return unversioned.String(), migrations, nil
+}
+
+// migrate runs the migrations newer than the schema version stored in the
+// database, each migration runs in its own transaction
+func migrate(db *sqlx.DB, script string) error {
+	unversioned, migrations, err := parseMigrations(script)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(unversioned) != "" {
+		if _, err := db.Exec(unversioned); err != nil {
+			return fmt.Errorf("db.Exec: %w", err)
+		}
+	}
+
+	var currentVersion int
+	if err := db.Get(&currentVersion, "PRAGMA user_version"); err != nil {
+		return fmt.Errorf("could not get schema version, db.Get: %w", err)
+	}
+	for _, m := range migrations {
+		if m.version <= currentVersion {
+			continue
+		}
+		tx, err := db.Beginx()
+		if err != nil {
+			return fmt.Errorf("could not start transaction, db.Beginx: %w", err)
+		}
+		if _, err := tx.Exec(m.statements); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("could not run migration %d, tx.Exec: %w", m.version, err)
+		}
+		// pragmas do not support bound parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", m.version)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("could not set schema version, tx.Exec: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("could not commit transaction, tx.Commit: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	_ "embed"
+	"testing"
+)
+
+//go:embed schema/migrations.sql
+var migrations string
+
+func TestMigrate(t *testing.T) {
+	tmpDirPath := t.TempDir()
+	conf := Config{
+		DataDirPath:  tmpDirPath,
+		DatabaseName: "test.db",
+	}
+	// opening an existing database must not run the migrations again
+	for i := 0; i < 2; i++ {
+		store, err := New(conf, &schemaInit, &migrations)
+		if err != nil {
+			t.Fatalf("failed to create store: %v", err)
+		}
+		var version int
+		if err := store.db.Get(&version, "PRAGMA user_version"); err != nil {
+			t.Fatalf("failed to get schema version: %v", err)
+		}
+		_, expected, err := parseMigrations(migrations)
+		if err != nil {
+			t.Fatalf("failed to parse migrations: %v", err)
+		}
+		if version != expected[len(expected)-1].version {
+			t.Errorf("expected schema version %d, got %d", expected[len(expected)-1].version, version)
+		}
+		store.db.Close()
+	}
+}
@@ -2,18 +2,63 @@ package db
 
 // Thread represents a chat thread that contains messages
 type Thread struct {
-	ID        string `db:"id"`
-	Name string  `db:"t_name"`
-	CreatedAt string `db:"created_at"`
-	UpdatedAt string `db:"updated_at"`
-	ExternalMessageStore bool `db:"external_message_store"`
+	ID                   string `db:"id" json:"id"`
+	Name                 string `db:"t_name" json:"name"`
+	CreatedAt            string `db:"created_at" json:"created_at"`
+	UpdatedAt            string `db:"updated_at" json:"updated_at"`
+	ExternalMessageStore bool   `db:"external_message_store" json:"-"`
+	// Metadata is a json object, e.g. the source of an imported thread
+	Metadata string `db:"metadata" json:"metadata,omitempty"`
+	// Params are json encoded generation parameters overriding the config
+	Params string `db:"params" json:"params,omitempty"`
+	// Model overrides the configured model if set
+	Model string `db:"model" json:"model,omitempty"`
 }
 
 // Message represents a chat message which is part of a thread
 type Message struct {
-	ID   string `db:"id"`
-	Role string `db:"m_role"`
-	Content string `db:"content"`
-	CreatedAt string `db:"created_at"`
-	ThreadID string `db:"thread_id"`
-}
\ No newline at end of file
+	ID        string `db:"id" json:"id"`
+	Role      string `db:"m_role" json:"role"`
+	Content   string `db:"content" json:"content"`
+	CreatedAt string `db:"created_at" json:"created_at"`
+	ThreadID  string `db:"thread_id" json:"thread_id"`
+	// Metadata is a json object, e.g. the source of an imported message
+	Metadata string `db:"metadata" json:"metadata,omitempty"`
+	// Params are the json encoded generation parameters of an assistant message
+	Params string `db:"params" json:"params,omitempty"`
+	// Model is the model an assistant message was generated with
+	Model string `db:"model" json:"model,omitempty"`
+	// ToolCalls are the json encoded tool calls of an assistant message
+	ToolCalls string `db:"tool_calls" json:"tool_calls,omitempty"`
+	// ToolCallID is the call a message with the tool role is the result of
+	ToolCallID string `db:"tool_call_id" json:"tool_call_id,omitempty"`
+	// Attachments are stored in their own table
+	Attachments []*Attachment `db:"-" json:"attachments,omitempty"`
+	// Parts are sent with the content, e.g. images, stored in their own table
+	Parts []*MessagePart `db:"-" json:"parts,omitempty"`
+}
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	ID        string `db:"id" json:"id"`
+	MessageID string `db:"message_id" json:"message_id"`
+	Path      string `db:"a_path" json:"path"`
+	Size      int64  `db:"size" json:"size"`
+	Content   string `db:"content" json:"content"`
+	CreatedAt string `db:"created_at" json:"created_at"`
+}
+
+const PartTypeImage = "image"
+
+// MessagePart is a non text part of a message, parts are ordered by Index
+type MessagePart struct {
+	ID        string `db:"id" json:"id"`
+	MessageID string `db:"message_id" json:"message_id"`
+	Index     int    `db:"part_index" json:"index"`
+	Type      string `db:"p_type" json:"type"`
+	MimeType  string `db:"mime_type" json:"mime_type"`
+	// Path is where the part was read from, only used to show the part
+	Path      string `db:"p_path" json:"path,omitempty"`
+	Data      []byte `db:"p_data" json:"data"`
+	CreatedAt string `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,57 @@
+-- migration: 1
+ALTER TABLE threads ADD COLUMN metadata TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN metadata TEXT NOT NULL DEFAULT '';
+-- messages used to be stored without being indexed for search
+INSERT INTO virtual_message_content (message_id, thread_id, message_content)
+    SELECT id, thread_id, content FROM messages
+    WHERE id NOT IN (SELECT message_id FROM virtual_message_content);
+
+-- migration: 2
+-- generation parameters as json, per thread overrides and the parameters
+-- an assistant message was generated with
+ALTER TABLE threads ADD COLUMN params TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN params TEXT NOT NULL DEFAULT '';
+
+-- migration: 3
+-- the model a thread uses instead of the configured one and the model an
+-- assistant message was generated with
+ALTER TABLE threads ADD COLUMN model TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN model TEXT NOT NULL DEFAULT '';
+
+-- migration: 4
+-- files attached to a message with @path, the content is kept so the
+-- history shows what was sent
+CREATE TABLE IF NOT EXISTS attachments (
+    id TEXT PRIMARY KEY,
+    message_id TEXT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    a_path TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS attachments_message_id ON attachments (message_id);
+
+-- migration: 5
+-- non text parts of a message like images, sent along with the content
+CREATE TABLE IF NOT EXISTS message_parts (
+    id TEXT PRIMARY KEY,
+    message_id TEXT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    part_index INTEGER NOT NULL,
+    p_type TEXT NOT NULL,
+    mime_type TEXT NOT NULL,
+    p_path TEXT NOT NULL DEFAULT '',
+    p_data BLOB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS message_parts_message_id ON message_parts (message_id);
+
+-- migration: 6
+-- tool calls requested in an assistant message as json and the call a
+-- tool message is the result of
+ALTER TABLE messages ADD COLUMN tool_calls TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN tool_call_id TEXT NOT NULL DEFAULT '';
+
+-- migration: 7
+-- attachments and parts of threads deleted before foreign keys were enforced
+DELETE FROM attachments WHERE message_id NOT IN (SELECT id FROM messages);
+DELETE FROM message_parts WHERE message_id NOT IN (SELECT id FROM messages);
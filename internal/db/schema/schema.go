@@ -0,0 +1,13 @@
+// Package schema embeds the sql to create and migrate the database
+package schema
+
+import (
+	_ "embed"
+)
+
+var (
+	//go:embed init.sql
+	Init string
+	//go:embed migrations.sql
+	Migrations string
+)
@@ -0,0 +1,23 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/db/schema"
+	"github.com/aavshr/panda/internal/store"
+	"github.com/aavshr/panda/internal/store/storetest"
+)
+
+func TestStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s, err := db.New(db.Config{
+			DataDirPath:  t.TempDir(),
+			DatabaseName: "test.db",
+		}, &schema.Init, &schema.Migrations)
+		if err != nil {
+			t.Fatalf("failed to create store: %v", err)
+		}
+		return s
+	})
+}
@@ -4,14 +4,14 @@ INSERT INTO threads (id, t_name, external_message_store, created_at, updated_at)
     ('t2', 'who was Douglas Engelbart', 'f', datetime('now', '-2 day'), datetime('now', '-2 day'));
 
 INSERT INTO messages (id, m_role, content, created_at, thread_id) VALUES
-    ('t0m0', 'user', 'do cats and mice really hate each other', datetime('now'), '0'),
-    ('t0m1', 'assistant', 'yes they do', datetime('now'), '0'),
-    ('t1m0', 'user', 'what are pure functions', datetime('now', '-1 day'), '1'),
-    ('t1m1', 'assistant', 'pure functions are functions that completely deterministic in their inputs', datetime('now', '-1 day'), '1'),
-    ('t1m2', 'assistant', 'they do not modify the state of the program', datetime('now', '-1 day'), '1'),
-    ('t2m0', 'user', 'he was an american engineer and inventor', datetime('now', '-2 day'), '2'),
-    ('t2m1', 'user', 'he invented the mouse', datetime('now', '-2 day'), '2'),
-    ('t2m2', 'user', 'he was a pioneer in the field of human computer interaction', datetime('now', '-2 day'), '2');
+    ('t0m0', 'user', 'do cats and mice really hate each other', datetime('now'), 't0'),
+    ('t0m1', 'assistant', 'yes they do', datetime('now'), 't0'),
+    ('t1m0', 'user', 'what are pure functions', datetime('now', '-1 day'), 't1'),
+    ('t1m1', 'assistant', 'pure functions are functions that completely deterministic in their inputs', datetime('now', '-1 day'), 't1'),
+    ('t1m2', 'assistant', 'they do not modify the state of the program', datetime('now', '-1 day'), 't1'),
+    ('t2m0', 'user', 'he was an american engineer and inventor', datetime('now', '-2 day'), 't2'),
+    ('t2m1', 'user', 'he invented the mouse', datetime('now', '-2 day'), 't2'),
+    ('t2m2', 'user', 'he was a pioneer in the field of human computer interaction', datetime('now', '-2 day'), 't2');
 
 INSERT INTO virtual_thread_names(thread_id, thread_name) VALUES
     ('t0', 'cat and mouse'),
@@ -0,0 +1,169 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aavshr/panda/internal/db"
+)
+
+type Format string
+
+const (
+	FormatMarkdown Format = "md"
+	FormatJSON     Format = "json"
+	FormatHTML     Format = "html"
+
+	defaultPageLimit = 100
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown export format")
+
+	Formats = []Format{FormatMarkdown, FormatJSON, FormatHTML}
+)
+
+type Store interface {
+	ListLatestThreadsPaginated(offset, limit int) ([]*db.Thread, error)
+	ListMessagesByThreadIDPaginated(threadID string, offset, limit int) ([]*db.Message, error)
+}
+
+// renderer writes a document incrementally so that threads and messages
+// never have to be held in memory all at once
+type renderer interface {
+	begin(multiple bool) error
+	beginThread(thread *db.Thread, first bool) error
+	message(message *db.Message, first bool) error
+	endThread(thread *db.Thread) error
+	end() error
+}
+
+type Exporter struct {
+	store     Store
+	format    Format
+	pageLimit int
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.TrimPrefix(strings.ToLower(s), ".")); f {
+	case FormatMarkdown, FormatJSON, FormatHTML:
+		return f, nil
+	case "markdown":
+		return FormatMarkdown, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownFormat, s)
+}
+
+func New(store Store, format Format) (*Exporter, error) {
+	if _, err := ParseFormat(string(format)); err != nil {
+		return nil, err
+	}
+	return &Exporter{
+		store:     store,
+		format:    format,
+		pageLimit: defaultPageLimit,
+	}, nil
+}
+
+// Extension returns the file extension for the export format
+func (e *Exporter) Extension() string {
+	return "." + string(e.format)
+}
+
+func (e *Exporter) newRenderer(w io.Writer) renderer {
+	switch e.format {
+	case FormatJSON:
+		return &jsonRenderer{w: w}
+	case FormatHTML:
+		return &htmlRenderer{w: w}
+	default:
+		return &markdownRenderer{w: w}
+	}
+}
+
+// ExportThread writes a single thread with all its messages to w
+func (e *Exporter) ExportThread(w io.Writer, thread *db.Thread) error {
+	r := e.newRenderer(w)
+	if err := r.begin(false); err != nil {
+		return err
+	}
+	if err := e.exportThread(r, thread, true); err != nil {
+		return err
+	}
+	return r.end()
+}
+
+// ExportAll writes all threads with their messages to w, latest first
+func (e *Exporter) ExportAll(w io.Writer) error {
+	r := e.newRenderer(w)
+	if err := r.begin(true); err != nil {
+		return err
+	}
+	first := true
+	for offset := 0; ; offset += e.pageLimit {
+		threads, err := e.store.ListLatestThreadsPaginated(offset, e.pageLimit)
+		if err != nil {
+			return fmt.Errorf("store.ListLatestThreadsPaginated: %w", err)
+		}
+		for _, thread := range threads {
+			if err := e.exportThread(r, thread, first); err != nil {
+				return err
+			}
+			first = false
+		}
+		if len(threads) < e.pageLimit {
+			break
+		}
+	}
+	return r.end()
+}
+
+func (e *Exporter) exportThread(r renderer, thread *db.Thread, first bool) error {
+	if err := r.beginThread(thread, first); err != nil {
+		return err
+	}
+	firstMessage := true
+	for offset := 0; ; offset += e.pageLimit {
+		messages, err := e.store.ListMessagesByThreadIDPaginated(thread.ID, offset, e.pageLimit)
+		if err != nil {
+			return fmt.Errorf("store.ListMessagesByThreadIDPaginated: %w", err)
+		}
+		for _, message := range messages {
+			if err := r.message(message, firstMessage); err != nil {
+				return err
+			}
+			firstMessage = false
+		}
+		if len(messages) < e.pageLimit {
+			break
+		}
+	}
+	return r.endThread(thread)
+}
+
+// FileName returns a file system friendly name for a thread export
+func FileName(thread *db.Thread, format Format) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSuffix(thread.Name, "..")) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case sb.Len() > 0 && !strings.HasSuffix(sb.String(), "-"):
+			sb.WriteRune('-')
+		}
+	}
+	slug := strings.Trim(sb.String(), "-")
+	if slug == "" {
+		return fmt.Sprintf("%s.%s", thread.ID, format)
+	}
+	return fmt.Sprintf("%s-%s.%s", slug, thread.ID, format)
+}
+
+func roleTitle(role string) string {
+	if role == "" {
+		return role
+	}
+	return strings.ToUpper(role[:1]) + role[1:]
+}
@@ -0,0 +1,124 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aavshr/panda/internal/db"
+)
+
+type pagedStore struct {
+	threads  []*db.Thread
+	messages []*db.Message
+}
+
+func page[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
+
+func (s *pagedStore) ListLatestThreadsPaginated(offset, limit int) ([]*db.Thread, error) {
+	return page(s.threads, offset, limit), nil
+}
+
+func (s *pagedStore) ListMessagesByThreadIDPaginated(threadID string, offset, limit int) ([]*db.Message, error) {
+	var messages []*db.Message
+	for _, m := range s.messages {
+		if m.ThreadID == threadID {
+			messages = append(messages, m)
+		}
+	}
+	return page(messages, offset, limit), nil
+}
+
+func newTestStore() *pagedStore {
+	s := &pagedStore{
+		threads: []*db.Thread{
+			{ID: "t0", Name: "first <thread>", CreatedAt: "2024-01-01 10:00:00", UpdatedAt: "2024-01-01 10:01:00"},
+			{ID: "t1", Name: "second", CreatedAt: "2024-01-02 10:00:00", UpdatedAt: "2024-01-02 10:01:00"},
+		},
+	}
+	// more messages than a page to exercise pagination
+	for i := 0; i < 5; i++ {
+		s.messages = append(s.messages,
+			&db.Message{ID: "t0u" + string(rune('0'+i)), Role: "user", Content: "question", ThreadID: "t0"},
+			&db.Message{ID: "t0a" + string(rune('0'+i)), Role: "assistant", Content: "answer", ThreadID: "t0"},
+		)
+	}
+	s.messages = append(s.messages, &db.Message{
+		ID: "t1u0", Role: "user", Content: "hi", ThreadID: "t1",
+		Attachments: []*db.Attachment{{Path: "main.go", Content: "package main"}},
+		Parts:       []*db.MessagePart{{Type: db.PartTypeImage, MimeType: "image/png", Path: "a.png", Data: []byte("png")}},
+	})
+	return s
+}
+
+func TestExportJSON(t *testing.T) {
+	store := newTestStore()
+	e, err := New(store, FormatJSON)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	e.pageLimit = 3
+
+	var buf bytes.Buffer
+	if err := e.ExportAll(&buf); err != nil {
+		t.Fatalf("ExportAll: %v", err)
+	}
+	var threads []struct {
+		ID       string        `json:"id"`
+		Messages []*db.Message `json:"messages"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &threads); err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, buf.String())
+	}
+	if len(threads) != 2 || len(threads[0].Messages) != 10 || len(threads[1].Messages) != 1 {
+		t.Errorf("unexpected export %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := e.ExportThread(&buf, store.threads[1]); err != nil {
+		t.Fatalf("ExportThread: %v", err)
+	}
+	var thread struct {
+		ID       string        `json:"id"`
+		Messages []*db.Message `json:"messages"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &thread); err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, buf.String())
+	}
+	if thread.ID != "t1" || len(thread.Messages) != 1 {
+		t.Errorf("unexpected export %s", buf.String())
+	}
+}
+
+func TestExportMarkdownAndHTML(t *testing.T) {
+	store := newTestStore()
+	for format, expected := range map[Format][]string{
+		FormatMarkdown: {"# first <thread>", "## User", "## Assistant", "\n---\n", "# second", "[image: a.png]", "attached: `main.go`"},
+		FormatHTML: {"<h1>first &lt;thread&gt;</h1>", `<div class="message user">`, "<hr>", "</html>",
+			`<img src="data:image/png;base64,cG5n" alt="a.png">`, "attached: main.go"},
+	} {
+		e, err := New(store, format)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := e.ExportAll(&buf); err != nil {
+			t.Fatalf("ExportAll: %v", err)
+		}
+		for _, s := range expected {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("%s export does not contain %q", format, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package export
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/llm"
+)
+
+var htmlTemplates = template.Must(template.New("begin").Funcs(template.FuncMap{
+	// images are embedded, html/template drops data urls unless typed
+	"dataURL": func(part *db.MessagePart) template.URL {
+		return template.URL(llm.DataURL(part))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>panda export</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #24292f; }
+h1 { color: #eb5e55; margin-bottom: 0.25rem; }
+hr { margin: 3rem 0; border: 0; border-top: 1px solid #d0d7de; }
+.meta { color: #626262; font-style: italic; font-size: 0.85rem; }
+.message { margin: 1.5rem 0; }
+.message img { max-width: 100%; margin-top: 0.5rem; }
+.role { font-weight: bold; }
+.user .role { color: #5fafaf; }
+.assistant .role { color: #00afff; }
+.content { white-space: pre-wrap; font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 0.9rem; background: #f6f8fa; padding: 0.75rem; border-radius: 6px; }
+</style>
+</head>
+<body>
+`))
+
+func init() {
+	template.Must(htmlTemplates.New("thread").Parse(`{{if not .First}}<hr>
+{{end}}<h1>{{.Thread.Name}}</h1>
+<div class="meta">created {{.Thread.CreatedAt}}, updated {{.Thread.UpdatedAt}}</div>
+`))
+	template.Must(htmlTemplates.New("message").Parse(`<div class="message {{.Role}}">
+<span class="role">{{.Role}}</span> <span class="meta">{{.CreatedAt}}{{if .Model}}, {{.Model}}{{end}}</span>
+<div class="content">{{.Content}}</div>
+{{range .Parts}}{{if eq .Type "image"}}<img src="{{dataURL .}}" alt="{{.Path}}">
+{{end}}{{end}}{{if .Attachments}}<div class="meta">attached:{{range $i, $a := .Attachments}}{{if $i}},{{end}} {{$a.Path}}{{end}}</div>
+{{end}}</div>
+`))
+	template.Must(htmlTemplates.New("end").Parse(`</body>
+</html>
+`))
+}
+
+type htmlRenderer struct {
+	w io.Writer
+}
+
+func (r *htmlRenderer) begin(_ bool) error {
+	return htmlTemplates.ExecuteTemplate(r.w, "begin", nil)
+}
+
+func (r *htmlRenderer) beginThread(thread *db.Thread, first bool) error {
+	return htmlTemplates.ExecuteTemplate(r.w, "thread", struct {
+		Thread *db.Thread
+		First  bool
+	}{thread, first})
+}
+
+func (r *htmlRenderer) message(message *db.Message, _ bool) error {
+	return htmlTemplates.ExecuteTemplate(r.w, "message", message)
+}
+
+func (r *htmlRenderer) endThread(_ *db.Thread) error {
+	return nil
+}
+
+func (r *htmlRenderer) end() error {
+	return htmlTemplates.ExecuteTemplate(r.w, "end", nil)
+}
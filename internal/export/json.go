@@ -0,0 +1,70 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aavshr/panda/internal/db"
+)
+
+// jsonRenderer writes a thread as its fields plus a messages array,
+// multiple threads are written as an array of threads
+type jsonRenderer struct {
+	w        io.Writer
+	multiple bool
+}
+
+func (r *jsonRenderer) begin(multiple bool) error {
+	r.multiple = multiple
+	if multiple {
+		_, err := io.WriteString(r.w, "[\n")
+		return err
+	}
+	return nil
+}
+
+func (r *jsonRenderer) beginThread(thread *db.Thread, first bool) error {
+	if !first {
+		if _, err := io.WriteString(r.w, ",\n"); err != nil {
+			return err
+		}
+	}
+	b, err := json.Marshal(thread)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	// reopen the thread object to append the messages
+	b = bytes.TrimSuffix(b, []byte("}"))
+	_, err = fmt.Fprintf(r.w, "%s,\"messages\":[", b)
+	return err
+}
+
+func (r *jsonRenderer) message(message *db.Message, first bool) error {
+	b, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	if !first {
+		if _, err := io.WriteString(r.w, ","); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(r.w, "\n%s", b)
+	return err
+}
+
+func (r *jsonRenderer) endThread(_ *db.Thread) error {
+	_, err := io.WriteString(r.w, "]}")
+	return err
+}
+
+func (r *jsonRenderer) end() error {
+	if r.multiple {
+		_, err := io.WriteString(r.w, "\n]\n")
+		return err
+	}
+	_, err := io.WriteString(r.w, "\n")
+	return err
+}
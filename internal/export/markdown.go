@@ -0,0 +1,71 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/llm"
+)
+
+type markdownRenderer struct {
+	w io.Writer
+}
+
+func (r *markdownRenderer) begin(_ bool) error {
+	return nil
+}
+
+func (r *markdownRenderer) beginThread(thread *db.Thread, first bool) error {
+	if !first {
+		if _, err := io.WriteString(r.w, "\n---\n\n"); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(r.w, "# %s\n\n_created %s, updated %s_\n", thread.Name, thread.CreatedAt, thread.UpdatedAt)
+	return err
+}
+
+func (r *markdownRenderer) message(message *db.Message, _ bool) error {
+	meta := message.CreatedAt
+	if message.Model != "" {
+		meta += ", " + message.Model
+	}
+	if _, err := fmt.Fprintf(r.w, "\n## %s\n\n_%s_\n\n%s\n",
+		roleTitle(message.Role), meta, strings.TrimRight(message.Content, "\n")); err != nil {
+		return err
+	}
+	// calls that can not be parsed are left out, the json export has them
+	calls, _ := llm.ParseToolCalls(message.ToolCalls)
+	for _, call := range calls {
+		if _, err := fmt.Fprintf(r.w, "\n_calls `%s %s`_\n", call.Name, call.Arguments); err != nil {
+			return err
+		}
+	}
+	for _, part := range message.Parts {
+		if part.Type != db.PartTypeImage {
+			continue
+		}
+		if _, err := fmt.Fprintf(r.w, "\n_[image: %s]_\n", part.Path); err != nil {
+			return err
+		}
+	}
+	if len(message.Attachments) == 0 {
+		return nil
+	}
+	var paths []string
+	for _, attachment := range message.Attachments {
+		paths = append(paths, "`"+attachment.Path+"`")
+	}
+	_, err := fmt.Fprintf(r.w, "\n_attached: %s_\n", strings.Join(paths, ", "))
+	return err
+}
+
+func (r *markdownRenderer) endThread(_ *db.Thread) error {
+	return nil
+}
+
+func (r *markdownRenderer) end() error {
+	return nil
+}
@@ -0,0 +1,140 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// chatGPTConversation is a conversation in the conversations.json file of
+// a ChatGPT data export, messages form a tree in mapping
+type chatGPTConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     float64                `json:"create_time"`
+	UpdateTime     float64                `json:"update_time"`
+	CurrentNode    string                 `json:"current_node"`
+	Mapping        map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	ID       string          `json:"id"`
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+	Message  *chatGPTMessage `json:"message"`
+}
+
+type chatGPTMessage struct {
+	ID     string `json:"id"`
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime *float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+	} `json:"content"`
+	Metadata struct {
+		ModelSlug string `json:"model_slug"`
+	} `json:"metadata"`
+}
+
+func parseChatGPT(data []byte) ([]*Conversation, error) {
+	var exported []chatGPTConversation
+	if err := json.Unmarshal(data, &exported); err != nil {
+		return nil, fmt.Errorf("could not parse chatgpt export, json.Unmarshal: %w", err)
+	}
+	conversations := make([]*Conversation, 0, len(exported))
+	for _, c := range exported {
+		id := c.ConversationID
+		if id == "" {
+			id = c.ID
+		}
+		conversation := &Conversation{
+			Source:    SourceChatGPT,
+			ID:        id,
+			Title:     c.Title,
+			CreatedAt: unixTime(c.CreateTime),
+			UpdatedAt: unixTime(c.UpdateTime),
+		}
+		if conversation.Title == "" {
+			conversation.Title = "Untitled"
+		}
+		for _, node := range c.activeBranch() {
+			m := node.Message
+			content := m.text()
+			// system prompts are usually empty and tool output can not be
+			// sent back without the tool calls that produced it
+			if content == "" || (m.Author.Role != roleUser && m.Author.Role != roleAssistant && m.Author.Role != roleSystem) {
+				continue
+			}
+			createdAt := conversation.CreatedAt
+			if m.CreateTime != nil {
+				createdAt = unixTime(*m.CreateTime)
+			}
+			message := ConversationMessage{
+				ID:        m.ID,
+				Role:      m.Author.Role,
+				Content:   content,
+				CreatedAt: createdAt,
+			}
+			if m.Author.Role == roleAssistant {
+				message.Model = m.Metadata.ModelSlug
+			}
+			conversation.Messages = append(conversation.Messages, message)
+		}
+		conversations = append(conversations, conversation)
+	}
+	return conversations, nil
+}
+
+// activeBranch walks up from the current node to the root, edited messages
+// and regenerated answers on other branches are not imported
+func (c *chatGPTConversation) activeBranch() []chatGPTNode {
+	var branch []chatGPTNode
+	seen := make(map[string]struct{})
+	for id := c.CurrentNode; id != ""; {
+		if _, ok := seen[id]; ok {
+			break
+		}
+		seen[id] = struct{}{}
+		node, ok := c.Mapping[id]
+		if !ok {
+			break
+		}
+		if node.Message != nil {
+			branch = append(branch, node)
+		}
+		id = node.Parent
+	}
+	for i, j := 0, len(branch)-1; i < j; i, j = i+1, j-1 {
+		branch[i], branch[j] = branch[j], branch[i]
+	}
+	return branch
+}
+
+// text joins the text parts of a message, other parts like images are skipped
+func (m *chatGPTMessage) text() string {
+	var parts []string
+	for _, raw := range m.Content.Parts {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			continue
+		}
+		if s = strings.TrimSpace(s); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func unixTime(seconds float64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second)))
+}
@@ -0,0 +1,312 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/jmoiron/sqlx"
+)
+
+type Source string
+
+const (
+	SourceAuto    Source = "auto"
+	SourceChatGPT Source = "chatgpt"
+	SourcePanda   Source = "panda"
+
+	timeFormat = "2006-01-02 15:04:05"
+)
+
+var (
+	ErrUnknownSource = errors.New("unknown import source")
+)
+
+type Store interface {
+	Begin() (*sqlx.Tx, error)
+	HasThreadTx(tx *sqlx.Tx, threadID string) (bool, error)
+	HasMessageTx(tx *sqlx.Tx, messageID string) (bool, error)
+	UpsertThreadTx(tx *sqlx.Tx, thread *db.Thread) error
+	CreateMessageTx(tx *sqlx.Tx, message *db.Message) error
+}
+
+// Conversation is a thread read from an export of another tool
+type Conversation struct {
+	Source    Source
+	ID        string
+	Title     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Messages  []ConversationMessage
+	// Metadata replaces the generated metadata if set
+	Metadata string
+	Params   string
+	Model    string
+}
+
+type ConversationMessage struct {
+	ID        string
+	Role      string
+	Content   string
+	CreatedAt time.Time
+	Metadata  string
+	Params    string
+	Model     string
+	// ToolCalls and ToolCallID link tool calls to their results
+	ToolCalls  string
+	ToolCallID string
+	// Attachments and Parts get new ids when they are stored
+	Attachments []*db.Attachment
+	Parts       []*db.MessagePart
+}
+
+// metadata is stored with imported threads and messages to keep the
+// original ids and timestamps
+type metadata struct {
+	Source    Source `json:"source"`
+	SourceID  string `json:"source_id"`
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+type Progress func(done, total int, conversation *Conversation)
+
+type Result struct {
+	ThreadsImported  int
+	ThreadsUpdated   int
+	ThreadsSkipped   int
+	MessagesImported int
+}
+
+type Importer struct {
+	store    Store
+	progress Progress
+}
+
+func ParseSource(s string) (Source, error) {
+	switch source := Source(s); source {
+	case SourceAuto, SourceChatGPT, SourcePanda:
+		return source, nil
+	case "":
+		return SourceAuto, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownSource, s)
+}
+
+func New(store Store, progress Progress) *Importer {
+	return &Importer{
+		store:    store,
+		progress: progress,
+	}
+}
+
+// Parse reads the conversations of an export, with SourceAuto the source is
+// detected from the content
+func Parse(r io.Reader, source Source) ([]*Conversation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+	if source == SourceAuto {
+		if source, err = detectSource(data); err != nil {
+			return nil, err
+		}
+	}
+	switch source {
+	case SourceChatGPT:
+		return parseChatGPT(data)
+	case SourcePanda:
+		return parsePanda(data)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownSource, source)
+}
+
+func detectSource(data []byte) (Source, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return "", fmt.Errorf("empty export")
+	}
+	var first map[string]json.RawMessage
+	if data[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return "", fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		if len(items) == 0 {
+			return SourcePanda, nil
+		}
+		data = items[0]
+	}
+	if err := json.Unmarshal(data, &first); err != nil {
+		return "", fmt.Errorf("could not detect export source, json.Unmarshal: %w", err)
+	}
+	if _, ok := first["mapping"]; ok {
+		return SourceChatGPT, nil
+	}
+	if _, ok := first["messages"]; ok {
+		return SourcePanda, nil
+	}
+	return "", fmt.Errorf("could not detect export source")
+}
+
+// Import saves the conversations, each in its own transaction, messages
+// that were imported before are skipped so imports can be repeated
+func (i *Importer) Import(conversations []*Conversation) (*Result, error) {
+	result := &Result{}
+	for n, conversation := range conversations {
+		imported, existed, err := i.importConversation(conversation)
+		if err != nil {
+			return result, fmt.Errorf("could not import %q: %w", conversation.Title, err)
+		}
+		switch {
+		case imported == 0:
+			result.ThreadsSkipped++
+		case existed:
+			result.ThreadsUpdated++
+		default:
+			result.ThreadsImported++
+		}
+		result.MessagesImported += imported
+		if i.progress != nil {
+			i.progress(n+1, len(conversations), conversation)
+		}
+	}
+	return result, nil
+}
+
+func (i *Importer) importConversation(c *Conversation) (int, bool, error) {
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+		if len(c.Messages) > 0 && !c.Messages[0].CreatedAt.IsZero() {
+			c.CreatedAt = c.Messages[0].CreatedAt
+		}
+	}
+	if c.UpdatedAt.IsZero() {
+		c.UpdatedAt = c.CreatedAt
+	}
+	threadID := importID(c.Source, c.ID)
+	if c.Metadata == "" {
+		c.Metadata = marshalMetadata(metadata{
+			Source:    c.Source,
+			SourceID:  c.ID,
+			CreatedAt: formatTime(c.CreatedAt),
+			UpdatedAt: formatTime(c.UpdatedAt),
+		})
+	}
+	thread := &db.Thread{
+		ID:        threadID,
+		Name:      c.Title,
+		CreatedAt: c.CreatedAt.Local().Format(timeFormat),
+		UpdatedAt: c.UpdatedAt.Local().Format(timeFormat),
+		Metadata:  c.Metadata,
+		Params:    c.Params,
+		Model:     c.Model,
+	}
+
+	tx, err := i.store.Begin()
+	if err != nil {
+		return 0, false, fmt.Errorf("could not start transaction, store.Begin: %w", err)
+	}
+	existed, err := i.store.HasThreadTx(tx, threadID)
+	if err != nil {
+		tx.Rollback()
+		return 0, false, fmt.Errorf("store.HasThreadTx: %w", err)
+	}
+	// the thread must exist before its messages
+	if err := i.store.UpsertThreadTx(tx, thread); err != nil {
+		tx.Rollback()
+		return 0, existed, fmt.Errorf("store.UpsertThreadTx: %w", err)
+	}
+	imported := 0
+	for _, m := range c.Messages {
+		messageID := importID(c.Source, m.ID)
+		exists, err := i.store.HasMessageTx(tx, messageID)
+		if err != nil {
+			tx.Rollback()
+			return 0, existed, fmt.Errorf("store.HasMessageTx: %w", err)
+		}
+		if exists {
+			continue
+		}
+		if m.Metadata == "" {
+			m.Metadata = marshalMetadata(metadata{
+				Source:    c.Source,
+				SourceID:  m.ID,
+				CreatedAt: formatTime(m.CreatedAt),
+			})
+		}
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = c.CreatedAt
+		}
+		message := &db.Message{
+			ID:        messageID,
+			Role:      m.Role,
+			Content:   m.Content,
+			CreatedAt: m.CreatedAt.Local().Format(timeFormat),
+			ThreadID:  threadID,
+			Metadata:  m.Metadata,
+			Params:    m.Params,
+			Model:     m.Model,
+			// call ids are only referenced within a thread so they are kept
+			ToolCalls:  m.ToolCalls,
+			ToolCallID: m.ToolCallID,
+		}
+		for _, a := range m.Attachments {
+			message.Attachments = append(message.Attachments, &db.Attachment{
+				Path:      a.Path,
+				Size:      a.Size,
+				Content:   a.Content,
+				CreatedAt: a.CreatedAt,
+			})
+		}
+		for _, p := range m.Parts {
+			message.Parts = append(message.Parts, &db.MessagePart{
+				Type:      p.Type,
+				MimeType:  p.MimeType,
+				Path:      p.Path,
+				Data:      p.Data,
+				CreatedAt: p.CreatedAt,
+			})
+		}
+		if err := i.store.CreateMessageTx(tx, message); err != nil {
+			tx.Rollback()
+			return 0, existed, fmt.Errorf("store.CreateMessageTx: %w", err)
+		}
+		imported++
+	}
+	// creating messages touches updated_at so restore the original one
+	if err := i.store.UpsertThreadTx(tx, thread); err != nil {
+		tx.Rollback()
+		return 0, existed, fmt.Errorf("store.UpsertThreadTx: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, existed, fmt.Errorf("could not commit transaction, tx.Commit: %w", err)
+	}
+	return imported, existed, nil
+}
+
+// importID namespaces ids from other tools so they can not collide with
+// ids generated by panda, panda exports keep their ids
+func importID(source Source, id string) string {
+	if source == SourcePanda {
+		return id
+	}
+	return fmt.Sprintf("%s-%s", source, id)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func marshalMetadata(m metadata) string {
+	// marshalling a struct of strings can not fail
+	b, _ := json.Marshal(m)
+	return string(b)
+}
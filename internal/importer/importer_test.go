@@ -0,0 +1,86 @@
+package importer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/db/schema"
+)
+
+func TestIntegrationImportChatGPT(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, err := db.New(db.Config{
+		DataDirPath:  t.TempDir(),
+		DatabaseName: "test.db",
+	}, &schema.Init, &schema.Migrations)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	f, err := os.Open("testdata/chatgpt.json")
+	if err != nil {
+		t.Fatalf("failed to open test data: %v", err)
+	}
+	defer f.Close()
+	conversations, err := Parse(f, SourceAuto)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(conversations) != 1 || conversations[0].Source != SourceChatGPT {
+		t.Fatalf("expected one chatgpt conversation, got %+v", conversations)
+	}
+
+	var progressCalls int
+	result, err := New(store, func(done, total int, _ *Conversation) {
+		progressCalls++
+	}).Import(conversations)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if result.ThreadsImported != 1 || result.MessagesImported != 3 || progressCalls != 1 {
+		t.Errorf("unexpected result %+v with %d progress calls", result, progressCalls)
+	}
+
+	messages, err := store.ListMessagesByThreadIDPaginated("chatgpt-c1", 0, 10)
+	if err != nil {
+		t.Fatalf("ListMessagesByThreadIDPaginated: %v", err)
+	}
+	// only the active branch without the empty system prompt is imported
+	expected := []string{
+		"how do I feed a sourdough starter",
+		"equal parts flour and water by weight",
+		"what about rye flour",
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("expected %d messages, got %d", len(expected), len(messages))
+	}
+	for i, m := range messages {
+		if m.Content != expected[i] {
+			t.Errorf("message %d: expected %q, got %q", i, expected[i], m.Content)
+		}
+	}
+	if messages[1].Model != "gpt-4o" {
+		t.Errorf("expected the model of the answer to be imported, got %q", messages[1].Model)
+	}
+
+	found, err := store.SearchMessageContentPaginated("rye", 0, 10)
+	if err != nil {
+		t.Fatalf("SearchMessageContentPaginated: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != "chatgpt-m4" {
+		t.Errorf("expected imported message to be searchable, got %+v", found)
+	}
+
+	// importing again does not duplicate anything
+	result, err = New(store, nil).Import(conversations)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if result.ThreadsSkipped != 1 || result.MessagesImported != 0 {
+		t.Errorf("expected re-import to be skipped, got %+v", result)
+	}
+}
@@ -0,0 +1,83 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aavshr/panda/internal/db"
+)
+
+const (
+	roleUser      = "user"
+	roleAssistant = "assistant"
+	roleSystem    = "system"
+)
+
+// pandaThread is a thread as written by the json export, other tools can
+// be imported by converting their history to this format
+type pandaThread struct {
+	db.Thread
+	Messages []*db.Message `json:"messages"`
+}
+
+func parsePanda(data []byte) ([]*Conversation, error) {
+	var threads []pandaThread
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var thread pandaThread
+		if err := json.Unmarshal(data, &thread); err != nil {
+			return nil, fmt.Errorf("could not parse panda export, json.Unmarshal: %w", err)
+		}
+		threads = append(threads, thread)
+	} else if err := json.Unmarshal(data, &threads); err != nil {
+		return nil, fmt.Errorf("could not parse panda export, json.Unmarshal: %w", err)
+	}
+
+	conversations := make([]*Conversation, 0, len(threads))
+	for _, t := range threads {
+		if t.ID == "" {
+			return nil, fmt.Errorf("thread %q has no id", t.Name)
+		}
+		conversation := &Conversation{
+			Source:    SourcePanda,
+			ID:        t.ID,
+			Title:     t.Name,
+			CreatedAt: parseTime(t.CreatedAt),
+			UpdatedAt: parseTime(t.UpdatedAt),
+			Metadata:  t.Metadata,
+			Params:    t.Params,
+			Model:     t.Model,
+		}
+		for _, m := range t.Messages {
+			if m.ID == "" {
+				return nil, fmt.Errorf("message in thread %q has no id", t.Name)
+			}
+			conversation.Messages = append(conversation.Messages, ConversationMessage{
+				ID:          m.ID,
+				Role:        m.Role,
+				Content:     m.Content,
+				CreatedAt:   parseTime(m.CreatedAt),
+				Metadata:    m.Metadata,
+				Params:      m.Params,
+				Model:       m.Model,
+				ToolCalls:   m.ToolCalls,
+				ToolCallID:  m.ToolCallID,
+				Attachments: m.Attachments,
+				Parts:       m.Parts,
+			})
+		}
+		conversations = append(conversations, conversation)
+	}
+	return conversations, nil
+}
+
+func parseTime(s string) time.Time {
+	for _, layout := range []string{timeFormat, time.RFC3339, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
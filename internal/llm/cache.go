@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const DefaultModelCacheTTL = 24 * time.Hour
+
+// ModelCache keeps the models listed by a backend on disk so that they do
+// not have to be listed again every time the model picker is opened
+type ModelCache struct {
+	path string
+	ttl  time.Duration
+}
+
+type cachedModels struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Models    []string  `json:"models"`
+}
+
+func NewModelCache(path string, ttl time.Duration) *ModelCache {
+	return &ModelCache{path: path, ttl: ttl}
+}
+
+func (c *ModelCache) read() (map[string]cachedModels, error) {
+	entries := make(map[string]cachedModels)
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return entries, nil
+		}
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("could not parse model cache, json.Unmarshal: %w", err)
+	}
+	return entries, nil
+}
+
+// Get returns the cached models of the backend at baseURL, fresh is false
+// if nothing is cached or the models are older than the ttl
+func (c *ModelCache) Get(baseURL string) (models []string, fresh bool) {
+	entries, err := c.read()
+	if err != nil {
+		// a broken cache is treated like an empty one and replaced on Set
+		return nil, false
+	}
+	entry, ok := entries[baseURL]
+	if !ok {
+		return nil, false
+	}
+	return entry.Models, time.Since(entry.FetchedAt) < c.ttl
+}
+
+// Set caches the models of the backend at baseURL
+func (c *ModelCache) Set(baseURL string, models []string) error {
+	entries, err := c.read()
+	if err != nil {
+		entries = make(map[string]cachedModels)
+	}
+	entries[baseURL] = cachedModels{FetchedAt: time.Now(), Models: models}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	if err := os.WriteFile(c.path, data, 0600); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return nil
+}
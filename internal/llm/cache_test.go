@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestModelCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "models.json")
+	cache := NewModelCache(path, time.Hour)
+
+	if models, fresh := cache.Get("https://api.openai.com/v1"); models != nil || fresh {
+		t.Fatalf("expected empty cache, got %v fresh %v", models, fresh)
+	}
+	expected := []string{"gpt-4o", "o3-mini"}
+	if err := cache.Set("https://api.openai.com/v1", expected); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	models, fresh := cache.Get("https://api.openai.com/v1")
+	if !reflect.DeepEqual(models, expected) || !fresh {
+		t.Errorf("expected fresh %v, got %v fresh %v", expected, models, fresh)
+	}
+	// models are cached per backend
+	if models, _ := cache.Get("http://localhost:11434/v1"); models != nil {
+		t.Errorf("expected no models for another backend, got %v", models)
+	}
+
+	// stale models are still returned so they can be shown while refreshing
+	stale := NewModelCache(path, 0)
+	if models, fresh := stale.Get("https://api.openai.com/v1"); !reflect.DeepEqual(models, expected) || fresh {
+		t.Errorf("expected stale %v, got %v fresh %v", expected, models, fresh)
+	}
+
+	// a corrupted cache is replaced
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if models, _ := cache.Get("https://api.openai.com/v1"); models != nil {
+		t.Errorf("expected no models from a corrupted cache, got %v", models)
+	}
+	if err := cache.Set("https://api.openai.com/v1", expected); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+}
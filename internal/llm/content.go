@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/aavshr/panda/internal/db"
+)
+
+// Content returns the content of a message as it is sent to the model,
+// attached files follow the text as labeled blocks
+func Content(message *db.Message) string {
+	if len(message.Attachments) == 0 {
+		return message.Content
+	}
+	var b strings.Builder
+	b.WriteString(message.Content)
+	for _, attachment := range message.Attachments {
+		fmt.Fprintf(&b, "\n\n<file path=%q>\n%s", attachment.Path, attachment.Content)
+		if !strings.HasSuffix(attachment.Content, "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString("</file>")
+	}
+	return b.String()
+}
+
+// DataURL returns the part as a base64 data url, e.g. data:image/png;base64,...
+func DataURL(part *db.MessagePart) string {
+	return fmt.Sprintf("data:%s;base64,%s", part.MimeType, base64.StdEncoding.EncodeToString(part.Data))
+}
+
+// HasImages reports whether any of the messages has an image part
+func HasImages(messages []*db.Message) bool {
+	for _, message := range messages {
+		for _, part := range message.Parts {
+			if part.Type == db.PartTypeImage {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasNewImages reports whether the newest message of the user has an image
+// part, the images of older messages are left out for models without vision
+func HasNewImages(messages []*db.Message) bool {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return HasImages(messages[i : i+1])
+		}
+	}
+	return false
+}
+
+// ImagePlaceholder is sent in place of an image to models that do not take
+// images so that the model knows there was one
+func ImagePlaceholder(part *db.MessagePart) string {
+	return fmt.Sprintf("[image %s left out, the model does not take images]", part.Path)
+}
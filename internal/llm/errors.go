@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrorKind is what went wrong with a request to the backend
+type ErrorKind string
+
+const (
+	ErrorKindUnknown       ErrorKind = "error"
+	ErrorKindAuth          ErrorKind = "authentication failed"
+	ErrorKindRateLimit     ErrorKind = "rate limited"
+	ErrorKindNetwork       ErrorKind = "network error"
+	ErrorKindContextLength ErrorKind = "context too long"
+)
+
+var ErrAPIKeyNotSet = errors.New("API key not set")
+
+// contextLengthCodes are error codes of backends for requests with more
+// tokens than the model takes
+var contextLengthCodes = []string{"context_length_exceeded", "string_above_max_length"}
+
+// StatusError is an error response of the backend, providers wrap their
+// errors in it so that they can be classified
+type StatusError struct {
+	StatusCode int
+	// Code is the error code of the backend, e.g. context_length_exceeded
+	Code string
+	// RetryAfter is how long the backend asks to wait before the next
+	// request, 0 if it did not say
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// Classify returns the kind of a failed request
+func Classify(err error) ErrorKind {
+	if err == nil {
+		return ErrorKindUnknown
+	}
+	if errors.Is(err, ErrAPIKeyNotSet) {
+		return ErrorKindAuth
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusUnauthorized, statusErr.StatusCode == http.StatusForbidden:
+			return ErrorKindAuth
+		case statusErr.StatusCode == http.StatusTooManyRequests:
+			return ErrorKindRateLimit
+		case isContextLength(statusErr):
+			return ErrorKindContextLength
+		case statusErr.StatusCode == http.StatusBadGateway, statusErr.StatusCode == http.StatusServiceUnavailable,
+			statusErr.StatusCode == http.StatusGatewayTimeout:
+			return ErrorKindNetwork
+		}
+		return ErrorKindUnknown
+	}
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE):
+		return ErrorKindNetwork
+	}
+	return ErrorKindUnknown
+}
+
+// isContextLength reports whether the backend rejected the request for
+// its length, not every backend sets a code so the message is checked too
+func isContextLength(err *StatusError) bool {
+	for _, code := range contextLengthCodes {
+		if err.Code == code {
+			return true
+		}
+	}
+	if err.StatusCode != http.StatusBadRequest && err.StatusCode != http.StatusRequestEntityTooLarge {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "context length") || strings.Contains(message, "context window")
+}
+
+// Retryable reports whether the request may succeed when it is made again
+func Retryable(err error) bool {
+	switch Classify(err) {
+	case ErrorKindRateLimit, ErrorKindNetwork:
+		return true
+	}
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode >= http.StatusInternalServerError
+}
+
+// ParseRetryAfter returns the wait of a Retry-After header in seconds or as
+// a date, 0 if it is not set or invalid
+func ParseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return max(0, time.Duration(seconds)*time.Second)
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return max(0, date.Sub(now))
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected ErrorKind
+	}{
+		{fmt.Errorf("CreateChatCompletionStream: %w", ErrAPIKeyNotSet), ErrorKindAuth},
+		{&StatusError{StatusCode: 401, Err: errors.New("invalid api key")}, ErrorKindAuth},
+		{&StatusError{StatusCode: 429, Err: errors.New("slow down")}, ErrorKindRateLimit},
+		{&StatusError{StatusCode: 400, Code: "context_length_exceeded", Err: errors.New("too long")}, ErrorKindContextLength},
+		{&StatusError{StatusCode: 400, Err: errors.New("This model's maximum context length is 8192 tokens")}, ErrorKindContextLength},
+		{&StatusError{StatusCode: 400, Err: errors.New("invalid temperature")}, ErrorKindUnknown},
+		{&StatusError{StatusCode: 503, Err: errors.New("overloaded")}, ErrorKindNetwork},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), ErrorKindNetwork},
+		{&net.OpError{Op: "dial", Err: errors.New("no route to host")}, ErrorKindNetwork},
+		{io.ErrUnexpectedEOF, ErrorKindNetwork},
+		{context.DeadlineExceeded, ErrorKindNetwork},
+		{errors.New("boom"), ErrorKindUnknown},
+	} {
+		if kind := Classify(tc.err); kind != tc.expected {
+			t.Errorf("expected %q for %v, got %q", tc.expected, tc.err, kind)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected bool
+	}{
+		{&StatusError{StatusCode: 429, Err: errors.New("slow down")}, true},
+		{&StatusError{StatusCode: 500, Err: errors.New("server error")}, true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{&StatusError{StatusCode: 401, Err: errors.New("invalid api key")}, false},
+		{&StatusError{StatusCode: 400, Code: "context_length_exceeded", Err: errors.New("too long")}, false},
+		{context.Canceled, false},
+	} {
+		if retryable := Retryable(tc.err); retryable != tc.expected {
+			t.Errorf("expected %v to be retryable %v", tc.err, tc.expected)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC)
+	for header, expected := range map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"Fri, 05 Jan 2024 12:00:10 GMT": 10 * time.Second,
+		"Fri, 05 Jan 2024 11:00:00 GMT": 0,
+		"soon":                          0,
+	} {
+		if d := ParseRetryAfter(header, now); d != expected {
+			t.Errorf("expected %v for %q, got %v", expected, header, d)
+		}
+	}
+}
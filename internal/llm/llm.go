@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ReasoningEfforts are the accepted values of Params.ReasoningEffort
+	ReasoningEfforts = []string{"low", "medium", "high"}
+
+	// reasoningModelPrefixes are model families that take a reasoning
+	// effort but no sampling parameters
+	reasoningModelPrefixes = []string{"o1", "o3", "o4"}
+
+	// textOnlyModelPrefixes are model families known to reject images,
+	// other models are assumed to take them
+	textOnlyModelPrefixes = []string{"gpt-3.5", "o1-mini", "o1-preview", "o3-mini"}
+
+	ErrImagesNotSupported = errors.New("the model does not take images")
+)
+
+// Params are the generation parameters of a completion request,
+// zero values are left to the backend defaults
+type Params struct {
+	Temperature *float32 `json:"temperature,omitempty"`
+	TopP        *float32 `json:"top_p,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	// ReasoningEffort is low, medium or high for reasoning models like o3-mini
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+}
+
+// ParseParams decodes params stored as json, empty is the zero value
+func ParseParams(s string) (Params, error) {
+	var p Params
+	if strings.TrimSpace(s) == "" {
+		return p, nil
+	}
+	if err := json.Unmarshal([]byte(s), &p); err != nil {
+		return p, fmt.Errorf("invalid params, json.Unmarshal: %w", err)
+	}
+	return p, p.Validate()
+}
+
+// Encode returns the params as json to be stored, empty for the zero value
+func (p Params) Encode() string {
+	if p.IsZero() {
+		return ""
+	}
+	// marshalling the params can not fail
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+func (p Params) IsZero() bool {
+	return p.Temperature == nil && p.TopP == nil && p.MaxTokens == 0 &&
+		p.Seed == nil && len(p.Stop) == 0 && p.ReasoningEffort == ""
+}
+
+// Merge returns the params with the values set in override replacing them
+func (p Params) Merge(override Params) Params {
+	if override.Temperature != nil {
+		p.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		p.TopP = override.TopP
+	}
+	if override.MaxTokens != 0 {
+		p.MaxTokens = override.MaxTokens
+	}
+	if override.Seed != nil {
+		p.Seed = override.Seed
+	}
+	if len(override.Stop) > 0 {
+		p.Stop = override.Stop
+	}
+	if override.ReasoningEffort != "" {
+		p.ReasoningEffort = override.ReasoningEffort
+	}
+	return p
+}
+
+func (p Params) Validate() error {
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if p.TopP != nil && (*p.TopP < 0 || *p.TopP > 1) {
+		return fmt.Errorf("top_p must be between 0 and 1")
+	}
+	if p.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must be a positive number")
+	}
+	// the openai api accepts at most 4 stop sequences
+	if len(p.Stop) > 4 {
+		return fmt.Errorf("at most 4 stop sequences are allowed")
+	}
+	if p.ReasoningEffort != "" {
+		valid := false
+		for _, effort := range ReasoningEfforts {
+			valid = valid || effort == p.ReasoningEffort
+		}
+		if !valid {
+			return fmt.Errorf("reasoning_effort must be one of %s", strings.Join(ReasoningEfforts, ", "))
+		}
+	}
+	return nil
+}
+
+// IsReasoningModel reports whether the model is a reasoning model like o3-mini
+func IsReasoningModel(model string) bool {
+	for _, prefix := range reasoningModelPrefixes {
+		if model == prefix || strings.HasPrefix(model, prefix+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsImages reports whether images can be sent to the model
+func SupportsImages(model string) bool {
+	for _, prefix := range textOnlyModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return false
+		}
+	}
+	return true
+}
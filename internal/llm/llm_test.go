@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aavshr/panda/internal/db"
+)
+
+func float32Ptr(f float32) *float32 {
+	return &f
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestParamsMerge(t *testing.T) {
+	global := Params{
+		Temperature:     float32Ptr(1),
+		MaxTokens:       100,
+		ReasoningEffort: "low",
+	}
+	thread := Params{
+		Temperature: float32Ptr(0),
+		Seed:        intPtr(42),
+		Stop:        []string{"END"},
+	}
+	expected := Params{
+		Temperature:     float32Ptr(0),
+		MaxTokens:       100,
+		Seed:            intPtr(42),
+		Stop:            []string{"END"},
+		ReasoningEffort: "low",
+	}
+	if merged := global.Merge(thread); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %+v, got %+v", expected, merged)
+	}
+	if merged := global.Merge(Params{}); !reflect.DeepEqual(merged, global) {
+		t.Errorf("merging zero params should not change params, got %+v", merged)
+	}
+}
+
+func TestParamsEncode(t *testing.T) {
+	if s := (Params{}).Encode(); s != "" {
+		t.Errorf("expected zero params to encode to empty string, got %q", s)
+	}
+	params := Params{TopP: float32Ptr(0.5), Seed: intPtr(7), ReasoningEffort: "high"}
+	parsed, err := ParseParams(params.Encode())
+	if err != nil {
+		t.Fatalf("ParseParams: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, params) {
+		t.Errorf("expected %+v, got %+v", params, parsed)
+	}
+}
+
+func TestParamsValidate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		params  Params
+		wantErr bool
+	}{
+		{"zero", Params{}, false},
+		{"temperature too high", Params{Temperature: float32Ptr(2.5)}, true},
+		{"top_p too high", Params{TopP: float32Ptr(1.5)}, true},
+		{"negative max tokens", Params{MaxTokens: -1}, true},
+		{"too many stop sequences", Params{Stop: []string{"a", "b", "c", "d", "e"}}, true},
+		{"unknown reasoning effort", Params{ReasoningEffort: "max"}, true},
+		{"valid", Params{Temperature: float32Ptr(0.2), TopP: float32Ptr(1), ReasoningEffort: "medium"}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.params.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("expected error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestIsReasoningModel(t *testing.T) {
+	for model, expected := range map[string]bool{
+		"o3-mini":     true,
+		"o1":          true,
+		"o4-mini":     true,
+		"gpt-4o":      false,
+		"gpt-4o-mini": false,
+		"o3mini":      false,
+	} {
+		if IsReasoningModel(model) != expected {
+			t.Errorf("IsReasoningModel(%q) expected %v", model, expected)
+		}
+	}
+}
+
+func TestContent(t *testing.T) {
+	message := &db.Message{
+		Content: "explain @main.go",
+		Attachments: []*db.Attachment{
+			{Path: "main.go", Content: "package main"},
+			{Path: "go.mod", Content: "module panda\n"},
+		},
+	}
+	expected := "explain @main.go\n\n<file path=\"main.go\">\npackage main\n</file>\n\n<file path=\"go.mod\">\nmodule panda\n</file>"
+	if content := Content(message); content != expected {
+		t.Errorf("expected %q, got %q", expected, content)
+	}
+	message.Attachments = nil
+	if content := Content(message); content != message.Content {
+		t.Errorf("expected the plain content, got %q", content)
+	}
+}
+
+func TestImages(t *testing.T) {
+	part := &db.MessagePart{Type: db.PartTypeImage, MimeType: "image/png", Data: []byte("png")}
+	if url := DataURL(part); url != "data:image/png;base64,cG5n" {
+		t.Errorf("unexpected data url %q", url)
+	}
+	messages := []*db.Message{{Content: "hi"}, {Content: "what is this", Parts: []*db.MessagePart{part}}}
+	if !HasImages(messages) || HasImages(messages[:1]) {
+		t.Error("expected only the second message to have images")
+	}
+	messages[1].Role = "user"
+	later := append(messages, &db.Message{Role: "assistant", Content: "a cat"}, &db.Message{Role: "user", Content: "thanks"})
+	if !HasNewImages(messages) || HasNewImages(later) {
+		t.Error("expected only the newest message of the user to count")
+	}
+	for model, expected := range map[string]bool{"gpt-4o": true, "gpt-4.1-mini": true, "o3-mini": false, "gpt-3.5-turbo": false} {
+		if SupportsImages(model) != expected {
+			t.Errorf("SupportsImages(%q): expected %v", model, expected)
+		}
+	}
+}
+
+func TestToolCalls(t *testing.T) {
+	calls := []ToolCall{{ID: "call_0", Name: "read_file", Arguments: `{"path":"main.go"}`}}
+	parsed, err := ParseToolCalls(EncodeToolCalls(calls))
+	if err != nil || !reflect.DeepEqual(parsed, calls) {
+		t.Errorf("expected %+v, got %+v (%v)", calls, parsed, err)
+	}
+	if EncodeToolCalls(nil) != "" {
+		t.Error("expected no calls to encode to empty")
+	}
+	if parsed, err := ParseToolCalls(""); err != nil || parsed != nil {
+		t.Errorf("expected no calls, got %+v (%v)", parsed, err)
+	}
+}
@@ -1,11 +1,18 @@
 package openai
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"sort"
+	"time"
 
 	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/llm"
 	client "github.com/sashabaranov/go-openai"
 )
 
@@ -14,26 +21,127 @@ const (
 )
 
 var (
-	ErrAPIKeyNotSet      = errors.New("API key not set")
+	ErrAPIKeyNotSet      = llm.ErrAPIKeyNotSet
 	ErrNoChoicesReturned = errors.New("no completion returned")
 	ErrBufferTooSmall    = errors.New("buffer too small")
 )
 
+// wrapError keeps the status of error responses so that they can be
+// classified with llm.Classify
+func wrapError(err error, retryAfter time.Duration) error {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		code, _ := apiErr.Code.(string)
+		return &llm.StatusError{StatusCode: apiErr.HTTPStatusCode, Code: code, RetryAfter: retryAfter, Err: err}
+	}
+	var requestErr *client.RequestError
+	if errors.As(err, &requestErr) {
+		return &llm.StatusError{StatusCode: requestErr.HTTPStatusCode, RetryAfter: retryAfter, Err: err}
+	}
+	return err
+}
+
+type retryAfterKey struct{}
+
+// withRetryAfter returns a context whose requests record the Retry-After
+// header of an error response in the returned duration, the errors of the
+// client do not have the headers
+func withRetryAfter(ctx context.Context) (context.Context, *time.Duration) {
+	retryAfter := new(time.Duration)
+	return context.WithValue(ctx, retryAfterKey{}, retryAfter), retryAfter
+}
+
+// retryAfterDoer records the Retry-After header for withRetryAfter
+type retryAfterDoer struct {
+	doer client.HTTPDoer
+}
+
+func (d retryAfterDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.doer.Do(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	if retryAfter, ok := req.Context().Value(retryAfterKey{}).(*time.Duration); ok {
+		*retryAfter = llm.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return resp, err
+}
+
+type zeroParamsKey struct{}
+
+// withZeroParams returns a context whose request sends the named parameters
+// as 0, the request of the client drops zeros with omitempty
+func withZeroParams(ctx context.Context, model string, params llm.Params) context.Context {
+	var names []string
+	if !llm.IsReasoningModel(model) {
+		if params.Temperature != nil && *params.Temperature == 0 {
+			names = append(names, "temperature")
+		}
+		if params.TopP != nil && *params.TopP == 0 {
+			names = append(names, "top_p")
+		}
+	}
+	if len(names) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, zeroParamsKey{}, names)
+}
+
+// zeroParamsDoer adds the parameters of withZeroParams to the json body
+type zeroParamsDoer struct {
+	doer client.HTTPDoer
+}
+
+func (d zeroParamsDoer) Do(req *http.Request) (*http.Response, error) {
+	names, ok := req.Context().Value(zeroParamsKey{}).([]string)
+	if !ok || req.Body == nil {
+		return d.doer.Do(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	for _, name := range names {
+		fields[name] = json.RawMessage("0")
+	}
+	if body, err = json.Marshal(fields); err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	return d.doer.Do(req)
+}
+
 type OpenAIStream struct {
 	stream *client.ChatCompletionStream
+	// toolCalls are assembled from the deltas by their index
+	toolCalls []llm.ToolCall
 }
 
 // TODO: verify that I can return EOF before copying the content
 // TODO: what if len(p) is too small?
-func (s OpenAIStream) Read(p []byte) (int, error) {
+func (s *OpenAIStream) Read(p []byte) (int, error) {
 	resp, err := s.stream.Recv()
 	if err != nil {
-		return 0, err
+		return 0, wrapError(err, 0)
 	}
 	if len(resp.Choices) == 0 {
 		return 0, ErrNoChoicesReturned
 	}
-	content := resp.Choices[0].Delta.Content
+	delta := resp.Choices[0].Delta
+	for _, call := range delta.ToolCalls {
+		s.addToolCallDelta(call)
+	}
+	content := delta.Content
 	n := copy(p, content)
 	if n < len(content) {
 		return n, ErrBufferTooSmall
@@ -41,7 +149,30 @@ func (s OpenAIStream) Read(p []byte) (int, error) {
 	return n, nil
 }
 
-func (s OpenAIStream) Close() error {
+// addToolCallDelta adds a streamed part of a tool call, the first part of
+// a call has its id and name and the arguments follow in pieces
+func (s *OpenAIStream) addToolCallDelta(delta client.ToolCall) {
+	index := len(s.toolCalls)
+	if delta.Index != nil {
+		index = *delta.Index
+	}
+	for len(s.toolCalls) <= index {
+		s.toolCalls = append(s.toolCalls, llm.ToolCall{})
+	}
+	call := &s.toolCalls[index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	call.Name += delta.Function.Name
+	call.Arguments += delta.Function.Arguments
+}
+
+// ToolCalls returns the tool calls of the completion once it is read
+func (s *OpenAIStream) ToolCalls() []llm.ToolCall {
+	return s.toolCalls
+}
+
+func (s *OpenAIStream) Close() error {
 	return s.stream.Close()
 }
 
@@ -49,6 +180,7 @@ type OpenAI struct {
 	baseURL string
 	apiKey  string
 	client  *client.Client
+	tools   []llm.ToolDefinition
 }
 
 func New(baseURL string) *OpenAI {
@@ -62,37 +194,174 @@ func New(baseURL string) *OpenAI {
 
 func (o *OpenAI) SetAPIKey(apiKey string) error {
 	o.apiKey = apiKey
-	o.client = client.NewClient(o.apiKey)
+	o.newClient()
 	return nil
 }
 
-func (o *OpenAI) dbMessagesToClientMessage(messages []*db.Message) []client.ChatCompletionMessage {
+// SetBaseURL sets the url of an openai compatible api, empty for the default
+func (o *OpenAI) SetBaseURL(baseURL string) error {
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	o.baseURL = baseURL
+	o.newClient()
+	return nil
+}
+
+// SetTools sets the tools offered to the model in every request, nil for none
+func (o *OpenAI) SetTools(tools []llm.ToolDefinition) {
+	o.tools = tools
+}
+
+// Snapshot returns a copy with the current settings, the settings of the
+// copy do not change with those of o
+func (o *OpenAI) Snapshot() llm.StreamCreator {
+	snapshot := *o
+	return &snapshot
+}
+
+func (o *OpenAI) newClient() {
+	conf := client.DefaultConfig(o.apiKey)
+	conf.BaseURL = o.baseURL
+	conf.HTTPClient = retryAfterDoer{doer: zeroParamsDoer{doer: conf.HTTPClient}}
+	o.client = client.NewClientWithConfig(conf)
+}
+
+// ListModels returns the ids of the models available to the api key
+func (o *OpenAI) ListModels(ctx context.Context) ([]string, error) {
+	if o.apiKey == "" {
+		return nil, ErrAPIKeyNotSet
+	}
+	ctx, retryAfter := withRetryAfter(ctx)
+	resp, err := o.client.ListModels(ctx)
+	if err != nil {
+		return nil, wrapError(err, *retryAfter)
+	}
+	models := make([]string, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		models = append(models, m.ID)
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+func (o *OpenAI) newRequest(model string, params llm.Params, messages []*db.Message) (client.ChatCompletionRequest, error) {
+	clientMessages, err := o.dbMessagesToClientMessage(messages, llm.SupportsImages(model))
+	if err != nil {
+		return client.ChatCompletionRequest{}, err
+	}
+	req := client.ChatCompletionRequest{
+		Model:    model,
+		Messages: clientMessages,
+		Seed:     params.Seed,
+		Stop:     params.Stop,
+	}
+	for _, tool := range o.tools {
+		req.Tools = append(req.Tools, client.Tool{
+			Type: client.ToolTypeFunction,
+			Function: &client.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	// reasoning models reject sampling parameters and max_tokens
+	if llm.IsReasoningModel(model) {
+		req.MaxCompletionTokens = params.MaxTokens
+		req.ReasoningEffort = params.ReasoningEffort
+		return req, nil
+	}
+	// explicit zeros are added to the body by zeroParamsDoer
+	req.MaxTokens = params.MaxTokens
+	if params.Temperature != nil {
+		req.Temperature = *params.Temperature
+	}
+	if params.TopP != nil {
+		req.TopP = *params.TopP
+	}
+	return req, nil
+}
+
+// dbMessagesToClientMessage converts the messages, without images the image
+// parts are replaced by a placeholder in the text
+func (o *OpenAI) dbMessagesToClientMessage(messages []*db.Message, images bool) ([]client.ChatCompletionMessage, error) {
 	var clientMessages []client.ChatCompletionMessage
 	for _, m := range messages {
 		m := m
-		clientMessages = append(clientMessages, client.ChatCompletionMessage{
-			Role:    m.Role,
-			Content: m.Content,
-		})
+		toolCalls, err := llm.ParseToolCalls(m.ToolCalls)
+		if err != nil {
+			return nil, fmt.Errorf("llm.ParseToolCalls: %w", err)
+		}
+		clientMessage := client.ChatCompletionMessage{
+			Role:       m.Role,
+			ToolCallID: m.ToolCallID,
+		}
+		for _, call := range toolCalls {
+			clientMessage.ToolCalls = append(clientMessage.ToolCalls, client.ToolCall{
+				ID:   call.ID,
+				Type: client.ToolTypeFunction,
+				Function: client.FunctionCall{
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				},
+			})
+		}
+		if len(m.Parts) == 0 {
+			clientMessage.Content = llm.Content(m)
+			clientMessages = append(clientMessages, clientMessage)
+			continue
+		}
+		if !images {
+			content := llm.Content(m)
+			for _, part := range m.Parts {
+				if part.Type == db.PartTypeImage {
+					content += "\n\n" + llm.ImagePlaceholder(part)
+				}
+			}
+			clientMessage.Content = content
+			clientMessages = append(clientMessages, clientMessage)
+			continue
+		}
+		// Content and MultiContent can not both be set
+		clientMessage.MultiContent = []client.ChatMessagePart{{
+			Type: client.ChatMessagePartTypeText,
+			Text: llm.Content(m),
+		}}
+		for _, part := range m.Parts {
+			if part.Type != db.PartTypeImage {
+				continue
+			}
+			clientMessage.MultiContent = append(clientMessage.MultiContent, client.ChatMessagePart{
+				Type: client.ChatMessagePartTypeImageURL,
+				ImageURL: &client.ChatMessageImageURL{
+					URL:    llm.DataURL(part),
+					Detail: client.ImageURLDetailAuto,
+				},
+			})
+		}
+		clientMessages = append(clientMessages, clientMessage)
 	}
-	return clientMessages
+	return clientMessages, nil
 }
 
 // TODO: fix coupling with db message
-func (o *OpenAI) CreateChatCompletion(ctx context.Context, model string, messages []*db.Message) (string, error) {
+func (o *OpenAI) CreateChatCompletion(ctx context.Context, model string, params llm.Params, messages []*db.Message) (string, error) {
 	if o.apiKey == "" {
 		return "", ErrAPIKeyNotSet
 	}
-	resp, err := o.client.CreateChatCompletion(
-		ctx,
-		client.ChatCompletionRequest{
-			Model:    model,
-			Messages: o.dbMessagesToClientMessage(messages),
-		},
-	)
+	if llm.HasNewImages(messages) && !llm.SupportsImages(model) {
+		return "", llm.ErrImagesNotSupported
+	}
+	req, err := o.newRequest(model, params, messages)
 	if err != nil {
 		return "", err
 	}
+	ctx, retryAfter := withRetryAfter(withZeroParams(ctx, model, params))
+	resp, err := o.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return "", wrapError(err, *retryAfter)
+	}
 	if len(resp.Choices) == 0 {
 		return "", ErrNoChoicesReturned
 	}
@@ -100,18 +369,22 @@ func (o *OpenAI) CreateChatCompletion(ctx context.Context, model string, message
 }
 
 // TODO: coupling with db.Message
-func (o *OpenAI) CreateChatCompletionStream(ctx context.Context, model string, messages []*db.Message) (io.ReadCloser, error) {
+func (o *OpenAI) CreateChatCompletionStream(ctx context.Context, model string, params llm.Params, messages []*db.Message) (io.ReadCloser, error) {
 	if o.apiKey == "" {
 		return nil, ErrAPIKeyNotSet
 	}
-	req := client.ChatCompletionRequest{
-		Model:    model,
-		Messages: o.dbMessagesToClientMessage(messages),
-		Stream:   true,
+	if llm.HasNewImages(messages) && !llm.SupportsImages(model) {
+		return nil, llm.ErrImagesNotSupported
 	}
-	stream, err := o.client.CreateChatCompletionStream(ctx, req)
+	req, err := o.newRequest(model, params, messages)
 	if err != nil {
 		return nil, err
 	}
-	return OpenAIStream{stream: stream}, nil
+	req.Stream = true
+	ctx, retryAfter := withRetryAfter(withZeroParams(ctx, model, params))
+	stream, err := o.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, wrapError(err, *retryAfter)
+	}
+	return &OpenAIStream{stream: stream}, nil
 }
@@ -0,0 +1,199 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/llm"
+	client "github.com/sashabaranov/go-openai"
+)
+
+func TestStreamToolCalls(t *testing.T) {
+	var req client.ChatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("could not decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		// the arguments of a call are streamed in pieces after its id and name
+		for _, chunk := range []string{
+			`{"choices":[{"index":0,"delta":{"role":"assistant","content":"let me check"}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_0","type":"function","function":{"name":"read_file","arguments":""}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"main.go\"}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_1","type":"function","function":{"name":"calculate","arguments":"{}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	o := New(server.URL)
+	if err := o.SetAPIKey("key"); err != nil {
+		t.Fatal(err)
+	}
+	o.SetTools([]llm.ToolDefinition{{Name: "read_file", Parameters: json.RawMessage(`{"type":"object"}`)}})
+	messages := []*db.Message{
+		{Role: "user", Content: "what is 1+1"},
+		{Role: "assistant", ToolCalls: `[{"id":"call_x","name":"calculate","arguments":"{\"expression\":\"1+1\"}"}]`},
+		{Role: "tool", ToolCallID: "call_x", Content: "2"},
+	}
+	stream, err := o.CreateChatCompletionStream(context.Background(), "gpt-4o", llm.Params{}, messages)
+	if err != nil {
+		t.Fatalf("CreateChatCompletionStream: %v", err)
+	}
+	content, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("io.ReadAll: %v", err)
+	}
+	if string(content) != "let me check" {
+		t.Errorf("unexpected content %q", content)
+	}
+	expected := []llm.ToolCall{
+		{ID: "call_0", Name: "read_file", Arguments: `{"path":"main.go"}`},
+		{ID: "call_1", Name: "calculate", Arguments: "{}"},
+	}
+	if calls := stream.(llm.ToolCallStream).ToolCalls(); !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected %+v, got %+v", expected, calls)
+	}
+
+	if len(req.Tools) != 1 || req.Tools[0].Function.Name != "read_file" {
+		t.Errorf("expected the tool in the request, got %+v", req.Tools)
+	}
+	if len(req.Messages) != 3 || len(req.Messages[1].ToolCalls) != 1 || req.Messages[1].ToolCalls[0].ID != "call_x" ||
+		req.Messages[2].ToolCallID != "call_x" {
+		t.Errorf("expected the tool call and result in the request, got %+v", req.Messages)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	for _, tc := range []struct {
+		status   int
+		body     string
+		expected llm.ErrorKind
+	}{
+		{http.StatusUnauthorized, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`, llm.ErrorKindAuth},
+		{http.StatusTooManyRequests, `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`, llm.ErrorKindRateLimit},
+		{http.StatusBadRequest, `{"error":{"message":"This model's maximum context length is 128000 tokens","type":"invalid_request_error","code":"context_length_exceeded"}}`, llm.ErrorKindContextLength},
+		{http.StatusBadGateway, `bad gateway`, llm.ErrorKindNetwork},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(tc.status)
+			fmt.Fprint(w, tc.body)
+		}))
+		o := New(server.URL)
+		if err := o.SetAPIKey("key"); err != nil {
+			t.Fatal(err)
+		}
+		messages := []*db.Message{{Role: "user", Content: "hi"}}
+		_, err := o.CreateChatCompletionStream(context.Background(), "gpt-4o", llm.Params{}, messages)
+		var statusErr *llm.StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != tc.status || statusErr.RetryAfter != 7*time.Second {
+			t.Errorf("expected a status error with %d and the wait of the response, got %#v", tc.status, err)
+		}
+		if kind := llm.Classify(err); kind != tc.expected {
+			t.Errorf("expected %q for %d, got %q", tc.expected, tc.status, kind)
+		}
+		server.Close()
+	}
+}
+
+func TestZeroParams(t *testing.T) {
+	var body map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("could not decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+	o := New(server.URL)
+	if err := o.SetAPIKey("key"); err != nil {
+		t.Fatal(err)
+	}
+
+	zero, half := float32(0), float32(0.5)
+	tests := []struct {
+		model       string
+		params      llm.Params
+		temperature string
+		topP        string
+	}{
+		{model: "gpt-4o", params: llm.Params{Temperature: &zero}, temperature: "0"},
+		{model: "gpt-4o", params: llm.Params{Temperature: &half, TopP: &zero}, temperature: "0.5", topP: "0"},
+		{model: "gpt-4o"},
+		// reasoning models reject sampling parameters
+		{model: "o3-mini", params: llm.Params{Temperature: &zero}},
+	}
+	for _, tt := range tests {
+		answer, err := o.CreateChatCompletion(context.Background(), tt.model, tt.params, []*db.Message{{Role: "user", Content: "hi"}})
+		if err != nil || answer != "ok" {
+			t.Fatalf("CreateChatCompletion: %q, %v", answer, err)
+		}
+		if got := string(body["temperature"]); got != tt.temperature {
+			t.Errorf("%s %+v: expected temperature %q, got %q", tt.model, tt.params, tt.temperature, got)
+		}
+		if got := string(body["top_p"]); got != tt.topP {
+			t.Errorf("%s %+v: expected top_p %q, got %q", tt.model, tt.params, tt.topP, got)
+		}
+		if string(body["model"]) != `"`+tt.model+`"` {
+			t.Errorf("expected the rest of the request to be kept, got %s", body["model"])
+		}
+	}
+}
+
+func TestImagesForTextModels(t *testing.T) {
+	var req client.ChatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = client.ChatCompletionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("could not decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+	o := New(server.URL)
+	if err := o.SetAPIKey("key"); err != nil {
+		t.Fatal(err)
+	}
+	image := &db.MessagePart{Type: db.PartTypeImage, MimeType: "image/png", Path: "cat.png", Data: []byte("png")}
+	messages := []*db.Message{
+		{Role: "user", Content: "what is this", Parts: []*db.MessagePart{image}},
+		{Role: "assistant", Content: "a cat"},
+	}
+	ctx := context.Background()
+
+	if _, err := o.CreateChatCompletion(ctx, "gpt-3.5-turbo", llm.Params{}, messages); !errors.Is(err, llm.ErrImagesNotSupported) {
+		t.Errorf("expected a new image to be rejected, got %v", err)
+	}
+	// the thread can go on with a text model, older images are left out
+	messages = append(messages, &db.Message{Role: "user", Content: "thanks"})
+	if _, err := o.CreateChatCompletion(ctx, "gpt-3.5-turbo", llm.Params{}, messages); err != nil {
+		t.Fatalf("CreateChatCompletion: %v", err)
+	}
+	first := req.Messages[0]
+	if len(first.MultiContent) != 0 || first.Content != "what is this\n\n"+llm.ImagePlaceholder(image) {
+		t.Errorf("expected the image to be replaced by a placeholder, got %+v", first)
+	}
+	if _, err := o.CreateChatCompletion(ctx, "gpt-4o", llm.Params{}, messages); err != nil {
+		t.Fatalf("CreateChatCompletion: %v", err)
+	}
+	if parts := req.Messages[0].MultiContent; len(parts) != 2 || parts[1].ImageURL == nil {
+		t.Errorf("expected the image to be sent to a vision model, got %+v", req.Messages[0])
+	}
+}
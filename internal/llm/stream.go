@@ -0,0 +1,20 @@
+package llm
+
+import (
+	"context"
+	"io"
+
+	"github.com/aavshr/panda/internal/db"
+)
+
+// StreamCreator makes completion streams
+type StreamCreator interface {
+	CreateChatCompletionStream(ctx context.Context, model string, params Params, messages []*db.Message) (io.ReadCloser, error)
+}
+
+// Snapshotter is implemented by backends whose settings may change while a
+// request is made on another goroutine, the snapshot keeps the settings of
+// the time it was taken
+type Snapshotter interface {
+	Snapshot() StreamCreator
+}
@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ToolDefinition describes a tool the model can call, Parameters is the
+// json schema of the arguments
+type ToolDefinition struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+}
+
+// ToolCall is a call of a tool requested by the model, Arguments is the
+// json object the model generated
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolCallStream is implemented by completion streams that can end with
+// tool calls, the calls are complete once the stream returned io.EOF
+type ToolCallStream interface {
+	ToolCalls() []ToolCall
+}
+
+// ParseToolCalls decodes tool calls stored as json, empty is no calls
+func ParseToolCalls(s string) ([]ToolCall, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var calls []ToolCall
+	if err := json.Unmarshal([]byte(s), &calls); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return calls, nil
+}
+
+// EncodeToolCalls encodes tool calls to be stored, empty for no calls
+func EncodeToolCalls(calls []ToolCall) string {
+	if len(calls) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(calls)
+	if err != nil {
+		// a struct of strings always marshals
+		return ""
+	}
+	return string(b)
+}
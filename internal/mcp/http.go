@@ -0,0 +1,217 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	headerSessionID       = "Mcp-Session-Id"
+	headerProtocolVersion = "MCP-Protocol-Version"
+
+	closeTimeout = 5 * time.Second
+)
+
+var ErrSessionExpired = errors.New("session expired")
+
+// httpTransport talks to a server over streamable http, every message is
+// posted to the endpoint and the server answers with json or an event
+// stream
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu              sync.Mutex
+	sessionID       string
+	protocolVersion string
+}
+
+func newHTTPTransport(conf ServerConfig) *httpTransport {
+	return &httpTransport{
+		url:     conf.URL,
+		headers: conf.Headers,
+		client:  &http.Client{},
+	}
+}
+
+func (t *httpTransport) setProtocolVersion(version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.protocolVersion = version
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, method string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequest: %w", err)
+	}
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set(headerSessionID, t.sessionID)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set(headerProtocolVersion, t.protocolVersion)
+	}
+	t.mu.Unlock()
+	return req, nil
+}
+
+func (t *httpTransport) post(ctx context.Context, msg *message) (*http.Response, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, data)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if sessionID := resp.Header.Get(headerSessionID); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		t.mu.Lock()
+		hasSession := t.sessionID != ""
+		t.mu.Unlock()
+		if resp.StatusCode == http.StatusNotFound && hasSession {
+			return nil, ErrSessionExpired
+		}
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, req *message) (*message, error) {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		var msg message
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			return nil, fmt.Errorf("could not decode the response: %w", err)
+		}
+		return &msg, nil
+	}
+
+	var result *message
+	err = readEvents(resp.Body, func(data []byte) bool {
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return true
+		}
+		switch {
+		case msg.isResponse() && bytes.Equal(msg.ID, req.ID):
+			result = &msg
+			return false
+		case msg.isRequest():
+			// answered in the background, the stream stays open until the
+			// response
+			go t.answer(context.WithoutCancel(ctx), answerServerRequest(&msg))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read the event stream: %w", err)
+	}
+	if result == nil {
+		return nil, errors.New("the event stream ended without a response")
+	}
+	return result, nil
+}
+
+func (t *httpTransport) answer(ctx context.Context, msg *message) {
+	resp, err := t.post(ctx, msg)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+func (t *httpTransport) notify(ctx context.Context, n *message) error {
+	resp, err := t.post(ctx, n)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// close ends the session, servers that do not support it answer with 405
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	req, err := t.newRequest(ctx, http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// readEvents calls fn with the data of every server-sent event until fn
+// returns false or the stream ends
+func readEvents(r io.Reader, fn func(data []byte) bool) error {
+	reader := bufio.NewReader(r)
+	var data bytes.Buffer
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if data.Len() > 0 {
+				if !fn(data.Bytes()) {
+					return nil
+				}
+				data.Reset()
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		if err == io.EOF {
+			if data.Len() > 0 {
+				fn(data.Bytes())
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	jsonrpcVersion = "2.0"
+
+	codeMethodNotFound = -32601
+)
+
+// message is a json-rpc request, notification or response, requests have
+// an id and a method, notifications only a method
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+func (m *message) isRequest() bool {
+	return m.Method != "" && len(m.ID) > 0
+}
+
+func (m *message) isResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+// Error is an error returned by the server
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// transport sends messages to a server
+type transport interface {
+	// roundTrip sends a request and waits for the response with its id
+	roundTrip(ctx context.Context, req *message) (*message, error)
+	// notify sends a notification, servers do not answer them
+	notify(ctx context.Context, n *message) error
+	close() error
+}
+
+// answerServerRequest answers requests the server sends to the client,
+// only ping is supported
+func answerServerRequest(req *message) *message {
+	resp := &message{JSONRPC: jsonrpcVersion, ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &Error{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	return resp
+}
@@ -0,0 +1,455 @@
+// Package mcp is a client for model context protocol servers, it lists
+// the tools, resources and prompts of a server and calls them
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aavshr/panda/internal/config"
+)
+
+const (
+	ProtocolVersion = "2025-06-18"
+	clientName      = "panda"
+)
+
+var (
+	// ClientVersion is sent to servers when connecting
+	ClientVersion = "dev"
+
+	ErrInvalidConfig       = errors.New("invalid mcp server config")
+	ErrUnsupportedProtocol = errors.New("unsupported protocol version")
+	ErrToolFailed          = errors.New("tool call failed")
+
+	// supportedVersions are the protocol versions a server can answer with
+	supportedVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+)
+
+// ServerConfig declares a server, either a command that talks over stdin
+// and stdout or the url of a streamable http server
+type ServerConfig struct {
+	Command string
+	Args    []string
+	Env     map[string]string
+	URL     string
+	Headers map[string]string
+	// TimeoutSeconds cancels tool calls, 0 for the default of the tools
+	TimeoutSeconds int
+	Disabled       bool
+}
+
+// NewServerConfig returns the server of the user config
+func NewServerConfig(c config.MCPServerConfig) ServerConfig {
+	return ServerConfig{
+		Command:        c.Command,
+		Args:           c.Args,
+		Env:            c.Env,
+		URL:            c.URL,
+		Headers:        c.Headers,
+		TimeoutSeconds: c.TimeoutSeconds,
+		Disabled:       c.Disabled,
+	}
+}
+
+// NewServerConfigs returns the servers of the user config by their names
+func NewServerConfigs(servers map[string]config.MCPServerConfig) map[string]ServerConfig {
+	configs := make(map[string]ServerConfig, len(servers))
+	for name, server := range servers {
+		configs[name] = NewServerConfig(server)
+	}
+	return configs
+}
+
+func (c ServerConfig) validate() error {
+	if (c.Command == "") == (c.URL == "") {
+		return fmt.Errorf("%w: either command or url has to be set", ErrInvalidConfig)
+	}
+	return nil
+}
+
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// ServerCapabilities are the features of a server, nil if it has none
+type ServerCapabilities struct {
+	Tools     json.RawMessage `json:"tools,omitempty"`
+	Resources json.RawMessage `json:"resources,omitempty"`
+	Prompts   json.RawMessage `json:"prompts,omitempty"`
+}
+
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptMessage is a message of a prompt with its content as text
+type PromptMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Content is a part of a tool result or a prompt message
+type Content struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	MimeType string            `json:"mimeType,omitempty"`
+	URI      string            `json:"uri,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// Client is a connection to a server
+type Client struct {
+	Name         string
+	Config       ServerConfig
+	ServerInfo   Implementation
+	Capabilities ServerCapabilities
+
+	transport transport
+	nextID    atomic.Int64
+}
+
+// Connect starts or connects to the server and initializes the session
+func Connect(ctx context.Context, name string, conf ServerConfig) (*Client, error) {
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+	c := &Client{Name: name, Config: conf}
+	var httpT *httpTransport
+	if conf.Command != "" {
+		t, err := newStdioTransport(conf)
+		if err != nil {
+			return nil, err
+		}
+		c.transport = t
+	} else {
+		httpT = newHTTPTransport(conf)
+		c.transport = httpT
+	}
+
+	var result struct {
+		ProtocolVersion string             `json:"protocolVersion"`
+		Capabilities    ServerCapabilities `json:"capabilities"`
+		ServerInfo      Implementation     `json:"serverInfo"`
+	}
+	err := c.call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      Implementation{Name: clientName, Version: ClientVersion},
+	}, &result)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	if !isSupported(result.ProtocolVersion) {
+		c.Close()
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedProtocol, result.ProtocolVersion)
+	}
+	if httpT != nil {
+		httpT.setProtocolVersion(result.ProtocolVersion)
+	}
+	c.ServerInfo = result.ServerInfo
+	c.Capabilities = result.Capabilities
+	if err := c.transport.notify(ctx, &message{JSONRPC: jsonrpcVersion, Method: "notifications/initialized"}); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("notifications/initialized: %w", err)
+	}
+	return c, nil
+}
+
+// ConnectAll connects to the servers that are not disabled concurrently,
+// the clients are sorted by name and the servers that failed are returned
+// as errors
+func ConnectAll(ctx context.Context, servers map[string]ServerConfig) ([]*Client, []error) {
+	names := make([]string, 0, len(servers))
+	for name, conf := range servers {
+		if !conf.Disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	clients := make([]*Client, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := Connect(ctx, name, servers[name])
+			if err != nil {
+				errs[i] = fmt.Errorf("mcp server %s: %w", name, err)
+				return
+			}
+			clients[i] = client
+		}()
+	}
+	wg.Wait()
+
+	var connected []*Client
+	var failed []error
+	for i := range names {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+		} else {
+			connected = append(connected, clients[i])
+		}
+	}
+	return connected, failed
+}
+
+func isSupported(version string) bool {
+	for _, v := range supportedVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Close ends the session and stops stdio servers
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+// call sends a request and decodes its result into result
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	id, _ := json.Marshal(c.nextID.Add(1))
+	resp, err := c.transport.roundTrip(ctx, &message{
+		JSONRPC: jsonrpcVersion,
+		ID:      id,
+		Method:  method,
+		Params:  data,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			// tells the server to stop working on the request
+			notifyCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), closeTimeout)
+			_ = c.transport.notify(notifyCtx, &message{
+				JSONRPC: jsonrpcVersion,
+				Method:  "notifications/cancelled",
+				Params:  json.RawMessage(fmt.Sprintf(`{"requestId":%s}`, id)),
+			})
+			cancel()
+		}
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("could not decode the result of %s: %w", method, err)
+	}
+	return nil
+}
+
+// paginate calls a list method until the server returns no cursor, page
+// decodes a result and returns its cursor
+func (c *Client) paginate(ctx context.Context, method string, page func(result json.RawMessage) (string, error)) error {
+	cursor := ""
+	for {
+		params := map[string]string{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var result json.RawMessage
+		if err := c.call(ctx, method, params, &result); err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+		next, err := page(result)
+		if err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+		if next == "" || next == cursor {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// ListTools lists the tools of the server, servers without tools have none
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	if c.Capabilities.Tools == nil {
+		return nil, nil
+	}
+	var tools []Tool
+	err := c.paginate(ctx, "tools/list", func(result json.RawMessage) (string, error) {
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		err := json.Unmarshal(result, &page)
+		tools = append(tools, page.Tools...)
+		return page.NextCursor, err
+	})
+	return tools, err
+}
+
+// ListResources lists the resources of the server
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	if c.Capabilities.Resources == nil {
+		return nil, nil
+	}
+	var resources []Resource
+	err := c.paginate(ctx, "resources/list", func(result json.RawMessage) (string, error) {
+		var page struct {
+			Resources  []Resource `json:"resources"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		err := json.Unmarshal(result, &page)
+		resources = append(resources, page.Resources...)
+		return page.NextCursor, err
+	})
+	return resources, err
+}
+
+// ListPrompts lists the prompts of the server
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	if c.Capabilities.Prompts == nil {
+		return nil, nil
+	}
+	var prompts []Prompt
+	err := c.paginate(ctx, "prompts/list", func(result json.RawMessage) (string, error) {
+		var page struct {
+			Prompts    []Prompt `json:"prompts"`
+			NextCursor string   `json:"nextCursor"`
+		}
+		err := json.Unmarshal(result, &page)
+		prompts = append(prompts, page.Prompts...)
+		return page.NextCursor, err
+	})
+	return prompts, err
+}
+
+// CallTool calls a tool and returns its content as text, a result the
+// server marks as an error is returned as ErrToolFailed
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	var result struct {
+		Content []Content `json:"content"`
+		IsError bool      `json:"isError"`
+	}
+	err := c.call(ctx, "tools/call", map[string]interface{}{
+		"name":      name,
+		"arguments": args,
+	}, &result)
+	if err != nil {
+		return "", fmt.Errorf("tools/call: %w", err)
+	}
+	text := contentText(result.Content)
+	if result.IsError {
+		return "", fmt.Errorf("%w: %s", ErrToolFailed, text)
+	}
+	return text, nil
+}
+
+// ReadResource reads a resource and returns its text contents, binary
+// contents are only described
+func (c *Client) ReadResource(ctx context.Context, uri string) (string, error) {
+	var result struct {
+		Contents []ResourceContents `json:"contents"`
+	}
+	if err := c.call(ctx, "resources/read", map[string]string{"uri": uri}, &result); err != nil {
+		return "", fmt.Errorf("resources/read: %w", err)
+	}
+	parts := make([]string, 0, len(result.Contents))
+	for _, contents := range result.Contents {
+		parts = append(parts, contents.text())
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// GetPrompt returns the messages of a prompt filled with the arguments
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) ([]PromptMessage, error) {
+	var result struct {
+		Messages []struct {
+			Role    string  `json:"role"`
+			Content Content `json:"content"`
+		} `json:"messages"`
+	}
+	if args == nil {
+		args = map[string]string{}
+	}
+	err := c.call(ctx, "prompts/get", map[string]interface{}{
+		"name":      name,
+		"arguments": args,
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("prompts/get: %w", err)
+	}
+	messages := make([]PromptMessage, 0, len(result.Messages))
+	for _, m := range result.Messages {
+		messages = append(messages, PromptMessage{Role: m.Role, Content: contentText([]Content{m.Content})})
+	}
+	return messages, nil
+}
+
+// contentText joins the text of the content parts, other parts are noted
+func contentText(content []Content) string {
+	parts := make([]string, 0, len(content))
+	for _, c := range content {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "resource":
+			if c.Resource != nil {
+				parts = append(parts, c.Resource.text())
+			}
+		case "resource_link":
+			parts = append(parts, fmt.Sprintf("[resource: %s]", c.URI))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s: %s]", c.Type, c.MimeType))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (r ResourceContents) text() string {
+	if r.Blob != "" {
+		return fmt.Sprintf("[binary resource %s: %s]", r.URI, r.MimeType)
+	}
+	return r.Text
+}
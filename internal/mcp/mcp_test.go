@@ -0,0 +1,280 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aavshr/panda/internal/llm"
+	"github.com/aavshr/panda/internal/tools"
+)
+
+// fixtureEnv makes the test binary run the fixture server instead of the
+// tests, the stdio tests start the binary itself as the server
+const fixtureEnv = "PANDA_MCP_FIXTURE_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fixtureEnv) == "1" {
+		serveStdio(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// handleFixture answers a request of the client, notifications get no
+// answer
+func handleFixture(req *message) *message {
+	if !req.isRequest() {
+		return nil
+	}
+	var params struct {
+		Cursor    string            `json:"cursor"`
+		Name      string            `json:"name"`
+		URI       string            `json:"uri"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	_ = json.Unmarshal(req.Params, &params)
+	var result interface{}
+	switch req.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}, "resources": map[string]interface{}{}, "prompts": map[string]interface{}{}},
+			"serverInfo":      Implementation{Name: "fixture", Version: "1.0.0"},
+		}
+	case "tools/list":
+		// the tools are split in two pages
+		if params.Cursor == "" {
+			result = map[string]interface{}{
+				"tools":      []Tool{{Name: "echo", Description: "echo the text", InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`)}},
+				"nextCursor": "2",
+			}
+		} else {
+			result = map[string]interface{}{"tools": []Tool{{Name: "fail"}}}
+		}
+	case "tools/call":
+		switch params.Name {
+		case "echo":
+			result = map[string]interface{}{"content": []Content{{Type: "text", Text: params.Arguments["text"]}}}
+		case "fail":
+			result = map[string]interface{}{"content": []Content{{Type: "text", Text: "it failed"}}, "isError": true}
+		default:
+			return &message{JSONRPC: jsonrpcVersion, ID: req.ID, Error: &Error{Code: -32602, Message: "unknown tool " + params.Name}}
+		}
+	case "resources/list":
+		result = map[string]interface{}{"resources": []Resource{{URI: "file:///notes.txt", Name: "notes"}}}
+	case "resources/read":
+		result = map[string]interface{}{"contents": []ResourceContents{{URI: params.URI, Text: "the notes"}}}
+	case "prompts/list":
+		result = map[string]interface{}{"prompts": []Prompt{{Name: "review", Arguments: []PromptArgument{{Name: "lang", Required: true}}}}}
+	case "prompts/get":
+		result = map[string]interface{}{"messages": []map[string]interface{}{
+			{"role": "user", "content": Content{Type: "text", Text: "review this " + params.Arguments["lang"] + " code"}},
+		}}
+	default:
+		return &message{JSONRPC: jsonrpcVersion, ID: req.ID, Error: &Error{Code: codeMethodNotFound, Message: "method not found"}}
+	}
+	data, _ := json.Marshal(result)
+	return &message{JSONRPC: jsonrpcVersion, ID: req.ID, Result: data}
+}
+
+// serveStdio runs the fixture over newline delimited json, it pings the
+// client before answering tool calls
+func serveStdio(r io.Reader, w io.Writer) {
+	scanner := bufio.NewScanner(r)
+	enc := json.NewEncoder(w)
+	for scanner.Scan() {
+		var req message
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			continue
+		}
+		if req.Method == "tools/call" {
+			_ = enc.Encode(&message{JSONRPC: jsonrpcVersion, ID: json.RawMessage(`"ping-1"`), Method: "ping"})
+		}
+		if resp := handleFixture(&req); resp != nil {
+			_ = enc.Encode(resp)
+		}
+	}
+}
+
+// newHTTPFixture serves the fixture over streamable http, tool calls are
+// answered with an event stream and the others with json
+func newHTTPFixture() *httptest.Server {
+	const sessionID = "session-1"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var req message
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Method == "initialize" {
+			w.Header().Set(headerSessionID, sessionID)
+		} else if r.Header.Get(headerSessionID) != sessionID || r.Header.Get(headerProtocolVersion) != ProtocolVersion {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+		resp := handleFixture(&req)
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		data, _ := json.Marshal(resp)
+		if req.Method == "tools/call" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\",\"params\":{}}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+}
+
+func testClient(t *testing.T, c *Client) {
+	t.Helper()
+	ctx := context.Background()
+	if c.ServerInfo.Name != "fixture" {
+		t.Errorf("unexpected server info %+v", c.ServerInfo)
+	}
+
+	serverTools, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools: %v", err)
+	}
+	if len(serverTools) != 2 || serverTools[0].Name != "echo" || serverTools[1].Name != "fail" {
+		t.Errorf("expected both pages of tools, got %+v", serverTools)
+	}
+	if out, err := c.CallTool(ctx, "echo", json.RawMessage(`{"text":"hello"}`)); err != nil || out != "hello" {
+		t.Errorf("CallTool: unexpected %q (%v)", out, err)
+	}
+	if _, err := c.CallTool(ctx, "fail", nil); !errors.Is(err, ErrToolFailed) || !strings.Contains(err.Error(), "it failed") {
+		t.Errorf("expected ErrToolFailed, got %v", err)
+	}
+	var rpcErr *Error
+	if _, err := c.CallTool(ctx, "missing", nil); !errors.As(err, &rpcErr) {
+		t.Errorf("expected a server error, got %v", err)
+	}
+
+	resources, err := c.ListResources(ctx)
+	if err != nil || len(resources) != 1 {
+		t.Fatalf("ListResources: unexpected %+v (%v)", resources, err)
+	}
+	if text, err := c.ReadResource(ctx, resources[0].URI); err != nil || text != "the notes" {
+		t.Errorf("ReadResource: unexpected %q (%v)", text, err)
+	}
+	prompts, err := c.ListPrompts(ctx)
+	if err != nil || len(prompts) != 1 || prompts[0].Name != "review" {
+		t.Fatalf("ListPrompts: unexpected %+v (%v)", prompts, err)
+	}
+	messages, err := c.GetPrompt(ctx, "review", map[string]string{"lang": "go"})
+	if err != nil || len(messages) != 1 || messages[0].Content != "review this go code" {
+		t.Errorf("GetPrompt: unexpected %+v (%v)", messages, err)
+	}
+
+	// the tools are routed back to the server through the registry
+	registryTools, err := c.RegistryTools(ctx)
+	if err != nil {
+		t.Fatalf("RegistryTools: %v", err)
+	}
+	registry := tools.NewRegistry()
+	for _, tool := range registryTools {
+		if err := registry.Register(tool); err != nil {
+			t.Fatal(err)
+		}
+	}
+	definitions := registry.Definitions()
+	if len(definitions) != 3 || definitions[0].Name != "fixture__echo" || definitions[2].Name != "fixture__read_resource" {
+		t.Errorf("unexpected definitions %+v", definitions)
+	}
+	run := func(name, args string) (string, error) {
+		return registry.Run(ctx, llm.ToolCall{Name: name, Arguments: args})
+	}
+	if out, err := run("fixture__echo", `{"text":"routed"}`); err != nil || out != "routed" {
+		t.Errorf("fixture__echo: unexpected %q (%v)", out, err)
+	}
+	if out, err := run("fixture__read_resource", `{"uri":"file:///notes.txt"}`); err != nil || out != "the notes" {
+		t.Errorf("fixture__read_resource: unexpected %q (%v)", out, err)
+	}
+}
+
+func TestStdio(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := Connect(ctx, "fixture", ServerConfig{
+		Command: executable,
+		Env:     map[string]string{fixtureEnv: "1"},
+	})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	testClient(t, c)
+	if err := c.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	// calls fail once the server exited
+	if _, err := c.ListTools(ctx); err == nil {
+		t.Error("expected an error after the server exited")
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	server := newHTTPFixture()
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := Connect(ctx, "fixture", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	testClient(t, c)
+	if err := c.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestConnectAll(t *testing.T) {
+	server := newHTTPFixture()
+	defer server.Close()
+	clients, errs := ConnectAll(context.Background(), map[string]ServerConfig{
+		"b":        {URL: server.URL},
+		"a":        {URL: server.URL},
+		"disabled": {URL: server.URL, Disabled: true},
+		"invalid":  {},
+		"missing":  {Command: "panda-mcp-server-that-does-not-exist"},
+	})
+	if len(clients) != 2 || clients[0].Name != "a" || clients[1].Name != "b" {
+		t.Errorf("expected a and b to connect, got %+v", clients)
+	}
+	if len(errs) != 2 || !errors.Is(errs[0], ErrInvalidConfig) {
+		t.Errorf("expected the invalid and missing servers to fail, got %v", errs)
+	}
+	for _, c := range clients {
+		c.Close()
+	}
+}
+
+func TestToolName(t *testing.T) {
+	if name := ToolName("my.server", "get/item"); name != "my_server__get_item" {
+		t.Errorf("unexpected name %q", name)
+	}
+	if name := ToolName(strings.Repeat("s", 40), strings.Repeat("t", 40)); len(name) != maxToolNameLen {
+		t.Errorf("expected the name to be cut to %d, got %d", maxToolNameLen, len(name))
+	}
+}
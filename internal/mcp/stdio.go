@@ -0,0 +1,212 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxLineSize is the largest message read from a stdio server
+	maxLineSize = 16 << 20
+	// stderrTailSize is how much of the stderr of a server is kept for
+	// errors when it exits
+	stderrTailSize = 2 << 10
+	// exitTimeout is how long a server has to exit after its stdin was
+	// closed before it is killed
+	exitTimeout = 2 * time.Second
+)
+
+// stdioTransport talks to a server started as a subprocess with newline
+// delimited messages on its stdin and stdout
+type stdioTransport struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *tailBuffer
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan *message
+	// err is why the server stopped, set before done is closed
+	err  error
+	done chan struct{}
+}
+
+func newStdioTransport(conf ServerConfig) (*stdioTransport, error) {
+	cmd := exec.Command(conf.Command, conf.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range conf.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("cmd.StdinPipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("cmd.StdoutPipe: %w", err)
+	}
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  &tailBuffer{max: stderrTailSize},
+		pending: make(map[string]chan *message),
+		done:    make(chan struct{}),
+	}
+	cmd.Stderr = t.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start %s: %w", conf.Command, err)
+	}
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			// servers are not supposed to write anything else to stdout,
+			// the line is skipped instead of failing all calls
+			continue
+		}
+		switch {
+		case msg.isRequest():
+			// the answer is sent from another goroutine to not block
+			// reading if the server does not read its stdin
+			go func() { _ = t.write(answerServerRequest(&msg)) }()
+		case msg.isResponse():
+			t.mu.Lock()
+			ch, ok := t.pending[string(msg.ID)]
+			delete(t.pending, string(msg.ID))
+			t.mu.Unlock()
+			if ok {
+				ch <- &msg
+			}
+		}
+	}
+	readErr := scanner.Err()
+	waitErr := t.cmd.Wait()
+
+	err := errors.New("server exited")
+	switch {
+	case readErr != nil:
+		err = fmt.Errorf("could not read from the server: %w", readErr)
+	case waitErr != nil:
+		err = fmt.Errorf("server exited: %w", waitErr)
+	}
+	if tail := strings.TrimSpace(t.stderr.String()); tail != "" {
+		err = fmt.Errorf("%w: %s", err, tail)
+	}
+	t.mu.Lock()
+	t.err = err
+	t.mu.Unlock()
+	close(t.done)
+}
+
+func (t *stdioTransport) write(msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("could not write to the server: %w", err)
+	}
+	return nil
+}
+
+func (t *stdioTransport) stopped() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, req *message) (*message, error) {
+	// the response can arrive before write returns
+	ch := make(chan *message, 1)
+	t.mu.Lock()
+	t.pending[string(req.ID)] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, string(req.ID))
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(req); err != nil {
+		select {
+		case <-t.done:
+			return nil, t.stopped()
+		default:
+			return nil, err
+		}
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-t.done:
+		return nil, t.stopped()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(ctx context.Context, n *message) error {
+	return t.write(n)
+}
+
+// close closes the stdin of the server and kills it if it does not exit
+func (t *stdioTransport) close() error {
+	_ = t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(exitTimeout):
+		_ = t.cmd.Process.Kill()
+		// children of the server can keep stdout open, they are not
+		// waited for
+		select {
+		case <-t.done:
+		case <-time.After(exitTimeout):
+		}
+	}
+	return nil
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aavshr/panda/internal/tools"
+)
+
+const (
+	// toolSeparator joins the server and tool names, tool names of the
+	// model can not contain dots or slashes
+	toolSeparator    = "__"
+	maxToolNameLen   = 64
+	readResourceName = "read_resource"
+	// maxListedResources is how many resources are named in the
+	// description of the read_resource tool
+	maxListedResources = 50
+)
+
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// ToolName is the name a tool of a server is offered to the model as
+func ToolName(server, tool string) string {
+	name := invalidToolNameChars.ReplaceAllString(server+toolSeparator+tool, "_")
+	if len(name) > maxToolNameLen {
+		name = name[:maxToolNameLen]
+	}
+	return name
+}
+
+// RegistryTools returns the tools of the server for the tools registry,
+// calls are routed to the server, resources are read with an extra
+// read_resource tool
+func (c *Client) RegistryTools(ctx context.Context) ([]*tools.Tool, error) {
+	serverTools, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := c.ListResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(c.Config.TimeoutSeconds) * time.Second
+
+	registryTools := make([]*tools.Tool, 0, len(serverTools)+1)
+	for _, serverTool := range serverTools {
+		name := serverTool.Name
+		parameters := serverTool.InputSchema
+		if len(parameters) == 0 {
+			parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		registryTools = append(registryTools, &tools.Tool{
+			Name:        ToolName(c.Name, name),
+			Description: strings.TrimSpace(fmt.Sprintf("%s (from the %s mcp server)", serverTool.Description, c.Name)),
+			Parameters:  parameters,
+			Run: func(ctx context.Context, args json.RawMessage) (string, error) {
+				return c.CallTool(ctx, name, args)
+			},
+			Timeout: timeout,
+		})
+	}
+	if len(resources) > 0 {
+		registryTools = append(registryTools, c.readResourceTool(resources, timeout))
+	}
+	return registryTools, nil
+}
+
+func (c *Client) readResourceTool(resources []Resource, timeout time.Duration) *tools.Tool {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Read a resource of the %s mcp server by its uri. Resources:", c.Name)
+	for i, resource := range resources {
+		if i == maxListedResources {
+			fmt.Fprintf(&b, "\n- and %d more", len(resources)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\n- %s: %s", resource.URI, resource.Name)
+		if resource.Description != "" {
+			fmt.Fprintf(&b, ", %s", resource.Description)
+		}
+	}
+	return &tools.Tool{
+		Name:        ToolName(c.Name, readResourceName),
+		Description: b.String(),
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {"uri": {"type": "string", "description": "the uri of the resource"}},
+			"required": ["uri"]
+		}`),
+		Run: func(ctx context.Context, args json.RawMessage) (string, error) {
+			var a struct {
+				URI string `json:"uri"`
+			}
+			if err := json.Unmarshal(args, &a); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			return c.ReadResource(ctx, a.URI)
+		},
+		Timeout: timeout,
+	}
+}
@@ -0,0 +1,218 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+const (
+	fileVersion = 1
+	saltSize    = 16
+	keySize     = 32
+
+	// PassphraseEnv can be set to not get prompted for the passphrase
+	PassphraseEnv = "PANDA_SECRETS_PASSPHRASE"
+)
+
+var (
+	ErrSecretNotFound  = errors.New("secret not found")
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted secrets file")
+	ErrNoTerminal      = errors.New("no terminal to prompt for the passphrase")
+
+	// scrypt parameters, variables so tests can lower them
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	passphraseMu     sync.Mutex
+	cachedPassphrase string
+)
+
+// encryptedFile is the format of the secrets file on disk, the secrets are a
+// json object of names to values encrypted with AES-GCM using a key derived
+// from the passphrase with scrypt
+type encryptedFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+type File struct {
+	path string
+}
+
+func Open(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Path() string {
+	return f.path
+}
+
+// Get decrypts the secrets file and returns the secret with name
+func (f *File) Get(name, passphrase string) (string, error) {
+	secrets, err := f.read(passphrase)
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	return value, nil
+}
+
+// Set adds or replaces the secret with name, a new file is encrypted with
+// passphrase and an existing file must be decryptable with it
+func (f *File) Set(name, value, passphrase string) error {
+	secrets, err := f.read(passphrase)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if secrets == nil {
+		secrets = make(map[string]string)
+	}
+	secrets[name] = value
+	return f.write(secrets, passphrase)
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("scrypt.Key: %w", err)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes.NewCipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cipher.NewGCM: %w", err)
+	}
+	return gcm, nil
+}
+
+func (f *File) read(passphrase string) (map[string]string, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var file encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("could not parse secrets file, json.Unmarshal: %w", err)
+	}
+	if file.Version != fileVersion {
+		return nil, fmt.Errorf("unsupported secrets file version %d", file.Version)
+	}
+	key, err := deriveKey(passphrase, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("could not parse secrets, json.Unmarshal: %w", err)
+	}
+	return secrets, nil
+}
+
+func (f *File) write(secrets map[string]string, passphrase string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	file := encryptedFile{
+		Version: fileVersion,
+		Salt:    make([]byte, saltSize),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return fmt.Errorf("rand.Read: %w", err)
+	}
+	key, err := deriveKey(passphrase, file.Salt)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return fmt.Errorf("rand.Read: %w", err)
+	}
+	file.Data = gcm.Seal(nil, file.Nonce, plaintext, nil)
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	// write to a temporary file first so a failed write can not lose secrets
+	tmpPath := f.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	if err := os.Rename(tmpPath, f.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+	return nil
+}
+
+// SetPassphrase caches the passphrase for the rest of the process
+func SetPassphrase(passphrase string) {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	cachedPassphrase = passphrase
+}
+
+// Passphrase returns the passphrase of the secrets file from the cache,
+// the environment or by prompting on the terminal
+func Passphrase() (string, error) {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	if cachedPassphrase != "" {
+		return cachedPassphrase, nil
+	}
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		cachedPassphrase = passphrase
+		return passphrase, nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", ErrNoTerminal
+	}
+	defer tty.Close()
+	fmt.Fprint(tty, "panda secrets passphrase: ")
+	passphrase, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	if err != nil {
+		return "", fmt.Errorf("term.ReadPassword: %w", err)
+	}
+	cachedPassphrase = string(passphrase)
+	return cachedPassphrase, nil
+}
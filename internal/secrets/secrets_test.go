@@ -0,0 +1,57 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	// keep the test fast
+	scryptN = 1 << 10
+
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	f := Open(path)
+	if _, err := f.Get("key", "passphrase"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+	if err := f.Set("key", "sk-secret", "passphrase"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := f.Set("other", "sk-other", "passphrase"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("os.Stat: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	for _, s := range []string{"sk-secret", "sk-other"} {
+		if bytes.Contains(data, []byte(s)) {
+			t.Errorf("secrets file contains %q in plaintext", s)
+		}
+	}
+
+	value, err := f.Get("key", "passphrase")
+	if err != nil || value != "sk-secret" {
+		t.Errorf("expected sk-secret, got %q, %v", value, err)
+	}
+	if _, err := f.Get("missing", "passphrase"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("expected ErrSecretNotFound, got %v", err)
+	}
+	if _, err := f.Get("key", "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if err := f.Set("key", "sk-new", "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase when setting with a wrong passphrase, got %v", err)
+	}
+}
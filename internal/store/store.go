@@ -0,0 +1,24 @@
+// Package store declares the store of threads and messages that the ui and
+// its tests use, db.Store implements it
+package store
+
+import "github.com/aavshr/panda/internal/db"
+
+type Store interface {
+	ListLatestThreadsPaginated(offset, limit int) ([]*db.Thread, error)
+	GetThread(threadID string) (*db.Thread, error)
+	SearchThreadNamesPaginated(term string, offset, limit int) ([]*db.Thread, error)
+	SearchMessageContentPaginated(term string, offset, limit int) ([]*db.Message, error)
+	ListMessagesByThreadIDPaginated(threadID string, offset, limit int) ([]*db.Message, error)
+	UpsertThread(thread *db.Thread) error
+	UpdateThreadName(threadID, name string) error
+	UpdateThreadParams(threadID, params string) error
+	UpdateThreadModel(threadID, model string) error
+	DeleteThread(threadID string) error
+	DeleteAllThreads() error
+	CreateMessage(message *db.Message) error
+	DeleteMessages(messageIDs []string) error
+	DeleteMessagesByThreadID(threadID string) error
+}
+
+var _ Store = (*db.Store)(nil)
@@ -0,0 +1,379 @@
+// Package storetest checks that implementations of store.Store behave the
+// same, the suite runs against db.Store and the mock of the ui
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/store"
+)
+
+// Run runs the suite, newStore returns an empty store for every test
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, s store.Store)
+	}{
+		{"Pagination", testPagination},
+		{"Upsert", testUpsert},
+		{"Updates", testUpdates},
+		{"CreateMessage", testCreateMessage},
+		{"SameTimestamp", testSameTimestamp},
+		{"DeleteThread", testDeleteThread},
+		{"DeleteAllThreads", testDeleteAllThreads},
+		{"DeleteMessages", testDeleteMessages},
+		{"Search", testSearch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newStore(t))
+		})
+	}
+}
+
+// seed stores threads 1 to n created a day apart with two messages each,
+// thread n is the newest
+func seed(t *testing.T, s store.Store, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		thread := &db.Thread{
+			ID:        fmt.Sprint(i),
+			Name:      fmt.Sprintf("thread %d", i),
+			CreatedAt: fmt.Sprintf("2024-01-%02d 10:00:00", i),
+			UpdatedAt: fmt.Sprintf("2024-01-%02d 10:00:00", i),
+		}
+		if err := s.UpsertThread(thread); err != nil {
+			t.Fatalf("UpsertThread: %v", err)
+		}
+		for j, role := range []string{"user", "assistant"} {
+			message := &db.Message{
+				ID:        fmt.Sprintf("%d-%d", i, j),
+				ThreadID:  thread.ID,
+				Role:      role,
+				Content:   fmt.Sprintf("message %d of thread %d", j, i),
+				CreatedAt: fmt.Sprintf("2024-01-%02d 10:00:%02d", i, j),
+			}
+			if err := s.CreateMessage(message); err != nil {
+				t.Fatalf("CreateMessage: %v", err)
+			}
+		}
+	}
+}
+
+func threadIDs(threads []*db.Thread) []string {
+	ids := make([]string, len(threads))
+	for i, thread := range threads {
+		ids[i] = thread.ID
+	}
+	return ids
+}
+
+func messageIDs(messages []*db.Message) []string {
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+	return ids
+}
+
+func listMessageIDs(t *testing.T, s store.Store, threadID string) []string {
+	t.Helper()
+	messages, err := s.ListMessagesByThreadIDPaginated(threadID, 0, -1)
+	if err != nil {
+		t.Fatalf("ListMessagesByThreadIDPaginated: %v", err)
+	}
+	return messageIDs(messages)
+}
+
+func testPagination(t *testing.T, s store.Store) {
+	seed(t, s, 5)
+	for _, tc := range []struct {
+		offset, limit int
+		expected      []string
+	}{
+		{0, 2, []string{"5", "4"}},
+		{2, 2, []string{"3", "2"}},
+		{4, 2, []string{"1"}},
+		{5, 2, []string{}},
+		{1, -1, []string{"4", "3", "2", "1"}},
+	} {
+		threads, err := s.ListLatestThreadsPaginated(tc.offset, tc.limit)
+		if err != nil {
+			t.Fatalf("ListLatestThreadsPaginated: %v", err)
+		}
+		if ids := threadIDs(threads); !slices.Equal(ids, tc.expected) {
+			t.Errorf("expected threads %v at offset %d with limit %d newest first, got %v", tc.expected, tc.offset, tc.limit, ids)
+		}
+	}
+
+	// a message created earlier is listed first regardless of the order
+	// messages are stored in
+	early := &db.Message{ID: "3-early", ThreadID: "3", Role: "user", Content: "first", CreatedAt: "2024-01-03 09:00:00"}
+	if err := s.CreateMessage(early); err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+	messages, err := s.ListMessagesByThreadIDPaginated("3", 1, 2)
+	if err != nil {
+		t.Fatalf("ListMessagesByThreadIDPaginated: %v", err)
+	}
+	if ids := messageIDs(messages); !slices.Equal(ids, []string{"3-0", "3-1"}) {
+		t.Errorf("expected the messages oldest first, got %v", ids)
+	}
+	if ids := listMessageIDs(t, s, "unknown"); len(ids) != 0 {
+		t.Errorf("expected no messages of an unknown thread, got %v", ids)
+	}
+}
+
+func testUpsert(t *testing.T, s store.Store) {
+	thread := &db.Thread{ID: "1", Name: "draft", CreatedAt: "2024-01-01 10:00:00", UpdatedAt: "2024-01-01 10:00:00", Params: `{"temperature":1}`, Model: "gpt-4o"}
+	if err := s.UpsertThread(thread); err != nil {
+		t.Fatalf("UpsertThread: %v", err)
+	}
+	// the stored thread is not changed through the value that was passed
+	thread.Name = "changed"
+	updated := &db.Thread{ID: "1", Name: "final", CreatedAt: "2024-01-01 10:00:00", UpdatedAt: "2024-01-02 10:00:00", Metadata: `{"source":"test"}`}
+	if err := s.UpsertThread(updated); err != nil {
+		t.Fatalf("UpsertThread: %v", err)
+	}
+
+	threads, err := s.ListLatestThreadsPaginated(0, 10)
+	if err != nil {
+		t.Fatalf("ListLatestThreadsPaginated: %v", err)
+	}
+	if len(threads) != 1 {
+		t.Fatalf("expected the thread once, got %v", threadIDs(threads))
+	}
+	got := threads[0]
+	if got.Name != "final" || got.UpdatedAt != "2024-01-02 10:00:00" || got.Metadata != `{"source":"test"}` {
+		t.Errorf("expected the name, update time and metadata to be updated, got %+v", got)
+	}
+	if got.Params != `{"temperature":1}` || got.Model != "gpt-4o" {
+		t.Errorf("expected the params and model to be kept, got %+v", got)
+	}
+}
+
+func testUpdates(t *testing.T, s store.Store) {
+	seed(t, s, 1)
+	if err := s.UpdateThreadName("1", "renamed"); err != nil {
+		t.Fatalf("UpdateThreadName: %v", err)
+	}
+	if err := s.UpdateThreadParams("1", `{"max_tokens":10}`); err != nil {
+		t.Fatalf("UpdateThreadParams: %v", err)
+	}
+	if err := s.UpdateThreadModel("1", "o3-mini"); err != nil {
+		t.Fatalf("UpdateThreadModel: %v", err)
+	}
+	thread, err := s.GetThread("1")
+	if err != nil {
+		t.Fatalf("GetThread: %v", err)
+	}
+	if thread.Name != "renamed" || thread.Params != `{"max_tokens":10}` || thread.Model != "o3-mini" {
+		t.Errorf("expected the thread to be updated, got %+v", thread)
+	}
+
+	if _, err := s.GetThread("unknown"); !errors.Is(err, db.ErrThreadNotFound) {
+		t.Errorf("GetThread: expected ErrThreadNotFound, got %v", err)
+	}
+	if err := s.UpdateThreadName("unknown", "name"); !errors.Is(err, db.ErrThreadNotFound) {
+		t.Errorf("UpdateThreadName: expected ErrThreadNotFound, got %v", err)
+	}
+	if err := s.UpdateThreadParams("unknown", "{}"); !errors.Is(err, db.ErrThreadNotFound) {
+		t.Errorf("UpdateThreadParams: expected ErrThreadNotFound, got %v", err)
+	}
+	if err := s.UpdateThreadModel("unknown", "gpt-4o"); !errors.Is(err, db.ErrThreadNotFound) {
+		t.Errorf("UpdateThreadModel: expected ErrThreadNotFound, got %v", err)
+	}
+}
+
+func testCreateMessage(t *testing.T, s store.Store) {
+	seed(t, s, 1)
+	message := &db.Message{ThreadID: "1", Role: "user", Content: "no id", CreatedAt: "2024-01-01 11:00:00"}
+	if err := s.CreateMessage(message); err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+	if message.ID == "" {
+		t.Error("expected an id to be generated")
+	}
+	thread, err := s.GetThread("1")
+	if err != nil {
+		t.Fatalf("GetThread: %v", err)
+	}
+	if thread.UpdatedAt <= "2024-01-01 10:00:00" {
+		t.Errorf("expected the thread to be marked as updated, got %s", thread.UpdatedAt)
+	}
+
+	duplicate := &db.Message{ID: message.ID, ThreadID: "1", Role: "user", Content: "again", CreatedAt: "2024-01-01 12:00:00"}
+	if err := s.CreateMessage(duplicate); !errors.Is(err, db.ErrMessageExists) {
+		t.Errorf("expected ErrMessageExists, got %v", err)
+	}
+	orphan := &db.Message{ID: "orphan", ThreadID: "unknown", Role: "user", Content: "lost", CreatedAt: "2024-01-01 12:00:00"}
+	if err := s.CreateMessage(orphan); !errors.Is(err, db.ErrThreadNotFound) {
+		t.Errorf("expected ErrThreadNotFound, got %v", err)
+	}
+	if ids := listMessageIDs(t, s, "1"); !slices.Equal(ids, []string{"1-0", "1-1", message.ID}) {
+		t.Errorf("expected only the new message to be stored, got %v", ids)
+	}
+}
+
+func testDeleteThread(t *testing.T, s store.Store) {
+	seed(t, s, 2)
+	if err := s.DeleteThread("1"); err != nil {
+		t.Fatalf("DeleteThread: %v", err)
+	}
+	if _, err := s.GetThread("1"); !errors.Is(err, db.ErrThreadNotFound) {
+		t.Errorf("expected the thread to be deleted, got %v", err)
+	}
+	if ids := listMessageIDs(t, s, "1"); len(ids) != 0 {
+		t.Errorf("expected the messages to be deleted with the thread, got %v", ids)
+	}
+	if ids := listMessageIDs(t, s, "2"); len(ids) != 2 {
+		t.Errorf("expected the other thread to keep its messages, got %v", ids)
+	}
+	messages, err := s.SearchMessageContentPaginated("message", 0, 10)
+	if err != nil {
+		t.Fatalf("SearchMessageContentPaginated: %v", err)
+	}
+	for _, message := range messages {
+		if message.ThreadID == "1" {
+			t.Errorf("expected the deleted messages not to be found, got %s", message.ID)
+		}
+	}
+	if err := s.DeleteThread("unknown"); err != nil {
+		t.Errorf("expected deleting an unknown thread to do nothing, got %v", err)
+	}
+}
+
+func testDeleteAllThreads(t *testing.T, s store.Store) {
+	seed(t, s, 2)
+	if err := s.DeleteAllThreads(); err != nil {
+		t.Fatalf("DeleteAllThreads: %v", err)
+	}
+	threads, err := s.ListLatestThreadsPaginated(0, 10)
+	if err != nil {
+		t.Fatalf("ListLatestThreadsPaginated: %v", err)
+	}
+	if len(threads) != 0 {
+		t.Errorf("expected no threads, got %v", threadIDs(threads))
+	}
+	for _, threadID := range []string{"1", "2"} {
+		if ids := listMessageIDs(t, s, threadID); len(ids) != 0 {
+			t.Errorf("expected the messages of thread %s to be deleted, got %v", threadID, ids)
+		}
+	}
+
+	// the ids can be used again
+	seed(t, s, 1)
+	if ids := listMessageIDs(t, s, "1"); len(ids) != 2 {
+		t.Errorf("expected the thread to be created again, got %v", ids)
+	}
+}
+
+func testDeleteMessages(t *testing.T, s store.Store) {
+	seed(t, s, 2)
+	if err := s.DeleteMessages([]string{"1-1", "2-0", "unknown"}); err != nil {
+		t.Fatalf("DeleteMessages: %v", err)
+	}
+	if ids := listMessageIDs(t, s, "1"); !slices.Equal(ids, []string{"1-0"}) {
+		t.Errorf("expected the first message of thread 1 to be kept, got %v", ids)
+	}
+	if ids := listMessageIDs(t, s, "2"); !slices.Equal(ids, []string{"2-1"}) {
+		t.Errorf("expected the second message of thread 2 to be kept, got %v", ids)
+	}
+
+	if err := s.DeleteMessagesByThreadID("2"); err != nil {
+		t.Fatalf("DeleteMessagesByThreadID: %v", err)
+	}
+	if ids := listMessageIDs(t, s, "2"); len(ids) != 0 {
+		t.Errorf("expected the messages of thread 2 to be deleted, got %v", ids)
+	}
+	if _, err := s.GetThread("2"); err != nil {
+		t.Errorf("expected the thread to be kept, got %v", err)
+	}
+	// deleted messages can be created again
+	message := &db.Message{ID: "2-1", ThreadID: "2", Role: "user", Content: "again", CreatedAt: "2024-01-02 11:00:00"}
+	if err := s.CreateMessage(message); err != nil {
+		t.Errorf("CreateMessage: %v", err)
+	}
+}
+
+func testSearch(t *testing.T, s store.Store) {
+	seed(t, s, 3)
+	for _, message := range []*db.Message{
+		{ID: "cat", ThreadID: "1", Role: "user", Content: "the cat sat on the mat", CreatedAt: "2024-01-01 11:00:00"},
+		{ID: "dog", ThreadID: "3", Role: "user", Content: "The dog sat on the cat", CreatedAt: "2024-01-03 11:00:00"},
+	} {
+		if err := s.CreateMessage(message); err != nil {
+			t.Fatalf("CreateMessage: %v", err)
+		}
+	}
+	if err := s.UpdateThreadName("2", "Cats and dogs"); err != nil {
+		t.Fatalf("UpdateThreadName: %v", err)
+	}
+
+	// the order of the matches is up to the implementation
+	for _, tc := range []struct {
+		term     string
+		expected []string
+	}{
+		{"cat", []string{"cat", "dog"}},
+		{"CAT", []string{"cat", "dog"}},
+		{`"sat on the cat"`, []string{"dog"}},
+		{"mat cat", []string{"cat"}},
+		{"bird", nil},
+	} {
+		messages, err := s.SearchMessageContentPaginated(tc.term, 0, 10)
+		if err != nil {
+			t.Fatalf("SearchMessageContentPaginated: %v", err)
+		}
+		ids := messageIDs(messages)
+		slices.Sort(ids)
+		if !slices.Equal(ids, tc.expected) {
+			t.Errorf("expected %q to match messages %v, got %v", tc.term, tc.expected, ids)
+		}
+	}
+	if messages, err := s.SearchMessageContentPaginated("message", 2, 3); err != nil || len(messages) != 3 {
+		t.Errorf("expected a page of the 6 matching messages, got %d, %v", len(messages), err)
+	}
+
+	for _, tc := range []struct {
+		term     string
+		expected []string
+	}{
+		{"thread", []string{"1", "3"}},
+		{"dogs", []string{"2"}},
+		{`"and dogs"`, []string{"2"}},
+		{"cats thread", nil},
+	} {
+		threads, err := s.SearchThreadNamesPaginated(tc.term, 0, 10)
+		if err != nil {
+			t.Fatalf("SearchThreadNamesPaginated: %v", err)
+		}
+		ids := threadIDs(threads)
+		slices.Sort(ids)
+		if !slices.Equal(ids, tc.expected) {
+			t.Errorf("expected %q to match threads %v, got %v", tc.term, tc.expected, ids)
+		}
+	}
+	if threads, err := s.SearchThreadNamesPaginated("thread", 1, 5); err != nil || len(threads) != 1 {
+		t.Errorf("expected the second matching thread, got %d, %v", len(threads), err)
+	}
+}
+
+// testSameTimestamp stores a tool call, its results and the answer within
+// the same second, the ids sort the other way round
+func testSameTimestamp(t *testing.T, s store.Store) {
+	seed(t, s, 1)
+	expected := []string{"1-0", "1-1", "z-call", "y-result", "x-result", "w-answer"}
+	for _, id := range expected[2:] {
+		message := &db.Message{ID: id, ThreadID: "1", Role: "tool", Content: id, CreatedAt: "2024-01-01 10:00:05"}
+		if err := s.CreateMessage(message); err != nil {
+			t.Fatalf("CreateMessage: %v", err)
+		}
+	}
+	if ids := listMessageIDs(t, s, "1"); !slices.Equal(ids, expected) {
+		t.Errorf("expected messages of the same second in the order they were stored, got %v", ids)
+	}
+}
@@ -0,0 +1,239 @@
+// Package templates stores prompt templates as files, a template has
+// {{name}} variables and includes the contents of files with
+// {{file:path}} and of stdin with {{stdin}}
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// Ext is the extension of template files in the directory
+	Ext = ".md"
+	// MaxIncludeSize is the largest file or stdin a template includes
+	MaxIncludeSize = 256 << 10
+
+	dirMode  = 0700
+	fileMode = 0600
+
+	stdinPlaceholder = "stdin"
+	filePrefix       = "file:"
+)
+
+var (
+	ErrNotFound        = errors.New("template not found")
+	ErrInvalidName     = errors.New("invalid template name")
+	ErrMissingVariable = errors.New("missing variable")
+	ErrNoStdin         = errors.New("the template includes stdin but there is none")
+	ErrTooLarge        = errors.New("include too large")
+
+	validName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	// placeholder matches everything in double braces, what is not a
+	// variable or an include is kept as it is, e.g. {{.Name}} of go templates
+	placeholder  = regexp.MustCompile(`\{\{\s*(.+?)\s*\}\}`)
+	variableName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+)
+
+type Template struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// Variables returns the names of the variables in the order they first
+// appear
+func (t *Template) Variables() []string {
+	var names []string
+	seen := make(map[string]struct{})
+	for _, match := range placeholder.FindAllStringSubmatch(t.Content, -1) {
+		name := match[1]
+		if name == stdinPlaceholder || !variableName.MatchString(name) {
+			continue
+		}
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// UsesStdin reports whether the template includes stdin
+func (t *Template) UsesStdin() bool {
+	for _, match := range placeholder.FindAllStringSubmatch(t.Content, -1) {
+		if match[1] == stdinPlaceholder {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand replaces the variables with their values and the includes with
+// the contents, stdin is only read if the template includes it and can be
+// nil otherwise, values are not expanded again
+func (t *Template) Expand(vars map[string]string, stdin io.Reader) (string, error) {
+	var stdinContent *string
+	var expandErr error
+	expanded := placeholder.ReplaceAllStringFunc(t.Content, func(match string) string {
+		if expandErr != nil {
+			return match
+		}
+		name := placeholder.FindStringSubmatch(match)[1]
+		switch {
+		case name == stdinPlaceholder:
+			if stdinContent == nil {
+				content, err := readStdin(stdin)
+				if err != nil {
+					expandErr = err
+					return match
+				}
+				stdinContent = &content
+			}
+			return *stdinContent
+		case strings.HasPrefix(name, filePrefix):
+			content, err := readInclude(strings.TrimSpace(strings.TrimPrefix(name, filePrefix)))
+			if err != nil {
+				expandErr = err
+				return match
+			}
+			return content
+		case variableName.MatchString(name):
+			value, ok := vars[name]
+			if !ok {
+				expandErr = fmt.Errorf("%w: %s", ErrMissingVariable, name)
+				return match
+			}
+			return value
+		}
+		return match
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return expanded, nil
+}
+
+func readStdin(stdin io.Reader) (string, error) {
+	if stdin == nil {
+		return "", ErrNoStdin
+	}
+	data, err := io.ReadAll(io.LimitReader(stdin, MaxIncludeSize+1))
+	if err != nil {
+		return "", fmt.Errorf("could not read stdin: %w", err)
+	}
+	if len(data) > MaxIncludeSize {
+		return "", fmt.Errorf("%w: stdin is over %d bytes", ErrTooLarge, MaxIncludeSize)
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
+
+func readInclude(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("could not include %s: %w", path, err)
+	}
+	if info.Size() > MaxIncludeSize {
+		return "", fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrTooLarge, path, info.Size(), MaxIncludeSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not include %s: %w", path, err)
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
+
+// Store keeps every template in a file named after it in a directory
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("%w: %q, use letters, digits, - and _", ErrInvalidName, name)
+	}
+	return filepath.Join(s.dir, name+Ext), nil
+}
+
+// List returns the templates sorted by name, there are none if the
+// directory does not exist
+func (s *Store) List() ([]*Template, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("os.ReadDir: %w", err)
+	}
+	var list []*Template
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), Ext)
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), Ext) || !validName.MatchString(name) {
+			continue
+		}
+		t, err := s.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (s *Store) Get(name string) (*Template, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	return &Template{Name: name, Content: string(data)}, nil
+}
+
+// Save creates or replaces the template
+func (s *Store) Save(t *Template) error {
+	path, err := s.path(t.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, dirMode); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(t.Content), fileMode); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Delete(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return fmt.Errorf("os.Remove: %w", err)
+	}
+	return nil
+}
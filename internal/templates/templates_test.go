@@ -0,0 +1,77 @@
+package templates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVariables(t *testing.T) {
+	tmpl := &Template{Content: "review {{ lang }} code in {{file:main.go}} for {{lang}} and {{focus}}, keep {{.Name}} and {{stdin}}"}
+	if names := tmpl.Variables(); !reflect.DeepEqual(names, []string{"lang", "focus"}) {
+		t.Errorf("unexpected variables %v", names)
+	}
+	if !tmpl.UsesStdin() {
+		t.Error("expected the template to use stdin")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &Template{Content: "{{lang}}: {{ file:" + path + " }}\n{{stdin}} {{stdin}} {{.Name}}"}
+	// values are not expanded again
+	expanded, err := tmpl.Expand(map[string]string{"lang": "{{stdin}}"}, strings.NewReader("diff\n"))
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	if expanded != "{{stdin}}: package main\ndiff diff {{.Name}}" {
+		t.Errorf("unexpected expansion %q", expanded)
+	}
+
+	if _, err := tmpl.Expand(nil, strings.NewReader("")); !errors.Is(err, ErrMissingVariable) {
+		t.Errorf("expected ErrMissingVariable, got %v", err)
+	}
+	if _, err := tmpl.Expand(map[string]string{"lang": "go"}, nil); !errors.Is(err, ErrNoStdin) {
+		t.Errorf("expected ErrNoStdin, got %v", err)
+	}
+	missing := &Template{Content: "{{file:" + filepath.Join(dir, "missing") + "}}"}
+	if _, err := missing.Expand(nil, nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "templates"))
+	if list, err := s.List(); err != nil || len(list) != 0 {
+		t.Errorf("expected no templates before the directory exists, got %v (%v)", list, err)
+	}
+	for _, name := range []string{"tests", "review"} {
+		if err := s.Save(&Template{Name: name, Content: "write " + name}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	if err := s.Save(&Template{Name: "../escape"}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("expected ErrInvalidName, got %v", err)
+	}
+	list, err := s.List()
+	if err != nil || len(list) != 2 || list[0].Name != "review" || list[1].Content != "write tests" {
+		t.Errorf("unexpected templates %+v (%v)", list, err)
+	}
+	if err := s.Delete("review"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get("review"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := s.Delete("review"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aavshr/panda/internal/attach"
+	"github.com/aavshr/panda/internal/utils"
+)
+
+const (
+	// maxGrepMatches is how many matching lines grep returns
+	maxGrepMatches = 200
+	// maxGrepLineLen shortens long matching lines like minified files
+	maxGrepLineLen = 300
+)
+
+var errStopWalk = errors.New("stop walk")
+
+// Builtin returns the built-in tools, they only read files
+func Builtin() []*Tool {
+	return []*Tool{
+		{
+			Name:        "read_file",
+			Description: "Read a text file. Paths are relative to the current working directory.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {"path": {"type": "string", "description": "path of the file"}},
+				"required": ["path"]
+			}`),
+			Run: readFile,
+		},
+		{
+			Name:        "list_directory",
+			Description: "List the files and directories in a directory, directories end with a slash.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {"path": {"type": "string", "description": "path of the directory, defaults to the current working directory"}}
+			}`),
+			Run: listDirectory,
+		},
+		{
+			Name:        "grep",
+			Description: "Search the files in a directory for lines matching a regular expression, files ignored by .gitignore are skipped.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"pattern": {"type": "string", "description": "RE2 regular expression"},
+					"path": {"type": "string", "description": "file or directory to search, defaults to the current working directory"}
+				},
+				"required": ["pattern"]
+			}`),
+			Run: grep,
+		},
+		{
+			Name:        "calculate",
+			Description: "Evaluate an arithmetic expression with + - * / %, parentheses and the functions sqrt, pow, abs, floor, ceil, round, min, max.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {"expression": {"type": "string", "description": "e.g. (2 + 3) * pow(2, 10)"}},
+				"required": ["expression"]
+			}`),
+			Run: calculate,
+		},
+	}
+}
+
+type pathArgs struct {
+	Path string `json:"path"`
+}
+
+func readFile(_ context.Context, args json.RawMessage) (string, error) {
+	var a pathArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	if a.Path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	// same limits as attaching a file with @path
+	files, err := attach.Resolve([]string{a.Path}, attach.DefaultLimits())
+	if err != nil {
+		return "", err
+	}
+	if len(files) != 1 || files[0].IsImage() {
+		return "", fmt.Errorf("%s is not a text file", a.Path)
+	}
+	return files[0].Content, nil
+}
+
+func listDirectory(_ context.Context, args json.RawMessage) (string, error) {
+	var a pathArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	if a.Path == "" {
+		a.Path = "."
+	}
+	path, err := utils.ExpandPath(a.Path)
+	if err != nil {
+		return "", fmt.Errorf("utils.ExpandPath: %w", err)
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, entry := range entries {
+		b.WriteString(entry.Name())
+		if entry.IsDir() {
+			b.WriteString("/")
+		}
+		b.WriteString("\n")
+	}
+	if b.Len() == 0 {
+		return "the directory is empty", nil
+	}
+	return b.String(), nil
+}
+
+type grepArgs struct {
+	Pattern string `json:"pattern"`
+	Path    string `json:"path"`
+}
+
+func grep(ctx context.Context, args json.RawMessage) (string, error) {
+	var a grepArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	re, err := regexp.Compile(a.Pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	if a.Path == "" {
+		a.Path = "."
+	}
+	path, err := utils.ExpandPath(a.Path)
+	if err != nil {
+		return "", fmt.Errorf("utils.ExpandPath: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		b       strings.Builder
+		matches int
+	)
+	search := func(file string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for n := 1; scanner.Scan(); n++ {
+			line := scanner.Text()
+			// binary files are skipped
+			if strings.IndexByte(line, 0) >= 0 {
+				return nil
+			}
+			if !re.MatchString(line) {
+				continue
+			}
+			// the line is cut like Truncate but kept on one line
+			if len(line) > maxGrepLineLen {
+				line = cut(line, maxGrepLineLen) + "..."
+			}
+			fmt.Fprintf(&b, "%s:%d:%s\n", file, n, line)
+			matches++
+			if matches >= maxGrepMatches {
+				fmt.Fprintf(&b, "[stopped after %d matches]\n", maxGrepMatches)
+				return errStopWalk
+			}
+		}
+		// lines too long for the scanner end the search of the file
+		return nil
+	}
+	if !info.IsDir() {
+		err = search(path)
+	} else {
+		err = attach.Walk(path, func(file string, _ fs.DirEntry) error {
+			return search(filepath.Clean(file))
+		})
+	}
+	if err != nil && !errors.Is(err, errStopWalk) {
+		return "", err
+	}
+	if matches == 0 {
+		return "no matches", nil
+	}
+	return b.String(), nil
+}
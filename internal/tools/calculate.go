@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+)
+
+type calculateArgs struct {
+	Expression string `json:"expression"`
+}
+
+// calculateFuncs are the functions calculate accepts with their arity
+var calculateFuncs = map[string]struct {
+	arity int
+	fn    func(args ...float64) float64
+}{
+	"sqrt":  {1, func(a ...float64) float64 { return math.Sqrt(a[0]) }},
+	"abs":   {1, func(a ...float64) float64 { return math.Abs(a[0]) }},
+	"floor": {1, func(a ...float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a ...float64) float64 { return math.Ceil(a[0]) }},
+	"round": {1, func(a ...float64) float64 { return math.Round(a[0]) }},
+	"pow":   {2, func(a ...float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min":   {2, func(a ...float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a ...float64) float64 { return math.Max(a[0], a[1]) }},
+}
+
+func calculate(_ context.Context, args json.RawMessage) (string, error) {
+	var a calculateArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return "", err
+	}
+	// arithmetic is a subset of go expressions so the go parser does the
+	// parsing and precedence
+	expr, err := parser.ParseExpr(a.Expression)
+	if err != nil {
+		return "", fmt.Errorf("invalid expression: %w", err)
+	}
+	result, err := evaluate(expr)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(result, 'g', -1, 64), nil
+}
+
+func evaluate(expr ast.Expr) (float64, error) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.INT && e.Kind != token.FLOAT {
+			return 0, fmt.Errorf("unsupported literal %s", e.Value)
+		}
+		return strconv.ParseFloat(e.Value, 64)
+	case *ast.ParenExpr:
+		return evaluate(e.X)
+	case *ast.UnaryExpr:
+		x, err := evaluate(e.X)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Op {
+		case token.SUB:
+			return -x, nil
+		case token.ADD:
+			return x, nil
+		}
+		return 0, fmt.Errorf("unsupported operator %s", e.Op)
+	case *ast.BinaryExpr:
+		x, err := evaluate(e.X)
+		if err != nil {
+			return 0, err
+		}
+		y, err := evaluate(e.Y)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Op {
+		case token.ADD:
+			return x + y, nil
+		case token.SUB:
+			return x - y, nil
+		case token.MUL:
+			return x * y, nil
+		case token.QUO:
+			if y == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return x / y, nil
+		case token.REM:
+			if y == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return math.Mod(x, y), nil
+		}
+		return 0, fmt.Errorf("unsupported operator %s", e.Op)
+	case *ast.CallExpr:
+		name, ok := e.Fun.(*ast.Ident)
+		if !ok {
+			return 0, fmt.Errorf("unsupported function call")
+		}
+		f, ok := calculateFuncs[name.Name]
+		if !ok {
+			return 0, fmt.Errorf("unknown function %s", name.Name)
+		}
+		if len(e.Args) != f.arity {
+			return 0, fmt.Errorf("%s takes %d arguments", name.Name, f.arity)
+		}
+		args := make([]float64, 0, len(e.Args))
+		for _, arg := range e.Args {
+			v, err := evaluate(arg)
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, v)
+		}
+		return f.fn(args...), nil
+	}
+	return 0, fmt.Errorf("unsupported expression")
+}
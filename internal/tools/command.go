@@ -0,0 +1,371 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aavshr/panda/internal/config"
+)
+
+const (
+	RunCommandName = "run_command"
+
+	DefaultCommandTimeout   = 30 * time.Second
+	DefaultCommandMaxOutput = 16 << 10
+	// commandWaitDelay is how long output is read after the command was
+	// killed, children that keep the pipes open are not waited for
+	commandWaitDelay = time.Second
+	// maxScriptDepth is how deep scripts of sh -c in scripts are checked
+	maxScriptDepth = 4
+)
+
+// shellOperators chain or redirect commands, a command with one of them is
+// never approved by the allow list
+var shellOperators = []string{"&&", "||", ";", "|", "&", "`", "$(", ">", "<", "\n"}
+
+// commandWrappers run the command that follows them and their options, the
+// options that take a value are listed
+var commandWrappers = map[string][]string{
+	"sudo":    {"-u", "-g", "-C", "-D", "-h", "-p", "-r", "-t", "-U"},
+	"env":     {"-u", "-C", "-S"},
+	"xargs":   {"-I", "-n", "-P", "-L", "-d", "-s", "-E", "-a"},
+	"nice":    {"-n"},
+	"time":    {"-f", "-o"},
+	"nohup":   {},
+	"exec":    {"-a"},
+	"command": {},
+}
+
+// shells run the script that follows their -c option
+var shells = []string{"sh", "bash", "zsh", "dash", "ksh"}
+
+// CommandConfig configures the run_command tool, commands are matched by
+// their leading words, e.g. "git status" matches "git status -s"
+type CommandConfig struct {
+	// Allow are commands that run without confirmation, chained commands and
+	// substitutions are always confirmed
+	Allow []string
+	// Deny are commands that never run, also if they are part of a chain, a
+	// substitution or a script of sh -c, run by a path or behind wrappers
+	// like sudo and xargs. The shell is only parsed on a best-effort basis
+	// so the list does not make running commands safe
+	Deny []string
+	// TimeoutSeconds kills commands running longer, 0 for the default
+	TimeoutSeconds int
+	// MaxOutput is how many bytes of stdout and of stderr are kept, 0 for
+	// the default
+	MaxOutput int
+}
+
+// NewCommandConfig returns the run_command config of the user config
+func NewCommandConfig(c config.CommandConfig) CommandConfig {
+	return CommandConfig{
+		Allow:          c.Allow,
+		Deny:           c.Deny,
+		TimeoutSeconds: c.TimeoutSeconds,
+		MaxOutput:      c.MaxOutput,
+	}
+}
+
+func (c CommandConfig) timeout() time.Duration {
+	if c.TimeoutSeconds <= 0 {
+		return DefaultCommandTimeout
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+func (c CommandConfig) maxOutput() int {
+	if c.MaxOutput <= 0 {
+		return DefaultCommandMaxOutput
+	}
+	return c.MaxOutput
+}
+
+type commandArgs struct {
+	Command string `json:"command"`
+}
+
+// RunCommand returns the run_command tool that runs shell commands in the
+// current working directory
+func RunCommand(config CommandConfig) *Tool {
+	return &Tool{
+		Name:        RunCommandName,
+		Description: "Run a shell command in the current working directory and get its exit code, stdout and stderr. The user confirms commands before they run.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {"command": {"type": "string", "description": "the command, run with sh -c"}},
+			"required": ["command"]
+		}`),
+		Policy: func(args json.RawMessage) (Decision, string) {
+			var a commandArgs
+			if err := decodeArgs(args, &a); err != nil {
+				// the call fails when it runs
+				return DecisionAsk, ""
+			}
+			return config.decide(a.Command)
+		},
+		Run: func(ctx context.Context, args json.RawMessage) (string, error) {
+			var a commandArgs
+			if err := decodeArgs(args, &a); err != nil {
+				return "", err
+			}
+			// the policy is checked again so that a denied command can not run
+			if decision, reason := config.decide(a.Command); decision == DecisionDeny {
+				return "", errors.New(reason)
+			}
+			return runCommand(ctx, a.Command, config.timeout(), config.maxOutput())
+		},
+		// the command times out on its own, output is still read after
+		Timeout: config.timeout() + 2*commandWaitDelay,
+	}
+}
+
+func (c CommandConfig) decide(command string) (Decision, string) {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return DecisionDeny, "the command is empty"
+	}
+	if pattern, ok := c.denied(command, 0); ok {
+		return DecisionDeny, fmt.Sprintf("%q is denied in the config", pattern)
+	}
+	for _, operator := range shellOperators {
+		if strings.Contains(command, operator) {
+			return DecisionAsk, ""
+		}
+	}
+	for _, pattern := range c.Allow {
+		if matchesCommand(command, pattern) {
+			return DecisionAllow, ""
+		}
+	}
+	return DecisionAsk, ""
+}
+
+// denied returns the deny pattern that a segment of the command or of the
+// scripts it runs with sh -c matches
+func (c CommandConfig) denied(command string, depth int) (string, bool) {
+	for _, segment := range commandSegments(command) {
+		words := commandWords(segment)
+		if script, ok := shellScript(words); ok && depth < maxScriptDepth {
+			if pattern, ok := c.denied(script, depth+1); ok {
+				return pattern, true
+			}
+		}
+		for _, pattern := range c.Deny {
+			patternWords := strings.Fields(pattern)
+			if len(patternWords) > 0 {
+				patternWords[0] = path.Base(patternWords[0])
+			}
+			if matchesWords(words, patternWords) {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// commandSegments splits a command at the shell operators and the
+// parentheses of subshells and substitutions
+func commandSegments(command string) []string {
+	segments := []string{command}
+	for _, operator := range slices.Concat(shellOperators, []string{"(", ")"}) {
+		var split []string
+		for _, segment := range segments {
+			split = append(split, strings.Split(segment, operator)...)
+		}
+		segments = split
+	}
+	return segments
+}
+
+// commandWords returns the words of the command that runs in a segment,
+// variable assignments and wrappers like sudo are removed and the command
+// is named by its base name, e.g. "sudo /bin/rm -rf x" is "rm -rf x"
+func commandWords(segment string) []string {
+	words := shellWords(segment)
+	for len(words) > 0 {
+		if isAssignment(words[0]) {
+			words = words[1:]
+			continue
+		}
+		name := path.Base(words[0])
+		valueOptions, ok := commandWrappers[name]
+		if !ok {
+			words[0] = name
+			return words
+		}
+		words = words[1:]
+		for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+			option := words[0]
+			words = words[1:]
+			if option == "--" {
+				break
+			}
+			if slices.Contains(valueOptions, option) && len(words) > 0 {
+				words = words[1:]
+			}
+		}
+	}
+	return words
+}
+
+// shellScript returns the script of a command like sh -c "script"
+func shellScript(words []string) (string, bool) {
+	if len(words) == 0 || !slices.Contains(shells, words[0]) {
+		return "", false
+	}
+	for i := 1; i < len(words) && strings.HasPrefix(words[i], "-"); i++ {
+		// the option may be combined with others, e.g. bash -lc
+		if !strings.HasPrefix(words[i], "--") && strings.Contains(words[i], "c") && i+1 < len(words) {
+			return words[i+1], true
+		}
+	}
+	return "", false
+}
+
+// isAssignment reports whether the word sets a variable, e.g. FOO=1
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// shellWords splits a command into words like the shell does, quotes are
+// removed and a backslash escapes the next character
+func shellWords(s string) []string {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord, escaped := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote != 0:
+			switch {
+			case r == quote:
+				quote = 0
+			case r == '\\' && quote == '"':
+				escaped = true
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// matchesCommand reports whether the leading words of command are the
+// words of pattern
+func matchesCommand(command, pattern string) bool {
+	return matchesWords(strings.Fields(command), strings.Fields(pattern))
+}
+
+func matchesWords(commandWords, patternWords []string) bool {
+	if len(patternWords) == 0 || len(patternWords) > len(commandWords) {
+		return false
+	}
+	for i, word := range patternWords {
+		if commandWords[i] != word {
+			return false
+		}
+	}
+	return true
+}
+
+// limitedBuffer keeps the first max bytes written to it and counts the rest
+type limitedBuffer struct {
+	buf     bytes.Buffer
+	max     int
+	dropped int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	keep := b.max - b.buf.Len()
+	if keep > len(p) {
+		keep = len(p)
+	}
+	if keep > 0 {
+		b.buf.Write(p[:keep])
+	}
+	b.dropped += len(p) - keep
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.dropped == 0 {
+		return b.buf.String()
+	}
+	return fmt.Sprintf("%s\n[truncated %d bytes]", b.buf.String(), b.dropped)
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// runCommand runs the command and formats its exit code and output for the
+// model, a failing command is not an error
+func runCommand(ctx context.Context, command string, timeout time.Duration, maxOutput int) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	stdout := &limitedBuffer{max: maxOutput}
+	stderr := &limitedBuffer{max: maxOutput}
+	cmd := shellCommand(ctx, command)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = commandWaitDelay
+
+	err := cmd.Run()
+	var b strings.Builder
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		fmt.Fprintf(&b, "timed out after %s\n", timeout)
+	case errors.As(err, &exitErr):
+		fmt.Fprintf(&b, "exit code: %d\n", exitErr.ExitCode())
+	case err != nil:
+		return "", fmt.Errorf("could not run the command: %w", err)
+	default:
+		b.WriteString("exit code: 0\n")
+	}
+	fmt.Fprintf(&b, "stdout:\n%s\n", stdout.String())
+	fmt.Fprintf(&b, "stderr:\n%s\n", stderr.String())
+	return b.String(), nil
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aavshr/panda/internal/llm"
+)
+
+func commandCall(command string) llm.ToolCall {
+	args, _ := json.Marshal(commandArgs{Command: command})
+	return llm.ToolCall{Name: RunCommandName, Arguments: string(args)}
+}
+
+func TestCommandDecide(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(RunCommand(CommandConfig{
+		Allow: []string{"git status", "ls"},
+		Deny:  []string{"rm", "git push"},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	testCases := map[string]Decision{
+		"git status -s":          DecisionAllow,
+		"ls":                     DecisionAllow,
+		"git stash":              DecisionAsk,
+		"lsof":                   DecisionAsk,
+		"ls && curl example.com": DecisionAsk,
+		"ls > out.txt":           DecisionAsk,
+		"rm -rf build":           DecisionDeny,
+		"ls; rm -rf build":       DecisionDeny,
+		"git push origin main":   DecisionDeny,
+		"  ":                     DecisionDeny,
+		// the deny list also holds behind paths, wrappers and substitutions
+		"/bin/rm -rf x":                 DecisionDeny,
+		"sudo rm -rf /":                 DecisionDeny,
+		"sudo -u root rm x":             DecisionDeny,
+		"env rm x":                      DecisionDeny,
+		"env -i FOO=1 rm x":             DecisionDeny,
+		"FOO=1 rm x":                    DecisionDeny,
+		"find . | xargs rm":             DecisionDeny,
+		"xargs -n 1 /usr/bin/rm < list": DecisionDeny,
+		"nice -n 10 rm x":               DecisionDeny,
+		"time rm x":                     DecisionDeny,
+		"nohup sudo env rm x":           DecisionDeny,
+		"sh -c 'rm -rf x'":              DecisionDeny,
+		`bash -c "ls && rm x"`:          DecisionDeny,
+		`bash -lc "sh -c 'rm x'"`:       DecisionDeny,
+		"echo $(rm -rf x)":              DecisionDeny,
+		"echo `rm -rf x`":               DecisionDeny,
+		"(rm x)":                        DecisionDeny,
+		`\rm x`:                         DecisionDeny,
+		`"rm" x`:                        DecisionDeny,
+		"git -C . push":                 DecisionAsk,
+		"sudo git push origin main":     DecisionDeny,
+		"ls $(echo .)":                  DecisionAsk,
+		"ls `echo .`":                   DecisionAsk,
+		"sh -c 'ls'":                    DecisionAsk,
+		`git commit -m "drop rmdir"`:    DecisionAsk,
+	}
+	for command, expected := range testCases {
+		if decision, _ := r.Decide(commandCall(command)); decision != expected {
+			t.Errorf("%q: expected %v, got %v", command, expected, decision)
+		}
+	}
+	// denied commands do not run even if they were not checked before
+	if _, err := r.Run(context.Background(), commandCall("rm -rf build")); err == nil {
+		t.Error("expected a denied command to fail")
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are written for sh")
+	}
+	r := NewRegistry()
+	if err := r.Register(RunCommand(CommandConfig{TimeoutSeconds: 1, MaxOutput: 8})); err != nil {
+		t.Fatal(err)
+	}
+	run := func(command string) string {
+		t.Helper()
+		output, err := r.Run(context.Background(), commandCall(command))
+		if err != nil {
+			t.Fatalf("%q: %v", command, err)
+		}
+		return output
+	}
+
+	if output := run("echo out; echo err >&2; exit 3"); output != "exit code: 3\nstdout:\nout\n\nstderr:\nerr\n\n" {
+		t.Errorf("unexpected output %q", output)
+	}
+	if output := run("echo 0123456789"); !strings.Contains(output, "stdout:\n01234567\n[truncated 3 bytes]") {
+		t.Errorf("expected stdout to be truncated, got %q", output)
+	}
+	if output := run("sleep 5"); !strings.HasPrefix(output, "timed out after 1s") {
+		t.Errorf("expected a timeout, got %q", output)
+	}
+}
@@ -0,0 +1,159 @@
+// Package tools holds the tools the model can call and runs the calls
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aavshr/panda/internal/llm"
+)
+
+const (
+	// MaxOutputSize is how much of the output of a tool is sent to the model
+	MaxOutputSize = 32 << 10
+	// DefaultTimeout cancels calls of tools without their own timeout
+	DefaultTimeout = 30 * time.Second
+)
+
+var (
+	ErrUnknownTool   = errors.New("unknown tool")
+	ErrDuplicateTool = errors.New("tool already registered")
+)
+
+// Decision is whether a call runs, needs confirmation or is refused
+type Decision int
+
+const (
+	DecisionAsk Decision = iota
+	DecisionAllow
+	DecisionDeny
+)
+
+// Tool is a function the model can call, Run gets the arguments the model
+// generated as a json object matching Parameters
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the json schema of the arguments
+	Parameters json.RawMessage
+	Run        func(ctx context.Context, args json.RawMessage) (string, error)
+	// Policy decides about a call before it is confirmed, the reason is
+	// given for denied calls, nil asks for every call
+	Policy func(args json.RawMessage) (Decision, string)
+	// Timeout cancels a call, 0 for DefaultTimeout
+	Timeout time.Duration
+}
+
+// Registry holds the tools offered to the model in registration order
+type Registry struct {
+	tools  []*Tool
+	byName map[string]*Tool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{byName: make(map[string]*Tool)}
+}
+
+// NewBuiltinRegistry returns a registry with the built-in tools
+func NewBuiltinRegistry() *Registry {
+	r := NewRegistry()
+	for _, tool := range Builtin() {
+		// built-in names are unique
+		_ = r.Register(tool)
+	}
+	return r
+}
+
+func (r *Registry) Register(tool *Tool) error {
+	if _, ok := r.byName[tool.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateTool, tool.Name)
+	}
+	r.tools = append(r.tools, tool)
+	r.byName[tool.Name] = tool
+	return nil
+}
+
+func (r *Registry) Get(name string) (*Tool, bool) {
+	tool, ok := r.byName[name]
+	return tool, ok
+}
+
+// Definitions returns the tools as they are sent to the model
+func (r *Registry) Definitions() []llm.ToolDefinition {
+	definitions := make([]llm.ToolDefinition, 0, len(r.tools))
+	for _, tool := range r.tools {
+		definitions = append(definitions, llm.ToolDefinition{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Parameters,
+		})
+	}
+	return definitions
+}
+
+// Decide returns whether the call can run without confirmation or is
+// refused, unknown tools are left to Run to fail
+func (r *Registry) Decide(call llm.ToolCall) (Decision, string) {
+	tool, ok := r.byName[call.Name]
+	if !ok || tool.Policy == nil {
+		return DecisionAsk, ""
+	}
+	return tool.Policy(json.RawMessage(call.Arguments))
+}
+
+// Run runs the call and returns the output for the model, long output is
+// truncated to MaxOutputSize
+func (r *Registry) Run(ctx context.Context, call llm.ToolCall) (string, error) {
+	tool, ok := r.byName[call.Name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownTool, call.Name)
+	}
+	args := json.RawMessage(call.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	timeout := tool.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	output, err := tool.Run(ctx, args)
+	if err != nil {
+		return "", err
+	}
+	return Truncate(output, MaxOutputSize), nil
+}
+
+// Truncate shortens s to at most max bytes without splitting a character
+// and notes how much was cut
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	kept := cut(s, max)
+	return fmt.Sprintf("%s\n[truncated %d bytes]", kept, len(s)-len(kept))
+}
+
+// cut returns the start of s up to max bytes without splitting a character
+func cut(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// decodeArgs decodes the arguments of a call into v
+func decodeArgs(args json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
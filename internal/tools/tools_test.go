@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aavshr/panda/internal/llm"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewBuiltinRegistry()
+	if err := r.Register(&Tool{Name: "grep"}); !errors.Is(err, ErrDuplicateTool) {
+		t.Errorf("expected ErrDuplicateTool, got %v", err)
+	}
+	definitions := r.Definitions()
+	if len(definitions) != len(Builtin()) || definitions[0].Name != "read_file" {
+		t.Errorf("unexpected definitions %+v", definitions)
+	}
+	if _, err := r.Run(context.Background(), llm.ToolCall{Name: "rm"}); !errors.Is(err, ErrUnknownTool) {
+		t.Errorf("expected ErrUnknownTool, got %v", err)
+	}
+	if _, err := r.Run(context.Background(), llm.ToolCall{Name: "calculate", Arguments: "{"}); err == nil {
+		t.Error("expected an error for invalid arguments")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if s := Truncate("short", 10); s != "short" {
+		t.Errorf("expected short to be kept, got %q", s)
+	}
+	// the cut moves back to the start of a character
+	if s := Truncate("aé", 2); s != "a\n[truncated 2 bytes]" {
+		t.Errorf("unexpected truncation %q", s)
+	}
+	if s := cut(strings.Repeat("é", 3), 5); s != "éé" {
+		t.Errorf("unexpected cut %q", s)
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	r := NewBuiltinRegistry()
+	testCases := map[string]string{
+		"1 + 2 * 3":            "7",
+		"(1 + 2) * 3":          "9",
+		"-2.5 * 2":             "-5",
+		"pow(2, 10) % 1000":    "24",
+		"sqrt(16) / 8":         "0.5",
+		"max(1, min(5, 3))":    "3",
+		"1 / 0":                "error",
+		"os.Exit(1)":           "error",
+		"unknown(1)":           "error",
+		"\"string\" + 1":       "error",
+		"1 << 2":               "error",
+		"round(2.5) + abs(-1)": "4",
+	}
+	for expression, expected := range testCases {
+		args, _ := json.Marshal(calculateArgs{Expression: expression})
+		result, err := r.Run(context.Background(), llm.ToolCall{Name: "calculate", Arguments: string(args)})
+		if expected == "error" {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %s", expression, result)
+			}
+			continue
+		}
+		if err != nil || result != expected {
+			t.Errorf("%s: expected %s, got %s (%v)", expression, expected, result, err)
+		}
+	}
+}
+
+func TestFileTools(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		".git/HEAD":  "ref: refs/heads/main",
+		".gitignore": "*.log\n",
+		"main.go":    "package main\n\nfunc main() {}\n",
+		"debug.log":  "func ignored() {}\n",
+		"pkg/pkg.go": "package pkg\n\nfunc Run() {}\n",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewBuiltinRegistry()
+	run := func(name, args string) (string, error) {
+		return r.Run(context.Background(), llm.ToolCall{Name: name, Arguments: args})
+	}
+
+	if out, err := run("read_file", `{"path":"main.go"}`); err != nil || !strings.HasPrefix(out, "package main") {
+		t.Errorf("read_file: unexpected %q (%v)", out, err)
+	}
+	if _, err := run("read_file", `{"path":"missing.go"}`); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("read_file: expected os.ErrNotExist, got %v", err)
+	}
+	if out, err := run("list_directory", `{}`); err != nil || out != ".git/\n.gitignore\ndebug.log\nmain.go\npkg/\n" {
+		t.Errorf("list_directory: unexpected %q (%v)", out, err)
+	}
+	out, err := run("grep", `{"pattern":"^func "}`)
+	if err != nil {
+		t.Fatalf("grep: %v", err)
+	}
+	if out != "main.go:3:func main() {}\npkg/pkg.go:3:func Run() {}\n" {
+		t.Errorf("grep: unexpected %q", out)
+	}
+	if out, err := run("grep", `{"pattern":"nothing","path":"pkg"}`); err != nil || out != "no matches" {
+		t.Errorf("grep: unexpected %q (%v)", out, err)
+	}
+	if _, err := run("grep", `{"pattern":"("}`); err == nil {
+		t.Error("grep: expected an error for an invalid pattern")
+	}
+	// long lines are cut on a character and stay on one line
+	long := filepath.Join("pkg", "long.txt")
+	if err := os.WriteFile(long, []byte("a"+strings.Repeat("é", maxGrepLineLen)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = run("grep", `{"pattern":"é","path":"pkg/long.txt"}`)
+	if err != nil || !utf8.ValidString(out) || strings.Count(out, "\n") != 1 || !strings.HasSuffix(out, "é...\n") {
+		t.Errorf("grep: unexpected %q (%v)", out, err)
+	}
+	if err := os.Remove(long); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,193 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aavshr/panda/internal/export"
+	"github.com/aavshr/panda/internal/ui/components"
+	"github.com/aavshr/panda/internal/ui/keymap"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// action is something the user can do, actions are listed in the command
+// palette, actions with a binding of the keymap in the main context are
+// dispatched from here
+type action struct {
+	ID      string
+	Title   string
+	binding *key.Binding
+	// keys are shown for actions without a binding, e.g. slash commands
+	keys    []string
+	Context keymap.Context
+	run     func(m *Model) tea.Cmd
+}
+
+// Keys returns the keys of the binding or the fixed keys
+func (a action) Keys() []string {
+	if a.binding == nil {
+		return a.keys
+	}
+	if !a.binding.Enabled() {
+		return nil
+	}
+	return a.binding.Keys()
+}
+
+// newActions returns the actions followed by the slash commands
+func newActions(keys *keymap.KeyMap, commands []slashCommand) []action {
+	actions := []action{
+		{ID: "settings", Title: "Open settings", binding: &keys.Settings, Context: keymap.ContextMain, run: (*Model).openSettings},
+		{ID: "params", Title: "Set generation parameters", binding: &keys.Params, Context: keymap.ContextMain, run: (*Model).openParams},
+		{ID: "model", Title: "Pick model", binding: &keys.Model, Context: keymap.ContextMain, run: (*Model).openModelPicker},
+		{ID: "help", Title: "Show key bindings", binding: &keys.Help, Context: keymap.ContextMain, run: (*Model).openKeyHelp},
+		{ID: "quit", Title: "Quit", binding: &keys.Quit, Context: keymap.ContextAll, run: func(*Model) tea.Cmd { return tea.Quit }},
+		{ID: "retry", Title: "Retry the last message", binding: &keys.Retry, Context: keymap.ContextAll, run: (*Model).retry},
+		{ID: "focus-history", Title: "Focus history", Context: keymap.ContextMain, run: func(m *Model) tea.Cmd {
+			return m.focus(components.ComponentHistory)
+		}},
+		{ID: "focus-messages", Title: "Focus messages", Context: keymap.ContextMain, run: func(m *Model) tea.Cmd {
+			return m.focus(components.ComponentMessages)
+		}},
+		{ID: "focus-chat", Title: "Focus chat input", Context: keymap.ContextMain, run: func(m *Model) tea.Cmd {
+			return m.focus(components.ComponentChatInput)
+		}},
+		{ID: "delete-thread", Title: "Delete thread", binding: &keys.DeleteThread, Context: keymap.ContextHistory, run: func(m *Model) tea.Cmd {
+			return m.deleteThread(m.activeThreadIndex)
+		}},
+		{ID: "export-thread", Title: "Export thread to markdown", binding: &keys.ExportThread, Context: keymap.ContextHistory, run: func(m *Model) tea.Cmd {
+			return m.commandExport(components.ChatInputCommandMsg{Name: "export", Args: []string{string(export.FormatMarkdown)}})
+		}},
+		{ID: "filter-threads", Title: "Filter threads", keys: []string{"/"}, Context: keymap.ContextHistory, run: func(m *Model) tea.Cmd {
+			return tea.Batch(m.focus(components.ComponentHistory), m.historyModel.StartFilter())
+		}},
+		{ID: "copy-code", Title: "Copy code block", binding: &keys.CopyCode, Context: keymap.ContextMessages, run: func(m *Model) tea.Cmd {
+			return m.startCodeBlockAction((*components.ChatModel).StartCopyCode, "copy")
+		}},
+		{ID: "write-code", Title: "Write code block to a file", binding: &keys.WriteCode, Context: keymap.ContextMessages, run: func(m *Model) tea.Cmd {
+			return m.startCodeBlockAction((*components.ChatModel).StartWriteCode, "write to a file")
+		}},
+		{ID: "send", Title: "Send message", binding: &keys.Send, Context: keymap.ContextChat, run: func(m *Model) tea.Cmd {
+			if strings.TrimSpace(m.chatInputModel.Value()) == "" {
+				return m.focus(components.ComponentChatInput)
+			}
+			// slash commands run and are never sent to the model
+			return m.chatInputModel.Send()
+		}},
+		{ID: "attach", Title: "Attach a file", keys: []string{"@path"}, Context: keymap.ContextChat, run: func(m *Model) tea.Cmd {
+			m.chatInputModel.SetValue(m.chatInputModel.Value() + "@")
+			return m.focus(components.ComponentChatInput)
+		}},
+	}
+	for _, command := range commands {
+		actions = append(actions, action{
+			ID:      "/" + command.Name,
+			Title:   upperFirst(command.Description),
+			keys:    []string{"/" + command.Name},
+			Context: keymap.ContextChat,
+			run:     command.runFromPalette,
+		})
+	}
+	return actions
+}
+
+// runFromPalette runs a command without arguments, commands that need
+// them are put into the input to type the arguments
+func (c slashCommand) runFromPalette(m *Model) tea.Cmd {
+	if strings.HasPrefix(c.Args, "<") {
+		m.chatInputModel.SetValue("/" + c.Name + " ")
+		return m.focus(components.ComponentChatInput)
+	}
+	return c.run(m, components.ChatInputCommandMsg{Name: c.Name})
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// mainAction returns the action of the main context bound to the key
+func (m *Model) mainAction(keyMsg tea.KeyMsg) (action, bool) {
+	for _, a := range m.actions {
+		if a.Context == keymap.ContextMain && a.binding != nil && key.Matches(keyMsg, *a.binding) {
+			return a, true
+		}
+	}
+	return action{}, false
+}
+
+// startCodeBlockAction focuses the messages and waits for the number of the
+// code block like the key of the action does
+func (m *Model) startCodeBlockAction(start func(*components.ChatModel) bool, verb string) tea.Cmd {
+	cmd := m.focus(components.ComponentMessages)
+	if !start(&m.messagesModel) {
+		return tea.Batch(cmd, m.statusBar.Notify("there are no code blocks in the thread", components.ToastInfo))
+	}
+	return tea.Batch(cmd, m.statusBar.Notify("type the number of the code block to "+verb, components.ToastInfo))
+}
+
+// focus moves the focus to a section of the main view
+func (m *Model) focus(com components.Component) tea.Cmd {
+	if com != components.ComponentChatInput {
+		m.chatInputModel.Blur()
+	}
+	m.setSelectedComponent(com)
+	m.setFocusedComponent(com)
+	return m.cmdFocusedComponent
+}
+
+// inMainView reports whether no dialog is open, the bindings of the all
+// context only work then since dialogs use keys like ctrl+p to move up
+func (m *Model) inMainView() bool {
+	switch m.focusedComponent {
+	case components.ComponentNone, components.ComponentHistory, components.ComponentMessages, components.ComponentChatInput:
+		return !m.showSettings && !m.showParams && !m.showModels && !m.showPalette && !m.showKeyHelp
+	}
+	return false
+}
+
+func (m *Model) openPalette() tea.Cmd {
+	items := make([]components.PaletteAction, 0, len(m.actions))
+	for _, a := range m.actions {
+		items = append(items, components.PaletteAction{ID: a.ID, Title: a.Title, Keys: a.Keys(), Context: string(a.Context)})
+	}
+	m.chatInputModel.Blur()
+	m.showPalette = true
+	m.focusedComponent = components.ComponentPalette
+	m.selectedComponent = components.ComponentPalette
+	return m.palette.Open(items)
+}
+
+func (m *Model) handlePaletteCloseMsg() tea.Cmd {
+	m.showPalette = false
+	return m.Init()
+}
+
+func (m *Model) openKeyHelp() tea.Cmd {
+	m.chatInputModel.Blur()
+	m.showKeyHelp = true
+	m.focusedComponent = components.ComponentKeyHelp
+	m.selectedComponent = components.ComponentKeyHelp
+	return nil
+}
+
+func (m *Model) handleKeyHelpCloseMsg() tea.Cmd {
+	m.showKeyHelp = false
+	return m.Init()
+}
+
+// handlePaletteRunMsg closes the palette and runs the action as if it was
+// run from the main view
+func (m *Model) handlePaletteRunMsg(msg components.PaletteRunMsg) tea.Cmd {
+	cmd := m.handlePaletteCloseMsg()
+	for _, a := range m.actions {
+		if a.ID == msg.ID {
+			return tea.Batch(cmd, a.run(m))
+		}
+	}
+	m.chatInputModel.SetError(fmt.Errorf("unknown action %s", msg.ID))
+	return cmd
+}
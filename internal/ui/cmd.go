@@ -1,12 +1,72 @@
 package ui
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	base "github.com/aavshr/panda/internal/llm"
+	"github.com/aavshr/panda/internal/mcp"
+	"github.com/aavshr/panda/internal/tools"
+	"github.com/aavshr/panda/internal/ui/components"
+	"github.com/aavshr/panda/internal/ui/llm"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+const (
+	listModelsTimeout = 15 * time.Second
+	// mcpConnectTimeout includes listing the tools of the servers
+	mcpConnectTimeout = 30 * time.Second
+	// mcpPromptTimeout is for listing the prompts of all servers as well
+	mcpPromptTimeout = 30 * time.Second
+)
+
 type SelectComponentMsg struct{}
 type FocusComponentMsg struct{}
-type ForwardChatCompletionStreamMsg struct{}
+
+// ChatCompletionChunkMsg is the next read of the answer, the stream is read
+// in the background so that waiting for the backend does not block the ui
+type ChatCompletionChunkMsg struct {
+	Stream  io.ReadCloser
+	Content string
+	Err     error
+}
+
+// RetryMsg reports that the request for the answer is made again after
+// an error
+type RetryMsg llm.RetryEvent
+
+// ToolResultMsg is the output of a tool call for the model, errors are
+// passed on to the model as well
+type ToolResultMsg struct {
+	// ThreadID is the thread the call was made in
+	ThreadID string
+	Call     base.ToolCall
+	Content  string
+}
+
+// MCPConnectedMsg has the mcp servers that connected with their tools and
+// the errors of the ones that did not
+type MCPConnectedMsg struct {
+	Clients []*mcp.Client
+	Tools   []*tools.Tool
+	Errs    []error
+}
+
+// MCPPromptsMsg has the prompts of the connected mcp servers as they are
+// asked for with /prompt
+type MCPPromptsMsg struct {
+	Prompts []string
+	Err     error
+}
+
+// MCPPromptMsg has the messages of a prompt of an mcp server
+type MCPPromptMsg struct {
+	Messages []mcp.PromptMessage
+	Err      error
+}
 
 func (m *Model) cmdSelectComponent() tea.Msg {
 	return SelectComponentMsg{}
@@ -16,8 +76,13 @@ func (m *Model) cmdFocusedComponent() tea.Msg {
 	return FocusComponentMsg{}
 }
 
-func (m *Model) cmdForwardChatCompletionStream() tea.Msg {
-	return ForwardChatCompletionStreamMsg{}
+func (m *Model) cmdReadChatCompletionStream(stream io.ReadCloser) tea.Cmd {
+	return func() tea.Msg {
+		// TODO: what buffer size makes it look smooth?
+		buffer := make([]byte, 64)
+		n, err := stream.Read(buffer)
+		return ChatCompletionChunkMsg{Stream: stream, Content: string(buffer[:n]), Err: err}
+	}
 }
 
 func (m *Model) cmdError(err error) func() tea.Msg {
@@ -25,3 +90,118 @@ func (m *Model) cmdError(err error) func() tea.Msg {
 		return err
 	}
 }
+
+// listModels lists the models of the backend and caches them for the
+// model picker, it runs in a command so it only gets what it uses
+func listModels(backend llm.LLM, cache *base.ModelCache, baseURL string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), listModelsTimeout)
+	defer cancel()
+	models, err := backend.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// the cache only saves a request, failing to write it is not an error
+	_ = cache.Set(baseURL, models)
+	return models, nil
+}
+
+func (m *Model) cmdListModels(baseURL string, submit bool) tea.Cmd {
+	backend, cache := m.llm, m.modelCache
+	return func() tea.Msg {
+		models, err := listModels(backend, cache, baseURL)
+		return components.SettingsTestResultMsg{
+			Models: models,
+			Err:    err,
+			Submit: submit,
+		}
+	}
+}
+
+func (m *Model) cmdLoadModels() tea.Cmd {
+	backend, cache := m.llm, m.modelCache
+	baseURL := m.userConfig.LLMBaseURL
+	return func() tea.Msg {
+		models, err := listModels(backend, cache, baseURL)
+		return components.ModelPickerLoadedMsg{
+			Models: models,
+			Err:    err,
+		}
+	}
+}
+
+func (m *Model) cmdRunTool(call base.ToolCall) tea.Cmd {
+	registry, threadID := m.tools, m.toolThreadID
+	return func() tea.Msg {
+		// tools time out on their own
+		content, err := registry.Run(context.Background(), call)
+		if err != nil {
+			content = "error: " + err.Error()
+		}
+		return ToolResultMsg{ThreadID: threadID, Call: call, Content: content}
+	}
+}
+
+// cmdConnectMCP connects to the mcp servers of the user config in the
+// background once, nothing is done if tools are disabled
+func (m *Model) cmdConnectMCP() tea.Cmd {
+	if m.mcpStarted || m.userConfig == nil || m.userConfig.DisableTools || len(m.userConfig.MCPServers) == 0 {
+		return nil
+	}
+	m.mcpStarted = true
+	servers := mcp.NewServerConfigs(m.userConfig.MCPServers)
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), mcpConnectTimeout)
+		defer cancel()
+		clients, errs := mcp.ConnectAll(ctx, servers)
+		msg := MCPConnectedMsg{Errs: errs}
+		for _, client := range clients {
+			clientTools, err := client.RegistryTools(ctx)
+			if err != nil {
+				_ = client.Close()
+				msg.Errs = append(msg.Errs, fmt.Errorf("mcp server %s: %w", client.Name, err))
+				continue
+			}
+			msg.Clients = append(msg.Clients, client)
+			msg.Tools = append(msg.Tools, clientTools...)
+		}
+		return msg
+	}
+}
+
+// cmdListMCPPrompts lists the prompts of the connected mcp servers with
+// their arguments, optional ones in brackets
+func (m *Model) cmdListMCPPrompts() tea.Cmd {
+	clients := slices.Clone(m.mcpClients)
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), mcpPromptTimeout)
+		defer cancel()
+		var msg MCPPromptsMsg
+		for _, client := range clients {
+			prompts, err := client.ListPrompts(ctx)
+			if err != nil {
+				return MCPPromptsMsg{Err: fmt.Errorf("mcp server %s: %w", client.Name, err)}
+			}
+			for _, prompt := range prompts {
+				usage := client.Name + " " + prompt.Name
+				for _, arg := range prompt.Arguments {
+					if arg.Required {
+						usage += " " + arg.Name + "="
+					} else {
+						usage += " [" + arg.Name + "=]"
+					}
+				}
+				msg.Prompts = append(msg.Prompts, usage)
+			}
+		}
+		return msg
+	}
+}
+
+func (m *Model) cmdGetMCPPrompt(client *mcp.Client, name string, args map[string]string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), mcpPromptTimeout)
+		defer cancel()
+		messages, err := client.GetPrompt(ctx, name, args)
+		return MCPPromptMsg{Messages: messages, Err: err}
+	}
+}
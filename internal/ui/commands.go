@@ -0,0 +1,443 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/export"
+	"github.com/aavshr/panda/internal/mcp"
+	"github.com/aavshr/panda/internal/templates"
+	"github.com/aavshr/panda/internal/ui/components"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var errBusy = errors.New("wait for the answer to finish")
+
+// slashCommand is a command of the chat input and how it is run
+type slashCommand struct {
+	components.SlashCommand
+	run func(m *Model, msg components.ChatInputCommandMsg) tea.Cmd
+}
+
+func newSlashCommands() []slashCommand {
+	return []slashCommand{
+		{components.SlashCommand{Name: "new", Description: "start a new thread"}, (*Model).commandNew},
+		{components.SlashCommand{Name: "model", Args: "[name]", Description: "pick or set the model of the thread"}, (*Model).commandModel},
+		{components.SlashCommand{Name: "system", Args: "<prompt>", Description: "add a system prompt to the thread", Raw: true}, (*Model).commandSystem},
+		{components.SlashCommand{Name: "clear", Description: "delete the messages of the thread"}, (*Model).commandClear},
+		{components.SlashCommand{Name: "export", Args: "[md|json|html]", Description: "export the thread to the working directory"}, (*Model).commandExport},
+		{components.SlashCommand{Name: "search", Args: "[query]", Description: "search the threads, no query lists the latest", Raw: true}, (*Model).commandSearch},
+		{components.SlashCommand{Name: "retry", Description: "ask again for the answer to the last message"}, (*Model).commandRetry},
+		{components.SlashCommand{Name: "template", Args: "[ls|save|rm|<name>]", Description: "list, save, delete or expand templates", Raw: true}, (*Model).commandTemplate},
+		{components.SlashCommand{Name: "prompt", Args: "[<server> <name> [arg=value]...]", Description: "list the prompts of mcp servers or put one into the input"}, (*Model).commandPrompt},
+		{components.SlashCommand{Name: "theme", Args: "[name]", Description: "switch the theme, no name lists the themes"}, (*Model).commandTheme},
+		{components.SlashCommand{Name: "help", Description: "list the commands"}, (*Model).commandHelp},
+	}
+}
+
+func chatInputCommands(slashCommands []slashCommand) []components.SlashCommand {
+	commands := make([]components.SlashCommand, 0, len(slashCommands))
+	for _, command := range slashCommands {
+		commands = append(commands, command.SlashCommand)
+	}
+	return commands
+}
+
+func (m *Model) handleChatInputCommandMsg(msg components.ChatInputCommandMsg) tea.Cmd {
+	for _, command := range m.commands {
+		if command.Name == msg.Name {
+			return command.run(m, msg)
+		}
+	}
+	m.chatInputModel.SetError(fmt.Errorf("unknown command /%s", msg.Name))
+	return nil
+}
+
+// busy reports whether an answer is streamed or tool calls wait, the
+// messages of the thread are not changed until they are done
+func (m *Model) busy() bool {
+	return m.activeLLMStream != nil || len(m.pendingToolCalls) > 0
+}
+
+// isLocal reports whether a message is only shown and never stored or sent,
+// e.g. the output of /help
+func isLocal(message *db.Message) bool {
+	return message.Role == roleSystem && message.ID == ""
+}
+
+func (m *Model) activeThread() (*db.Thread, error) {
+	if m.activeThreadIndex >= len(m.threads) {
+		return nil, fmt.Errorf("invalid active thread index")
+	}
+	return m.threads[m.activeThreadIndex], nil
+}
+
+func (m *Model) commandNew(components.ChatInputCommandMsg) tea.Cmd {
+	if m.busy() {
+		m.chatInputModel.SetError(errBusy)
+		return nil
+	}
+	if err := m.selectActiveThread(0); err != nil {
+		return m.cmdError(err)
+	}
+	m.historyModel.Select(0)
+	return nil
+}
+
+func (m *Model) commandModel(msg components.ChatInputCommandMsg) tea.Cmd {
+	if m.userConfig == nil {
+		m.chatInputModel.SetError(fmt.Errorf("configure panda in the settings first"))
+		return nil
+	}
+	switch len(msg.Args) {
+	case 0:
+		return m.openModelPicker()
+	case 1:
+		cmd := m.handleModelPickerSelectMsg(components.ModelPickerSelectMsg{Model: msg.Args[0]})
+		m.chatInputModel.SetHint("model of the thread: " + msg.Args[0])
+		return cmd
+	}
+	m.chatInputModel.SetError(fmt.Errorf("usage: /model [name]"))
+	return nil
+}
+
+func (m *Model) commandSystem(msg components.ChatInputCommandMsg) tea.Cmd {
+	if m.busy() {
+		m.chatInputModel.SetError(errBusy)
+		return nil
+	}
+	if msg.Rest == "" {
+		m.chatInputModel.SetError(fmt.Errorf("usage: /system <prompt>"))
+		return nil
+	}
+	thread, err := m.ensureThread(msg.Rest)
+	if err != nil {
+		return m.cmdError(err)
+	}
+	systemMessage := &db.Message{
+		Role:      roleSystem,
+		ThreadID:  thread.ID,
+		Content:   msg.Rest,
+		CreatedAt: m.now().Format(timeFormat),
+	}
+	if err := m.store.CreateMessage(systemMessage); err != nil {
+		return m.cmdError(fmt.Errorf("store.CreateMessage: %w", err))
+	}
+	m.setMessages(append(m.messages, systemMessage))
+	m.messagesModel.ScrollToBottom()
+	return nil
+}
+
+func (m *Model) commandClear(components.ChatInputCommandMsg) tea.Cmd {
+	if m.busy() {
+		m.chatInputModel.SetError(errBusy)
+		return nil
+	}
+	thread, err := m.activeThread()
+	if err != nil {
+		return m.cmdError(err)
+	}
+	// the new thread item has no stored messages
+	if m.activeThreadIndex > 0 {
+		if err := m.store.DeleteMessagesByThreadID(thread.ID); err != nil {
+			return m.cmdError(fmt.Errorf("store.DeleteMessagesByThreadID: %w", err))
+		}
+	}
+	m.setMessages([]*db.Message{})
+	m.toolRounds = 0
+	return nil
+}
+
+func (m *Model) commandExport(msg components.ChatInputCommandMsg) tea.Cmd {
+	if len(msg.Args) > 1 {
+		m.chatInputModel.SetError(fmt.Errorf("usage: /export [md|json|html]"))
+		return nil
+	}
+	format := export.FormatMarkdown
+	if len(msg.Args) == 1 {
+		var err error
+		if format, err = export.ParseFormat(msg.Args[0]); err != nil {
+			m.chatInputModel.SetError(err)
+			return nil
+		}
+	}
+	if m.activeThreadIndex == 0 {
+		m.chatInputModel.SetError(fmt.Errorf("nothing to export in a new thread"))
+		return nil
+	}
+	thread, err := m.activeThread()
+	if err != nil {
+		return m.cmdError(err)
+	}
+	name, err := m.exportThread(thread, format)
+	if err != nil {
+		m.chatInputModel.SetError(err)
+		return nil
+	}
+	m.chatInputModel.SetHint("exported to " + name)
+	return nil
+}
+
+// commandSearch shows the threads whose names or messages match the query
+// in the history, the query is searched for as a phrase
+func (m *Model) commandSearch(msg components.ChatInputCommandMsg) tea.Cmd {
+	if m.busy() {
+		m.chatInputModel.SetError(errBusy)
+		return nil
+	}
+	var threads []*db.Thread
+	if msg.Rest == "" {
+		latest, err := m.store.ListLatestThreadsPaginated(0, m.conf.InitThreadsLimit)
+		if err != nil {
+			return m.cmdError(fmt.Errorf("store.ListLatestThreadsPaginated: %w", err))
+		}
+		threads = latest
+	} else {
+		found, err := m.searchThreads(ftsPhrase(msg.Rest))
+		if err != nil {
+			m.chatInputModel.SetError(err)
+			return nil
+		}
+		if len(found) == 0 {
+			m.chatInputModel.SetHint("no threads match " + msg.Rest)
+			return nil
+		}
+		threads = found
+		m.chatInputModel.SetHint(fmt.Sprintf("%d threads match, /search lists the latest again", len(found)))
+	}
+	m.setThreads(append([]*db.Thread{m.threads[0]}, threads...))
+	if err := m.selectActiveThread(0); err != nil {
+		return m.cmdError(err)
+	}
+	m.historyModel.Select(0)
+	return nil
+}
+
+// searchThreads returns the threads matching by name first and then those
+// with matching messages, every thread once
+func (m *Model) searchThreads(query string) ([]*db.Thread, error) {
+	limit := m.conf.InitThreadsLimit
+	byName, err := m.store.SearchThreadNamesPaginated(query, 0, limit)
+	if err != nil {
+		return nil, fmt.Errorf("store.SearchThreadNamesPaginated: %w", err)
+	}
+	messages, err := m.store.SearchMessageContentPaginated(query, 0, limit)
+	if err != nil {
+		return nil, fmt.Errorf("store.SearchMessageContentPaginated: %w", err)
+	}
+	threads := byName
+	seen := make(map[string]struct{})
+	for _, thread := range byName {
+		seen[thread.ID] = struct{}{}
+	}
+	for _, message := range messages {
+		if _, ok := seen[message.ThreadID]; ok || len(threads) >= limit {
+			continue
+		}
+		seen[message.ThreadID] = struct{}{}
+		thread, err := m.store.GetThread(message.ThreadID)
+		if err != nil {
+			return nil, fmt.Errorf("store.GetThread: %w", err)
+		}
+		threads = append(threads, thread)
+	}
+	return threads, nil
+}
+
+// ftsPhrase quotes s so that fts5 searches for it as a phrase instead of
+// parsing it as a query
+func ftsPhrase(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// commandRetry deletes everything after the last message of the user and
+// asks for the answer again
+func (m *Model) commandRetry(components.ChatInputCommandMsg) tea.Cmd {
+	if m.busy() {
+		m.chatInputModel.SetError(errBusy)
+		return nil
+	}
+	thread, err := m.activeThread()
+	if err != nil {
+		return m.cmdError(err)
+	}
+	last := -1
+	for i, message := range slices.Backward(m.messages) {
+		if message.Role == roleUser {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		m.chatInputModel.SetError(fmt.Errorf("no message to retry"))
+		return nil
+	}
+	var ids []string
+	for _, message := range m.messages[last+1:] {
+		if message.ID != "" {
+			ids = append(ids, message.ID)
+		}
+	}
+	if err := m.store.DeleteMessages(ids); err != nil {
+		return m.cmdError(fmt.Errorf("store.DeleteMessages: %w", err))
+	}
+	m.setMessages(m.messages[:last+1])
+	m.toolRounds = 0
+	return m.startCompletion(thread)
+}
+
+func (m *Model) commandTemplate(msg components.ChatInputCommandMsg) tea.Cmd {
+	m.runTemplateCommand(msg.Rest)
+	return nil
+}
+
+// commandPrompt lists the prompts of the mcp servers, or gets a prompt
+// filled with the arguments in the background
+func (m *Model) commandPrompt(msg components.ChatInputCommandMsg) tea.Cmd {
+	if len(msg.Args) == 0 {
+		if len(m.mcpClients) == 0 {
+			m.chatInputModel.SetHint("no mcp servers are connected")
+			return nil
+		}
+		return m.cmdListMCPPrompts()
+	}
+	if len(msg.Args) < 2 {
+		m.chatInputModel.SetError(fmt.Errorf("usage: /prompt [<server> <name> [arg=value]...]"))
+		return nil
+	}
+	i := slices.IndexFunc(m.mcpClients, func(client *mcp.Client) bool {
+		return client.Name == msg.Args[0]
+	})
+	if i < 0 {
+		m.chatInputModel.SetError(fmt.Errorf("no mcp server %s is connected", msg.Args[0]))
+		return nil
+	}
+	args := make(map[string]string)
+	for _, arg := range msg.Args[2:] {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			m.chatInputModel.SetError(fmt.Errorf("expected arg=value, got %q", arg))
+			return nil
+		}
+		args[name] = value
+	}
+	return m.cmdGetMCPPrompt(m.mcpClients[i], msg.Args[1], args)
+}
+
+// commandHelp shows the commands as a message that is not stored
+func (m *Model) commandHelp(components.ChatInputCommandMsg) tea.Cmd {
+	width := 0
+	for _, command := range m.commands {
+		width = max(width, len(command.Usage()))
+	}
+	lines := make([]string, 0, len(m.commands)+1)
+	for _, command := range m.commands {
+		lines = append(lines, fmt.Sprintf("%-*s  %s", width, command.Usage(), command.Description))
+	}
+	lines = append(lines, "start a message with // to send it with a leading /")
+	m.setMessages(append(m.messages, &db.Message{
+		Role:      roleSystem,
+		Content:   strings.Join(lines, "\n"),
+		CreatedAt: m.now().Format(timeFormat),
+	}))
+	m.messagesModel.ScrollToBottom()
+	return nil
+}
+
+// runTemplateCommand lists, saves, deletes or expands templates, the
+// variables of a template are asked for before it is expanded into the
+// input
+func (m *Model) runTemplateCommand(args string) {
+	command, rest := cutWord(args)
+	switch command {
+	case "", "ls":
+		list, err := m.templates.List()
+		if err != nil {
+			m.chatInputModel.SetError(err)
+			return
+		}
+		if len(list) == 0 {
+			m.chatInputModel.SetHint("no templates, save one with /template save <name> <content>")
+			return
+		}
+		names := make([]string, 0, len(list))
+		for _, t := range list {
+			names = append(names, t.Name)
+		}
+		m.chatInputModel.SetHint("templates: " + strings.Join(names, "  "))
+	case "save":
+		name, content := cutWord(rest)
+		if content == "" {
+			m.chatInputModel.SetError(fmt.Errorf("usage: /template save <name> <content>"))
+			return
+		}
+		if err := m.templates.Save(&templates.Template{Name: name, Content: content}); err != nil {
+			m.chatInputModel.SetError(err)
+			return
+		}
+		m.chatInputModel.SetHint("saved template " + name)
+	case "rm":
+		if err := m.templates.Delete(strings.TrimSpace(rest)); err != nil {
+			m.chatInputModel.SetError(err)
+			return
+		}
+		m.chatInputModel.SetHint("deleted template " + strings.TrimSpace(rest))
+	default:
+		t, err := m.templates.Get(command)
+		if err != nil {
+			m.chatInputModel.SetError(err)
+			return
+		}
+		variables := t.Variables()
+		if len(variables) == 0 {
+			m.expandTemplate(t, nil)
+			return
+		}
+		m.pendingTemplate = t
+		questions := make([]string, 0, len(variables))
+		for _, variable := range variables {
+			questions = append(questions, fmt.Sprintf("value of {{%s}}", variable))
+		}
+		m.chatInputModel.Ask(questions)
+	}
+}
+
+func (m *Model) handleChatInputAnswersMsg(msg components.ChatInputAnswersMsg) {
+	t := m.pendingTemplate
+	m.pendingTemplate = nil
+	if t == nil {
+		return
+	}
+	vars := make(map[string]string)
+	for i, variable := range t.Variables() {
+		if i < len(msg.Answers) {
+			vars[variable] = msg.Answers[i]
+		}
+	}
+	m.expandTemplate(t, vars)
+}
+
+// expandTemplate puts the expanded template into the input to be edited
+// and sent, there is no stdin to include in the terminal ui
+func (m *Model) expandTemplate(t *templates.Template, vars map[string]string) {
+	expanded, err := t.Expand(vars, nil)
+	if err != nil {
+		m.chatInputModel.SetError(err)
+		return
+	}
+	m.chatInputModel.SetValue(expanded)
+}
+
+// cutWord splits s after its first word, the rest is trimmed
+func cutWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, unicode.IsSpace)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimSpace(s[i:])
+}
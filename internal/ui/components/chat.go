@@ -2,9 +2,13 @@ package components
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/aavshr/panda/internal/ui/keymap"
 	"github.com/aavshr/panda/internal/ui/styles"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -15,6 +19,39 @@ type Message struct {
 	Content   string
 	CreatedAt string
 	IsUser    bool
+	// Model generated an assistant message, shown instead of "AI" if set
+	Model string
+	// Attachments are the paths of the files attached to the message
+	Attachments []string
+	// Images are the paths of the images sent with the message
+	Images []string
+	// IsTool is set for the result of a tool call
+	IsTool bool
+	// IsSystem is set for system prompts and the output of commands
+	IsSystem bool
+	// ToolCalls are the tool calls of an assistant message as shown,
+	// e.g. read_file {"path":"main.go"}
+	ToolCalls []string
+}
+
+const (
+	chordCopy  = "y"
+	chordWrite = "w"
+
+	// maxToolLines is how much of a tool result is shown, the model gets
+	// all of it
+	maxToolLines = 8
+)
+
+// ToolConfirmMsg answers the confirmation of a tool call
+type ToolConfirmMsg struct {
+	Approved bool
+}
+
+func ToolConfirmCmd(approved bool) tea.Cmd {
+	return func() tea.Msg {
+		return ToolConfirmMsg{Approved: approved}
+	}
 }
 
 type ChatModel struct {
@@ -25,6 +62,20 @@ type ChatModel struct {
 	userStyle      lipgloss.Style
 	assistantStyle lipgloss.Style
 	timestampStyle lipgloss.Style
+	codeLabelStyle lipgloss.Style
+	toolStyle      lipgloss.Style
+
+	// code blocks of assistant messages numbered in display order
+	codeBlocks []CodeBlock
+	// pending key chord for code blocks, e.g. "y" followed by "12"
+	chord       string
+	chordDigits string
+	// path prompt for writing a code block to a file
+	pathInput      textinput.Model
+	pathBlockIndex int
+	// confirm is the tool call waiting for confirmation
+	confirm string
+	keys    *keymap.KeyMap
 }
 
 func NewChatModel(width, height int) ChatModel {
@@ -32,19 +83,40 @@ func NewChatModel(width, height int) ChatModel {
 	vp.KeyMap.PageDown.SetEnabled(true)
 	vp.KeyMap.PageUp.SetEnabled(true)
 
-	userStyle := styles.UserMessageStyle()
-	assistantStyle := styles.AIMessageStyle()
-	timestampStyle := styles.MetadataStyle()
+	pathInput := textinput.New()
+	pathInput.Prompt = "write to: "
 
-	return ChatModel{
+	m := ChatModel{
 		viewport:       vp,
 		messages:       []Message{},
 		width:          width,
 		height:         height,
-		userStyle:      userStyle,
-		assistantStyle: assistantStyle,
-		timestampStyle: timestampStyle,
+		pathInput:      pathInput,
+		pathBlockIndex: -1,
+		keys:           keymap.Default(),
 	}
+	m.setStyles()
+	return m
+}
+
+func (m *ChatModel) setStyles() {
+	m.userStyle = styles.UserMessageStyle()
+	m.assistantStyle = styles.AIMessageStyle()
+	m.timestampStyle = styles.MetadataStyle()
+	m.codeLabelStyle = styles.CodeBlockLabelStyle()
+	m.toolStyle = styles.ToolMessageStyle()
+}
+
+// RefreshStyles rebuilds the styles after the theme changed and renders
+// the messages again
+func (m *ChatModel) RefreshStyles() {
+	m.setStyles()
+	m.updateViewportContent()
+}
+
+// SetKeyMap sets the key bindings
+func (m *ChatModel) SetKeyMap(keys *keymap.KeyMap) {
+	m.keys = keys
 }
 
 func (m *ChatModel) SetMessage(index int, msg Message) {
@@ -68,6 +140,9 @@ func (m *ChatModel) SetSize(width, height int) {
 	m.height = height
 	m.viewport.Width = width
 	m.viewport.Height = height
+	if m.pathBlockIndex >= 0 || m.confirm != "" {
+		m.viewport.Height = height - 1
+	}
 	m.updateViewportContent()
 }
 
@@ -75,23 +150,68 @@ func (m *ChatModel) ScrollToBottom() {
 	m.viewport.GotoBottom()
 }
 
+func (m *ChatModel) CodeBlocks() []CodeBlock {
+	return m.codeBlocks
+}
+
 func (m *ChatModel) formatMessage(msg Message) string {
-	if msg.Content == "" {
+	if msg.Content == "" && len(msg.ToolCalls) == 0 {
 		return ""
 	}
+	content := msg.Content
+	if msg.IsTool {
+		lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+		if len(lines) > maxToolLines {
+			lines = append(lines[:maxToolLines], fmt.Sprintf("... %d more lines", len(lines)-maxToolLines))
+		}
+		content = strings.Join(lines, "\n")
+	} else if !msg.IsUser && !msg.IsSystem {
+		var blocks []CodeBlock
+		blocks, content = numberCodeBlocks(content, len(m.codeBlocks), func(n int, lang string) string {
+			return m.codeLabelStyle.Render(codeBlockLabel(n, lang))
+		})
+		m.codeBlocks = append(m.codeBlocks, blocks...)
+	}
 	var style lipgloss.Style
 	var sender = "You"
 	if msg.IsUser {
 		style = m.userStyle
+	} else if msg.IsTool {
+		style = m.toolStyle
+		sender = "Tool"
+	} else if msg.IsSystem {
+		style = m.toolStyle
+		sender = "System"
 	} else {
 		style = m.assistantStyle
 		sender = "AI"
+		if msg.Model != "" {
+			sender = msg.Model
+		}
 	}
 
 	header := style.Render(sender) + m.timestampStyle.Render(msg.CreatedAt)
 	contentWidth := m.width - 4
-	wrappedContent := wrapText(msg.Content, contentWidth)
+	wrappedContent := wrapText(content, contentWidth)
 	indentedContent := strings.ReplaceAll(wrappedContent, "\n", "\n  ")
+	if len(msg.ToolCalls) > 0 {
+		callStyle := m.toolStyle.Copy().UnsetPaddingLeft()
+		calls := make([]string, 0, len(msg.ToolCalls))
+		for _, call := range msg.ToolCalls {
+			calls = append(calls, callStyle.Render("-> "+call))
+		}
+		if indentedContent != "" {
+			indentedContent += "\n  "
+		}
+		indentedContent += strings.Join(calls, "\n  ")
+	}
+	for _, image := range msg.Images {
+		indentedContent += "\n" + m.timestampStyle.Render("[image: "+image+"]")
+	}
+	if len(msg.Attachments) > 0 {
+		attached := wrapText("attached: "+strings.Join(msg.Attachments, ", "), contentWidth)
+		indentedContent += "\n" + m.timestampStyle.Render(strings.ReplaceAll(attached, "\n", "\n  "))
+	}
 	return fmt.Sprintf("%s\n  %s\n", header, indentedContent)
 }
 
@@ -99,6 +219,7 @@ func (m *ChatModel) formatMessage(msg Message) string {
 func (m *ChatModel) updateViewportContent() {
 	var sb strings.Builder
 
+	m.codeBlocks = m.codeBlocks[:0]
 	for _, msg := range m.messages {
 		sb.WriteString(m.formatMessage(msg))
 		sb.WriteString("\n")
@@ -145,24 +266,163 @@ func wrapText(text string, width int) string {
 }
 
 func (m *ChatModel) View() string {
+	if m.pathBlockIndex >= 0 {
+		return lipgloss.JoinVertical(lipgloss.Left, m.viewport.View(), m.pathInput.View())
+	}
+	if m.confirm != "" {
+		prompt := m.codeLabelStyle.MaxWidth(m.width).Render(fmt.Sprintf("run %s? (y/n)", m.confirm))
+		return lipgloss.JoinVertical(lipgloss.Left, m.viewport.View(), prompt)
+	}
 	return m.viewport.View()
 }
 
+// StartCopyCode waits for the number of the code block to copy like the
+// copy key does, false if there are no code blocks
+func (m *ChatModel) StartCopyCode() bool {
+	return m.startChord(chordCopy)
+}
+
+// StartWriteCode waits for the number of the code block to write to a file
+// like the write key does, false if there are no code blocks
+func (m *ChatModel) StartWriteCode() bool {
+	return m.startChord(chordWrite)
+}
+
+func (m *ChatModel) startChord(chord string) bool {
+	m.resetChord()
+	if len(m.codeBlocks) == 0 {
+		return false
+	}
+	m.chord = chord
+	return true
+}
+
+func (m *ChatModel) resetChord() {
+	m.chord = ""
+	m.chordDigits = ""
+}
+
+// completeChord returns the command for the pending chord once the digits
+// typed so far can only refer to a single code block
+func (m *ChatModel) completeChord(force bool) tea.Cmd {
+	n, err := strconv.Atoi(m.chordDigits)
+	if err != nil || n < 1 || n > len(m.codeBlocks) {
+		m.resetChord()
+		return nil
+	}
+	if !force && n*10 <= len(m.codeBlocks) {
+		// another digit could still follow
+		return nil
+	}
+	index := n - 1
+	chord := m.chord
+	m.resetChord()
+	switch chord {
+	case chordCopy:
+		return CodeBlockCopyCmd(index, m.codeBlocks[index])
+	case chordWrite:
+		m.pathBlockIndex = index
+		m.pathInput.Placeholder = fmt.Sprintf("path for code block %d...", n)
+		m.pathInput.SetValue("")
+		m.viewport.Height = m.height - 1
+		return m.pathInput.Focus()
+	}
+	return nil
+}
+
+func (m *ChatModel) closePathInput() {
+	m.pathBlockIndex = -1
+	m.pathInput.Blur()
+	m.viewport.Height = m.height
+}
+
+func (m *ChatModel) updatePathInput(msg tea.Msg) (ChatModel, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.Type {
+		case tea.KeyEscape:
+			m.closePathInput()
+			return *m, nil
+		case tea.KeyEnter:
+			path := strings.TrimSpace(m.pathInput.Value())
+			if path == "" {
+				return *m, nil
+			}
+			index := m.pathBlockIndex
+			m.closePathInput()
+			return *m, CodeBlockWriteCmd(index, m.codeBlocks[index], path)
+		}
+	}
+	var cmd tea.Cmd
+	m.pathInput, cmd = m.pathInput.Update(msg)
+	return *m, cmd
+}
+
+// ConfirmTool asks to confirm the tool call, the answer is a ToolConfirmMsg
+func (m *ChatModel) ConfirmTool(call string) {
+	m.resetChord()
+	m.confirm = call
+	m.viewport.Height = m.height - 1
+	m.ScrollToBottom()
+}
+
+func (m *ChatModel) closeConfirm() {
+	m.confirm = ""
+	m.viewport.Height = m.height
+}
+
+func (m *ChatModel) updateConfirm(msg tea.Msg) (ChatModel, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "y", "Y", "enter":
+			m.closeConfirm()
+			return *m, ToolConfirmCmd(true)
+		case "n", "N", "esc":
+			m.closeConfirm()
+			return *m, ToolConfirmCmd(false)
+		}
+	}
+	// the messages can be scrolled to read the call before answering
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return *m, cmd
+}
+
 func (m *ChatModel) Update(msg tea.Msg) (ChatModel, tea.Cmd) {
 	var (
 		cmd  tea.Cmd
 		cmds []tea.Cmd
 	)
 
+	if m.pathBlockIndex >= 0 {
+		return m.updatePathInput(msg)
+	}
+	if m.confirm != "" {
+		return m.updateConfirm(msg)
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyEscape:
+		if m.chord != "" {
+			switch {
+			case msg.Type == tea.KeyEnter:
+				return *m, m.completeChord(true)
+			case msg.Type == tea.KeyRunes && len(msg.Runes) == 1 && msg.Runes[0] >= '0' && msg.Runes[0] <= '9':
+				m.chordDigits += string(msg.Runes)
+				return *m, m.completeChord(false)
+			}
+			// any other key cancels the chord
+			m.resetChord()
+			return *m, nil
+		}
+		switch {
+		case key.Matches(msg, m.keys.Back):
 			return *m, EscapeCmd
+		case key.Matches(msg, m.keys.CopyCode) && m.StartCopyCode():
+			return *m, nil
+		case key.Matches(msg, m.keys.WriteCode) && m.StartWriteCode():
+			return *m, nil
 		}
 
-	case tea.WindowSizeMsg:
-		m.SetSize(msg.Width, msg.Height)
 	}
 
 	m.viewport, cmd = m.viewport.Update(msg)
@@ -1,9 +1,15 @@
 package components
 
 import (
+	"fmt"
 	"strings"
+	"unicode"
 
+	"github.com/aavshr/panda/internal/attach"
+	"github.com/aavshr/panda/internal/ui/keymap"
+	"github.com/aavshr/panda/internal/ui/styles"
 	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -11,14 +17,36 @@ import (
 const (
 	valueQuit = "quit"
 	valueExit = "exit"
+	// indent is inserted by tab when it does not send
+	indent = "    "
 )
 
 type ChatInputReturnMsg struct {
 	Value string
 }
 
+// ChatInputAnswersMsg has the answers to the questions of Ask in order
+type ChatInputAnswersMsg struct {
+	Answers []string
+}
+
+// maxSuggestions is how many path completions or commands are listed
+// below the input
+const maxSuggestions = 8
+
 type ChatInputModel struct {
-	inner textarea.Model
+	inner  textarea.Model
+	height int
+	// hint is shown below the input, either the path completions, the
+	// matching commands or an error, its lines are taken from the input
+	hint      string
+	hintLines int
+	isError   bool
+	commands  []SlashCommand
+	keys      *keymap.KeyMap
+	// questions are asked one after the other instead of sending messages
+	questions []string
+	answers   []string
 }
 
 func NewChatInputModel(width, height int) ChatInputModel {
@@ -34,12 +62,23 @@ func NewChatInputModel(width, height int) ChatInputModel {
 	inner.Cursor.SetMode(cursor.CursorBlink)
 
 	return ChatInputModel{
-		inner: inner,
+		inner:  inner,
+		height: height,
+		keys:   keymap.Default(),
 	}
 }
 
 func (c *ChatInputModel) View() string {
-	return c.inner.View()
+	if c.hint == "" {
+		return c.inner.View()
+	}
+	style := styles.HintStyle()
+	if c.isError {
+		style = styles.ErrorStyle()
+	}
+	lines := strings.SplitN(c.hint, "\n", c.hintLines+1)
+	hint := strings.Join(lines[:min(len(lines), c.hintLines)], "\n")
+	return c.inner.View() + "\n" + style.MaxWidth(c.inner.Width()).Render(hint)
 }
 
 func (c *ChatInputModel) Focus() tea.Cmd {
@@ -54,34 +93,189 @@ func (c *ChatInputModel) Value() string {
 	return c.inner.Value()
 }
 
+// SetValue replaces the input, e.g. to restore a message that could not be sent
+func (c *ChatInputModel) SetValue(value string) {
+	c.inner.SetValue(value)
+}
+
+// SetError shows err below the input until the next key press
+func (c *ChatInputModel) SetError(err error) {
+	c.setHint(err.Error(), true)
+}
+
+// SetHint shows hint below the input until the next key press
+func (c *ChatInputModel) SetHint(hint string) {
+	c.setHint(hint, false)
+}
+
+// Ask asks the questions one after the other, the answers are sent with
+// ChatInputAnswersMsg and esc cancels
+func (c *ChatInputModel) Ask(questions []string) {
+	c.questions = questions
+	c.answers = make([]string, 0, len(questions))
+	c.inner.Reset()
+	c.clearHint()
+}
+
+func (c *ChatInputModel) asking() bool {
+	return len(c.answers) < len(c.questions)
+}
+
+// clearHint removes the hint, the current question stays while asking
+func (c *ChatInputModel) clearHint() {
+	if c.asking() {
+		c.setHint(fmt.Sprintf("%s (%d/%d, esc to cancel)", c.questions[len(c.answers)], len(c.answers)+1, len(c.questions)), false)
+		return
+	}
+	c.setHint("", false)
+}
+
+// answer takes the input as the answer to the current question
+func (c *ChatInputModel) answer() tea.Cmd {
+	c.answers = append(c.answers, c.inner.Value())
+	c.inner.Reset()
+	if c.asking() {
+		c.clearHint()
+		return nil
+	}
+	answers := c.answers
+	c.questions, c.answers = nil, nil
+	c.clearHint()
+	return func() tea.Msg {
+		return ChatInputAnswersMsg{Answers: answers}
+	}
+}
+
+// setHint shows the hint below the input, at least one line of the input
+// is kept and a longer hint is cut
+func (c *ChatInputModel) setHint(hint string, isError bool) {
+	c.hint, c.isError = hint, isError
+	c.hintLines = 0
+	if hint != "" {
+		c.hintLines = max(1, min(strings.Count(hint, "\n")+1, c.height-1))
+	}
+	c.inner.SetHeight(max(1, c.height-c.hintLines))
+}
+
+// complete completes an @path at the end of the input, it returns false if
+// there is nothing to complete so that tab sends the message
+func (c *ChatInputModel) complete() bool {
+	value := c.inner.Value()
+	start := strings.LastIndexFunc(value, unicode.IsSpace) + 1
+	token := value[start:]
+	if !strings.HasPrefix(token, attach.RefPrefix) {
+		return false
+	}
+	prefix := strings.TrimPrefix(token, attach.RefPrefix)
+	candidates := attach.Complete(prefix)
+	if len(candidates) == 0 {
+		return false
+	}
+	completed := attach.CommonPrefix(candidates)
+	if completed == prefix && len(candidates) == 1 {
+		return false
+	}
+	c.inner.SetValue(value[:start] + attach.RefPrefix + completed)
+	if len(candidates) == 1 {
+		c.setHint("", false)
+		return true
+	}
+	suggestions := candidates
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	hint := strings.Join(suggestions, "  ")
+	if len(candidates) > maxSuggestions {
+		hint += "  ..."
+	}
+	c.setHint(hint, false)
+	return true
+}
+
 func (c *ChatInputModel) EnterCmd(value string) tea.Cmd {
 	return func() tea.Msg {
 		return ChatInputReturnMsg{Value: value}
 	}
 }
 
+// SetSize resizes the input, the hint keeps its lines
+func (c *ChatInputModel) SetSize(width, height int) {
+	c.inner.SetWidth(width)
+	c.height = height
+	c.setHint(c.hint, c.isError)
+}
+
+// send runs the command or sends the message in the input
+// Send sends the input like the send key does, during the questions of a
+// template it answers the question
+func (c *ChatInputModel) Send() tea.Cmd {
+	if c.asking() {
+		return c.answer()
+	}
+	return c.send()
+}
+
+func (c *ChatInputModel) send() tea.Cmd {
+	value := strings.TrimSpace(c.inner.Value())
+	if strings.ToLower(value) == valueQuit || strings.ToLower(value) == valueExit {
+		return tea.Quit
+	}
+	if isCommand(value) {
+		return c.runCommand(value)
+	}
+	// a double slash sends a message starting with a slash
+	value = strings.TrimPrefix(value, commandPrefix)
+
+	if value == "" {
+		return nil
+	}
+	c.inner.Reset()
+	return c.EnterCmd(value)
+}
+
 func (c *ChatInputModel) Update(msg tea.Msg) (ChatInputModel, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		var cmd tea.Cmd
+		c.inner, cmd = c.inner.Update(msg)
+		return *c, cmd
+	}
+	if c.hint != "" {
+		c.clearHint()
+	}
+	// the bindings are matched before the textarea gets the key so that
+	// e.g. enter can send
+	switch {
+	case key.Matches(keyMsg, c.keys.Complete) && c.complete():
+		return *c, nil
+	case key.Matches(keyMsg, c.keys.Send) && c.asking():
+		return *c, c.answer()
+	case key.Matches(keyMsg, c.keys.Complete) && c.completeCommand():
+		return *c, nil
+	case key.Matches(keyMsg, c.keys.Send):
+		return *c, c.send()
+	case key.Matches(keyMsg, c.keys.Complete):
+		// complete does not send, with nothing to complete it indents
+		c.inner.InsertString(indent)
+		return *c, nil
+	case key.Matches(keyMsg, c.keys.Back):
+		if c.asking() {
+			c.questions, c.answers = nil, nil
+			c.inner.Reset()
+			c.clearHint()
+			return *c, nil
+		}
+		return *c, EscapeCmd
+	}
 	var cmd tea.Cmd
 	c.inner, cmd = c.inner.Update(msg)
-
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyCtrlD:
-			return *c, tea.Quit
-		case tea.KeyTab:
-			value := strings.TrimSpace(c.inner.Value())
-			if strings.ToLower(value) == valueQuit || strings.ToLower(value) == valueExit {
-				return *c, tea.Quit
-			}
-
-			if value != "" {
-				c.inner.Reset()
-				return *c, c.EnterCmd(value)
-			}
-		case tea.KeyEscape:
-			return *c, EscapeCmd
-		}
+	if !c.asking() {
+		c.showCommandSuggestions()
 	}
 	return *c, cmd
 }
+
+// SetKeyMap sets the key bindings
+func (c *ChatInputModel) SetKeyMap(keys *keymap.KeyMap) {
+	c.keys = keys
+}
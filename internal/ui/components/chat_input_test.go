@@ -0,0 +1,174 @@
+package components
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aavshr/panda/internal/ui/keymap"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestChatInputComplete(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"main.go", "main_test.go"} {
+		if err := os.WriteFile(filepath.Join(root, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewChatInputModel(40, 3)
+	c.Focus()
+	c.SetValue("explain @m")
+	if _, cmd := c.Update(tea.KeyMsg{Type: tea.KeyTab}); cmd != nil {
+		t.Fatal("expected tab to complete instead of sending")
+	}
+	if c.Value() != "explain @main" || c.hint != "main.go  main_test.go" {
+		t.Fatalf("expected the common prefix and both files listed, got %q and %q", c.Value(), c.hint)
+	}
+
+	c.SetValue("explain @main.")
+	c.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if c.Value() != "explain @main.go" || c.hint != "" {
+		t.Fatalf("expected the single match to be completed, got %q and %q", c.Value(), c.hint)
+	}
+
+	// nothing left to complete so tab sends the message
+	_, cmd := c.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if cmd == nil {
+		t.Fatal("expected a command")
+	}
+	if msg, ok := cmd().(ChatInputReturnMsg); !ok || msg.Value != "explain @main.go" {
+		t.Errorf("expected the message to be sent, got %+v", msg)
+	}
+}
+
+func TestChatInputAsk(t *testing.T) {
+	c := NewChatInputModel(40, 3)
+	c.Focus()
+	c.SetCommands([]SlashCommand{{Name: "template", Raw: true}})
+	c.SetValue("/template review")
+	_, cmd := c.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if msg, ok := cmd().(ChatInputCommandMsg); !ok || msg.Name != "template" || msg.Rest != "review" || msg.Args != nil {
+		t.Fatalf("expected a template command, got %+v", msg)
+	}
+
+	c.Ask([]string{"value of {{lang}}", "value of {{focus}}"})
+	if c.hint != "value of {{lang}} (1/2, esc to cancel)" {
+		t.Errorf("expected the first question, got %q", c.hint)
+	}
+	c.SetValue("go")
+	if _, cmd := c.Update(tea.KeyMsg{Type: tea.KeyTab}); cmd != nil {
+		t.Fatal("expected the second question instead of a command")
+	}
+	// the question stays after typing
+	c.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if c.hint != "value of {{focus}} (2/2, esc to cancel)" {
+		t.Errorf("expected the second question, got %q", c.hint)
+	}
+	c.SetValue("errors")
+	_, cmd = c.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if msg, ok := cmd().(ChatInputAnswersMsg); !ok || len(msg.Answers) != 2 || msg.Answers[0] != "go" || msg.Answers[1] != "errors" {
+		t.Errorf("expected both answers, got %+v", msg)
+	}
+	if c.hint != "" || c.asking() {
+		t.Errorf("expected the questions to be done, got %q", c.hint)
+	}
+
+	c.Ask([]string{"value of {{lang}}"})
+	if _, cmd := c.Update(tea.KeyMsg{Type: tea.KeyEscape}); cmd != nil || c.asking() {
+		t.Error("expected esc to cancel the questions")
+	}
+}
+
+func TestChatInputCommands(t *testing.T) {
+	c := NewChatInputModel(60, 6)
+	c.Focus()
+	c.SetCommands([]SlashCommand{
+		{Name: "model", Args: "[name]", Description: "set the model"},
+		{Name: "new", Description: "start a new thread"},
+		{Name: "help", Description: "list the commands"},
+	})
+
+	c.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	if strings.Count(c.hint, "\n") != 2 || !strings.HasPrefix(c.hint, "/help") {
+		t.Errorf("expected all commands to be listed sorted, got %q", c.hint)
+	}
+	c.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("m")})
+	if !strings.HasPrefix(c.hint, "/model [name]  set the model") || strings.Contains(c.hint, "\n") {
+		t.Errorf("expected only /model to be listed, got %q", c.hint)
+	}
+	if _, cmd := c.Update(tea.KeyMsg{Type: tea.KeyTab}); cmd != nil || c.Value() != "/model " {
+		t.Fatalf("expected the name to be completed, got %q", c.Value())
+	}
+	c.SetValue(`/model "gpt 4o"`)
+	_, cmd := c.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if msg, ok := cmd().(ChatInputCommandMsg); !ok || msg.Name != "model" || !reflect.DeepEqual(msg.Args, []string{"gpt 4o"}) {
+		t.Errorf("expected the quoted argument, got %+v", msg)
+	}
+	if c.Value() != "" {
+		t.Errorf("expected the input to be reset, got %q", c.Value())
+	}
+
+	c.SetValue("/unknown")
+	if _, cmd := c.Update(tea.KeyMsg{Type: tea.KeyTab}); cmd != nil || !c.isError || c.Value() != "/unknown" {
+		t.Errorf("expected an error and the input to be kept, got %q", c.hint)
+	}
+	c.SetValue(`/model "gpt`)
+	if _, cmd := c.Update(tea.KeyMsg{Type: tea.KeyTab}); cmd != nil || !c.isError {
+		t.Errorf("expected an error for the unterminated quote, got %q", c.hint)
+	}
+
+	// a double slash is sent as a message without the first slash
+	c.SetValue("//etc/hosts is empty")
+	_, cmd = c.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if msg, ok := cmd().(ChatInputReturnMsg); !ok || msg.Value != "/etc/hosts is empty" {
+		t.Errorf("expected a message, got %+v", msg)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	for input, expected := range map[string][]string{
+		"":                   nil,
+		"  a  b ":            {"a", "b"},
+		`"a b" 'c "d"' e\ f`: {"a b", `c "d"`, "e f"},
+		`'' "" x`:            {"", "", "x"},
+		`a\"b`:               {`a"b`},
+		`"it's"`:             {"it's"},
+		`\`:                  {`\`},
+	} {
+		args, err := ParseArgs(input)
+		if err != nil || !reflect.DeepEqual(args, expected) {
+			t.Errorf("ParseArgs(%q) = %q, %v, expected %q", input, args, err, expected)
+		}
+	}
+	if _, err := ParseArgs(`a "b`); !errors.Is(err, ErrUnterminatedQuote) {
+		t.Errorf("expected ErrUnterminatedQuote, got %v", err)
+	}
+}
+
+func TestChatInputKeyMap(t *testing.T) {
+	c := NewChatInputModel(40, 3)
+	c.Focus()
+	c.SetKeyMap(keymap.Vim())
+	c.SetValue("func main() {\n")
+	// tab indents when it does not send
+	if _, cmd := c.Update(tea.KeyMsg{Type: tea.KeyTab}); cmd != nil || c.Value() != "func main() {\n"+indent {
+		t.Fatalf("expected tab to indent, got %q", c.Value())
+	}
+	_, cmd := c.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if cmd == nil {
+		t.Fatal("expected ctrl+s to send")
+	}
+	if msg, ok := cmd().(ChatInputReturnMsg); !ok || msg.Value != "func main() {" {
+		t.Errorf("expected the message to be sent, got %+v", msg)
+	}
+}
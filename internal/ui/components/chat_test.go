@@ -0,0 +1,31 @@
+package components
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestChatConfirmTool(t *testing.T) {
+	m := NewChatModel(80, 10)
+	m.AddMessage(Message{ToolCalls: []string{`read_file {"path":"main.go"}`}})
+	m.ConfirmTool(`read_file {"path":"main.go"}`)
+	view := m.View()
+	if !strings.Contains(view, `-> read_file {"path":"main.go"}`) || !strings.Contains(view, "(y/n)") {
+		t.Fatalf("expected the call and the prompt in the view, got %q", view)
+	}
+	for key, approved := range map[string]bool{"y": true, "n": false} {
+		m.ConfirmTool("calculate {}")
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		if cmd == nil {
+			t.Fatalf("%s: expected a command", key)
+		}
+		if msg, ok := cmd().(ToolConfirmMsg); !ok || msg.Approved != approved {
+			t.Errorf("%s: expected approved %v, got %+v", key, approved, msg)
+		}
+		if m.confirm != "" {
+			t.Errorf("%s: expected the prompt to close", key)
+		}
+	}
+}
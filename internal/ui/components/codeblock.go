@@ -0,0 +1,107 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const codeFence = "```"
+
+// CodeBlock is a fenced code block found in a message
+type CodeBlock struct {
+	Lang string
+	Code string
+}
+
+type CodeBlockCopyMsg struct {
+	Index int
+	Block CodeBlock
+}
+
+type CodeBlockWriteMsg struct {
+	Index int
+	Block CodeBlock
+	Path  string
+}
+
+func CodeBlockCopyCmd(index int, block CodeBlock) tea.Cmd {
+	return func() tea.Msg {
+		return CodeBlockCopyMsg{Index: index, Block: block}
+	}
+}
+
+func CodeBlockWriteCmd(index int, block CodeBlock, path string) tea.Cmd {
+	return func() tea.Msg {
+		return CodeBlockWriteMsg{Index: index, Block: block, Path: path}
+	}
+}
+
+// isFence reports whether the line opens or closes a fenced code block
+// and returns the info string after the fence
+func isFence(line string) (bool, string) {
+	trimmed := strings.TrimLeft(line, " ")
+	// fences can be indented by at most 3 spaces
+	if len(line)-len(trimmed) > 3 || !strings.HasPrefix(trimmed, codeFence) {
+		return false, ""
+	}
+	return true, strings.TrimSpace(strings.TrimLeft(trimmed, "`"))
+}
+
+// ExtractCodeBlocks returns the fenced code blocks in content in order
+// an unterminated block at the end is included so that numbering stays
+// stable while a message is still streaming
+func ExtractCodeBlocks(content string) []CodeBlock {
+	blocks, _ := numberCodeBlocks(content, 0, nil)
+	return blocks
+}
+
+// numberCodeBlocks finds the fenced code blocks in content and inserts a
+// label line above each one starting from offset+1
+func numberCodeBlocks(content string, offset int, label func(n int, lang string) string) ([]CodeBlock, string) {
+	var (
+		blocks  []CodeBlock
+		current *CodeBlock
+		code    []string
+		out     strings.Builder
+	)
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		fence, info := isFence(line)
+		switch {
+		case fence && current == nil:
+			current = &CodeBlock{}
+			if fields := strings.Fields(info); len(fields) > 0 {
+				current.Lang = fields[0]
+			}
+			code = code[:0]
+			if label != nil {
+				out.WriteString(label(offset+len(blocks)+1, current.Lang))
+				out.WriteString("\n")
+			}
+		case fence && info == "":
+			current.Code = strings.Join(code, "\n")
+			blocks = append(blocks, *current)
+			current = nil
+		case current != nil:
+			code = append(code, line)
+		}
+		out.WriteString(line)
+	}
+	if current != nil {
+		current.Code = strings.Join(code, "\n")
+		blocks = append(blocks, *current)
+	}
+	return blocks, out.String()
+}
+
+func codeBlockLabel(n int, lang string) string {
+	if lang == "" {
+		return fmt.Sprintf("[%d]", n)
+	}
+	return fmt.Sprintf("[%d] %s", n, lang)
+}
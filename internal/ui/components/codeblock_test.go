@@ -0,0 +1,55 @@
+package components
+
+import (
+	"testing"
+)
+
+func TestExtractCodeBlocks(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected []CodeBlock
+	}{
+		{
+			"no blocks",
+			"just some text",
+			nil,
+		},
+		{
+			"multiple blocks",
+			"first\n```go\nfmt.Println(1)\n```\nthen\n```\necho hi\necho bye\n```",
+			[]CodeBlock{
+				{Lang: "go", Code: "fmt.Println(1)"},
+				{Lang: "", Code: "echo hi\necho bye"},
+			},
+		},
+		{
+			"nested fence with info string is code",
+			"```markdown\n```go\nx\n```",
+			[]CodeBlock{
+				{Lang: "markdown", Code: "```go\nx"},
+			},
+		},
+		{
+			"unterminated block while streaming",
+			"```python\nprint(1)",
+			[]CodeBlock{
+				{Lang: "python", Code: "print(1)"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			blocks := ExtractCodeBlocks(tc.content)
+			if len(blocks) != len(tc.expected) {
+				t.Fatalf("expected %d blocks, got %d: %+v", len(tc.expected), len(blocks), blocks)
+			}
+			for i, block := range blocks {
+				if block != tc.expected[i] {
+					t.Errorf("block %d: expected %+v, got %+v", i, tc.expected[i], block)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package components
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/aavshr/panda/internal/attach"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const commandPrefix = "/"
+
+var ErrUnterminatedQuote = errors.New("unterminated quote")
+
+// SlashCommand is a command typed into the chat input, e.g. /model gpt-4o,
+// commands are never sent as messages
+type SlashCommand struct {
+	// Name is the name without the slash
+	Name string
+	// Args describes the arguments, e.g. "[name]"
+	Args        string
+	Description string
+	// Raw commands take free text, e.g. a prompt, their arguments are not
+	// split and only Rest is set
+	Raw bool
+}
+
+// Usage returns the command as it is typed with its arguments
+func (c SlashCommand) Usage() string {
+	if c.Args == "" {
+		return commandPrefix + c.Name
+	}
+	return commandPrefix + c.Name + " " + c.Args
+}
+
+// ChatInputCommandMsg is sent for a slash command, Args are the arguments
+// split like a shell does and Rest is the input after the name as typed
+type ChatInputCommandMsg struct {
+	Name string
+	Args []string
+	Rest string
+}
+
+// SetCommands sets the slash commands that are completed and dispatched
+func (c *ChatInputModel) SetCommands(commands []SlashCommand) {
+	c.commands = append([]SlashCommand(nil), commands...)
+	sort.Slice(c.commands, func(i, j int) bool { return c.commands[i].Name < c.commands[j].Name })
+}
+
+// isCommand reports whether the input is a slash command, a double slash
+// sends a message starting with a slash
+func isCommand(value string) bool {
+	return strings.HasPrefix(value, commandPrefix) && !strings.HasPrefix(value, commandPrefix+commandPrefix)
+}
+
+func (c *ChatInputModel) commandsWithPrefix(prefix string) []SlashCommand {
+	var matches []SlashCommand
+	for _, command := range c.commands {
+		if strings.HasPrefix(command.Name, prefix) {
+			matches = append(matches, command)
+		}
+	}
+	return matches
+}
+
+func (c *ChatInputModel) findCommand(name string) (SlashCommand, bool) {
+	for _, command := range c.commands {
+		if command.Name == name {
+			return command, true
+		}
+	}
+	return SlashCommand{}, false
+}
+
+// typedCommandName returns the name while the name of a command is typed
+func typedCommandName(value string) (string, bool) {
+	if !isCommand(value) || strings.IndexFunc(value, unicode.IsSpace) >= 0 {
+		return "", false
+	}
+	return strings.TrimPrefix(value, commandPrefix), true
+}
+
+// showCommandSuggestions lists the commands matching the typed name below
+// the input
+func (c *ChatInputModel) showCommandSuggestions() {
+	name, ok := typedCommandName(c.inner.Value())
+	if !ok {
+		return
+	}
+	matches := c.commandsWithPrefix(name)
+	if len(matches) == 0 {
+		c.setHint(fmt.Sprintf("unknown command /%s, /help lists the commands", name), true)
+		return
+	}
+	width := 0
+	for _, command := range matches {
+		width = max(width, len(command.Usage()))
+	}
+	lines := make([]string, 0, len(matches))
+	for i, command := range matches {
+		if i == maxSuggestions {
+			lines = append(lines, "...")
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%-*s  %s", width, command.Usage(), command.Description))
+	}
+	c.setHint(strings.Join(lines, "\n"), false)
+}
+
+// completeCommand completes the name of a command, it returns false if
+// the name is complete so that tab runs the command
+func (c *ChatInputModel) completeCommand() bool {
+	name, ok := typedCommandName(c.inner.Value())
+	if !ok {
+		return false
+	}
+	if _, ok := c.findCommand(name); ok {
+		return false
+	}
+	matches := c.commandsWithPrefix(name)
+	switch len(matches) {
+	case 0:
+		return false
+	case 1:
+		// the space shows that the arguments follow
+		c.inner.SetValue(commandPrefix + matches[0].Name + " ")
+		c.clearHint()
+		return true
+	}
+	names := make([]string, 0, len(matches))
+	for _, command := range matches {
+		names = append(names, command.Name)
+	}
+	c.inner.SetValue(commandPrefix + attach.CommonPrefix(names))
+	c.showCommandSuggestions()
+	return true
+}
+
+// runCommand turns the input into a ChatInputCommandMsg, unknown commands
+// and invalid arguments are shown as errors and the input is kept
+func (c *ChatInputModel) runCommand(value string) tea.Cmd {
+	name, rest := strings.TrimPrefix(value, commandPrefix), ""
+	if i := strings.IndexFunc(name, unicode.IsSpace); i >= 0 {
+		name, rest = name[:i], strings.TrimSpace(name[i:])
+	}
+	command, ok := c.findCommand(name)
+	if !ok {
+		c.setHint(fmt.Sprintf("unknown command /%s, /help lists the commands", name), true)
+		return nil
+	}
+	var args []string
+	if !command.Raw {
+		var err error
+		if args, err = ParseArgs(rest); err != nil {
+			c.setHint(fmt.Sprintf("/%s: %v", name, err), true)
+			return nil
+		}
+	}
+	c.inner.Reset()
+	return func() tea.Msg {
+		return ChatInputCommandMsg{Name: name, Args: args, Rest: rest}
+	}
+}
+
+// ParseArgs splits s at whitespace, single quotes keep everything up to
+// the next single quote and double quotes and backslashes escape like in
+// a shell
+func ParseArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inArg = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, ErrUnterminatedQuote
+	}
+	if escaped {
+		current.WriteRune('\\')
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -12,6 +12,10 @@ const (
 	ComponentInnerMessages Component = "innerMessages"
 	ComponentChatInput     Component = "chatInput"
 	ComponentSettings      Component = "settings"
+	ComponentParams        Component = "params"
+	ComponentModelPicker   Component = "modelPicker"
+	ComponentPalette       Component = "palette"
+	ComponentKeyHelp       Component = "keyHelp"
 	ComponentNone          Component = "none" // utility component
 )
 
@@ -0,0 +1,80 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/aavshr/panda/internal/ui/keymap"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type KeyHelpCloseMsg struct{}
+
+func KeyHelpCloseCmd() tea.Msg {
+	return KeyHelpCloseMsg{}
+}
+
+// contextTitles are the headings of the contexts in the help
+var contextTitles = map[keymap.Context]string{
+	keymap.ContextAll:      "Everywhere",
+	keymap.ContextMain:     "Nothing focused",
+	keymap.ContextHistory:  "History",
+	keymap.ContextMessages: "Messages",
+	keymap.ContextChat:     "Chat input",
+}
+
+// KeyHelpModel lists the key bindings by where they work and warns about
+// conflicting bindings
+type KeyHelpModel struct {
+	keys *keymap.KeyMap
+
+	dialogStyles
+}
+
+func NewKeyHelpModel() KeyHelpModel {
+	return KeyHelpModel{
+		keys:         keymap.Default(),
+		dialogStyles: newDialogStyles(),
+	}
+}
+
+// SetKeyMap sets the key bindings
+func (m *KeyHelpModel) SetKeyMap(keys *keymap.KeyMap) {
+	m.keys = keys
+}
+
+func (m *KeyHelpModel) View() string {
+	bindings := m.keys.Bindings()
+	width := 0
+	for _, b := range bindings {
+		width = max(width, len(b.Help().Key))
+	}
+	rows := []string{m.titleStyle.Render("Keys")}
+	for _, context := range keymap.Contexts {
+		rows = append(rows, "", m.focusedStyle.Render(contextTitles[context]))
+		for _, b := range bindings {
+			if b.Context != context || !b.Enabled() {
+				continue
+			}
+			rows = append(rows, m.labelStyle.Render(fmt.Sprintf("  %-*s  %s", width, b.Help().Key, b.Help().Desc)))
+		}
+	}
+	if conflicts := m.keys.Conflicts(); len(conflicts) > 0 {
+		rows = append(rows, "")
+		for _, conflict := range conflicts {
+			rows = append(rows, m.errorStyle.Render("conflict: "+conflict.String()))
+		}
+	}
+	rows = append(rows, "", m.metadataStyle.Render(fmt.Sprintf("%s close", m.keys.Back.Help().Key)))
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+func (m *KeyHelpModel) Update(msg tea.Msg) (KeyHelpModel, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		if key.Matches(keyMsg, m.keys.Back, m.keys.Help) {
+			return *m, KeyHelpCloseCmd
+		}
+	}
+	return *m, nil
+}
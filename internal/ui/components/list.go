@@ -3,6 +3,7 @@ package components
 import (
 	"strings"
 
+	"github.com/aavshr/panda/internal/ui/keymap"
 	"github.com/aavshr/panda/internal/ui/styles"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
@@ -21,6 +22,10 @@ type ListDeleteMsg struct {
 	Index int
 }
 
+type ListExportMsg struct {
+	Index int
+}
+
 func ListEnterCmd(selectedIndex int) func() tea.Msg {
 	return func() tea.Msg {
 		return ListEnterMsg{
@@ -45,8 +50,18 @@ func ListDeleteCmd(selectedIndex int) func() tea.Msg {
 	}
 }
 
+func ListExportCmd(selectedIndex int) func() tea.Msg {
+	return func() tea.Msg {
+		return ListExportMsg{
+			Index: selectedIndex,
+		}
+	}
+}
+
 type ListModel struct {
-	inner list.Model
+	inner    list.Model
+	delegate list.ItemDelegate
+	keys     *keymap.KeyMap
 }
 
 type NewListModelInput struct {
@@ -77,7 +92,24 @@ func NewListModel(i *NewListModelInput) ListModel {
 
 	model.InfiniteScrolling = i.AllowInfiniteScrolling
 
-	return ListModel{inner: model}
+	m := ListModel{inner: model, delegate: i.Delegate}
+	m.SetKeyMap(keymap.Default())
+	return m
+}
+
+// SetKeyMap sets the key bindings, the cursor moves with up and down
+func (m *ListModel) SetKeyMap(keys *keymap.KeyMap) {
+	m.keys = keys
+	m.inner.KeyMap.CursorUp = keys.Up
+	m.inner.KeyMap.CursorDown = keys.Down
+}
+
+// RefreshStyles rebuilds the styles after the theme changed
+func (m *ListModel) RefreshStyles() {
+	m.inner.Styles.Title = styles.DefaultListStyle()
+	if d, ok := m.delegate.(interface{ RefreshStyles() }); ok {
+		d.RefreshStyles()
+	}
 }
 
 func (m *ListModel) Focus() {
@@ -90,6 +122,22 @@ func (m *ListModel) Blur() {
 	m.inner.Select(-1)
 }
 
+// StartFilter starts typing a filter as if the filter key was pressed
+func (m *ListModel) StartFilter() tea.Cmd {
+	keys := m.inner.KeyMap.Filter.Keys()
+	if len(keys) == 0 || len([]rune(keys[0])) != 1 {
+		return nil
+	}
+	var cmd tea.Cmd
+	m.inner, cmd = m.inner.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(keys[0])})
+	return cmd
+}
+
+// Filtering reports whether a filter is being typed
+func (m *ListModel) Filtering() bool {
+	return m.inner.FilterState() == list.Filtering
+}
+
 func (m *ListModel) Select(i int) {
 	m.inner.Select(i)
 }
@@ -102,6 +150,10 @@ func (m *ListModel) SetItem(index int, item list.Item) tea.Cmd {
 	return m.inner.SetItem(index, item)
 }
 
+func (m *ListModel) SetSize(width, height int) {
+	m.inner.SetSize(width, height)
+}
+
 func (m *ListModel) View() string {
 	return m.inner.View()
 }
@@ -118,19 +170,20 @@ func (m *ListModel) Update(msg tea.Msg) (ListModel, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyEscape:
+		// typed keys go to the filter while filtering
+		if m.inner.FilterState() == list.Filtering {
+			return *m, cmd
+		}
+		index := m.inner.Index()
+		switch {
+		case key.Matches(msg, m.keys.Back):
 			return *m, EscapeCmd
-		case tea.KeyEnter:
-			index := m.inner.Index()
-			if index >= 0 {
-				return *m, ListEnterCmd(index)
-			}
-		case tea.KeyCtrlD:
-			index := m.inner.Index()
-			if index >= 0 {
-				return *m, ListDeleteCmd(index)
-			}
+		case msg.Type == tea.KeyEnter && index >= 0:
+			return *m, ListEnterCmd(index)
+		case key.Matches(msg, m.keys.DeleteThread) && index >= 0:
+			return *m, ListDeleteCmd(index)
+		case key.Matches(msg, m.keys.ExportThread) && index >= 0:
+			return *m, ListExportCmd(index)
 		}
 		if key.Matches(msg, m.inner.KeyMap.CursorUp) || key.Matches(msg, m.inner.KeyMap.CursorDown) {
 			return *m, ListSelectCmd(m.inner.Index())
@@ -0,0 +1,209 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const modelPickerHeight = 12
+
+// ModelPickerSelectMsg picks a model for the active thread or, with
+// Default, as the configured model
+type ModelPickerSelectMsg struct {
+	Model   string
+	Default bool
+}
+
+// ModelPickerRefreshMsg asks to list the models of the backend again
+type ModelPickerRefreshMsg struct{}
+
+// ModelPickerLoadedMsg is the result of listing the models of the backend
+type ModelPickerLoadedMsg struct {
+	Models []string
+	Err    error
+}
+
+type ModelPickerCloseMsg struct{}
+
+func ModelPickerSelectCmd(msg ModelPickerSelectMsg) tea.Cmd {
+	return func() tea.Msg {
+		return msg
+	}
+}
+
+func ModelPickerRefreshCmd() tea.Msg {
+	return ModelPickerRefreshMsg{}
+}
+
+func ModelPickerCloseCmd() tea.Msg {
+	return ModelPickerCloseMsg{}
+}
+
+// filterModels returns the models containing query, ignoring case
+func filterModels(models []string, query string) []string {
+	query = strings.ToLower(strings.TrimSpace(query))
+	var matches []string
+	for _, model := range models {
+		if strings.Contains(strings.ToLower(model), query) {
+			matches = append(matches, model)
+		}
+	}
+	return matches
+}
+
+type ModelPickerModel struct {
+	filter   textinput.Model
+	models   []string
+	matches  []string
+	selected int
+	// current is the model the active thread uses
+	current string
+
+	loading bool
+	err     error
+
+	dialogStyles
+}
+
+func NewModelPickerModel() ModelPickerModel {
+	filter := textinput.New()
+	filter.Prompt = "> "
+	filter.Placeholder = "filter or enter a model name"
+	return ModelPickerModel{
+		filter:       filter,
+		dialogStyles: newDialogStyles(),
+	}
+}
+
+// Open resets the picker with the cached models, loading is set while the
+// models are listed again
+func (m *ModelPickerModel) Open(current string, models []string, loading bool) tea.Cmd {
+	m.current = current
+	m.loading = loading
+	m.err = nil
+	m.filter.SetValue("")
+	m.setModels(models)
+	m.selectCurrent()
+	return m.filter.Focus()
+}
+
+func (m *ModelPickerModel) selectCurrent() {
+	for i, model := range m.matches {
+		if model == m.current {
+			m.selected = i
+		}
+	}
+}
+
+func (m *ModelPickerModel) Blur() {
+	m.filter.Blur()
+}
+
+func (m *ModelPickerModel) setModels(models []string) {
+	m.models = models
+	m.updateMatches()
+}
+
+func (m *ModelPickerModel) updateMatches() {
+	m.matches = filterModels(m.models, m.filter.Value())
+	if m.selected >= len(m.matches) {
+		m.selected = 0
+	}
+}
+
+// choice is the selected model, the typed name if nothing matches so that
+// models the backend does not list can be used
+func (m *ModelPickerModel) choice() string {
+	if len(m.matches) > 0 {
+		return m.matches[m.selected]
+	}
+	return strings.TrimSpace(m.filter.Value())
+}
+
+func (m *ModelPickerModel) View() string {
+	rows := []string{m.titleStyle.Render("Models"), "", m.filter.View(), ""}
+	start := 0
+	if m.selected >= modelPickerHeight {
+		start = m.selected - modelPickerHeight + 1
+	}
+	for i := start; i < len(m.matches) && i < start+modelPickerHeight; i++ {
+		name := m.matches[i]
+		if name == m.current {
+			name += " (current)"
+		}
+		if i == m.selected {
+			rows = append(rows, m.focusedStyle.Render("> "+name))
+		} else {
+			rows = append(rows, m.labelStyle.Render("  "+name))
+		}
+	}
+	if len(m.matches) == 0 && !m.loading {
+		if value := strings.TrimSpace(m.filter.Value()); value != "" {
+			rows = append(rows, m.metadataStyle.Render(fmt.Sprintf("  use %q", value)))
+		} else {
+			rows = append(rows, m.metadataStyle.Render("  no models"))
+		}
+	}
+	rows = append(rows, "")
+	switch {
+	case m.err != nil:
+		rows = append(rows, m.errorStyle.Render(fmt.Sprintf("could not list models: %v", m.err)))
+	case m.loading:
+		rows = append(rows, m.metadataStyle.Render("listing models..."))
+	}
+	rows = append(rows, m.metadataStyle.Render("↑/↓ move • enter use in thread • ctrl+d set as default • ctrl+r refresh • esc close"))
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+func (m *ModelPickerModel) Update(msg tea.Msg) (ModelPickerModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ModelPickerLoadedMsg:
+		m.loading = false
+		m.err = msg.Err
+		if msg.Err == nil {
+			m.setModels(msg.Models)
+			if m.filter.Value() == "" {
+				m.selectCurrent()
+			}
+		}
+		return *m, nil
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return *m, tea.Quit
+		case tea.KeyEscape:
+			return *m, ModelPickerCloseCmd
+		case tea.KeyUp, tea.KeyCtrlP:
+			if len(m.matches) > 0 {
+				m.selected = (m.selected - 1 + len(m.matches)) % len(m.matches)
+			}
+			return *m, nil
+		case tea.KeyDown, tea.KeyCtrlN, tea.KeyTab:
+			if len(m.matches) > 0 {
+				m.selected = (m.selected + 1) % len(m.matches)
+			}
+			return *m, nil
+		case tea.KeyCtrlR:
+			m.loading = true
+			m.err = nil
+			return *m, ModelPickerRefreshCmd
+		case tea.KeyEnter, tea.KeyCtrlD:
+			model := m.choice()
+			if model == "" {
+				return *m, nil
+			}
+			return *m, ModelPickerSelectCmd(ModelPickerSelectMsg{
+				Model:   model,
+				Default: msg.Type == tea.KeyCtrlD,
+			})
+		}
+	}
+	var cmd tea.Cmd
+	m.filter, cmd = m.filter.Update(msg)
+	m.updateMatches()
+	return *m, cmd
+}
@@ -0,0 +1,48 @@
+package components
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func pickerSelect(t *testing.T, m *ModelPickerModel, keyType tea.KeyType) ModelPickerSelectMsg {
+	t.Helper()
+	_, cmd := m.Update(tea.KeyMsg{Type: keyType})
+	if cmd == nil {
+		t.Fatal("expected a command")
+	}
+	msg, ok := cmd().(ModelPickerSelectMsg)
+	if !ok {
+		t.Fatalf("expected ModelPickerSelectMsg, got %T", msg)
+	}
+	return msg
+}
+
+func TestModelPicker(t *testing.T) {
+	m := NewModelPickerModel()
+	m.Open("o3-mini", []string{"gpt-4o", "gpt-4o-mini", "o3-mini"}, false)
+	if msg := pickerSelect(t, &m, tea.KeyEnter); msg.Model != "o3-mini" || msg.Default {
+		t.Errorf("expected the current model to be selected, got %+v", msg)
+	}
+
+	for _, r := range "MINI" {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	if len(m.matches) != 2 {
+		t.Fatalf("expected 2 matches, got %v", m.matches)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if msg := pickerSelect(t, &m, tea.KeyCtrlD); msg.Model != "o3-mini" || !msg.Default {
+		t.Errorf("expected o3-mini as default, got %+v", msg)
+	}
+
+	// models that are not listed can be typed in
+	m.Open("o3-mini", []string{"gpt-4o"}, false)
+	for _, r := range "llama3" {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	if msg := pickerSelect(t, &m, tea.KeyEnter); msg.Model != "llama3" {
+		t.Errorf("expected the typed model, got %+v", msg)
+	}
+}
@@ -0,0 +1,172 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sahilm/fuzzy"
+)
+
+const paletteHeight = 12
+
+// PaletteAction is an action listed in the command palette
+type PaletteAction struct {
+	ID    string
+	Title string
+	// Keys are the key bindings of the action, e.g. ctrl+e or /new
+	Keys []string
+	// Context is where the keys work, e.g. history
+	Context string
+}
+
+func (a PaletteAction) keys() string {
+	return strings.Join(a.Keys, " ")
+}
+
+// PaletteRunMsg runs the action with the ID
+type PaletteRunMsg struct {
+	ID string
+}
+
+type PaletteCloseMsg struct{}
+
+func PaletteRunCmd(id string) tea.Cmd {
+	return func() tea.Msg {
+		return PaletteRunMsg{ID: id}
+	}
+}
+
+func PaletteCloseCmd() tea.Msg {
+	return PaletteCloseMsg{}
+}
+
+// actionSource matches the titles, keys and contexts of actions
+type actionSource []PaletteAction
+
+func (s actionSource) String(i int) string {
+	return s[i].Title + " " + s[i].keys() + " " + s[i].Context
+}
+
+func (s actionSource) Len() int {
+	return len(s)
+}
+
+// filterActions returns the actions fuzzy matching query, best matches
+// first, all actions in their order without a query
+func filterActions(actions []PaletteAction, query string) []PaletteAction {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return actions
+	}
+	matches := fuzzy.FindFrom(query, actionSource(actions))
+	filtered := make([]PaletteAction, 0, len(matches))
+	for _, match := range matches {
+		filtered = append(filtered, actions[match.Index])
+	}
+	return filtered
+}
+
+type PaletteModel struct {
+	filter   textinput.Model
+	actions  []PaletteAction
+	matches  []PaletteAction
+	selected int
+
+	dialogStyles
+}
+
+func NewPaletteModel() PaletteModel {
+	filter := textinput.New()
+	filter.Prompt = "> "
+	filter.Placeholder = "type to search actions"
+	return PaletteModel{
+		filter:       filter,
+		dialogStyles: newDialogStyles(),
+	}
+}
+
+// Open resets the palette with the actions
+func (m *PaletteModel) Open(actions []PaletteAction) tea.Cmd {
+	m.actions = actions
+	m.selected = 0
+	m.filter.SetValue("")
+	m.updateMatches()
+	return m.filter.Focus()
+}
+
+func (m *PaletteModel) Blur() {
+	m.filter.Blur()
+}
+
+func (m *PaletteModel) updateMatches() {
+	m.matches = filterActions(m.actions, m.filter.Value())
+	if m.selected >= len(m.matches) {
+		m.selected = 0
+	}
+}
+
+func (m *PaletteModel) View() string {
+	rows := []string{m.titleStyle.Render("Actions"), "", m.filter.View(), ""}
+	start := 0
+	if m.selected >= paletteHeight {
+		start = m.selected - paletteHeight + 1
+	}
+	titleWidth, keysWidth := 0, 0
+	for _, action := range m.matches {
+		titleWidth = max(titleWidth, len(action.Title))
+		keysWidth = max(keysWidth, len(action.keys()))
+	}
+	for i := start; i < len(m.matches) && i < start+paletteHeight; i++ {
+		action := m.matches[i]
+		line := fmt.Sprintf("%-*s  %-*s  %s", titleWidth, action.Title, keysWidth, action.keys(), action.Context)
+		if i == m.selected {
+			rows = append(rows, m.focusedStyle.Render("> "+line))
+		} else {
+			rows = append(rows, m.labelStyle.Render("  "+line))
+		}
+	}
+	if len(m.matches) == 0 {
+		rows = append(rows, m.metadataStyle.Render("  no actions"))
+	}
+	rows = append(rows, "", m.metadataStyle.Render("↑/↓ move • enter run • esc close"))
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+func (m *PaletteModel) Update(msg tea.Msg) (PaletteModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return *m, tea.Quit
+		case tea.KeyEscape:
+			return *m, PaletteCloseCmd
+		case tea.KeyUp, tea.KeyCtrlP:
+			if len(m.matches) > 0 {
+				m.selected = (m.selected - 1 + len(m.matches)) % len(m.matches)
+			}
+			return *m, nil
+		case tea.KeyDown, tea.KeyCtrlN, tea.KeyTab:
+			if len(m.matches) > 0 {
+				m.selected = (m.selected + 1) % len(m.matches)
+			}
+			return *m, nil
+		case tea.KeyEnter:
+			if len(m.matches) == 0 {
+				return *m, nil
+			}
+			return *m, PaletteRunCmd(m.matches[m.selected].ID)
+		}
+	}
+	query := m.filter.Value()
+	var cmd tea.Cmd
+	m.filter, cmd = m.filter.Update(msg)
+	if m.filter.Value() != query {
+		// the best match is selected after typing
+		m.selected = 0
+	}
+	m.updateMatches()
+	return *m, cmd
+}
@@ -0,0 +1,47 @@
+package components
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPalette(t *testing.T) {
+	m := NewPaletteModel()
+	m.Open([]PaletteAction{
+		{ID: "settings", Title: "Open settings", Keys: []string{"s"}, Context: "global"},
+		{ID: "delete-thread", Title: "Delete thread", Keys: []string{"ctrl+d"}, Context: "history"},
+		{ID: "export-thread", Title: "Export thread to markdown", Keys: []string{"ctrl+e"}, Context: "history"},
+	})
+	if len(m.matches) != 3 {
+		t.Fatalf("expected all actions without a query, got %v", m.matches)
+	}
+
+	for _, r := range "exth" {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	if len(m.matches) != 1 || m.matches[0].ID != "export-thread" {
+		t.Fatalf("expected the fuzzy match, got %v", m.matches)
+	}
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if msg, ok := cmd().(PaletteRunMsg); !ok || msg.ID != "export-thread" {
+		t.Errorf("expected the action to run, got %+v", msg)
+	}
+
+	// keys and contexts are matched as well
+	m.Open(m.actions)
+	for _, r := range "ctrl+d" {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	if len(m.matches) == 0 || m.matches[0].ID != "delete-thread" {
+		t.Errorf("expected the action bound to ctrl+d first, got %v", m.matches)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("zzz")})
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter}); cmd != nil {
+		t.Error("expected nothing to run without matches")
+	}
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEscape}); cmd == nil || cmd() != (PaletteCloseMsg{}) {
+		t.Error("expected esc to close the palette")
+	}
+}
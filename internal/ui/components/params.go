@@ -0,0 +1,299 @@
+package components
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aavshr/panda/internal/llm"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type paramsField int
+
+const (
+	paramsFieldTemperature paramsField = iota
+	paramsFieldTopP
+	paramsFieldMaxTokens
+	paramsFieldSeed
+	paramsFieldStop
+	paramsFieldReasoningEffort
+	paramsFieldSave
+	paramsFieldClear
+	paramsFieldCount
+
+	paramsLabelWidth = 18
+)
+
+// reasoningEffortOptions are cycled through on the params panel, empty
+// inherits the config
+var reasoningEffortOptions = append([]string{""}, llm.ReasoningEfforts...)
+
+// ParamsSubmitMsg sets the generation params of the active thread
+type ParamsSubmitMsg struct {
+	Params llm.Params
+}
+
+type ParamsCloseMsg struct{}
+
+func ParamsSubmitCmd(msg ParamsSubmitMsg) tea.Cmd {
+	return func() tea.Msg {
+		return msg
+	}
+}
+
+func ParamsCloseCmd() tea.Msg {
+	return ParamsCloseMsg{}
+}
+
+// ParamsModel edits the generation params of a thread, empty fields
+// inherit the params of the config
+type ParamsModel struct {
+	inputs    map[paramsField]*textinput.Model
+	focused   paramsField
+	effortIdx int
+
+	threadName string
+	defaults   llm.Params
+	err        error
+
+	dialogStyles
+}
+
+func NewParamsModel() ParamsModel {
+	inputs := make(map[paramsField]*textinput.Model)
+	for _, field := range []paramsField{paramsFieldTemperature, paramsFieldTopP, paramsFieldMaxTokens, paramsFieldSeed, paramsFieldStop} {
+		inputs[field] = newSettingsInput("", textinput.EchoNormal)
+	}
+	return ParamsModel{
+		inputs:       inputs,
+		dialogStyles: newDialogStyles(),
+	}
+}
+
+func formatFloat(f *float32) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(float64(*f), 'f', -1, 32)
+}
+
+func formatInt(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+func formatPositiveInt(i int) string {
+	if i <= 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
+
+// placeholder shows the inherited value of an empty field
+func placeholder(inherited string) string {
+	if inherited == "" {
+		return "default"
+	}
+	return inherited + " (config)"
+}
+
+// SetValues fills the form with the params of a thread, defaults are the
+// params of the config shown for empty fields
+func (m *ParamsModel) SetValues(threadName string, params, defaults llm.Params) {
+	m.threadName = threadName
+	m.defaults = defaults
+	m.err = nil
+
+	values := map[paramsField][2]string{
+		paramsFieldTemperature: {formatFloat(params.Temperature), formatFloat(defaults.Temperature)},
+		paramsFieldTopP:        {formatFloat(params.TopP), formatFloat(defaults.TopP)},
+		paramsFieldMaxTokens:   {formatPositiveInt(params.MaxTokens), formatPositiveInt(defaults.MaxTokens)},
+		paramsFieldSeed:        {formatInt(params.Seed), formatInt(defaults.Seed)},
+		paramsFieldStop:        {strings.Join(params.Stop, ", "), strings.Join(defaults.Stop, ", ")},
+	}
+	for field, v := range values {
+		m.inputs[field].SetValue(v[0])
+		m.inputs[field].Placeholder = placeholder(v[1])
+	}
+	m.effortIdx = 0
+	for i, effort := range reasoningEffortOptions {
+		if effort == params.ReasoningEffort {
+			m.effortIdx = i
+		}
+	}
+}
+
+func (m *ParamsModel) Focus() tea.Cmd {
+	return m.focusField(paramsFieldTemperature)
+}
+
+func (m *ParamsModel) Blur() {
+	for _, input := range m.inputs {
+		input.Blur()
+	}
+}
+
+func (m *ParamsModel) focusField(field paramsField) tea.Cmd {
+	m.Blur()
+	m.focused = field
+	if input, ok := m.inputs[field]; ok {
+		return input.Focus()
+	}
+	return nil
+}
+
+func (m *ParamsModel) moveFocus(delta int) tea.Cmd {
+	return m.focusField((m.focused + paramsField(delta) + paramsFieldCount) % paramsFieldCount)
+}
+
+func (m *ParamsModel) parseFloat(field paramsField, name string) (*float32, error) {
+	s := strings.TrimSpace(m.inputs[field].Value())
+	if s == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", name)
+	}
+	v := float32(f)
+	return &v, nil
+}
+
+// values validates the form and returns the params of the thread
+func (m *ParamsModel) values() (llm.Params, error) {
+	var (
+		params llm.Params
+		err    error
+	)
+	if params.Temperature, err = m.parseFloat(paramsFieldTemperature, "temperature"); err != nil {
+		return params, err
+	}
+	if params.TopP, err = m.parseFloat(paramsFieldTopP, "top_p"); err != nil {
+		return params, err
+	}
+	if s := strings.TrimSpace(m.inputs[paramsFieldMaxTokens].Value()); s != "" {
+		if params.MaxTokens, err = strconv.Atoi(s); err != nil {
+			return params, fmt.Errorf("max tokens must be a number")
+		}
+	}
+	if s := strings.TrimSpace(m.inputs[paramsFieldSeed].Value()); s != "" {
+		seed, err := strconv.Atoi(s)
+		if err != nil {
+			return params, fmt.Errorf("seed must be a number")
+		}
+		params.Seed = &seed
+	}
+	for _, stop := range strings.Split(m.inputs[paramsFieldStop].Value(), ",") {
+		if stop = strings.TrimSpace(stop); stop != "" {
+			params.Stop = append(params.Stop, stop)
+		}
+	}
+	params.ReasoningEffort = reasoningEffortOptions[m.effortIdx]
+	return params, params.Validate()
+}
+
+func (m *ParamsModel) submit() tea.Cmd {
+	params, err := m.values()
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	m.err = nil
+	return ParamsSubmitCmd(ParamsSubmitMsg{Params: params})
+}
+
+func (m *ParamsModel) renderField(field paramsField, label, value string) string {
+	style := m.labelStyle
+	if field == m.focused {
+		style = m.focusedStyle
+	}
+	return style.Copy().Width(paramsLabelWidth).Render(label) + value
+}
+
+func (m *ParamsModel) renderButton(field paramsField, label string) string {
+	if field == m.focused {
+		return m.focusedStyle.Render("[ " + label + " ]")
+	}
+	return m.labelStyle.Render("[ " + label + " ]")
+}
+
+func (m *ParamsModel) View() string {
+	effort := reasoningEffortOptions[m.effortIdx]
+	if effort == "" {
+		effort = placeholder(m.defaults.ReasoningEffort)
+	}
+	rows := []string{
+		m.titleStyle.Render("Parameters"),
+		m.metadataStyle.Render(m.threadName),
+		"",
+		m.renderField(paramsFieldTemperature, "Temperature", m.inputs[paramsFieldTemperature].View()),
+		m.renderField(paramsFieldTopP, "Top p", m.inputs[paramsFieldTopP].View()),
+		m.renderField(paramsFieldMaxTokens, "Max tokens", m.inputs[paramsFieldMaxTokens].View()),
+		m.renderField(paramsFieldSeed, "Seed", m.inputs[paramsFieldSeed].View()),
+		m.renderField(paramsFieldStop, "Stop sequences", m.inputs[paramsFieldStop].View()),
+		m.renderField(paramsFieldReasoningEffort, "Reasoning effort", fmt.Sprintf("‹ %s ›", effort)),
+		"",
+		m.renderButton(paramsFieldSave, "Save") + "  " + m.renderButton(paramsFieldClear, "Use config"),
+		"",
+	}
+	if m.err != nil {
+		rows = append(rows, m.errorStyle.Render(m.err.Error()))
+	}
+	rows = append(rows, m.metadataStyle.Render("↑/↓ move • enter next • ←/→ reasoning effort • stop sequences are comma separated • esc close"))
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+func (m *ParamsModel) Update(msg interface{}) (ParamsModel, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.Type {
+		case tea.KeyCtrlC:
+			return *m, tea.Quit
+		case tea.KeyEscape:
+			return *m, ParamsCloseCmd
+		case tea.KeyUp, tea.KeyShiftTab:
+			return *m, m.moveFocus(-1)
+		case tea.KeyDown, tea.KeyTab:
+			return *m, m.moveFocus(1)
+		case tea.KeyEnter:
+			switch m.focused {
+			case paramsFieldSave:
+				return *m, m.submit()
+			case paramsFieldClear:
+				return *m, ParamsSubmitCmd(ParamsSubmitMsg{})
+			}
+			return *m, m.moveFocus(1)
+		}
+		switch m.focused {
+		case paramsFieldReasoningEffort:
+			switch keyMsg.Type {
+			case tea.KeyLeft:
+				m.effortIdx = (m.effortIdx - 1 + len(reasoningEffortOptions)) % len(reasoningEffortOptions)
+			case tea.KeyRight, tea.KeySpace:
+				m.effortIdx = (m.effortIdx + 1) % len(reasoningEffortOptions)
+			}
+			return *m, nil
+		case paramsFieldSave, paramsFieldClear:
+			switch keyMsg.Type {
+			case tea.KeyLeft, tea.KeyRight:
+				if m.focused == paramsFieldSave {
+					return *m, m.focusField(paramsFieldClear)
+				}
+				return *m, m.focusField(paramsFieldSave)
+			}
+			return *m, nil
+		}
+	}
+
+	input, ok := m.inputs[m.focused]
+	if !ok {
+		return *m, nil
+	}
+	var cmd tea.Cmd
+	*input, cmd = input.Update(msg)
+	return *m, cmd
+}
@@ -1,81 +1,453 @@
 package components
 
 import (
+	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 
+	"github.com/aavshr/panda/internal/ui/styles"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
-type SettingsMode int
+type settingsField int
 
 const (
-	SettingsModeAPIKey SettingsMode = iota
-	SettingsModeLLMModel
+	settingsFieldAPIKey settingsField = iota
+	settingsFieldPassphrase
+	settingsFieldModel
+	settingsFieldTemperature
+	settingsFieldMaxTokens
+	settingsFieldBaseURL
+	settingsFieldTheme
+	settingsFieldTest
+	settingsFieldSave
+	settingsFieldCount
+
+	settingsLabelWidth     = 14
+	settingsDropdownHeight = 6
+	defaultLLMModel        = "o3-mini"
 )
 
+// apiKeyRefPrefixes mark an api key input as a reference instead of a key
+var apiKeyRefPrefixes = []string{"env:", "cmd:", "secrets:"}
+
+// SettingsValues are the values edited on the settings screen
+type SettingsValues struct {
+	// APIKey is either the key itself or a reference like env:OPENAI_API_KEY,
+	// empty keeps the current key
+	APIKey      string
+	IsRef       bool
+	Passphrase  string
+	LLMModel    string
+	Temperature *float32
+	MaxTokens   int
+	BaseURL     string
+	Theme       string
+}
+
 type SettingsSubmitMsg struct {
-	APIKey   string
-	LLMModel string
+	SettingsValues
 }
 
-type SettingsModel struct {
-	inner textinput.Model
-	mode  SettingsMode
-	msg   SettingsSubmitMsg
+// SettingsTestMsg asks to list the models with the entered key and base url
+type SettingsTestMsg struct {
+	SettingsValues
+	// Submit saves the settings if the test succeeds
+	Submit bool
+}
+
+// SettingsTestResultMsg is the result of a SettingsTestMsg
+type SettingsTestResultMsg struct {
+	Models []string
+	Err    error
+	Submit bool
 }
 
+type SettingsCloseMsg struct{}
+
 func SettingsSubmitCmd(msg SettingsSubmitMsg) tea.Cmd {
 	return func() tea.Msg {
 		return msg
 	}
 }
 
+func SettingsTestCmd(msg SettingsTestMsg) tea.Cmd {
+	return func() tea.Msg {
+		return msg
+	}
+}
+
+func SettingsCloseCmd() tea.Msg {
+	return SettingsCloseMsg{}
+}
+
+type SettingsModel struct {
+	inputs   map[settingsField]*textinput.Model
+	focused  settingsField
+	themeIdx int
+	// themes are the theme names offered, the built-in ones by default
+	themes []string
+
+	// hasAPIKey allows saving without entering the key again
+	hasAPIKey bool
+	// firstRun settings can not be closed without saving
+	firstRun bool
+
+	models        []string
+	modelMatches  []string
+	modelSelected int
+
+	testing bool
+	status  string
+	err     error
+
+	dialogStyles
+}
+
+func newSettingsInput(placeholder string, echoMode textinput.EchoMode) *textinput.Model {
+	input := textinput.New()
+	input.Prompt = ""
+	input.Placeholder = placeholder
+	input.EchoMode = echoMode
+	return &input
+}
+
 func NewSettingsModel() SettingsModel {
-	inner := textinput.New()
-	inner.Placeholder = "Enter your API key..."
 	return SettingsModel{
-		inner: inner,
-		mode:  SettingsModeAPIKey,
+		inputs: map[settingsField]*textinput.Model{
+			// keys and passphrases are never shown on screen
+			settingsFieldAPIKey:      newSettingsInput("API key or a reference like env:OPENAI_API_KEY", textinput.EchoPassword),
+			settingsFieldPassphrase:  newSettingsInput("passphrase to encrypt the key", textinput.EchoPassword),
+			settingsFieldModel:       newSettingsInput(defaultLLMModel, textinput.EchoNormal),
+			settingsFieldTemperature: newSettingsInput("default", textinput.EchoNormal),
+			settingsFieldMaxTokens:   newSettingsInput("default", textinput.EchoNormal),
+			settingsFieldBaseURL:     newSettingsInput("https://api.openai.com/v1", textinput.EchoNormal),
+		},
+		themes:       styles.Names(nil),
+		firstRun:     true,
+		dialogStyles: newDialogStyles(),
 	}
 }
 
+// SetValues fills the form with the current settings, the api key and
+// passphrase are never filled in
+func (m *SettingsModel) SetValues(values SettingsValues, hasAPIKey bool) {
+	m.hasAPIKey = hasAPIKey
+	m.firstRun = !hasAPIKey
+	m.inputs[settingsFieldAPIKey].SetValue("")
+	m.inputs[settingsFieldPassphrase].SetValue("")
+	if hasAPIKey {
+		m.inputs[settingsFieldAPIKey].Placeholder = "unchanged"
+	}
+	m.inputs[settingsFieldModel].SetValue(values.LLMModel)
+	m.inputs[settingsFieldTemperature].SetValue("")
+	if values.Temperature != nil {
+		m.inputs[settingsFieldTemperature].SetValue(strconv.FormatFloat(float64(*values.Temperature), 'f', -1, 32))
+	}
+	m.inputs[settingsFieldMaxTokens].SetValue("")
+	if values.MaxTokens > 0 {
+		m.inputs[settingsFieldMaxTokens].SetValue(strconv.Itoa(values.MaxTokens))
+	}
+	m.inputs[settingsFieldBaseURL].SetValue(values.BaseURL)
+	m.themeIdx = 0
+	for i, theme := range m.themes {
+		if theme == values.Theme {
+			m.themeIdx = i
+		}
+	}
+	m.status = ""
+	m.err = nil
+	m.updateModelMatches()
+}
+
+// SetThemes sets the theme names offered, it is called before SetValues
+func (m *SettingsModel) SetThemes(names []string) {
+	m.themes = names
+}
+
+// SetModels sets the models suggested for the model field
+func (m *SettingsModel) SetModels(models []string) {
+	m.models = models
+	m.updateModelMatches()
+}
+
 func (m *SettingsModel) Focus() tea.Cmd {
-	return m.inner.Focus()
+	return m.focusField(settingsFieldAPIKey)
 }
 
 func (m *SettingsModel) Blur() {
-	m.inner.Blur()
+	for _, input := range m.inputs {
+		input.Blur()
+	}
+}
+
+func (m *SettingsModel) focusField(field settingsField) tea.Cmd {
+	m.Blur()
+	m.focused = field
+	if input, ok := m.inputs[field]; ok {
+		return input.Focus()
+	}
+	return nil
+}
+
+func isAPIKeyRef(value string) bool {
+	for _, prefix := range apiKeyRefPrefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// needsPassphrase reports whether a new key was entered that will be encrypted
+func (m *SettingsModel) needsPassphrase() bool {
+	key := strings.TrimSpace(m.inputs[settingsFieldAPIKey].Value())
+	return key != "" && !isAPIKeyRef(key)
+}
+
+func (m *SettingsModel) fieldVisible(field settingsField) bool {
+	if field == settingsFieldPassphrase {
+		return m.needsPassphrase()
+	}
+	return true
+}
+
+func (m *SettingsModel) moveFocus(delta int) tea.Cmd {
+	field := m.focused
+	for {
+		field = (field + settingsField(delta) + settingsFieldCount) % settingsFieldCount
+		if m.fieldVisible(field) {
+			return m.focusField(field)
+		}
+	}
+}
+
+func (m *SettingsModel) updateModelMatches() {
+	m.modelMatches = filterModels(m.models, m.inputs[settingsFieldModel].Value())
+	if m.modelSelected >= len(m.modelMatches) {
+		m.modelSelected = 0
+	}
+}
+
+// values validates the form and returns its values
+func (m *SettingsModel) values() (SettingsValues, error) {
+	values := SettingsValues{
+		APIKey:     strings.TrimSpace(m.inputs[settingsFieldAPIKey].Value()),
+		Passphrase: m.inputs[settingsFieldPassphrase].Value(),
+		LLMModel:   strings.TrimSpace(m.inputs[settingsFieldModel].Value()),
+		BaseURL:    strings.TrimSpace(m.inputs[settingsFieldBaseURL].Value()),
+		Theme:      m.themes[m.themeIdx],
+	}
+	values.IsRef = isAPIKeyRef(values.APIKey)
+	if values.APIKey == "" && !m.hasAPIKey {
+		return values, fmt.Errorf("an API key is required")
+	}
+	if m.needsPassphrase() && values.Passphrase == "" {
+		return values, fmt.Errorf("a passphrase is required to encrypt the API key")
+	}
+	if values.LLMModel == "" {
+		values.LLMModel = defaultLLMModel
+	}
+	if s := strings.TrimSpace(m.inputs[settingsFieldTemperature].Value()); s != "" {
+		temperature, err := strconv.ParseFloat(s, 32)
+		if err != nil || temperature < 0 || temperature > 2 {
+			return values, fmt.Errorf("temperature must be a number between 0 and 2")
+		}
+		t := float32(temperature)
+		values.Temperature = &t
+	}
+	if s := strings.TrimSpace(m.inputs[settingsFieldMaxTokens].Value()); s != "" {
+		maxTokens, err := strconv.Atoi(s)
+		if err != nil || maxTokens < 0 {
+			return values, fmt.Errorf("max tokens must be a positive number")
+		}
+		values.MaxTokens = maxTokens
+	}
+	if values.BaseURL != "" {
+		u, err := url.Parse(values.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return values, fmt.Errorf("base url must be an http or https url")
+		}
+	}
+	return values, nil
+}
+
+func (m *SettingsModel) test(submit bool) tea.Cmd {
+	values, err := m.values()
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	m.err = nil
+	m.testing = true
+	m.status = "testing connection..."
+	return SettingsTestCmd(SettingsTestMsg{SettingsValues: values, Submit: submit})
+}
+
+func (m *SettingsModel) handleTestResult(msg SettingsTestResultMsg) tea.Cmd {
+	m.testing = false
+	if msg.Err != nil {
+		m.status = ""
+		m.err = fmt.Errorf("connection failed: %w", msg.Err)
+		return nil
+	}
+	m.err = nil
+	m.models = msg.Models
+	m.updateModelMatches()
+	m.status = fmt.Sprintf("connected, %d models available", len(msg.Models))
+	if msg.Submit {
+		values, err := m.values()
+		if err != nil {
+			m.err = err
+			return nil
+		}
+		return SettingsSubmitCmd(SettingsSubmitMsg{SettingsValues: values})
+	}
+	return nil
+}
+
+func (m *SettingsModel) renderField(field settingsField, label, value string) string {
+	style := m.labelStyle
+	if field == m.focused {
+		style = m.focusedStyle
+	}
+	return style.Copy().Width(settingsLabelWidth).Render(label) + value
+}
+
+func (m *SettingsModel) renderButton(field settingsField, label string) string {
+	if field == m.focused {
+		return m.focusedStyle.Render("[ " + label + " ]")
+	}
+	return m.labelStyle.Render("[ " + label + " ]")
 }
 
 func (m *SettingsModel) View() string {
-	return m.inner.View()
+	rows := []string{m.titleStyle.Render("Settings"), ""}
+	rows = append(rows, m.renderField(settingsFieldAPIKey, "API key", m.inputs[settingsFieldAPIKey].View()))
+	if m.needsPassphrase() {
+		rows = append(rows, m.renderField(settingsFieldPassphrase, "Passphrase", m.inputs[settingsFieldPassphrase].View()))
+	}
+	rows = append(rows, m.renderField(settingsFieldModel, "Model", m.inputs[settingsFieldModel].View()))
+	if m.focused == settingsFieldModel && len(m.modelMatches) > 0 {
+		start := 0
+		if m.modelSelected >= settingsDropdownHeight {
+			start = m.modelSelected - settingsDropdownHeight + 1
+		}
+		for i := start; i < len(m.modelMatches) && i < start+settingsDropdownHeight; i++ {
+			item := m.metadataStyle.Render(m.modelMatches[i])
+			if i == m.modelSelected {
+				item = m.focusedStyle.Render("> " + m.modelMatches[i])
+			}
+			rows = append(rows, strings.Repeat(" ", settingsLabelWidth)+item)
+		}
+	}
+	rows = append(rows,
+		m.renderField(settingsFieldTemperature, "Temperature", m.inputs[settingsFieldTemperature].View()),
+		m.renderField(settingsFieldMaxTokens, "Max tokens", m.inputs[settingsFieldMaxTokens].View()),
+		m.renderField(settingsFieldBaseURL, "Base URL", m.inputs[settingsFieldBaseURL].View()),
+		m.renderField(settingsFieldTheme, "Theme", fmt.Sprintf("‹ %s ›", m.themes[m.themeIdx])),
+		"",
+		m.renderButton(settingsFieldTest, "Test connection")+"  "+m.renderButton(settingsFieldSave, "Save"),
+		"",
+	)
+	switch {
+	case m.err != nil:
+		rows = append(rows, m.errorStyle.Render(m.err.Error()))
+	case m.status != "":
+		rows = append(rows, m.metadataStyle.Render(m.status))
+	}
+	help := "↑/↓ move • enter next • ctrl+n/ctrl+p/tab pick model • ←/→ theme • ctrl+c quit"
+	if !m.firstRun {
+		help += " • esc close"
+	}
+	rows = append(rows, m.metadataStyle.Render(help))
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
 }
 
 func (m *SettingsModel) Update(msg interface{}) (SettingsModel, tea.Cmd) {
-	var cmd tea.Cmd
-	m.inner, cmd = m.inner.Update(msg)
-
 	switch msg := msg.(type) {
+	case SettingsTestResultMsg:
+		return *m, m.handleTestResult(msg)
 	case tea.KeyMsg:
 		switch msg.Type {
+		case tea.KeyCtrlC:
+			return *m, tea.Quit
+		case tea.KeyEscape:
+			if m.firstRun {
+				return *m, nil
+			}
+			return *m, SettingsCloseCmd
+		case tea.KeyUp, tea.KeyShiftTab:
+			return *m, m.moveFocus(-1)
+		case tea.KeyDown:
+			return *m, m.moveFocus(1)
 		case tea.KeyEnter:
-			value := strings.TrimSpace(m.inner.Value())
-			if value != "" {
-				if m.mode == SettingsModeAPIKey {
-					m.mode = SettingsModeLLMModel
-					m.inner.Placeholder = "Enter your LLM model (default o3-mini)..."
-					m.msg.APIKey = value
-					m.inner.SetValue("")
-					m.View()
-				} else {
-					m.msg.LLMModel = value
-					return *m, SettingsSubmitCmd(m.msg)
+			switch m.focused {
+			case settingsFieldTest:
+				if !m.testing {
+					return *m, m.test(false)
+				}
+				return *m, nil
+			case settingsFieldSave:
+				if !m.testing {
+					return *m, m.test(true)
 				}
+				return *m, nil
 			}
-		case tea.KeyEscape, tea.KeyCtrlC, tea.KeyCtrlD:
-			return *m, tea.Quit
+			return *m, m.moveFocus(1)
 		}
+		switch m.focused {
+		case settingsFieldModel:
+			switch msg.Type {
+			case tea.KeyCtrlN:
+				if len(m.modelMatches) > 0 {
+					m.modelSelected = (m.modelSelected + 1) % len(m.modelMatches)
+				}
+				return *m, nil
+			case tea.KeyCtrlP:
+				if len(m.modelMatches) > 0 {
+					m.modelSelected = (m.modelSelected - 1 + len(m.modelMatches)) % len(m.modelMatches)
+				}
+				return *m, nil
+			case tea.KeyTab:
+				if len(m.modelMatches) > 0 {
+					m.inputs[settingsFieldModel].SetValue(m.modelMatches[m.modelSelected])
+					m.inputs[settingsFieldModel].CursorEnd()
+					m.updateModelMatches()
+				}
+				return *m, nil
+			}
+		case settingsFieldTheme:
+			switch msg.Type {
+			case tea.KeyLeft:
+				m.themeIdx = (m.themeIdx - 1 + len(m.themes)) % len(m.themes)
+			case tea.KeyRight, tea.KeySpace:
+				m.themeIdx = (m.themeIdx + 1) % len(m.themes)
+			}
+			return *m, nil
+		case settingsFieldTest, settingsFieldSave:
+			switch msg.Type {
+			case tea.KeyLeft, tea.KeyRight, tea.KeyTab:
+				if m.focused == settingsFieldTest {
+					return *m, m.focusField(settingsFieldSave)
+				}
+				return *m, m.focusField(settingsFieldTest)
+			}
+			return *m, nil
+		}
+	}
+
+	input, ok := m.inputs[m.focused]
+	if !ok {
+		return *m, nil
+	}
+	var cmd tea.Cmd
+	*input, cmd = input.Update(msg)
+	if m.focused == settingsFieldModel {
+		m.updateModelMatches()
 	}
 	return *m, cmd
 }
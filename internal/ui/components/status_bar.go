@@ -0,0 +1,92 @@
+package components
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ToastLevel is how a toast is shown
+type ToastLevel int
+
+const (
+	ToastInfo ToastLevel = iota
+	ToastError
+)
+
+const (
+	infoToastDuration  = 4 * time.Second
+	errorToastDuration = 10 * time.Second
+)
+
+// StatusBarExpireMsg removes the toast it was sent for, a newer toast stays
+type StatusBarExpireMsg struct {
+	id int
+}
+
+// StatusBarModel is the line below the main view, it shows a toast until it
+// expires and the status otherwise
+type StatusBarModel struct {
+	width int
+	toast string
+	level ToastLevel
+	// toastID is counted up for every toast so that the expiry of an older
+	// toast does not remove a newer one
+	toastID int
+
+	dialogStyles
+}
+
+func NewStatusBarModel() StatusBarModel {
+	return StatusBarModel{dialogStyles: newDialogStyles()}
+}
+
+func (m *StatusBarModel) SetWidth(width int) {
+	m.width = width
+}
+
+// Notify shows the toast in place of the previous one, the returned command
+// expires it, errors are shown longer
+func (m *StatusBarModel) Notify(text string, level ToastLevel) tea.Cmd {
+	m.toastID++
+	m.toast, m.level = text, level
+	id := m.toastID
+	duration := infoToastDuration
+	if level == ToastError {
+		duration = errorToastDuration
+	}
+	return tea.Tick(duration, func(time.Time) tea.Msg {
+		return StatusBarExpireMsg{id: id}
+	})
+}
+
+// Clear removes the toast
+func (m *StatusBarModel) Clear() {
+	m.toast = ""
+}
+
+// Toast returns the text of the shown toast, empty if there is none
+func (m *StatusBarModel) Toast() string {
+	return m.toast
+}
+
+func (m *StatusBarModel) Update(msg tea.Msg) (StatusBarModel, tea.Cmd) {
+	if msg, ok := msg.(StatusBarExpireMsg); ok && msg.id == m.toastID {
+		m.toast = ""
+	}
+	return *m, nil
+}
+
+// View returns a single line, the status is shown while there is no toast
+func (m *StatusBarModel) View(status string) string {
+	text, style := status, m.metadataStyle
+	if m.toast != "" {
+		// a toast may have several lines, e.g. the output of a server
+		text, style = strings.Join(strings.Fields(m.toast), " "), m.focusedStyle
+		if m.level == ToastError {
+			style = m.errorStyle
+		}
+	}
+	return style.Copy().PaddingLeft(1).MaxWidth(m.width).Render(text)
+}
@@ -0,0 +1,29 @@
+package components
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatusBar(t *testing.T) {
+	m := NewStatusBarModel()
+	m.SetWidth(20)
+	if view := m.View("gpt-4o"); !strings.Contains(view, "gpt-4o") {
+		t.Errorf("expected the status without a toast, got %q", view)
+	}
+
+	m.Notify("first", ToastInfo)
+	m.Notify("network error:\nconnection reset by peer", ToastError)
+	if view := m.View("gpt-4o"); !strings.Contains(view, "network error: conn") || strings.Contains(view, "peer") {
+		t.Errorf("expected the newest toast on one line cut to the width, got %q", view)
+	}
+	// the first toast expires while the second one is shown
+	m.Update(StatusBarExpireMsg{id: 1})
+	if m.Toast() == "" {
+		t.Error("expected the newer toast to stay")
+	}
+	m.Update(StatusBarExpireMsg{id: 2})
+	if m.Toast() != "" || !strings.Contains(m.View("gpt-4o"), "gpt-4o") {
+		t.Errorf("expected the status once the toast expired, got %q", m.View("gpt-4o"))
+	}
+}
@@ -0,0 +1,30 @@
+package components
+
+import (
+	"github.com/aavshr/panda/internal/ui/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// dialogStyles are the styles shared by the dialogs
+type dialogStyles struct {
+	titleStyle    lipgloss.Style
+	labelStyle    lipgloss.Style
+	focusedStyle  lipgloss.Style
+	metadataStyle lipgloss.Style
+	errorStyle    lipgloss.Style
+}
+
+func newDialogStyles() dialogStyles {
+	return dialogStyles{
+		titleStyle:    styles.DefaultListStyle(),
+		labelStyle:    styles.DefaultListItemStyle(),
+		focusedStyle:  styles.DefaultListSelectedStyle(),
+		metadataStyle: styles.MetadataStyle(),
+		errorStyle:    styles.ErrorStyle(),
+	}
+}
+
+// RefreshStyles rebuilds the styles after the theme changed
+func (s *dialogStyles) RefreshStyles() {
+	*s = newDialogStyles()
+}
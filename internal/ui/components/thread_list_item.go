@@ -1,10 +1,12 @@
 package components
 
 import (
+	"io"
+
 	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/ui/styles"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
-	"io"
 )
 
 // ThreadListItem implements the list.Item, list.DefaultItem and list.ItemDelegate interface
@@ -58,8 +60,14 @@ func NewThreadListItems(threads []*db.Thread) []list.Item {
 }
 
 func NewThreadListItemDelegate() list.ItemDelegate {
-	return &ThreadListItemDelegate{
+	d := &ThreadListItemDelegate{
 		inner: list.NewDefaultDelegate(),
 	}
+	d.RefreshStyles()
+	return d
 }
 
+// RefreshStyles colors the selected thread with the theme
+func (d *ThreadListItemDelegate) RefreshStyles() {
+	styles.SetSelectedListItem(&d.inner.Styles.SelectedTitle, &d.inner.Styles.SelectedDesc)
+}
@@ -0,0 +1,393 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aavshr/panda/internal/config"
+	"github.com/aavshr/panda/internal/db"
+	base "github.com/aavshr/panda/internal/llm"
+	"github.com/aavshr/panda/internal/mcp"
+	"github.com/aavshr/panda/internal/tools"
+	"github.com/aavshr/panda/internal/ui/components"
+	"github.com/aavshr/panda/internal/ui/llm"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func flowThreads() []*db.Thread {
+	return []*db.Thread{
+		{ID: "1", Name: "Layout engine", CreatedAt: "2024-01-03", UpdatedAt: "2024-01-04"},
+		{ID: "2", Name: "Resize handling", CreatedAt: "2024-01-01", UpdatedAt: "2024-01-02"},
+	}
+}
+
+func flowMessages() []*db.Message {
+	return []*db.Message{
+		{ID: "1", ThreadID: "1", Role: roleUser, Content: "How do I split the screen?", CreatedAt: "2024-01-03 10:00:00"},
+		{ID: "2", ThreadID: "1", Role: roleAssistant, Content: "Join the panes horizontally.", CreatedAt: "2024-01-03 10:00:05"},
+		{ID: "3", ThreadID: "2", Role: roleUser, Content: "What happens on resize?", CreatedAt: "2024-01-01 10:00:00"},
+		{ID: "4", ThreadID: "2", Role: roleAssistant, Content: "The layout is computed again.", CreatedAt: "2024-01-01 10:00:05"},
+	}
+}
+
+// focusHistory moves from the chat input into the history
+func (h *harness) focusHistory() {
+	h.t.Helper()
+	h.keys(press(tea.KeyEscape), press(tea.KeyUp), press(tea.KeyEnter))
+}
+
+func TestFirstRunSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	config.SetFilePath(path)
+	t.Cleanup(func() { config.SetFilePath("") })
+	t.Setenv("PANDA_TEST_KEY", "sk-test")
+
+	h := newHarness(t, 80, 24, nil, nil)
+	if !h.m.showSettings {
+		t.Fatal("expected the settings without a config")
+	}
+	h.assertGolden("first_run_settings", false)
+
+	// a reference is not encrypted so the passphrase stays hidden, save is
+	// below the model, temperature, max tokens, base url, theme and test
+	h.typeText("env:PANDA_TEST_KEY")
+	for range 7 {
+		h.keys(press(tea.KeyDown))
+	}
+	h.keys(press(tea.KeyEnter))
+	if h.m.showSettings {
+		t.Fatalf("expected the settings to be saved:\n%s", h.view(false))
+	}
+	h.assertGolden("first_run_saved", false)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved config.Config
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.LLMAPIKeyRef != "env:PANDA_TEST_KEY" || saved.LLMAPIKey != "" {
+		t.Errorf("expected only the reference to be saved, got %+v", saved)
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	h := newHarness(t, 80, 24, flowThreads(), flowMessages())
+	h.llm.Script(llm.Response{Chunks: []string{"Keep the history ", "on the left."}})
+
+	h.typeText("Where does the history go?")
+	h.keys(press(tea.KeyTab))
+	h.assertGolden("send_message", false)
+
+	if len(h.m.threads) != 4 {
+		t.Fatalf("expected a new thread, got %d threads", len(h.m.threads))
+	}
+	messages, err := h.store.ListMessagesByThreadIDPaginated(h.m.threads[1].ID, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Content != "Where does the history go?" || messages[1].Content != "Keep the history on the left." {
+		t.Errorf("expected the question and the answer to be stored, got %+v", messages)
+	}
+	requests := h.llm.Requests()
+	if len(requests) != 1 || !requests[0].Stream || requests[0].Model != "gpt-4o" || len(requests[0].Messages) != 1 {
+		t.Fatalf("expected the question to be streamed with the configured model, got %+v", requests)
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	h := newHarness(t, 80, 24, flowThreads(), flowMessages())
+	h.llm.Script(
+		llm.Response{Chunks: []string{"Resizes ", "recompute ", "the layout."}},
+		llm.Response{Chunks: []string{"Half an "}, Err: fmt.Errorf("read tcp: %w", syscall.ECONNRESET)},
+	)
+
+	h.typeText("Explain resizing")
+	h.send(press(tea.KeyTab))
+	for !strings.Contains(h.view(false), "Resizes") {
+		if !h.step() {
+			t.Fatal("expected the first chunk to be shown")
+		}
+	}
+	if strings.Contains(h.view(false), "recompute") {
+		t.Fatal("expected a chunk at a time")
+	}
+	h.assertGolden("stream_partial", false)
+	h.settle()
+	if h.m.activeLLMStream != nil || !strings.Contains(h.view(false), "Resizes recompute the layout.") {
+		t.Fatalf("expected the stream to finish:\n%s", h.view(false))
+	}
+
+	// an error in the middle of the stream drops the unfinished answer and
+	// is shown in the status bar
+	h.typeText("And then?")
+	h.keys(press(tea.KeyTab))
+	h.assertGolden("stream_error", false)
+	if h.m.activeLLMStream != nil || !strings.Contains(h.m.statusBar.Toast(), "network error") {
+		t.Errorf("expected the stream to stop with a network error, got %q", h.m.statusBar.Toast())
+	}
+	last := h.m.messages[len(h.m.messages)-1]
+	if last.Role != roleUser || last.Content != "And then?" {
+		t.Errorf("expected the message of the user to be kept, got %+v", last)
+	}
+}
+
+func TestRetryAfterError(t *testing.T) {
+	h := newHarness(t, 80, 24, flowThreads(), flowMessages())
+	h.llm.Script(
+		llm.Response{Err: &base.StatusError{StatusCode: http.StatusTooManyRequests, Err: errors.New("slow down")}},
+		llm.Response{Chunks: []string{"Second ", "try."}},
+	)
+
+	h.typeText("Are you there?")
+	h.keys(press(tea.KeyTab))
+	if toast := h.m.statusBar.Toast(); !strings.Contains(toast, "rate limited") || !strings.Contains(toast, "ctrl+r") {
+		t.Fatalf("expected the rate limit with how to retry, got %q", toast)
+	}
+
+	h.keys(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if h.m.statusBar.Toast() != "" {
+		t.Errorf("expected the toast to be removed, got %q", h.m.statusBar.Toast())
+	}
+	messages, err := h.store.ListMessagesByThreadIDPaginated(h.m.threads[1].ID, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Content != "Are you there?" || messages[1].Content != "Second try." {
+		t.Errorf("expected the message once with the answer of the retry, got %+v", messages)
+	}
+	if requests := h.llm.Requests(); len(requests) != 2 || len(requests[1].Messages) != 1 {
+		t.Errorf("expected the message to be sent again, got %+v", requests)
+	}
+}
+
+func TestRetryProgress(t *testing.T) {
+	h := newHarness(t, 80, 24, flowThreads(), flowMessages())
+
+	h.send(RetryMsg{
+		Attempt:     2,
+		MaxAttempts: 4,
+		Delay:       1234 * time.Millisecond,
+		Err:         &base.StatusError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("overloaded")},
+	})
+	if toast := h.m.statusBar.Toast(); toast != "network error, retrying in 1.2s (attempt 2 of 4)" {
+		t.Errorf("expected the retry in the status bar, got %q", toast)
+	}
+
+	// reads of a stopped stream are dropped
+	before := h.view(false)
+	h.send(ChatCompletionChunkMsg{Stream: io.NopCloser(strings.NewReader("")), Content: "stale"})
+	if h.view(false) != before {
+		t.Errorf("expected the read of another stream to be dropped:\n%s", h.view(false))
+	}
+}
+
+func TestSwitchThreads(t *testing.T) {
+	h := newHarness(t, 80, 24, flowThreads(), flowMessages())
+
+	h.focusHistory()
+	h.keys(press(tea.KeyDown))
+	if h.m.activeThreadIndex != 1 || !strings.Contains(h.view(false), "Join the panes horizontally.") {
+		t.Fatalf("expected the first thread:\n%s", h.view(false))
+	}
+	// the colors show which section is focused
+	h.assertGolden("switch_thread", true)
+
+	h.keys(press(tea.KeyDown))
+	if view := h.view(false); !strings.Contains(view, "The layout is computed again.") || strings.Contains(view, "Join the panes") {
+		t.Fatalf("expected the second thread:\n%s", view)
+	}
+	h.keys(press(tea.KeyEnter))
+	if h.m.focusedComponent != h.m.selectedComponent || h.m.activeThreadIndex != 2 {
+		t.Errorf("expected the chat input of the second thread to be focused")
+	}
+}
+
+func TestSwitchThreadsWhileStreaming(t *testing.T) {
+	h := newHarness(t, 80, 24, flowThreads(), flowMessages())
+	h.llm.Script(llm.Response{Chunks: []string{"Split ", "the ", "screen ", "with ", "lipgloss."}})
+
+	h.focusHistory()
+	h.keys(press(tea.KeyDown), press(tea.KeyEnter))
+	h.typeText("And vertically?")
+	h.send(press(tea.KeyTab))
+	for !strings.Contains(h.view(false), "Split") {
+		if !h.step() {
+			t.Fatal("expected the first chunk to be shown")
+		}
+	}
+
+	// the next read is held back while the history is used
+	held := h.queue
+	h.queue = nil
+	h.focusHistory()
+	h.keys(press(tea.KeyDown), press(tea.KeyCtrlD))
+	if h.m.activeLLMStream == nil {
+		t.Fatal("expected the answer to be streamed still")
+	}
+	h.queue = append(h.queue, held...)
+	h.settle()
+
+	if h.m.activeThreadIndex != 1 || !strings.Contains(h.m.statusBar.Toast(), errBusy.Error()) {
+		t.Errorf("expected to stay in the first thread, got %d and %q", h.m.activeThreadIndex, h.m.statusBar.Toast())
+	}
+	if _, err := h.store.GetThread("2"); err != nil {
+		t.Errorf("expected the second thread to be kept, got %v", err)
+	}
+	for id, want := range map[string]int{"1": 4, "2": 2} {
+		messages, err := h.store.ListMessagesByThreadIDPaginated(id, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) != want {
+			t.Errorf("expected %d messages in thread %s, got %+v", want, id, messages)
+		}
+	}
+	if last := h.m.messages[len(h.m.messages)-1]; last.ThreadID != "1" || last.Content != "Split the screen with lipgloss." {
+		t.Errorf("expected the answer in the first thread, got %+v", last)
+	}
+}
+
+func TestSendWhileStreaming(t *testing.T) {
+	h := newHarness(t, 80, 24, flowThreads(), flowMessages())
+	h.llm.Script(
+		llm.Response{Chunks: []string{"Split ", "the ", "screen."}},
+		llm.Response{Chunks: []string{"Vertically."}},
+	)
+
+	h.typeText("first")
+	h.send(press(tea.KeyTab))
+	for !strings.Contains(h.view(false), "Split") {
+		if !h.step() {
+			t.Fatal("expected the first chunk to be shown")
+		}
+	}
+	// the next read is held back while the second message is sent
+	held := h.queue
+	h.queue = nil
+	h.typeText("second")
+	h.keys(press(tea.KeyTab))
+	if !strings.Contains(h.view(false), errBusy.Error()) || h.m.chatInputModel.Value() != "second" {
+		t.Errorf("expected the second message to wait:\n%s", h.view(false))
+	}
+	h.queue = append(h.queue, held...)
+	h.settle()
+
+	if requests := h.llm.Requests(); len(requests) != 1 {
+		t.Errorf("expected one request, got %d", len(requests))
+	}
+	messages, err := h.store.ListMessagesByThreadIDPaginated(h.m.threads[1].ID, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Content != "first" || messages[1].Content != "Split the screen." {
+		t.Errorf("expected the first message and its answer to be stored, got %+v", messages)
+	}
+}
+
+func TestDeleteThread(t *testing.T) {
+	h := newHarness(t, 80, 24, flowThreads(), flowMessages())
+
+	h.focusHistory()
+	h.keys(press(tea.KeyDown), press(tea.KeyCtrlD))
+	h.assertGolden("delete_thread", false)
+
+	if _, err := h.store.GetThread("1"); !errors.Is(err, db.ErrThreadNotFound) {
+		t.Errorf("expected the thread to be deleted, got %v", err)
+	}
+	if len(h.m.threads) != 2 || h.m.threads[h.m.activeThreadIndex].ID != "2" {
+		t.Errorf("expected the next thread to be selected, got %d", h.m.activeThreadIndex)
+	}
+}
+
+func TestMCPToolNameTaken(t *testing.T) {
+	h := newHarness(t, 80, 24, flowThreads(), flowMessages())
+	h.send(MCPConnectedMsg{Tools: []*tools.Tool{
+		{Name: "fs__read_file"},
+		{Name: "fs__read_file"},
+		{Name: tools.RunCommandName},
+	}})
+
+	if _, ok := h.m.tools.Get("fs__read_file"); !ok || len(h.m.mcpTools) != 1 {
+		t.Errorf("expected the first tool to be kept, got %d", len(h.m.mcpTools))
+	}
+	if toast := h.m.statusBar.Toast(); !strings.Contains(toast, "fs__read_file, "+tools.RunCommandName) {
+		t.Errorf("expected a warning about the left out tools, got %q", toast)
+	}
+}
+
+func TestMCPPrompt(t *testing.T) {
+	h := newHarness(t, 80, 24, flowThreads(), flowMessages())
+	h.send(components.ChatInputCommandMsg{Name: "prompt", Args: []string{"fs", "review", "path=main.go"}})
+	if view := h.view(false); !strings.Contains(view, "no mcp server fs is connected") {
+		t.Errorf("expected an error for the unknown server, got\n%s", view)
+	}
+
+	h.send(MCPPromptsMsg{Prompts: []string{"fs review path=", "fs summary [lines=]"}})
+	if view := h.view(false); !strings.Contains(view, "prompts: fs review path=, fs summary [lines=]") {
+		t.Errorf("expected the prompts to be listed, got\n%s", view)
+	}
+
+	// the messages are put into the input to be edited and sent
+	h.send(MCPPromptMsg{Messages: []mcp.PromptMessage{
+		{Role: roleUser, Content: "review main.go"},
+		{Role: roleAssistant},
+		{Role: roleUser, Content: "keep it short"},
+	}})
+	if value := h.m.chatInputModel.Value(); value != "review main.go\n\nkeep it short" {
+		t.Errorf("expected the messages in the input, got %q", value)
+	}
+	if len(h.llm.Requests()) != 0 {
+		t.Error("expected the prompt not to be sent")
+	}
+}
+
+func TestPaletteActions(t *testing.T) {
+	messages := append(flowMessages(), &db.Message{ID: "5", ThreadID: "2", Role: roleAssistant, Content: "```go\nfmt.Println(1)\n```", CreatedAt: "2024-01-01 10:00:10"})
+	h := newHarness(t, 80, 24, flowThreads(), messages)
+
+	h.send(components.PaletteRunMsg{ID: "filter-threads"})
+	h.settle()
+	if h.m.focusedComponent != components.ComponentHistory || !h.m.historyModel.Filtering() {
+		t.Fatal("expected the filter of the history to be typed")
+	}
+	h.keys(press(tea.KeyEscape), press(tea.KeyEscape))
+
+	// the code blocks are those of the shown thread
+	h.send(components.PaletteRunMsg{ID: "write-code"})
+	h.settle()
+	if !strings.Contains(h.m.statusBar.Toast(), "no code blocks") {
+		t.Errorf("expected no code blocks in the new thread, got %q", h.m.statusBar.Toast())
+	}
+	if err := h.m.selectActiveThread(2); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "main.go")
+	h.send(components.PaletteRunMsg{ID: "write-code"})
+	h.settle()
+	h.keys(runes("1"))
+	h.typeText(path)
+	h.keys(press(tea.KeyEnter))
+	if data, err := os.ReadFile(path); err != nil || string(data) != "fmt.Println(1)\n" {
+		t.Errorf("expected the code block to be written, got %q, %v", data, err)
+	}
+
+	// the input is sent like with the send key
+	h.m.chatInputModel.SetValue("/new")
+	h.send(components.PaletteRunMsg{ID: "send"})
+	h.settle()
+	if len(h.llm.Requests()) != 0 || h.m.activeThreadIndex != 0 {
+		t.Errorf("expected /new to run as a command, got %d requests", len(h.llm.Requests()))
+	}
+}
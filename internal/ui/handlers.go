@@ -5,44 +5,59 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"slices"
-	"time"
+	"strings"
 
+	"github.com/aavshr/panda/internal/attach"
 	"github.com/aavshr/panda/internal/config"
 	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/export"
+	base "github.com/aavshr/panda/internal/llm"
+	"github.com/aavshr/panda/internal/secrets"
+	"github.com/aavshr/panda/internal/tools"
 	"github.com/aavshr/panda/internal/ui/components"
 	"github.com/aavshr/panda/internal/ui/styles"
 	"github.com/aavshr/panda/internal/utils"
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func (m *Model) handleKeyMsg(keyMsg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch keyMsg.String() {
-	case "k", "up":
+	switch {
+	case key.Matches(keyMsg, m.keys.Up):
 		switch m.selectedComponent {
 		case components.ComponentChatInput:
-			m.setSelectedComponent(components.ComponentHistory)
+			if m.layout.showHistory {
+				m.setSelectedComponent(components.ComponentHistory)
+			} else {
+				m.setSelectedComponent(components.ComponentMessages)
+			}
 		}
-	case "j", "down":
+	case key.Matches(keyMsg, m.keys.Down):
 		switch m.selectedComponent {
 		case components.ComponentMessages, components.ComponentHistory:
 			m.setSelectedComponent(components.ComponentChatInput)
 		}
-	case "h", "left":
+	case key.Matches(keyMsg, m.keys.Left):
 		switch m.selectedComponent {
 		case components.ComponentMessages:
-			m.setSelectedComponent(components.ComponentHistory)
+			if m.layout.showHistory {
+				m.setSelectedComponent(components.ComponentHistory)
+			}
 		}
-	case "l", "right":
+	case key.Matches(keyMsg, m.keys.Right):
 		switch m.selectedComponent {
 		case components.ComponentHistory:
 			m.setSelectedComponent(components.ComponentMessages)
 		}
-	case "enter":
+	case key.Matches(keyMsg, m.keys.Focus):
 		m.setFocusedComponent(m.selectedComponent)
 		return m, m.cmdFocusedComponent
-	case "ctrl+c", "ctrl+d":
-		return m, tea.Quit
+	default:
+		if a, ok := m.mainAction(keyMsg); ok {
+			return m, a.run(m)
+		}
 	}
 	return m, m.cmdSelectComponent
 }
@@ -89,8 +104,12 @@ func (m *Model) createNewThread(name string) (*db.Thread, error) {
 	thread := &db.Thread{
 		ID:        newThreadID,
 		Name:      name,
-		CreatedAt: time.Now().Format(timeFormat),
-		UpdatedAt: time.Now().Format(timeFormat),
+		CreatedAt: m.now().Format(timeFormat),
+		UpdatedAt: m.now().Format(timeFormat),
+		// params and the model set before the thread existed are kept on
+		// the new thread item
+		Params: m.threads[0].Params,
+		Model:  m.threads[0].Model,
 	}
 	if err := m.store.UpsertThread(thread); err != nil {
 		return thread, err
@@ -98,71 +117,469 @@ func (m *Model) createNewThread(name string) (*db.Thread, error) {
 	return thread, nil
 }
 
+func (m *Model) applyLLMSettings(apiKey, baseURL string) error {
+	if err := m.llm.SetBaseURL(baseURL); err != nil {
+		return fmt.Errorf("llm.SetBaseURL: %w", err)
+	}
+	if err := m.llm.SetAPIKey(apiKey); err != nil {
+		return fmt.Errorf("llm.SetAPIKey: %w", err)
+	}
+	m.setTools()
+	return nil
+}
+
+// setTools rebuilds the tools from the user config and the connected mcp
+// servers and offers them to the model
+func (m *Model) setTools() {
+	m.tools = newToolRegistry(m.userConfig, m.mcpTools)
+	if m.userConfig != nil && m.userConfig.DisableTools {
+		m.llm.SetTools(nil)
+	} else {
+		m.llm.SetTools(m.tools.Definitions())
+	}
+}
+
+// newToolRegistry returns the built-in tools with run_command configured
+// by the user config, followed by the tools of mcp servers
+func newToolRegistry(userConfig *config.Config, mcpTools []*tools.Tool) *tools.Registry {
+	var commands tools.CommandConfig
+	if userConfig != nil {
+		commands = tools.NewCommandConfig(userConfig.Commands)
+	}
+	registry := tools.NewBuiltinRegistry()
+	// the built-in names are distinct from run_command
+	_ = registry.Register(tools.RunCommand(commands))
+	for _, tool := range mcpTools {
+		// the names are checked when the servers connect
+		_ = registry.Register(tool)
+	}
+	return registry
+}
+
+// handleMCPConnectedMsg adds the tools of the servers, a tool whose name is
+// taken, e.g. because it was cut to the same prefix, is left out with a
+// warning
+func (m *Model) handleMCPConnectedMsg(msg MCPConnectedMsg) tea.Cmd {
+	m.mcpClients = append(m.mcpClients, msg.Clients...)
+	var skipped []string
+	for _, tool := range msg.Tools {
+		if err := m.tools.Register(tool); err != nil {
+			skipped = append(skipped, tool.Name)
+			continue
+		}
+		m.mcpTools = append(m.mcpTools, tool)
+	}
+	m.setTools()
+	var cmd tea.Cmd
+	if len(skipped) > 0 {
+		cmd = m.statusBar.Notify(fmt.Sprintf("mcp tools left out, their names are taken: %s", strings.Join(skipped, ", ")), components.ToastError)
+	}
+	if len(msg.Errs) > 0 {
+		// the hint below the input is a single line, stderr of a server
+		// can span several
+		errs := make([]string, 0, len(msg.Errs))
+		for _, err := range msg.Errs {
+			errs = append(errs, strings.Join(strings.Fields(err.Error()), " "))
+		}
+		m.chatInputModel.SetError(errors.New(strings.Join(errs, "; ")))
+	}
+	return cmd
+}
+
+func (m *Model) handleMCPPromptsMsg(msg MCPPromptsMsg) {
+	if msg.Err != nil {
+		m.chatInputModel.SetError(msg.Err)
+		return
+	}
+	if len(msg.Prompts) == 0 {
+		m.chatInputModel.SetHint("the mcp servers have no prompts")
+		return
+	}
+	m.chatInputModel.SetHint("prompts: " + strings.Join(msg.Prompts, ", "))
+}
+
+// handleMCPPromptMsg puts the messages of a prompt into the input to be
+// edited and sent like a template, they are sent as one message of the
+// user so their roles are dropped
+func (m *Model) handleMCPPromptMsg(msg MCPPromptMsg) {
+	if msg.Err != nil {
+		m.chatInputModel.SetError(msg.Err)
+		return
+	}
+	contents := make([]string, 0, len(msg.Messages))
+	for _, message := range msg.Messages {
+		if message.Content != "" {
+			contents = append(contents, message.Content)
+		}
+	}
+	if len(contents) == 0 {
+		m.chatInputModel.SetHint("the prompt has no messages")
+		return
+	}
+	m.chatInputModel.SetValue(strings.Join(contents, "\n\n"))
+}
+
+func (m *Model) openSettings() tea.Cmd {
+	values := components.SettingsValues{}
+	if m.userConfig != nil {
+		values = components.SettingsValues{
+			LLMModel:    m.userConfig.LLMModel,
+			Temperature: m.userConfig.Params.Temperature,
+			MaxTokens:   m.userConfig.Params.MaxTokens,
+			BaseURL:     m.userConfig.LLMBaseURL,
+			Theme:       m.userConfig.Theme,
+		}
+	}
+	m.settingsModel.SetValues(values, m.apiKey != "")
+	if models, _ := m.modelCache.Get(values.BaseURL); models != nil {
+		m.settingsModel.SetModels(models)
+	}
+	m.showSettings = true
+	m.focusedComponent = components.ComponentSettings
+	m.selectedComponent = components.ComponentSettings
+	return m.settingsModel.Focus()
+}
+
+// resolveSettingsAPIKey returns the key entered on the settings screen,
+// the current key if none was entered
+func (m *Model) resolveSettingsAPIKey(values components.SettingsValues) (string, error) {
+	switch {
+	case values.APIKey == "":
+		return m.apiKey, nil
+	case values.IsRef:
+		if values.Passphrase != "" {
+			secrets.SetPassphrase(values.Passphrase)
+		}
+		return config.ResolveRef(values.APIKey)
+	}
+	return values.APIKey, nil
+}
+
+func (m *Model) handleSettingsTestMsg(msg components.SettingsTestMsg) tea.Cmd {
+	apiKey, err := m.resolveSettingsAPIKey(msg.SettingsValues)
+	if err != nil {
+		return func() tea.Msg {
+			return components.SettingsTestResultMsg{Err: err}
+		}
+	}
+	// the entered settings are applied for the test and restored if the
+	// settings are closed without saving
+	if err := m.applyLLMSettings(apiKey, msg.BaseURL); err != nil {
+		return m.cmdError(err)
+	}
+	return m.cmdListModels(msg.BaseURL, msg.Submit)
+}
+
+func (m *Model) handleSettingsCloseMsg() tea.Cmd {
+	if m.userConfig == nil {
+		return nil
+	}
+	if err := m.applyLLMSettings(m.apiKey, m.userConfig.LLMBaseURL); err != nil {
+		return m.cmdError(err)
+	}
+	m.showSettings = false
+	return m.Init()
+}
+
 func (m *Model) handleSettingsSubmitMsg(msg components.SettingsSubmitMsg) tea.Cmd {
-	savedConfig, err := config.Save(config.Config{
-		LLMAPIKey: msg.APIKey,
-		LLMModel:  msg.LLMModel,
-	})
+	newConfig := config.Config{}
+	if m.userConfig != nil {
+		newConfig = *m.userConfig
+	}
+	apiKey, err := m.resolveSettingsAPIKey(msg.SettingsValues)
+	if err != nil {
+		return m.cmdError(fmt.Errorf("resolveSettingsAPIKey: %w", err))
+	}
+	// only a reference to the key is saved in the config file
+	switch {
+	case msg.APIKey == "":
+	case msg.IsRef:
+		newConfig.LLMAPIKeyRef = msg.APIKey
+		newConfig.LLMAPIKey = ""
+	default:
+		ref, err := config.StoreSecret(config.DefaultSecretName, msg.APIKey, msg.Passphrase)
+		if err != nil {
+			return m.cmdError(fmt.Errorf("config.StoreSecret: %w", err))
+		}
+		newConfig.LLMAPIKeyRef = ref
+		newConfig.LLMAPIKey = ""
+	}
+	newConfig.LLMModel = msg.LLMModel
+	newConfig.LLMBaseURL = msg.BaseURL
+	newConfig.Params.Temperature = msg.Temperature
+	newConfig.Params.MaxTokens = msg.MaxTokens
+	newConfig.Theme = msg.Theme
+
+	savedConfig, err := config.Save(newConfig)
 	if err != nil {
 		return m.cmdError(fmt.Errorf("config.Save: %w", err))
 	}
-	if err := m.llm.SetAPIKey(msg.APIKey); err != nil {
-		return m.cmdError(fmt.Errorf("llm.SetAPIKey: %w", err))
+	if err := m.applyLLMSettings(apiKey, savedConfig.LLMBaseURL); err != nil {
+		return m.cmdError(err)
 	}
+	m.apiKey = apiKey
 	m.showSettings = false
 	m.userConfig = savedConfig
+	if err := m.setTheme(m.themeName()); err != nil {
+		m.chatInputModel.SetError(err)
+	}
+	return m.Init()
+}
+
+func (m *Model) openParams() tea.Cmd {
+	if m.activeThreadIndex >= len(m.threads) {
+		return m.cmdError(fmt.Errorf("invalid active thread index"))
+	}
+	thread := m.threads[m.activeThreadIndex]
+	params, err := base.ParseParams(thread.Params)
+	if err != nil {
+		return m.cmdError(fmt.Errorf("llm.ParseParams: %w", err))
+	}
+	var defaults base.Params
+	if m.userConfig != nil {
+		defaults = m.userConfig.Params
+	}
+	m.paramsModel.SetValues(thread.Name, params, defaults)
+	m.showParams = true
+	m.focusedComponent = components.ComponentParams
+	m.selectedComponent = components.ComponentParams
+	return m.paramsModel.Focus()
+}
+
+func (m *Model) handleParamsCloseMsg() tea.Cmd {
+	m.showParams = false
+	return m.Init()
+}
+
+func (m *Model) handleParamsSubmitMsg(msg components.ParamsSubmitMsg) tea.Cmd {
+	if m.activeThreadIndex >= len(m.threads) {
+		return m.cmdError(fmt.Errorf("invalid active thread index"))
+	}
+	thread := m.threads[m.activeThreadIndex]
+	params := msg.Params.Encode()
+	// the new thread item is not stored, its params are applied on creation
+	if m.activeThreadIndex > 0 {
+		if err := m.store.UpdateThreadParams(thread.ID, params); err != nil {
+			return m.cmdError(fmt.Errorf("store.UpdateThreadParams: %w", err))
+		}
+	}
+	thread.Params = params
+	return m.handleParamsCloseMsg()
+}
+
+func (m *Model) openModelPicker() tea.Cmd {
+	if m.activeThreadIndex >= len(m.threads) {
+		return m.cmdError(fmt.Errorf("invalid active thread index"))
+	}
+	models, fresh := m.modelCache.Get(m.userConfig.LLMBaseURL)
+	cmds := []tea.Cmd{
+		m.modelPicker.Open(m.threadModel(m.threads[m.activeThreadIndex]), models, !fresh),
+	}
+	if !fresh {
+		cmds = append(cmds, m.cmdLoadModels())
+	}
+	m.showModels = true
+	m.focusedComponent = components.ComponentModelPicker
+	m.selectedComponent = components.ComponentModelPicker
+	return tea.Batch(cmds...)
+}
+
+func (m *Model) handleModelPickerCloseMsg() tea.Cmd {
+	m.showModels = false
 	return m.Init()
 }
 
+func (m *Model) handleModelPickerSelectMsg(msg components.ModelPickerSelectMsg) tea.Cmd {
+	if msg.Default {
+		newConfig := *m.userConfig
+		newConfig.LLMModel = msg.Model
+		savedConfig, err := config.Save(newConfig)
+		if err != nil {
+			return m.cmdError(fmt.Errorf("config.Save: %w", err))
+		}
+		m.userConfig = savedConfig
+		return m.handleModelPickerCloseMsg()
+	}
+	if m.activeThreadIndex >= len(m.threads) {
+		return m.cmdError(fmt.Errorf("invalid active thread index"))
+	}
+	thread := m.threads[m.activeThreadIndex]
+	model := msg.Model
+	// picking the configured model follows later changes of the config
+	if model == m.userConfig.LLMModel {
+		model = ""
+	}
+	// the new thread item is not stored, its model is applied on creation
+	if m.activeThreadIndex > 0 {
+		if err := m.store.UpdateThreadModel(thread.ID, model); err != nil {
+			return m.cmdError(fmt.Errorf("store.UpdateThreadModel: %w", err))
+		}
+	}
+	thread.Model = model
+	return m.handleModelPickerCloseMsg()
+}
+
+// threadModel returns the model of the thread, the configured model if
+// the thread does not override it
+func (m *Model) threadModel(thread *db.Thread) string {
+	if thread.Model != "" {
+		return thread.Model
+	}
+	return m.userConfig.LLMModel
+}
+
+// threadParams returns the params of the config overridden by the thread
+func (m *Model) threadParams(thread *db.Thread) (base.Params, error) {
+	threadParams, err := base.ParseParams(thread.Params)
+	if err != nil {
+		return base.Params{}, fmt.Errorf("llm.ParseParams: %w", err)
+	}
+	return m.userConfig.Params.Merge(threadParams), nil
+}
+
+func hasImage(files []*attach.File) bool {
+	for _, file := range files {
+		if file.IsImage() {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Model) handleChatInputReturnMsg(msg components.ChatInputReturnMsg) tea.Cmd {
+	if m.busy() {
+		// the message is kept to be sent once the answer is done
+		m.chatInputModel.SetValue(msg.Value)
+		m.chatInputModel.SetError(errBusy)
+		return nil
+	}
 	if m.activeThreadIndex >= len(m.threads) {
 		return m.cmdError(fmt.Errorf("invalid active thread index"))
 	}
+	// a path that can not be attached is shown with the message so that it
+	// can be fixed and sent again
+	files, err := attach.Resolve(attach.Refs(msg.Value), attach.DefaultLimits())
+	if err == nil && hasImage(files) && !base.SupportsImages(m.threadModel(m.threads[m.activeThreadIndex])) {
+		err = fmt.Errorf("%w, pick another model with m", base.ErrImagesNotSupported)
+	}
+	if err != nil {
+		m.chatInputModel.SetValue(msg.Value)
+		m.chatInputModel.SetError(err)
+		return nil
+	}
 
 	// TODO: use llm to generate thread name as well
 
 	// TODO: more robust behavior for thread creation
 	// first thread is always for new thread
-	if m.activeThreadIndex == 0 {
-		n := 25
-		if len(msg.Value) < n {
-			n = len(msg.Value)
-		}
-		name := fmt.Sprintf("%s..", msg.Value[:n])
-		newThread, err := m.createNewThread(name)
-		if err != nil {
-			return m.cmdError(fmt.Errorf("createNewThread: %w", err))
-		}
-		m.setThreads(slices.Insert(m.threads, 1, newThread))
-		m.setActiveThreadIndex(1)
+	activeThread, err := m.ensureThread(msg.Value)
+	if err != nil {
+		return m.cmdError(err)
 	}
-	activeThread := m.threads[m.activeThreadIndex]
 	userMessage := &db.Message{
 		Role:      roleUser,
 		ThreadID:  activeThread.ID,
 		Content:   msg.Value,
-		CreatedAt: time.Now().Format(timeFormat),
+		CreatedAt: m.now().Format(timeFormat),
 	}
+	attach.AddToMessage(userMessage, files)
 	if err := m.store.CreateMessage(userMessage); err != nil {
 		return m.cmdError(fmt.Errorf("store.CreateMessage: %w", err))
 	}
-	messages := append(m.messages, userMessage)
-	m.setMessages(messages)
-	reader, err := m.llm.CreateChatCompletionStream(context.Background(),
-		m.userConfig.LLMModel, messages)
+	m.setMessages(append(m.messages, userMessage))
+	m.toolRounds = 0
+	return m.startCompletion(activeThread)
+}
+
+// ensureThread returns the active thread, the new thread item is stored
+// first and named after the content
+func (m *Model) ensureThread(content string) (*db.Thread, error) {
+	if m.activeThreadIndex >= len(m.threads) {
+		return nil, fmt.Errorf("invalid active thread index")
+	}
+	if m.activeThreadIndex == 0 {
+		newThread, err := m.createNewThread(utils.ThreadName(content))
+		if err != nil {
+			return nil, fmt.Errorf("createNewThread: %w", err)
+		}
+		m.threads[0].Params = ""
+		m.threads[0].Model = ""
+		m.setThreads(slices.Insert(m.threads, 1, newThread))
+		m.setActiveThreadIndex(1)
+	}
+	return m.threads[m.activeThreadIndex], nil
+}
+
+// startCompletion streams the answer to the messages of the thread
+func (m *Model) startCompletion(thread *db.Thread) tea.Cmd {
+	shown := thread.ID == m.shownThreadID()
+	history := m.messages
+	if !shown {
+		stored, err := m.store.ListMessagesByThreadIDPaginated(thread.ID, 0, m.conf.MessagesLimit)
+		if err != nil {
+			return m.cmdError(fmt.Errorf("store.ListMessagesByThreadIDPaginated: %w", err))
+		}
+		history = stored
+	}
+	messages := make([]*db.Message, 0, len(history))
+	for _, message := range history {
+		if !isLocal(message) {
+			messages = append(messages, message)
+		}
+	}
+	params, err := m.threadParams(thread)
 	if err != nil {
-		return m.cmdError(fmt.Errorf("llm.CreateChatCompletionStream: %w", err))
+		return m.cmdError(err)
+	}
+	model := m.threadModel(thread)
+	reader, err := m.llm.CreateChatCompletionStream(context.Background(), model, params, messages)
+	if err != nil {
+		return m.failCompletion(fmt.Errorf("llm.CreateChatCompletionStream: %w", err))
 	}
-	m.activeLLMStream = reader
 
 	// placeholder empty llm message for stream to update as data rolls in
 	// message will only be saved to db when stream is done
-	m.setMessages(append(m.messages, &db.Message{
+	m.activeLLMStream = reader
+	m.streamThreadID = thread.ID
+	m.streamMessage = &db.Message{
 		Role:     roleAssistant,
-		ThreadID: activeThread.ID,
-	}))
-	m.messagesModel.ScrollToBottom()
-	return m.cmdForwardChatCompletionStream
+		ThreadID: thread.ID,
+		Params:   params.Encode(),
+		Model:    model,
+	}
+	if shown {
+		m.setMessages(append(m.messages, m.streamMessage))
+		m.messagesModel.ScrollToBottom()
+	}
+	return m.cmdReadChatCompletionStream(reader)
+}
+
+// shownThreadID is the id of the thread whose messages are shown
+func (m *Model) shownThreadID() string {
+	if m.activeThreadIndex >= len(m.threads) {
+		return ""
+	}
+	return m.threads[m.activeThreadIndex].ID
+}
+
+// threadByID returns the listed thread with the id or nil
+func (m *Model) threadByID(id string) *db.Thread {
+	for _, thread := range m.threads {
+		if thread.ID == id {
+			return thread
+		}
+	}
+	return nil
+}
+
+// messageIndex returns the index of the shown message or -1
+func (m *Model) messageIndex(message *db.Message) int {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i] == message {
+			return i
+		}
+	}
+	return -1
 }
 
 func (m *Model) handleEscapeMsg() {
@@ -207,6 +624,11 @@ func (m *Model) selectActiveThread(index int) error {
 func (m *Model) handleListSelectMsg(msg components.ListSelectMsg) tea.Cmd {
 	switch m.focusedComponent {
 	case components.ComponentHistory:
+		if m.busy() {
+			// the answer is written to the shown thread
+			m.historyModel.Select(m.activeThreadIndex)
+			return m.statusBar.Notify(errBusy.Error(), components.ToastInfo)
+		}
 		if err := m.selectActiveThread(msg.Index); err != nil {
 			return m.cmdError(err)
 		}
@@ -217,71 +639,207 @@ func (m *Model) handleListSelectMsg(msg components.ListSelectMsg) tea.Cmd {
 func (m *Model) handleListDeleteMsg(msg components.ListDeleteMsg) tea.Cmd {
 	switch m.focusedComponent {
 	case components.ComponentHistory:
-		// first item is always for new thread so no deletion
-		if msg.Index == 0 {
+		return m.deleteThread(msg.Index)
+	}
+	return nil
+}
+
+// deleteThread deletes the thread at index and selects the next one
+func (m *Model) deleteThread(index int) tea.Cmd {
+	// first item is always for new thread so no deletion
+	if index <= 0 || index >= len(m.threads) {
+		return nil
+	}
+	if m.busy() {
+		return m.statusBar.Notify(errBusy.Error(), components.ToastInfo)
+	}
+	if err := m.store.DeleteThread(m.threads[index].ID); err != nil {
+		return m.cmdError(err)
+	}
+	m.setThreads(append(m.threads[:index], m.threads[index+1:]...))
+	if index >= len(m.threads) {
+		index = len(m.threads) - 1
+	}
+	if err := m.selectActiveThread(index); err != nil {
+		return m.cmdError(err)
+	}
+	return nil
+}
+
+func (m *Model) handleListExportMsg(msg components.ListExportMsg) tea.Cmd {
+	switch m.focusedComponent {
+	case components.ComponentHistory:
+		// first item is always for new thread so nothing to export
+		if msg.Index <= 0 || msg.Index >= len(m.threads) {
 			return nil
 		}
-		if err := m.store.DeleteThread(m.threads[msg.Index].ID); err != nil {
-			return m.cmdError(err)
-		}
-		m.setThreads(append(m.threads[:msg.Index], m.threads[msg.Index+1:]...))
-		if msg.Index >= len(m.threads) {
-			msg.Index = len(m.threads) - 1
-		}
-		if err := m.selectActiveThread(msg.Index); err != nil {
+		if _, err := m.exportThread(m.threads[msg.Index], export.FormatMarkdown); err != nil {
 			return m.cmdError(err)
 		}
 	}
 	return nil
 }
 
-func (m *Model) handleForwardChatCompletionStreamMsg(_ ForwardChatCompletionStreamMsg) tea.Cmd {
-	if m.activeThreadIndex >= len(m.threads) {
-		return m.cmdError(fmt.Errorf("invalid active thread index"))
+// exportThread writes the thread to a new file in the working directory
+// and returns its name, existing files are never overwritten
+func (m *Model) exportThread(thread *db.Thread, format export.Format) (string, error) {
+	exporter, err := export.New(m.store, format)
+	if err != nil {
+		return "", fmt.Errorf("export.New: %w", err)
+	}
+	name := export.FileName(thread, format)
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", fmt.Errorf("os.OpenFile: %w", err)
 	}
-	activeThreadId := m.threads[m.activeThreadIndex].ID
-	llmMessageIndex := len(m.messages) - 1
-	if llmMessageIndex == 0 {
-		return m.cmdError(fmt.Errorf("bad llm message index: 0, should be at least 1"))
+	defer f.Close()
+	if err := exporter.ExportThread(f, thread); err != nil {
+		return "", fmt.Errorf("exporter.ExportThread: %w", err)
 	}
-	content := m.messages[llmMessageIndex].Content
-	createdAt := m.messages[llmMessageIndex].CreatedAt
+	return name, nil
+}
 
-	// TODO: what buffer size makes it look smooth?
-	buffer := make([]byte, 64)
+// handleChatCompletionChunkMsg adds the read to the answer and reads on,
+// reads of a stream that was stopped are dropped
+func (m *Model) handleChatCompletionChunkMsg(msg ChatCompletionChunkMsg) tea.Cmd {
+	if msg.Stream != m.activeLLMStream || m.streamMessage == nil {
+		return nil
+	}
+	message := m.streamMessage
 
 	streamDone := false
-	n, err := m.activeLLMStream.Read(buffer)
-	if err != nil {
-		if !errors.Is(err, io.EOF) {
-			return m.cmdError(fmt.Errorf("activeLLMStream.Read: %w", err))
+	if msg.Err != nil {
+		if !errors.Is(msg.Err, io.EOF) {
+			return m.failCompletion(fmt.Errorf("activeLLMStream.Read: %w", msg.Err))
 		}
 		streamDone = true
 		m.activeLLMStream.Close()
 	}
-	if n > 0 {
-		content = fmt.Sprintf("%s%s", content, string(buffer[:n]))
-		if createdAt == "" {
-			createdAt = time.Now().Format(timeFormat)
-		}
-	}
-	updatedLLMMessage := &db.Message{
-		Role:      roleAssistant,
-		Content:   content,
-		CreatedAt: createdAt,
-		ThreadID:  activeThreadId,
+	message.Content += msg.Content
+	// answers with only tool calls have no content
+	if message.CreatedAt == "" && (msg.Content != "" || streamDone) {
+		message.CreatedAt = m.now().Format(timeFormat)
 	}
-	m.messages[llmMessageIndex] = updatedLLMMessage
-	m.messagesModel.SetMessage(llmMessageIndex, components.Message{
-		Content:   updatedLLMMessage.Content,
-		CreatedAt: updatedLLMMessage.CreatedAt,
-		IsUser:    false,
-	})
 	if streamDone {
-		if err := m.store.CreateMessage(updatedLLMMessage); err != nil {
+		// the model may answer with tool calls instead of or after content
+		var calls []base.ToolCall
+		if stream, ok := m.activeLLMStream.(base.ToolCallStream); ok {
+			calls = stream.ToolCalls()
+			message.ToolCalls = base.EncodeToolCalls(calls)
+		}
+		m.activeLLMStream = nil
+		m.streamMessage = nil
+		if index := m.messageIndex(message); index >= 0 {
+			m.messagesModel.SetMessage(index, toComponentMessage(message))
+		}
+		if err := m.store.CreateMessage(message); err != nil {
 			return m.cmdError(fmt.Errorf("store.CreateMessage: %w", err))
 		}
+		m.pendingToolCalls = calls
+		m.toolThreadID = m.streamThreadID
+		if len(calls) > 0 {
+			return m.nextToolCall()
+		}
+		return nil
+	}
+	if index := m.messageIndex(message); index >= 0 {
+		m.messagesModel.SetMessage(index, toComponentMessage(message))
+	}
+	return m.cmdReadChatCompletionStream(msg.Stream)
+}
+
+// nextToolCall asks to confirm the next pending tool call, once all calls
+// are answered the completion continues with their results
+func (m *Model) nextToolCall() tea.Cmd {
+	if len(m.pendingToolCalls) > 0 {
+		call := m.pendingToolCalls[0]
+		switch decision, reason := m.tools.Decide(call); decision {
+		case tools.DecisionAllow:
+			return m.cmdRunTool(call)
+		case tools.DecisionDeny:
+			return m.handleToolResultMsg(ToolResultMsg{ThreadID: m.toolThreadID, Call: call, Content: "the call was refused: " + reason})
+		}
+		m.messagesModel.ConfirmTool(formatToolCall(call))
+		m.chatInputModel.Blur()
+		m.setSelectedComponent(components.ComponentMessages)
+		m.setFocusedComponent(components.ComponentMessages)
 		return nil
 	}
-	return m.cmdForwardChatCompletionStream
+	m.setSelectedComponent(components.ComponentChatInput)
+	m.setFocusedComponent(components.ComponentChatInput)
+	m.toolRounds++
+	if m.toolRounds > maxToolRounds {
+		m.chatInputModel.SetError(fmt.Errorf("stopped after %d rounds of tool calls", maxToolRounds))
+		return nil
+	}
+	thread := m.threadByID(m.toolThreadID)
+	if thread == nil {
+		return m.cmdError(fmt.Errorf("thread %s of the tool calls is gone", m.toolThreadID))
+	}
+	return m.startCompletion(thread)
+}
+
+func (m *Model) handleToolConfirmMsg(msg components.ToolConfirmMsg) tea.Cmd {
+	if len(m.pendingToolCalls) == 0 {
+		return nil
+	}
+	call := m.pendingToolCalls[0]
+	if !msg.Approved {
+		// the model is told so that it can go on without the result
+		return m.handleToolResultMsg(ToolResultMsg{ThreadID: m.toolThreadID, Call: call, Content: "the user declined to run the tool"})
+	}
+	return m.cmdRunTool(call)
+}
+
+// handleToolResultMsg stores the result in the thread of the call, results
+// of calls that are no longer pending are dropped
+func (m *Model) handleToolResultMsg(msg ToolResultMsg) tea.Cmd {
+	if len(m.pendingToolCalls) == 0 || msg.ThreadID != m.toolThreadID || msg.Call.ID != m.pendingToolCalls[0].ID {
+		return nil
+	}
+	toolMessage := &db.Message{
+		Role:       roleTool,
+		ThreadID:   msg.ThreadID,
+		Content:    msg.Content,
+		CreatedAt:  m.now().Format(timeFormat),
+		ToolCallID: msg.Call.ID,
+	}
+	if err := m.store.CreateMessage(toolMessage); err != nil {
+		return m.cmdError(fmt.Errorf("store.CreateMessage: %w", err))
+	}
+	if msg.ThreadID == m.shownThreadID() {
+		m.messages = append(m.messages, toolMessage)
+		m.messagesModel.AddMessage(toComponentMessage(toolMessage))
+	}
+	m.pendingToolCalls = m.pendingToolCalls[1:]
+	return m.nextToolCall()
+}
+
+func (m *Model) handleCodeBlockCopyMsg(msg components.CodeBlockCopyMsg) tea.Cmd {
+	// bubbletea owns stdout so the sequence goes to the terminal via stderr
+	if err := utils.CopyToClipboard(os.Stderr, msg.Block.Code); err != nil {
+		return m.cmdError(fmt.Errorf("utils.CopyToClipboard: %w", err))
+	}
+	return nil
+}
+
+func (m *Model) handleCodeBlockWriteMsg(msg components.CodeBlockWriteMsg) tea.Cmd {
+	path, err := utils.ExpandPath(msg.Path)
+	if err != nil {
+		return m.cmdError(fmt.Errorf("utils.ExpandPath: %w", err))
+	}
+	// never overwrite existing files
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return m.cmdError(fmt.Errorf("os.OpenFile: %w", err))
+	}
+	defer f.Close()
+	code := msg.Block.Code
+	if !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+	if _, err := f.WriteString(code); err != nil {
+		return m.cmdError(fmt.Errorf("f.WriteString: %w", err))
+	}
+	return nil
 }
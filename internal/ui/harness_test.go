@@ -0,0 +1,244 @@
+package ui
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aavshr/panda/internal/config"
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/ui/llm"
+	"github.com/aavshr/panda/internal/ui/store"
+	"github.com/adrg/xdg"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+const (
+	// cmdTimeout is how long a command may take, the ones that take longer
+	// are timers like the cursor blink and are dropped
+	cmdTimeout = 50 * time.Millisecond
+	// maxSteps stops models that keep sending themselves messages
+	maxSteps = 1000
+)
+
+func TestMain(m *testing.M) {
+	// the ui reads its config, keymap and caches from the xdg directories
+	dir, err := os.MkdirTemp("", "panda-ui")
+	if err != nil {
+		panic(err)
+	}
+	for env, name := range map[string]string{"XDG_CONFIG_HOME": "config", "XDG_CACHE_HOME": "cache", "XDG_DATA_HOME": "data"} {
+		os.Setenv(env, filepath.Join(dir, name))
+	}
+	xdg.Reload()
+	if err := os.MkdirAll(config.GetDir(), 0700); err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(config.GetFilePath(), []byte(`{"llm_api_key": "test", "llm_model": "gpt-4o"}`), 0600); err != nil {
+		panic(err)
+	}
+	// the auto theme does not depend on the terminal running the tests
+	lipgloss.SetHasDarkBackground(true)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// harness drives the model like the bubbletea loop does, messages are
+// delivered one at a time and the commands they return are run to queue
+// the next ones
+type harness struct {
+	t     *testing.T
+	m     *Model
+	store *store.Mock
+	llm   *llm.Mock
+	queue []tea.Msg
+	quit  bool
+}
+
+// newHarness starts the model with the threads and messages in the store
+// at the terminal size
+func newHarness(t *testing.T, width, height int, threads []*db.Thread, messages []*db.Message) *harness {
+	t.Helper()
+	h := &harness{
+		t:     t,
+		store: store.NewMock(threads, messages),
+		llm:   llm.NewMock(),
+	}
+	m, err := New(&Config{InitThreadsLimit: 10, MaxThreadsLimit: 100, MessagesLimit: 50}, h.store, h.llm)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	m.now = func() time.Time {
+		return time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC)
+	}
+	h.m = m
+	h.run(m.Init())
+	h.send(tea.WindowSizeMsg{Width: width, Height: height})
+	h.settle()
+	return h
+}
+
+// send delivers a message and queues the messages of its command
+func (h *harness) send(msg tea.Msg) {
+	h.t.Helper()
+	_, cmd := h.m.Update(msg)
+	h.run(cmd)
+}
+
+// step delivers the next queued message, false if there is none
+func (h *harness) step() bool {
+	h.t.Helper()
+	if len(h.queue) == 0 {
+		return false
+	}
+	msg := h.queue[0]
+	h.queue = h.queue[1:]
+	h.send(msg)
+	return true
+}
+
+// settle delivers the queued messages until the model is idle
+func (h *harness) settle() {
+	h.t.Helper()
+	for i := 0; h.step(); i++ {
+		if i == maxSteps {
+			h.t.Fatalf("the model did not settle after %d messages", maxSteps)
+		}
+	}
+}
+
+// keys sends the keys and settles after each one
+func (h *harness) keys(keys ...tea.KeyMsg) {
+	h.t.Helper()
+	for _, k := range keys {
+		h.send(k)
+		h.settle()
+	}
+}
+
+// typeText types the text in one go like the terminal sends fast typing
+func (h *harness) typeText(text string) {
+	h.t.Helper()
+	h.keys(runes(text))
+}
+
+func press(t tea.KeyType) tea.KeyMsg {
+	return tea.KeyMsg{Type: t}
+}
+
+func runes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+// run runs the command and its batched commands concurrently and queues
+// their messages in order
+func (h *harness) run(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	results := make(chan tea.Msg, 1)
+	go func() {
+		results <- cmd()
+	}()
+	select {
+	case msg := <-results:
+		h.handle(msg)
+	case <-time.After(cmdTimeout):
+	}
+}
+
+// handle queues the message of a command
+func (h *harness) handle(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case nil:
+	case tea.BatchMsg:
+		h.runBatch(msg)
+	case tea.QuitMsg:
+		h.quit = true
+	default:
+		h.queue = append(h.queue, msg)
+	}
+}
+
+func (h *harness) runBatch(cmds []tea.Cmd) {
+	done := make([]chan tea.Msg, len(cmds))
+	for i, cmd := range cmds {
+		done[i] = make(chan tea.Msg, 1)
+		if cmd == nil {
+			done[i] <- nil
+			continue
+		}
+		go func() {
+			done[i] <- cmd()
+		}()
+	}
+	// the commands that are still running after the timeout are dropped,
+	// the ones that finished are kept in order
+	timeout := time.After(cmdTimeout)
+	expired := false
+	for _, results := range done {
+		if !expired {
+			select {
+			case msg := <-results:
+				h.handle(msg)
+				continue
+			case <-timeout:
+				expired = true
+			}
+		}
+		select {
+		case msg := <-results:
+			h.handle(msg)
+		default:
+		}
+	}
+}
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// view renders the model, with ansi the colors are rendered in true color
+// so that changes of the styles show up as well
+func (h *harness) view(ansi bool) string {
+	if !ansi {
+		return ansiPattern.ReplaceAllString(h.m.View(), "")
+	}
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	defer lipgloss.SetColorProfile(profile)
+	return h.m.View()
+}
+
+// assertGolden compares the view with testdata/<name>.golden, -update
+// writes the view to it instead
+func (h *harness) assertGolden(name string, ansi bool) {
+	h.t.Helper()
+	assertGolden(h.t, name, h.view(ansi))
+}
+
+func assertGolden(t *testing.T, name, view string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(view), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to create it", err)
+	}
+	if view != string(golden) {
+		t.Errorf("the view does not match %s, run the tests with -update after checking it:\n%s", path, view)
+	}
+}
@@ -0,0 +1,288 @@
+// Package keymap holds the key bindings of the terminal ui, they come from
+// a preset and are overridden by name in keymap.json
+package keymap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// Context is where a binding works
+type Context string
+
+const (
+	// ContextAll bindings work everywhere but in dialogs
+	ContextAll Context = "all"
+	// ContextMain bindings work when no section is focused
+	ContextMain     Context = "main"
+	ContextHistory  Context = "history"
+	ContextMessages Context = "messages"
+	ContextChat     Context = "chat"
+)
+
+// Contexts are the contexts in the order they are shown in the help
+var Contexts = []Context{ContextAll, ContextMain, ContextHistory, ContextMessages, ContextChat}
+
+const (
+	PresetDefault = "default"
+	PresetVim     = "vim"
+	PresetEmacs   = "emacs"
+)
+
+var (
+	ErrUnknownPreset  = errors.New("unknown keymap preset")
+	ErrUnknownBinding = errors.New("unknown key binding")
+
+	Presets = []string{PresetDefault, PresetVim, PresetEmacs}
+)
+
+// shared are bindings that are meant to share keys, tab completes and
+// sends once there is nothing to complete
+var shared = map[[2]string]bool{
+	{"complete", "send"}: true,
+}
+
+type KeyMap struct {
+	Palette key.Binding
+	Quit    key.Binding
+	Back    key.Binding
+	Retry   key.Binding
+
+	Up       key.Binding
+	Down     key.Binding
+	Left     key.Binding
+	Right    key.Binding
+	Focus    key.Binding
+	Help     key.Binding
+	Settings key.Binding
+	Params   key.Binding
+	Model    key.Binding
+
+	DeleteThread key.Binding
+	ExportThread key.Binding
+
+	CopyCode  key.Binding
+	WriteCode key.Binding
+
+	Send     key.Binding
+	Complete key.Binding
+}
+
+// Binding is a binding of the keymap with its name in keymap.json
+type Binding struct {
+	Name    string
+	Context Context
+	*key.Binding
+}
+
+// Bindings returns the bindings in the order they are shown in the help
+func (k *KeyMap) Bindings() []Binding {
+	return []Binding{
+		{"palette", ContextAll, &k.Palette},
+		{"quit", ContextAll, &k.Quit},
+		{"back", ContextAll, &k.Back},
+		{"retry", ContextAll, &k.Retry},
+		{"up", ContextMain, &k.Up},
+		{"down", ContextMain, &k.Down},
+		{"left", ContextMain, &k.Left},
+		{"right", ContextMain, &k.Right},
+		{"focus", ContextMain, &k.Focus},
+		{"help", ContextMain, &k.Help},
+		{"settings", ContextMain, &k.Settings},
+		{"params", ContextMain, &k.Params},
+		{"model", ContextMain, &k.Model},
+		{"delete_thread", ContextHistory, &k.DeleteThread},
+		{"export_thread", ContextHistory, &k.ExportThread},
+		{"copy_code", ContextMessages, &k.CopyCode},
+		{"write_code", ContextMessages, &k.WriteCode},
+		{"send", ContextChat, &k.Send},
+		{"complete", ContextChat, &k.Complete},
+	}
+}
+
+func binding(desc string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(strings.Join(keys, "/"), desc))
+}
+
+// Default returns the default bindings
+func Default() *KeyMap {
+	return &KeyMap{
+		Palette: binding("command palette", "ctrl+p"),
+		Quit:    binding("quit", "ctrl+c"),
+		Back:    binding("focus out, close", "esc"),
+		Retry:   binding("retry the last message", "ctrl+r"),
+
+		Up:       binding("move up", "k", "up"),
+		Down:     binding("move down", "j", "down"),
+		Left:     binding("move left", "h", "left"),
+		Right:    binding("move right", "l", "right"),
+		Focus:    binding("focus the section", "enter"),
+		Help:     binding("key bindings", "?"),
+		Settings: binding("settings", "s"),
+		Params:   binding("generation parameters", "p"),
+		Model:    binding("pick the model", "m"),
+
+		DeleteThread: binding("delete the thread", "ctrl+d"),
+		ExportThread: binding("export the thread to markdown", "ctrl+e"),
+
+		CopyCode:  binding("copy code block <n>", "y"),
+		WriteCode: binding("write code block <n> to a file", "w"),
+
+		Send:     binding("send the message", "tab"),
+		Complete: binding("complete a path or command", "tab"),
+	}
+}
+
+// Vim keeps tab for completing and indenting and moves with hjkl only
+func Vim() *KeyMap {
+	k := Default()
+	setKeys(&k.Up, "k")
+	setKeys(&k.Down, "j")
+	setKeys(&k.Left, "h")
+	setKeys(&k.Right, "l")
+	setKeys(&k.Focus, "enter", "i")
+	setKeys(&k.Send, "ctrl+s")
+	return k
+}
+
+// Emacs moves with ctrl+p/n/b/f and opens the palette with alt+x
+func Emacs() *KeyMap {
+	k := Default()
+	setKeys(&k.Palette, "alt+x")
+	setKeys(&k.Back, "esc", "ctrl+g")
+	setKeys(&k.Up, "ctrl+p", "up")
+	setKeys(&k.Down, "ctrl+n", "down")
+	setKeys(&k.Left, "ctrl+b", "left")
+	setKeys(&k.Right, "ctrl+f", "right")
+	setKeys(&k.CopyCode, "alt+w")
+	setKeys(&k.WriteCode, "ctrl+w")
+	setKeys(&k.Send, "alt+enter")
+	return k
+}
+
+// setKeys replaces the keys of a binding, no keys disable it
+func setKeys(b *key.Binding, keys ...string) {
+	b.SetKeys(keys...)
+	b.SetHelp(strings.Join(keys, "/"), b.Help().Desc)
+	b.SetEnabled(len(keys) > 0)
+}
+
+// New returns the bindings of a preset, empty for the default
+func New(preset string) (*KeyMap, error) {
+	switch preset {
+	case "", PresetDefault:
+		return Default(), nil
+	case PresetVim:
+		return Vim(), nil
+	case PresetEmacs:
+		return Emacs(), nil
+	}
+	return nil, fmt.Errorf("%w: %s, use one of %s", ErrUnknownPreset, preset, strings.Join(Presets, ", "))
+}
+
+// Apply overrides bindings by name, unknown names are returned as an error
+// after the others are applied
+func (k *KeyMap) Apply(bindings map[string][]string) error {
+	byName := make(map[string]Binding)
+	for _, b := range k.Bindings() {
+		byName[b.Name] = b
+	}
+	var unknown []string
+	for name, keys := range bindings {
+		b, ok := byName[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		setKeys(b.Binding, keys...)
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%w: %s", ErrUnknownBinding, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// File is the content of keymap.json
+type File struct {
+	Preset   string              `json:"preset,omitempty"`
+	Bindings map[string][]string `json:"bindings,omitempty"`
+}
+
+// Load reads the keymap file, the default bindings are used if it does not
+// exist, an invalid file returns the default bindings with the error
+func Load(path string) (*KeyMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Default(), nil
+		}
+		return Default(), fmt.Errorf("os.ReadFile: %w", err)
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Default(), fmt.Errorf("invalid keymap %s: %w", path, err)
+	}
+	k, err := New(f.Preset)
+	if err != nil {
+		return Default(), err
+	}
+	return k, k.Apply(f.Bindings)
+}
+
+// Conflict is a key bound to several bindings that work in the same place
+type Conflict struct {
+	Key      string
+	Bindings []string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s is bound to %s", c.Key, strings.Join(c.Bindings, " and "))
+}
+
+func overlaps(a, b Context) bool {
+	return a == b || a == ContextAll || b == ContextAll
+}
+
+// Conflicts returns the keys bound to bindings that can not both work,
+// sorted by key
+func (k *KeyMap) Conflicts() []Conflict {
+	byKey := make(map[string][]Binding)
+	for _, b := range k.Bindings() {
+		if !b.Enabled() {
+			continue
+		}
+		for _, keyName := range b.Keys() {
+			byKey[keyName] = append(byKey[keyName], b)
+		}
+	}
+	var conflicts []Conflict
+	for keyName, bindings := range byKey {
+		names := make(map[string]struct{})
+		for i, a := range bindings {
+			for _, b := range bindings[i+1:] {
+				if overlaps(a.Context, b.Context) && !shared[[2]string{a.Name, b.Name}] && !shared[[2]string{b.Name, a.Name}] {
+					names[a.Name] = struct{}{}
+					names[b.Name] = struct{}{}
+				}
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		conflict := Conflict{Key: keyName}
+		for name := range names {
+			conflict.Bindings = append(conflict.Bindings, name)
+		}
+		sort.Strings(conflict.Bindings)
+		conflicts = append(conflicts, conflict)
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Key < conflicts[j].Key })
+	return conflicts
+}
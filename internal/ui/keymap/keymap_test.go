@@ -0,0 +1,80 @@
+package keymap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPresetsHaveNoConflicts(t *testing.T) {
+	for _, preset := range Presets {
+		k, err := New(preset)
+		if err != nil {
+			t.Fatalf("New(%s): %v", preset, err)
+		}
+		if conflicts := k.Conflicts(); len(conflicts) > 0 {
+			t.Errorf("expected no conflicts in %s, got %v", preset, conflicts)
+		}
+	}
+	if _, err := New("nano"); !errors.Is(err, ErrUnknownPreset) {
+		t.Errorf("expected ErrUnknownPreset, got %v", err)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	k := Default()
+	err := k.Apply(map[string][]string{
+		// quit works everywhere so it collides with delete_thread
+		"quit": {"ctrl+c", "ctrl+d"},
+		// different sections do not collide
+		"copy_code": {"s"},
+		"send":      {"ctrl+s"},
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	expected := []Conflict{{Key: "ctrl+d", Bindings: []string{"delete_thread", "quit"}}}
+	if conflicts := k.Conflicts(); !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("expected %v, got %v", expected, conflicts)
+	}
+
+	// disabled bindings do not collide
+	if err := k.Apply(map[string][]string{"delete_thread": nil}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if conflicts := k.Conflicts(); len(conflicts) != 0 || k.DeleteThread.Enabled() {
+		t.Errorf("expected delete_thread to be disabled, got %v", conflicts)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if k, err := Load(filepath.Join(dir, "missing.json")); err != nil || k.Send.Keys()[0] != "tab" {
+		t.Fatalf("expected the default keymap without a file, got %v", err)
+	}
+
+	path := filepath.Join(dir, "keymap.json")
+	content := `{"preset": "emacs", "bindings": {"send": ["ctrl+j"], "yank": ["ctrl+y"]}}`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	k, err := Load(path)
+	if !errors.Is(err, ErrUnknownBinding) {
+		t.Errorf("expected ErrUnknownBinding, got %v", err)
+	}
+	if !reflect.DeepEqual(k.Send.Keys(), []string{"ctrl+j"}) || k.Send.Help().Key != "ctrl+j" {
+		t.Errorf("expected send to be overridden, got %v", k.Send.Keys())
+	}
+	if !reflect.DeepEqual(k.Palette.Keys(), []string{"alt+x"}) {
+		t.Errorf("expected the emacs preset, got %v", k.Palette.Keys())
+	}
+
+	if err := os.WriteFile(path, []byte(`{"preset": `), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if k, err := Load(path); err == nil || k == nil {
+		t.Errorf("expected an error and the default keymap, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/aavshr/panda/internal/ui/components"
+)
+
+/*
+	| history  |  messages  |
+	|          |            |
+	|          |            |
+	|----------|------------|
+	|  chat input           |
+	 status bar
+*/
+const (
+	widthSeparationRatio  = 0.2
+	heightSeparationRatio = 0.1
+
+	// borderSize is what a border takes on both sides
+	borderSize = 2
+
+	minHistoryWidth    = 16
+	maxHistoryWidth    = 40
+	minMessagesWidth   = 20
+	minMessagesHeight  = 3
+	minChatInputHeight = 3
+	// statusBarHeight is the line below the main border
+	statusBarHeight = 1
+	// collapseWidth is the terminal width below which the history is
+	// hidden to leave the width to the messages
+	collapseWidth = 70
+
+	// the main border around the messages and the chat input
+	minWidth  = minMessagesWidth + 2*borderSize
+	minHeight = minMessagesHeight + minChatInputHeight + 3*borderSize + statusBarHeight
+
+	// defaultWidth and defaultHeight are used until the terminal reports
+	// its size
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// size is the size of the content of a section inside its border
+type size struct {
+	width  int
+	height int
+}
+
+// layout is the size of every section for a terminal size
+type layout struct {
+	width     int
+	height    int
+	history   size
+	messages  size
+	chatInput size
+	statusBar size
+	// showHistory is unset on narrow terminals
+	showHistory bool
+	// tooSmall is set below the minimum size, only a note is shown then
+	tooSmall bool
+}
+
+// computeLayout splits the terminal between the sections, the history
+// takes a share of the width next to the messages and the chat input a
+// share of the height below them
+func computeLayout(width, height int) layout {
+	l := layout{width: width, height: height}
+	if width < minWidth || height < minHeight {
+		l.tooSmall = true
+		return l
+	}
+	// everything but the status bar is inside the main border, the chat
+	// input and the row of history and messages have their own borders
+	l.statusBar = size{width: width, height: statusBarHeight}
+	inner := width - borderSize
+	available := height - 3*borderSize - statusBarHeight
+	l.chatInput.width = inner - borderSize
+	l.chatInput.height = max(minChatInputHeight, int(float64(available)*heightSeparationRatio))
+	l.messages.height = available - l.chatInput.height
+	l.history.height = l.messages.height
+
+	l.showHistory = width >= collapseWidth
+	if !l.showHistory {
+		l.messages.width = inner - borderSize
+		return l
+	}
+	panes := inner - 2*borderSize
+	l.history.width = min(max(int(float64(panes)*widthSeparationRatio), minHistoryWidth), maxHistoryWidth)
+	l.messages.width = panes - l.history.width
+	return l
+}
+
+// setLayout resizes the sections, it is called whenever the terminal is
+// resized
+func (m *Model) setLayout(l layout) {
+	m.layout = l
+	if l.tooSmall {
+		return
+	}
+	m.historyModel.SetSize(l.history.width, l.history.height)
+	m.messagesModel.SetSize(l.messages.width, l.messages.height)
+	m.chatInputModel.SetSize(l.chatInput.width, l.chatInput.height)
+	m.statusBar.SetWidth(l.statusBar.width)
+	sizes := map[components.Component]size{
+		components.ComponentHistory:   l.history,
+		components.ComponentMessages:  l.messages,
+		components.ComponentChatInput: l.chatInput,
+	}
+	for com, s := range sizes {
+		if container, ok := m.componentsToContainer[com]; ok {
+			m.componentsToContainer[com] = container.Width(s.width).Height(s.height)
+		}
+	}
+	// the hidden history can not be selected
+	if !l.showHistory && m.focusedComponent == components.ComponentHistory {
+		m.historyModel.Blur()
+		m.focusedComponent = components.ComponentNone
+	}
+	if !l.showHistory && m.selectedComponent == components.ComponentHistory {
+		m.setSelectedComponent(components.ComponentMessages)
+	}
+}
+
+func (l layout) tooSmallView() string {
+	return fmt.Sprintf("The terminal is too small (%dx%d), resize it to at least %dx%d.", l.width, l.height, minWidth, minHeight)
+}
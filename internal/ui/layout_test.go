@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func layoutThreads() []*db.Thread {
+	return []*db.Thread{
+		{ID: "1", Name: "Layout engine", CreatedAt: "2024-01-03", UpdatedAt: "2024-01-04"},
+		{ID: "2", Name: "Resize handling", CreatedAt: "2024-01-01", UpdatedAt: "2024-01-02"},
+	}
+}
+
+func TestComputeLayout(t *testing.T) {
+	for _, tc := range []struct {
+		width, height int
+		showHistory   bool
+		tooSmall      bool
+	}{
+		{200, 60, true, false},
+		{collapseWidth, minHeight, true, false},
+		{collapseWidth - 1, 30, false, false},
+		{minWidth, minHeight, false, false},
+		{minWidth - 1, 30, false, true},
+		{100, minHeight - 1, false, true},
+	} {
+		l := computeLayout(tc.width, tc.height)
+		if l.showHistory != tc.showHistory || l.tooSmall != tc.tooSmall {
+			t.Errorf("%dx%d: expected history %v and too small %v, got %+v", tc.width, tc.height, tc.showHistory, tc.tooSmall, l)
+			continue
+		}
+		if l.tooSmall {
+			continue
+		}
+		width := l.messages.width + borderSize
+		if l.showHistory {
+			width += l.history.width + borderSize
+			if l.history.width < minHistoryWidth || l.history.width > maxHistoryWidth {
+				t.Errorf("%dx%d: history width %d out of bounds", tc.width, tc.height, l.history.width)
+			}
+		}
+		// the row of panes and the chat input fill the main border
+		if width+borderSize != tc.width || l.chatInput.width+2*borderSize != tc.width {
+			t.Errorf("%dx%d: sections do not fill the width: %+v", tc.width, tc.height, l)
+		}
+		// the status bar is below the main border
+		if l.messages.height+l.chatInput.height+3*borderSize+l.statusBar.height != tc.height || l.statusBar.width != tc.width {
+			t.Errorf("%dx%d: sections do not fill the height: %+v", tc.width, tc.height, l)
+		}
+		if l.messages.width < minMessagesWidth || l.messages.height < minMessagesHeight || l.chatInput.height < minChatInputHeight {
+			t.Errorf("%dx%d: below the minimum sizes: %+v", tc.width, tc.height, l)
+		}
+	}
+}
+
+func TestLayoutGolden(t *testing.T) {
+	for _, tc := range []struct{ width, height int }{
+		{120, 40},
+		{80, 24},
+		{60, 20},
+		{20, 8},
+	} {
+		name := fmt.Sprintf("%dx%d", tc.width, tc.height)
+		t.Run(name, func(t *testing.T) {
+			// the terminal reports its size after the start and on resizes
+			h := newHarness(t, tc.width, tc.height, layoutThreads(), nil)
+			view := h.view(false)
+
+			if !h.m.layout.tooSmall {
+				lines := strings.Split(view, "\n")
+				if len(lines) != tc.height {
+					t.Errorf("expected %d lines, got %d", tc.height, len(lines))
+				}
+				for i, line := range lines {
+					if w := lipgloss.Width(line); w != tc.width {
+						t.Errorf("line %d is %d wide, expected %d", i, w, tc.width)
+					}
+				}
+			}
+
+			assertGolden(t, "layout_"+name, view)
+		})
+	}
+}
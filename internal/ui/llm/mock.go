@@ -0,0 +1,232 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aavshr/panda/internal/db"
+)
+
+var ErrMockFailure = errors.New("mock failure")
+
+// defaultMockChunks are streamed once the scripted responses ran out
+var defaultMockChunks = []string{"this is a mock AI response.", "\nwith a new line."}
+
+// Response is a scripted answer of the Mock, the chunks are streamed one
+// per read and Err is returned after them. Err without chunks fails the
+// request itself.
+type Response struct {
+	Chunks []string
+	Err    error
+}
+
+// MockConfig configures how the Mock answers
+type MockConfig struct {
+	// Echo answers with the last user message instead of the canned answer
+	// once the scripted responses ran out
+	Echo bool
+	// ChunkSize splits every answer into chunks of as many characters, 0
+	// streams the chunks as they are scripted
+	ChunkSize int
+	// Latency is waited before an answer and before every chunk
+	Latency time.Duration
+	// FailEvery fails every nth request with ErrMockFailure, 0 never fails
+	FailEvery int
+}
+
+// Request is a request the Mock got
+type Request struct {
+	Model    string
+	Params   Params
+	Messages []*db.Message
+	Stream   bool
+}
+
+// Mock is an offline backend for tests and demos, it answers with
+// scripted responses in order and records the requests it gets
+type Mock struct {
+	mu        sync.Mutex
+	conf      MockConfig
+	responses []Response
+	requests  []Request
+	tools     []ToolDefinition
+}
+
+func NewMock(responses ...Response) *Mock {
+	return &Mock{responses: responses}
+}
+
+// SetConfig changes how the following requests are answered
+func (m *Mock) SetConfig(conf MockConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conf = conf
+}
+
+// Script queues responses after the ones that are left
+func (m *Mock) Script(responses ...Response) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses = append(m.responses, responses...)
+}
+
+// Requests returns the requests in the order they were made
+func (m *Mock) Requests() []Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.requests)
+}
+
+// Tools returns the tools offered to the model
+func (m *Mock) Tools() []ToolDefinition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tools
+}
+
+// respond records the request and returns its answer
+func (m *Mock) respond(request Request) (Response, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	request.Messages = slices.Clone(request.Messages)
+	m.requests = append(m.requests, request)
+	if m.conf.FailEvery > 0 && len(m.requests)%m.conf.FailEvery == 0 {
+		return Response{Err: fmt.Errorf("%w: request %d", ErrMockFailure, len(m.requests))}, m.conf.Latency
+	}
+
+	var r Response
+	switch {
+	case len(m.responses) > 0:
+		r = m.responses[0]
+		m.responses = m.responses[1:]
+	case m.conf.Echo:
+		r = Response{Chunks: []string{lastUserMessage(request.Messages)}}
+	default:
+		r = Response{Chunks: defaultMockChunks}
+	}
+	r.Chunks = slices.Clone(r.Chunks)
+	if m.conf.ChunkSize > 0 {
+		r.Chunks = splitChunks(strings.Join(r.Chunks, ""), m.conf.ChunkSize)
+	}
+	return r, m.conf.Latency
+}
+
+func lastUserMessage(messages []*db.Message) string {
+	for _, message := range slices.Backward(messages) {
+		if message.Role == "user" {
+			return message.Content
+		}
+	}
+	return ""
+}
+
+// splitChunks splits s into chunks of size runes, the last one may be
+// shorter
+func splitChunks(s string, size int) []string {
+	var chunks []string
+	r := []rune(s)
+	for len(r) > 0 {
+		n := min(size, len(r))
+		chunks = append(chunks, string(r[:n]))
+		r = r[n:]
+	}
+	return chunks
+}
+
+// wait sleeps for d unless the context is done first
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (m *Mock) CreateChatCompletion(ctx context.Context, model string, params Params, messages []*db.Message) (string, error) {
+	r, latency := m.respond(Request{Model: model, Params: params, Messages: messages})
+	if err := wait(ctx, latency); err != nil {
+		return "", err
+	}
+	if r.Err != nil {
+		return "", r.Err
+	}
+	return strings.Join(r.Chunks, ""), nil
+}
+
+func (m *Mock) CreateChatCompletionStream(ctx context.Context, model string, params Params, messages []*db.Message) (io.ReadCloser, error) {
+	r, latency := m.respond(Request{Model: model, Params: params, Messages: messages, Stream: true})
+	if len(r.Chunks) == 0 && r.Err != nil {
+		if err := wait(ctx, latency); err != nil {
+			return nil, err
+		}
+		return nil, r.Err
+	}
+	return &mockStream{ctx: ctx, chunks: r.Chunks, err: r.Err, latency: latency}, nil
+}
+
+func (m *Mock) SetAPIKey(string) error {
+	return nil
+}
+
+func (m *Mock) SetBaseURL(string) error {
+	return nil
+}
+
+func (m *Mock) SetTools(tools []ToolDefinition) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tools = tools
+}
+
+func (m *Mock) ListModels(ctx context.Context) ([]string, error) {
+	return []string{"mock"}, nil
+}
+
+// mockStream returns a chunk per read after the latency, the rest of a
+// chunk that does not fit is returned by the next read
+type mockStream struct {
+	ctx     context.Context
+	chunks  []string
+	err     error
+	latency time.Duration
+	// partial is set while the rest of a chunk is read
+	partial bool
+}
+
+func (s *mockStream) Read(p []byte) (int, error) {
+	if !s.partial {
+		if err := wait(s.ctx, s.latency); err != nil {
+			return 0, err
+		}
+	}
+	if len(s.chunks) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, s.chunks[0])
+	s.partial = n < len(s.chunks[0])
+	if s.partial {
+		s.chunks[0] = s.chunks[0][n:]
+	} else {
+		s.chunks = s.chunks[1:]
+	}
+	return n, nil
+}
+
+func (s *mockStream) Close() error {
+	return nil
+}
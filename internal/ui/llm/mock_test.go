@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aavshr/panda/internal/db"
+)
+
+// readChunks reads the stream a read at a time
+func readChunks(t *testing.T, stream io.Reader) ([]string, error) {
+	t.Helper()
+	var chunks []string
+	buffer := make([]byte, 64)
+	for {
+		n, err := stream.Read(buffer)
+		if n > 0 {
+			chunks = append(chunks, string(buffer[:n]))
+		}
+		if errors.Is(err, io.EOF) {
+			return chunks, nil
+		}
+		if err != nil {
+			return chunks, err
+		}
+	}
+}
+
+func TestMockScript(t *testing.T) {
+	boom := errors.New("boom")
+	m := NewMock(Response{Chunks: []string{"a", "bc"}})
+	m.Script(Response{Chunks: []string{"partial"}, Err: boom}, Response{Err: boom})
+	ctx := context.Background()
+	messages := []*db.Message{{Role: "user", Content: "hi"}}
+
+	stream, err := m.CreateChatCompletionStream(ctx, "gpt-4o", Params{}, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunks, err := readChunks(t, stream); err != nil || !slices.Equal(chunks, []string{"a", "bc"}) {
+		t.Errorf("expected the scripted chunks, got %q, %v", chunks, err)
+	}
+	stream, err = m.CreateChatCompletionStream(ctx, "gpt-4o", Params{}, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunks, err := readChunks(t, stream); !errors.Is(err, boom) || !slices.Equal(chunks, []string{"partial"}) {
+		t.Errorf("expected the error after the chunks, got %q, %v", chunks, err)
+	}
+	if _, err := m.CreateChatCompletion(ctx, "o3-mini", Params{}, nil); !errors.Is(err, boom) {
+		t.Errorf("expected the request to fail, got %v", err)
+	}
+	if answer, err := m.CreateChatCompletion(ctx, "o3-mini", Params{}, nil); err != nil || answer == "" {
+		t.Errorf("expected the canned answer once the script ran out, got %q, %v", answer, err)
+	}
+
+	requests := m.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("expected the requests to be recorded, got %d", len(requests))
+	}
+	if r := requests[0]; r.Model != "gpt-4o" || !r.Stream || len(r.Messages) != 1 || r.Messages[0].Content != "hi" {
+		t.Errorf("unexpected first request %+v", r)
+	}
+	if r := requests[3]; r.Model != "o3-mini" || r.Stream {
+		t.Errorf("unexpected last request %+v", r)
+	}
+}
+
+func TestMockConfig(t *testing.T) {
+	m := NewMock()
+	m.SetConfig(MockConfig{Echo: true, ChunkSize: 3, FailEvery: 2})
+	ctx := context.Background()
+	messages := []*db.Message{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "answer"},
+		{Role: "user", Content: "héllo!"},
+	}
+
+	stream, err := m.CreateChatCompletionStream(ctx, "mock", Params{}, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunks, err := readChunks(t, stream); err != nil || !slices.Equal(chunks, []string{"hél", "lo!"}) {
+		t.Errorf("expected the last user message in chunks of 3 characters, got %q, %v", chunks, err)
+	}
+	if _, err := m.CreateChatCompletionStream(ctx, "mock", Params{}, messages); !errors.Is(err, ErrMockFailure) {
+		t.Errorf("expected every second request to fail, got %v", err)
+	}
+
+	m.SetConfig(MockConfig{Latency: time.Hour})
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err = m.CreateChatCompletionStream(ctx, "mock", Params{}, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := stream.Read(make([]byte, 8)); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the latency to stop with the context, got %v", err)
+	}
+}
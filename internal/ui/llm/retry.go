@@ -0,0 +1,260 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/aavshr/panda/internal/db"
+	base "github.com/aavshr/panda/internal/llm"
+)
+
+// RetryConfig configures how requests are made again after transient
+// errors like rate limits, server errors and dropped connections
+type RetryConfig struct {
+	// MaxAttempts is how often a request is made at most, 1 never retries
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, it doubles with every
+	// retry up to MaxDelay and a random part of up to half of it is taken
+	// off so that clients do not retry in step
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Budget is how many retries are left across requests, a successful
+	// request gives one back so that an outage does not multiply the
+	// requests
+	Budget int
+}
+
+// DefaultRetryConfig retries a request up to three times within about
+// half a minute
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: 4,
+		BaseDelay:   time.Second,
+		MaxDelay:    20 * time.Second,
+		Budget:      10,
+	}
+}
+
+// RetryEvent is reported before a request is made again
+type RetryEvent struct {
+	// Attempt is the attempt that is made after the delay, 2 for the first
+	// retry
+	Attempt     int
+	MaxAttempts int
+	Delay       time.Duration
+	// Err is why the previous attempt failed
+	Err error
+}
+
+// Retry makes the requests of a backend again after transient errors, the
+// wait of a Retry-After header is used over the backoff
+type Retry struct {
+	LLM
+	conf RetryConfig
+
+	mu      sync.Mutex
+	budget  int
+	onRetry func(RetryEvent)
+	// jitter returns a random number in [0, 1)
+	jitter func() float64
+}
+
+func NewRetry(backend LLM, conf RetryConfig) *Retry {
+	return &Retry{
+		LLM:    backend,
+		conf:   conf,
+		budget: conf.Budget,
+		jitter: rand.Float64,
+	}
+}
+
+// OnRetry sets where retries are reported, f is called from the goroutine
+// making the request
+func (r *Retry) OnRetry(f func(RetryEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onRetry = f
+}
+
+// backoff returns the wait before the retry after the attempt
+func (r *Retry) backoff(attempt int) time.Duration {
+	delay := r.conf.BaseDelay
+	for i := 1; i < attempt && delay < r.conf.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, r.conf.MaxDelay)
+	return delay - time.Duration(r.jitter()*float64(delay)/2)
+}
+
+// succeeded gives a retry back to the budget
+func (r *Retry) succeeded() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.budget = min(r.budget+1, r.conf.Budget)
+}
+
+// retry waits before the next attempt after the failed one, err is
+// returned if the request is not made again
+func (r *Retry) retry(ctx context.Context, attempt int, err error) error {
+	if attempt >= r.conf.MaxAttempts || ctx.Err() != nil || !base.Retryable(err) {
+		return err
+	}
+	delay := r.backoff(attempt)
+	var statusErr *base.StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		// waiting longer than the backoff ever would is left to the user
+		if statusErr.RetryAfter > r.conf.MaxDelay {
+			return err
+		}
+		delay = statusErr.RetryAfter
+	}
+
+	r.mu.Lock()
+	if r.budget <= 0 {
+		r.mu.Unlock()
+		return err
+	}
+	r.budget--
+	onRetry := r.onRetry
+	r.mu.Unlock()
+
+	if onRetry != nil {
+		onRetry(RetryEvent{Attempt: attempt + 1, MaxAttempts: r.conf.MaxAttempts, Delay: delay, Err: err})
+	}
+	return wait(ctx, delay)
+}
+
+func (r *Retry) CreateChatCompletion(ctx context.Context, model string, params Params, messages []*db.Message) (string, error) {
+	for attempt := 1; ; attempt++ {
+		answer, err := r.LLM.CreateChatCompletion(ctx, model, params, messages)
+		if err == nil {
+			r.succeeded()
+			return answer, nil
+		}
+		if err := r.retry(ctx, attempt, err); err != nil {
+			return "", err
+		}
+	}
+}
+
+// CreateChatCompletionStream returns a stream that makes the request on the
+// first read so that waiting for a retry does not block the caller, the
+// request is made with the settings of the backend at this call
+func (r *Retry) CreateChatCompletionStream(ctx context.Context, model string, params Params, messages []*db.Message) (io.ReadCloser, error) {
+	var backend base.StreamCreator = r.LLM
+	if snapshotter, ok := r.LLM.(base.Snapshotter); ok {
+		backend = snapshotter.Snapshot()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &retryStream{
+		retry:  r,
+		ctx:    ctx,
+		cancel: cancel,
+		create: func(ctx context.Context) (io.ReadCloser, error) {
+			return backend.CreateChatCompletionStream(ctx, model, params, messages)
+		},
+	}, nil
+}
+
+// retryStream makes the request again until content was read, content
+// that was passed on can not be taken back so later errors are returned.
+// It is read on one goroutine and may be closed on another, closing ends
+// the wait for a retry
+type retryStream struct {
+	retry  *Retry
+	ctx    context.Context
+	cancel context.CancelFunc
+	create func(ctx context.Context) (io.ReadCloser, error)
+
+	mu     sync.Mutex
+	stream io.ReadCloser
+
+	attempt int
+	// started is set once content was read
+	started bool
+}
+
+func (s *retryStream) current() io.ReadCloser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream
+}
+
+// open makes the request, a stream made after the stream was closed is
+// closed right away
+func (s *retryStream) open() (io.ReadCloser, error) {
+	stream, err := s.create(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ctx.Err(); err != nil {
+		_ = stream.Close()
+		return nil, err
+	}
+	s.stream = stream
+	return stream, nil
+}
+
+// drop closes the stream of the failed attempt
+func (s *retryStream) drop(stream io.ReadCloser) {
+	s.mu.Lock()
+	if s.stream == stream {
+		s.stream = nil
+	}
+	s.mu.Unlock()
+	_ = stream.Close()
+}
+
+func (s *retryStream) Read(p []byte) (int, error) {
+	for {
+		stream := s.current()
+		if stream == nil {
+			s.attempt++
+			opened, err := s.open()
+			if err != nil {
+				if err := s.retry.retry(s.ctx, s.attempt, err); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			stream = opened
+		}
+		n, err := stream.Read(p)
+		s.started = s.started || n > 0
+		if errors.Is(err, io.EOF) {
+			s.retry.succeeded()
+		}
+		if err == nil || errors.Is(err, io.EOF) || s.started {
+			return n, err
+		}
+		s.drop(stream)
+		if err := s.retry.retry(s.ctx, s.attempt, err); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// ToolCalls returns the tool calls of the attempt that was read
+func (s *retryStream) ToolCalls() []base.ToolCall {
+	if stream, ok := s.current().(base.ToolCallStream); ok {
+		return stream.ToolCalls()
+	}
+	return nil
+}
+
+// Close ends the stream and the wait for a retry, the tool calls that were
+// read are kept
+func (s *retryStream) Close() error {
+	s.cancel()
+	stream := s.current()
+	if stream == nil {
+		return nil
+	}
+	return stream.Close()
+}
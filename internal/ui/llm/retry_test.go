@@ -0,0 +1,284 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aavshr/panda/internal/db"
+	base "github.com/aavshr/panda/internal/llm"
+	"github.com/aavshr/panda/internal/llm/openai"
+)
+
+const (
+	helloChunk = `{"choices":[{"index":0,"delta":{"content":"hello"}}]}`
+	worldChunk = `{"choices":[{"index":0,"delta":{"content":" world"}}]}`
+)
+
+// failureServer answers the requests with the handlers in order, the last
+// one answers all later requests
+func failureServer(t *testing.T, handlers ...http.HandlerFunc) (*openai.OpenAI, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		handlers[min(n, len(handlers))-1](w, r)
+	}))
+	t.Cleanup(server.Close)
+	o := openai.New(server.URL)
+	if err := o.SetAPIKey("key"); err != nil {
+		t.Fatal(err)
+	}
+	return o, &requests
+}
+
+func status(code int, retryAfter string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"error":{"message":"%s","type":"server_error"}}`, http.StatusText(code))
+	}
+}
+
+// stream sends the chunks and drops the connection unless it is done
+func stream(done bool, chunks ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		if done {
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.(http.Flusher).Flush()
+		// closing the connection ends the chunked body early
+		if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+func completion(content string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":%q}}]}`, content)
+	}
+}
+
+func newTestRetry(backend LLM, conf RetryConfig) (*Retry, *[]RetryEvent) {
+	r := NewRetry(backend, conf)
+	r.jitter = func() float64 { return 0 }
+	var events []RetryEvent
+	r.OnRetry(func(e RetryEvent) { events = append(events, e) })
+	return r, &events
+}
+
+var testRetryConfig = RetryConfig{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond, Budget: 10}
+
+func TestRetryStream(t *testing.T) {
+	messages := []*db.Message{{Role: "user", Content: "hi"}}
+	tests := []struct {
+		name     string
+		handlers []http.HandlerFunc
+		conf     RetryConfig
+		chunks   []string
+		kind     base.ErrorKind
+		requests int32
+		delays   []time.Duration
+	}{
+		{
+			name:     "server errors and a dropped stream before content",
+			handlers: []http.HandlerFunc{status(503, ""), status(500, ""), stream(false), stream(true, helloChunk, worldChunk)},
+			conf:     testRetryConfig,
+			chunks:   []string{"hello", " world"},
+			requests: 4,
+			delays:   []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond},
+		},
+		{
+			name:     "dropped stream after content",
+			handlers: []http.HandlerFunc{stream(false, helloChunk), stream(true, worldChunk)},
+			conf:     testRetryConfig,
+			chunks:   []string{"hello"},
+			kind:     base.ErrorKindNetwork,
+			requests: 1,
+		},
+		{
+			name:     "retry after longer than the max delay",
+			handlers: []http.HandlerFunc{status(429, "60"), stream(true, helloChunk)},
+			conf:     testRetryConfig,
+			kind:     base.ErrorKindRateLimit,
+			requests: 1,
+		},
+		{
+			name:     "not retryable",
+			handlers: []http.HandlerFunc{status(401, ""), stream(true, helloChunk)},
+			conf:     testRetryConfig,
+			kind:     base.ErrorKindAuth,
+			requests: 1,
+		},
+		{
+			name:     "attempts used up",
+			handlers: []http.HandlerFunc{status(502, "")},
+			conf:     RetryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Budget: 10},
+			kind:     base.ErrorKindNetwork,
+			requests: 2,
+			delays:   []time.Duration{time.Millisecond},
+		},
+		{
+			name:     "budget used up",
+			handlers: []http.HandlerFunc{status(502, "")},
+			conf:     RetryConfig{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Budget: 1},
+			kind:     base.ErrorKindNetwork,
+			requests: 2,
+			delays:   []time.Duration{time.Millisecond},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, requests := failureServer(t, tt.handlers...)
+			r, events := newTestRetry(backend, tt.conf)
+
+			s, err := r.CreateChatCompletionStream(context.Background(), "gpt-4o", Params{}, messages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := requests.Load(); n != 0 {
+				t.Errorf("expected the request to be made on the first read, got %d requests", n)
+			}
+			chunks, err := readChunks(t, s)
+			if err := s.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+			if !slices.Equal(chunks, tt.chunks) {
+				t.Errorf("expected chunks %q, got %q", tt.chunks, chunks)
+			}
+			if tt.kind == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if tt.kind != "" && base.Classify(err) != tt.kind {
+				t.Errorf("expected a %q error, got %v", tt.kind, err)
+			}
+			if n := requests.Load(); n != tt.requests {
+				t.Errorf("expected %d requests, got %d", tt.requests, n)
+			}
+			var delays []time.Duration
+			for i, e := range *events {
+				delays = append(delays, e.Delay)
+				if e.Attempt != i+2 || e.MaxAttempts != tt.conf.MaxAttempts || e.Err == nil {
+					t.Errorf("unexpected event %d: %+v", i, e)
+				}
+			}
+			if !slices.Equal(delays, tt.delays) {
+				t.Errorf("expected delays %v, got %v", tt.delays, delays)
+			}
+		})
+	}
+}
+
+func TestRetryCompletion(t *testing.T) {
+	backend, requests := failureServer(t, status(429, "1"), completion("hello"))
+	conf := testRetryConfig
+	conf.MaxDelay = time.Second
+	r, events := newTestRetry(backend, conf)
+
+	answer, err := r.CreateChatCompletion(context.Background(), "gpt-4o", Params{}, []*db.Message{{Role: "user", Content: "hi"}})
+	if err != nil || answer != "hello" {
+		t.Fatalf("expected the answer of the retry, got %q, %v", answer, err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("expected 2 requests, got %d", n)
+	}
+	if len(*events) != 1 || (*events)[0].Delay != time.Second {
+		t.Errorf("expected a retry after the Retry-After of the server, got %+v", *events)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	boom := &base.StatusError{StatusCode: 503, Err: errors.New("unavailable")}
+	m := NewMock()
+	m.Script(Response{Err: boom}, Response{Chunks: []string{"a"}}, Response{Err: boom}, Response{Err: boom}, Response{Chunks: []string{"b"}})
+	r, events := newTestRetry(m, RetryConfig{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Budget: 2})
+	ctx := context.Background()
+
+	// the first retry is given back by the success
+	if answer, err := r.CreateChatCompletion(ctx, "mock", Params{}, nil); err != nil || answer != "a" {
+		t.Fatalf("expected the first answer, got %q, %v", answer, err)
+	}
+	if answer, err := r.CreateChatCompletion(ctx, "mock", Params{}, nil); err != nil || answer != "b" {
+		t.Fatalf("expected the second answer, got %q, %v", answer, err)
+	}
+	if len(*events) != 3 {
+		t.Errorf("expected 3 retries, got %d", len(*events))
+	}
+
+	m.Script(Response{Err: boom}, Response{Err: boom}, Response{Err: boom})
+	if _, err := r.CreateChatCompletion(ctx, "mock", Params{}, nil); !errors.Is(err, boom) {
+		t.Errorf("expected the error once the budget is used up, got %v", err)
+	}
+	if len(*events) != 4 {
+		t.Errorf("expected a single retry with the budget left, got %d", len(*events)-3)
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	m := NewMock()
+	m.Script(Response{Err: &base.StatusError{StatusCode: 503, Err: errors.New("unavailable")}})
+	r, _ := newTestRetry(m, RetryConfig{MaxAttempts: 4, BaseDelay: time.Hour, MaxDelay: time.Hour, Budget: 10})
+	ctx, cancel := context.WithCancel(context.Background())
+	r.OnRetry(func(RetryEvent) { cancel() })
+
+	if _, err := r.CreateChatCompletion(ctx, "mock", Params{}, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the wait to end with the context, got %v", err)
+	}
+}
+
+func TestRetryStreamClosed(t *testing.T) {
+	o, requests := failureServer(t, status(http.StatusServiceUnavailable, ""))
+	r, _ := newTestRetry(o, RetryConfig{MaxAttempts: 4, BaseDelay: time.Hour, MaxDelay: time.Hour, Budget: 10})
+	s, err := r.CreateChatCompletionStream(context.Background(), "gpt-4o", Params{}, []*db.Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// closing ends the wait for the retry and no request is made after
+	r.OnRetry(func(RetryEvent) { s.Close() })
+	if _, err := s.Read(make([]byte, 64)); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the wait to end with the stream, got %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("expected a single request, got %d", n)
+	}
+}
+
+func TestRetryStreamSnapshot(t *testing.T) {
+	o, _ := failureServer(t, stream(true, helloChunk))
+	r, _ := newTestRetry(o, testRetryConfig)
+	s, err := r.CreateChatCompletionStream(context.Background(), "gpt-4o", Params{}, []*db.Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// the settings change on the ui goroutine while the stream is read
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = o.SetBaseURL("http://127.0.0.1:1")
+		o.SetTools([]base.ToolDefinition{{Name: "late"}})
+	}()
+	content, err := io.ReadAll(s)
+	<-done
+	if err != nil || string(content) != "hello" {
+		t.Errorf("expected the answer with the settings of the stream, got %q, %v", content, err)
+	}
+}
@@ -4,62 +4,66 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/aavshr/panda/internal/config"
 	"github.com/aavshr/panda/internal/db"
+	base "github.com/aavshr/panda/internal/llm"
+	"github.com/aavshr/panda/internal/mcp"
+	"github.com/aavshr/panda/internal/templates"
+	"github.com/aavshr/panda/internal/tools"
 	"github.com/aavshr/panda/internal/ui/components"
+	"github.com/aavshr/panda/internal/ui/keymap"
 	"github.com/aavshr/panda/internal/ui/llm"
 	"github.com/aavshr/panda/internal/ui/store"
 	"github.com/aavshr/panda/internal/ui/styles"
-
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 const (
-	/*
-		| history  |  messages  |
-		|          |            |
-		|          |            |
-		|----------|------------|
-		|  chat input           |
-	*/
-	widthSeparationRatio  = 0.2
-	heightSeparationRatio = 0.1
-	titleMessages         = "Messages"
-	titleHistory          = "History"
-	timeFormat            = "2006-01-02 15:04:05"
-	newThreadName         = "New"
-	roleUser              = "user"
-	roleAssistant         = "assistant"
-	roleSystem            = "system"
+	titleMessages = "Messages"
+	titleHistory  = "History"
+	timeFormat    = "2006-01-02 15:04:05"
+	newThreadName = "New"
+	roleUser      = "user"
+	roleAssistant = "assistant"
+	roleSystem    = "system"
+	roleTool      = "tool"
+	// maxToolRounds is how often the model can call tools for a message
+	maxToolRounds = 10
+	// maxToolCallLen shortens the arguments of a tool call when it is shown
+	maxToolCallLen = 200
 )
 
 type Config struct {
 	InitThreadsLimit int
 	MaxThreadsLimit  int
 	MessagesLimit    int
-	Width            int
-	Height           int
-	LLMModel         string
-
-	historyWidth    int
-	historyHeight   int
-	messagesWidth   int
-	messagesHeight  int
-	chatInputWidth  int
-	chatInputHeight int
+	// Width and Height are the size of the terminal until it reports its
+	// size, a default is used if they are not set
+	Width    int
+	Height   int
+	LLMModel string
 }
 
 type Model struct {
 	conf         *Config
 	userConfig   *config.Config
 	showSettings bool
+	showParams   bool
+	showModels   bool
+	// apiKey is the resolved key of the user config
+	apiKey string
 
 	messagesModel  components.ChatModel
 	historyModel   components.ListModel
 	chatInputModel components.ChatInputModel
 	settingsModel  components.SettingsModel
+	paramsModel    components.ParamsModel
+	modelPicker    components.ModelPickerModel
+	modelCache     *base.ModelCache
 
 	threads           []*db.Thread
 	threadsOffset     int
@@ -68,6 +72,46 @@ type Model struct {
 	messages        []*db.Message
 	messagesOffset  int
 	activeLLMStream io.ReadCloser
+	// streamThreadID and streamMessage are the thread and the answer the
+	// stream writes to, even if another thread is shown by then
+	streamThreadID string
+	streamMessage  *db.Message
+
+	tools *tools.Registry
+	// pendingToolCalls wait for confirmation, the first one is shown, their
+	// results go to the thread of toolThreadID
+	pendingToolCalls []base.ToolCall
+	toolThreadID     string
+	// commands are the slash commands of the chat input
+	commands []slashCommand
+	// layout is the size of the sections for the size of the terminal
+	layout  layout
+	keys    *keymap.KeyMap
+	keyHelp components.KeyHelpModel
+	// themes are the user themes, the built-in ones are always available
+	themes []styles.Theme
+	// showKeyHelp shows the key bindings generated from the keymap
+	showKeyHelp bool
+	// actions are listed in the command palette
+	actions     []action
+	palette     components.PaletteModel
+	showPalette bool
+	// statusBar shows errors and notices as toasts below the main view
+	statusBar components.StatusBarModel
+	// toolRounds counts the completions with tool results since the last
+	// message of the user
+	toolRounds int
+	// mcpClients are the connected mcp servers, their tools are kept to
+	// rebuild the registry when the settings change
+	mcpClients []*mcp.Client
+	mcpTools   []*tools.Tool
+	// mcpStarted is set once the servers are connected to, Init runs
+	// again whenever a dialog closes
+	mcpStarted bool
+
+	templates *templates.Store
+	// pendingTemplate is expanded with the answers to its variables
+	pendingTemplate *templates.Template
 
 	componentsToContainer map[components.Component]lipgloss.Style
 	focusedComponent      components.Component
@@ -75,27 +119,31 @@ type Model struct {
 
 	store store.Store
 	llm   llm.LLM
-
-	errorState error
+	// now is the clock of the timestamps, fixed in tests
+	now func() time.Time
 }
 
 func New(conf *Config, store store.Store, llm llm.LLM) (*Model, error) {
-	if conf.Width == 0 || conf.Height == 0 {
-		return nil, fmt.Errorf("invalid config: width and height must be greater than 0")
+	width, height := conf.Width, conf.Height
+	if width <= 0 || height <= 0 {
+		width, height = defaultWidth, defaultHeight
 	}
-	conf.historyWidth = int(float64(conf.Width) * widthSeparationRatio)
-	conf.historyHeight = conf.Height - int(float64(conf.Height)*heightSeparationRatio)
-	conf.messagesWidth = conf.Width - conf.historyWidth
-	conf.messagesHeight = conf.historyHeight
-	conf.chatInputWidth = conf.Width + 2 // to account for border and padding
-	conf.chatInputHeight = conf.Height - conf.historyHeight
+	l := computeLayout(width, height)
 
 	m := &Model{
 		conf:  conf,
 		store: store,
 		llm:   llm,
+		now:   time.Now,
 	}
+	m.tools = newToolRegistry(nil, nil)
+	m.templates = templates.NewStore(config.GetTemplatesDir())
 	m.settingsModel = components.NewSettingsModel()
+	m.paramsModel = components.NewParamsModel()
+	m.modelPicker = components.NewModelPickerModel()
+	keys, keyMapErr := keymap.Load(config.GetKeyMapPath())
+	m.keys = keys
+	m.modelCache = base.NewModelCache(config.GetModelsCachePath(), base.DefaultModelCacheTTL)
 	userConfig, err := config.Load()
 	if err != nil {
 		if !errors.Is(err, config.ErrConfigNotFound) {
@@ -104,9 +152,14 @@ func New(conf *Config, store store.Store, llm llm.LLM) (*Model, error) {
 		m.showSettings = true
 	} else {
 		m.userConfig = userConfig
-		if err := m.llm.SetAPIKey(m.userConfig.LLMAPIKey); err != nil {
-			return m, fmt.Errorf("llm.SetAPIKey %w", err)
+		apiKey, err := m.userConfig.APIKey()
+		if err != nil {
+			return m, fmt.Errorf("userConfig.APIKey %w", err)
+		}
+		if err := m.applyLLMSettings(apiKey, m.userConfig.LLMBaseURL); err != nil {
+			return m, err
 		}
+		m.apiKey = apiKey
 	}
 
 	m.activeThreadIndex = 0
@@ -126,31 +179,46 @@ func New(conf *Config, store store.Store, llm llm.LLM) (*Model, error) {
 	m.historyModel = components.NewListModel(&components.NewListModelInput{
 		Title:                  titleHistory,
 		Items:                  components.NewThreadListItems(m.threads),
-		Width:                  conf.historyWidth,
-		Height:                 conf.historyHeight,
+		Width:                  l.history.width,
+		Height:                 l.history.height,
 		Delegate:               components.NewThreadListItemDelegate(),
 		AllowInfiniteScrolling: false,
 	})
 	m.setThreads(append(m.threads, threads...))
 	m.historyModel.Select(0) // New Thread is selected by default
-	m.messagesModel = components.NewChatModel(conf.messagesWidth, conf.messagesHeight)
-	m.chatInputModel = components.NewChatInputModel(conf.chatInputWidth, conf.chatInputHeight)
+	m.messagesModel = components.NewChatModel(l.messages.width, l.messages.height)
+	m.chatInputModel = components.NewChatInputModel(l.chatInput.width, l.chatInput.height)
+	m.commands = newSlashCommands()
+	m.chatInputModel.SetCommands(chatInputCommands(m.commands))
+	m.actions = newActions(m.keys, m.commands)
+	m.palette = components.NewPaletteModel()
+	m.statusBar = components.NewStatusBarModel()
+	m.keyHelp = components.NewKeyHelpModel()
+	m.keyHelp.SetKeyMap(m.keys)
+	m.messagesModel.SetKeyMap(m.keys)
+	m.chatInputModel.SetKeyMap(m.keys)
+	m.historyModel.SetKeyMap(m.keys)
+	themeErr := m.loadThemes()
+	if err := m.setTheme(m.themeName()); err != nil {
+		themeErr = errors.Join(themeErr, err)
+	}
+	// the keymap and themes are not fatal, problems are shown until the
+	// next key
+	if keyMapErr != nil {
+		m.chatInputModel.SetError(fmt.Errorf("keymap: %w", keyMapErr))
+	} else if conflicts := m.keys.Conflicts(); len(conflicts) > 0 {
+		m.chatInputModel.SetError(fmt.Errorf("keymap conflict: %s, see the key bindings with %s", conflicts[0], m.keys.Help.Help().Key))
+	} else if themeErr != nil {
+		m.chatInputModel.SetError(fmt.Errorf("theme: %w", themeErr))
+	}
 
 	listContainer := styles.ListContainerStyle()
-	historyContainer := listContainer.Copy().
-		Width(m.conf.historyWidth).
-		Height(m.conf.historyHeight)
-	messagesContainer := listContainer.Copy().
-		Width(m.conf.messagesWidth).
-		Height(m.conf.messagesHeight)
-	chatInputContainer := styles.ContainerStyle().
-		Width(m.conf.chatInputWidth).
-		Height(m.conf.chatInputHeight)
 	m.componentsToContainer = map[components.Component]lipgloss.Style{
-		components.ComponentHistory:   historyContainer,
-		components.ComponentMessages:  messagesContainer,
-		components.ComponentChatInput: chatInputContainer,
+		components.ComponentHistory:   listContainer.Copy(),
+		components.ComponentMessages:  listContainer.Copy(),
+		components.ComponentChatInput: styles.ContainerStyle(),
 	}
+	m.setLayout(l)
 	return m, nil
 }
 
@@ -164,17 +232,44 @@ func (m *Model) setMessages(messages []*db.Message) {
 	// TODO: a more efficient way to do this?
 	m.messagesModel.ResetMessages()
 	for _, message := range messages {
-		isUser := message.Role == roleUser
-		m.messagesModel.AddMessage(
-			components.Message{
-				Content:   message.Content,
-				CreatedAt: message.CreatedAt,
-				IsUser:    isUser,
-			},
-		)
+		m.messagesModel.AddMessage(toComponentMessage(message))
 	}
 }
 
+func toComponentMessage(message *db.Message) components.Message {
+	var attachments []string
+	for _, attachment := range message.Attachments {
+		attachments = append(attachments, attachment.Path)
+	}
+	var images []string
+	for _, part := range message.Parts {
+		if part.Type == db.PartTypeImage {
+			images = append(images, part.Path)
+		}
+	}
+	// calls that can not be parsed are still sent as they are stored
+	calls, _ := base.ParseToolCalls(message.ToolCalls)
+	var toolCalls []string
+	for _, call := range calls {
+		toolCalls = append(toolCalls, formatToolCall(call))
+	}
+	return components.Message{
+		Content:     message.Content,
+		CreatedAt:   message.CreatedAt,
+		IsUser:      message.Role == roleUser,
+		IsTool:      message.Role == roleTool,
+		IsSystem:    message.Role == roleSystem,
+		Model:       message.Model,
+		Attachments: attachments,
+		Images:      images,
+		ToolCalls:   toolCalls,
+	}
+}
+
+func formatToolCall(call base.ToolCall) string {
+	return tools.Truncate(call.Name+" "+call.Arguments, maxToolCallLen)
+}
+
 func (m *Model) setActiveThreadIndex(index int) {
 	m.activeThreadIndex = index
 	m.historyModel.Select(index)
@@ -187,19 +282,43 @@ func (m *Model) Init() tea.Cmd {
 		return m.settingsModel.Focus()
 	}
 	m.settingsModel.Blur()
+	m.paramsModel.Blur()
+	m.modelPicker.Blur()
+	m.palette.Blur()
 	m.focusedComponent = components.ComponentChatInput
 	m.selectedComponent = components.ComponentChatInput
 	return tea.Batch(
 		m.chatInputModel.Focus(),
+		m.cmdConnectMCP(),
 	)
 }
 
-func (m *Model) View() string {
-	if m.errorState != nil {
-		return fmt.Sprintf("Error: %v", m.errorState)
+// Close stops the mcp servers, it is called after the program exited
+func (m *Model) Close() {
+	for _, client := range m.mcpClients {
+		_ = client.Close()
 	}
+	m.mcpClients = nil
+}
+
+func (m *Model) View() string {
 	if m.showSettings {
-		return m.settingsModel.View()
+		return styles.SettingsContainerStyle().Render(m.settingsModel.View())
+	}
+	if m.showParams {
+		return styles.SettingsContainerStyle().Render(m.paramsModel.View())
+	}
+	if m.showModels {
+		return styles.SettingsContainerStyle().Render(m.modelPicker.View())
+	}
+	if m.showPalette {
+		return styles.SettingsContainerStyle().Render(m.palette.View())
+	}
+	if m.showKeyHelp {
+		return styles.SettingsContainerStyle().Render(m.keyHelp.View())
+	}
+	if m.layout.tooSmall {
+		return m.layout.tooSmallView()
 	}
 
 	mainContainer := styles.MainContainerStyle()
@@ -212,28 +331,51 @@ func (m *Model) View() string {
 		styles.SetFocusedBorder(&container)
 	}
 
-	return mainContainer.Render(
-		lipgloss.JoinVertical(lipgloss.Left,
-			lipgloss.JoinHorizontal(
-				lipgloss.Top,
-				m.componentsToContainer[components.ComponentHistory].Render(m.historyModel.View()),
-				m.componentsToContainer[components.ComponentMessages].Render(
-					m.messagesModel.View(),
+	panes := []string{m.componentsToContainer[components.ComponentMessages].Render(m.messagesModel.View())}
+	if m.layout.showHistory {
+		panes = append([]string{m.componentsToContainer[components.ComponentHistory].Render(m.historyModel.View())}, panes...)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		mainContainer.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				lipgloss.JoinHorizontal(lipgloss.Top, panes...),
+				lipgloss.JoinVertical(
+					lipgloss.Left,
+					m.componentsToContainer[components.ComponentChatInput].Render(m.chatInputModel.View()),
 				),
 			),
-			lipgloss.JoinVertical(
-				lipgloss.Left,
-				m.componentsToContainer[components.ComponentChatInput].Render(m.chatInputModel.View()),
-			),
 		),
+		m.statusBar.View(m.status()),
 	)
 }
 
 func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if sizeMsg, ok := msg.(tea.WindowSizeMsg); ok {
+		m.setLayout(computeLayout(sizeMsg.Width, sizeMsg.Height))
+		return m, nil
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.inMainView() {
+		switch {
+		case key.Matches(keyMsg, m.keys.Quit):
+			return m, tea.Quit
+		case key.Matches(keyMsg, m.keys.Palette):
+			return m, m.openPalette()
+		case key.Matches(keyMsg, m.keys.Retry):
+			return m, m.retry()
+		}
+	}
 	var cmd tea.Cmd
 	switch m.focusedComponent {
 	case components.ComponentSettings:
 		m.settingsModel, cmd = m.settingsModel.Update(msg)
+	case components.ComponentParams:
+		m.paramsModel, cmd = m.paramsModel.Update(msg)
+	case components.ComponentModelPicker:
+		m.modelPicker, cmd = m.modelPicker.Update(msg)
+	case components.ComponentPalette:
+		m.palette, cmd = m.palette.Update(msg)
+	case components.ComponentKeyHelp:
+		m.keyHelp, cmd = m.keyHelp.Update(msg)
 	case components.ComponentHistory:
 		m.historyModel, cmd = m.historyModel.Update(msg)
 	case components.ComponentMessages:
@@ -250,8 +392,32 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case components.SettingsSubmitMsg:
 		cmd = m.handleSettingsSubmitMsg(msg)
+	case components.SettingsTestMsg:
+		cmd = m.handleSettingsTestMsg(msg)
+	case components.SettingsCloseMsg:
+		cmd = m.handleSettingsCloseMsg()
+	case components.ParamsSubmitMsg:
+		cmd = m.handleParamsSubmitMsg(msg)
+	case components.ParamsCloseMsg:
+		cmd = m.handleParamsCloseMsg()
+	case components.ModelPickerSelectMsg:
+		cmd = m.handleModelPickerSelectMsg(msg)
+	case components.ModelPickerRefreshMsg:
+		cmd = m.cmdLoadModels()
+	case components.ModelPickerCloseMsg:
+		cmd = m.handleModelPickerCloseMsg()
+	case components.PaletteRunMsg:
+		cmd = m.handlePaletteRunMsg(msg)
+	case components.PaletteCloseMsg:
+		cmd = m.handlePaletteCloseMsg()
+	case components.KeyHelpCloseMsg:
+		cmd = m.handleKeyHelpCloseMsg()
 	case components.ChatInputReturnMsg:
 		cmd = m.handleChatInputReturnMsg(msg)
+	case components.ChatInputCommandMsg:
+		cmd = m.handleChatInputCommandMsg(msg)
+	case components.ChatInputAnswersMsg:
+		m.handleChatInputAnswersMsg(msg)
 	case components.EscapeMsg:
 		m.handleEscapeMsg()
 	case components.ListEnterMsg:
@@ -260,10 +426,30 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmd = m.handleListSelectMsg(msg)
 	case components.ListDeleteMsg:
 		cmd = m.handleListDeleteMsg(msg)
-	case ForwardChatCompletionStreamMsg:
-		cmd = m.handleForwardChatCompletionStreamMsg(msg)
+	case components.ListExportMsg:
+		cmd = m.handleListExportMsg(msg)
+	case components.CodeBlockCopyMsg:
+		cmd = m.handleCodeBlockCopyMsg(msg)
+	case components.CodeBlockWriteMsg:
+		cmd = m.handleCodeBlockWriteMsg(msg)
+	case ChatCompletionChunkMsg:
+		cmd = m.handleChatCompletionChunkMsg(msg)
+	case RetryMsg:
+		cmd = m.handleRetryMsg(msg)
+	case components.ToolConfirmMsg:
+		cmd = m.handleToolConfirmMsg(msg)
+	case ToolResultMsg:
+		cmd = m.handleToolResultMsg(msg)
+	case MCPConnectedMsg:
+		cmd = m.handleMCPConnectedMsg(msg)
+	case MCPPromptsMsg:
+		m.handleMCPPromptsMsg(msg)
+	case MCPPromptMsg:
+		m.handleMCPPromptMsg(msg)
+	case components.StatusBarExpireMsg:
+		m.statusBar, cmd = m.statusBar.Update(msg)
 	case error:
-		m.errorState = msg
+		cmd = m.notifyError(msg)
 	}
 	return m, cmd
 }
@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	base "github.com/aavshr/panda/internal/llm"
+	"github.com/aavshr/panda/internal/ui/components"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// status is shown in the status bar while there is no toast
+func (m *Model) status() string {
+	var parts []string
+	if m.userConfig != nil && m.activeThreadIndex < len(m.threads) {
+		parts = append(parts, m.threadModel(m.threads[m.activeThreadIndex]))
+	}
+	if m.activeLLMStream != nil {
+		parts = append(parts, "answering...")
+	}
+	parts = append(parts, m.keys.Palette.Help().Key+" commands", m.keys.Help.Help().Key+" keys")
+	return strings.Join(parts, "  ")
+}
+
+// retryKey returns how a failed answer is asked for again
+func (m *Model) retryKey() string {
+	if m.keys.Retry.Enabled() {
+		return m.keys.Retry.Help().Key
+	}
+	return "/retry"
+}
+
+// describeError says what went wrong, errors of the backend say what can
+// be done about them
+func (m *Model) describeError(err error) string {
+	switch kind := base.Classify(err); kind {
+	case base.ErrorKindAuth:
+		return fmt.Sprintf("%s, check the API key in the settings (%s): %v", kind, m.keys.Settings.Help().Key, err)
+	case base.ErrorKindRateLimit:
+		return fmt.Sprintf("%s, wait a moment and retry with %s", kind, m.retryKey())
+	case base.ErrorKindNetwork:
+		return fmt.Sprintf("%s, retry with %s: %v", kind, m.retryKey(), err)
+	case base.ErrorKindContextLength:
+		return fmt.Sprintf("%s for the model, start a new thread or pick another model with %s", kind, m.keys.Model.Help().Key)
+	}
+	return fmt.Sprintf("error: %v", err)
+}
+
+// notifyError shows the error as a toast, the session goes on
+func (m *Model) notifyError(err error) tea.Cmd {
+	return m.statusBar.Notify(m.describeError(err), components.ToastError)
+}
+
+// handleRetryMsg shows that the answer is asked for again after an error
+func (m *Model) handleRetryMsg(msg RetryMsg) tea.Cmd {
+	text := fmt.Sprintf("%s, retrying in %s (attempt %d of %d)",
+		base.Classify(msg.Err), msg.Delay.Round(100*time.Millisecond), msg.Attempt, msg.MaxAttempts)
+	return m.statusBar.Notify(text, components.ToastInfo)
+}
+
+// failCompletion stops the answer after an error, the unfinished answer is
+// dropped and the message of the user stays in the thread to be retried
+func (m *Model) failCompletion(err error) tea.Cmd {
+	if m.activeLLMStream != nil {
+		_ = m.activeLLMStream.Close()
+		m.activeLLMStream = nil
+	}
+	// the answer is only stored once the stream is done
+	if index := m.messageIndex(m.streamMessage); index >= 0 {
+		m.setMessages(slices.Delete(m.messages, index, index+1))
+	}
+	m.streamMessage = nil
+	text := m.describeError(err)
+	if base.Classify(err) == base.ErrorKindUnknown {
+		text += ", retry with " + m.retryKey()
+	}
+	return m.statusBar.Notify(text, components.ToastError)
+}
+
+// retry asks again for the answer to the last message of the user
+func (m *Model) retry() tea.Cmd {
+	m.statusBar.Clear()
+	return m.commandRetry(components.ChatInputCommandMsg{Name: "retry"})
+}
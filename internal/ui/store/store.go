@@ -1,83 +1,299 @@
 package store
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
 	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/store"
+	"github.com/aavshr/panda/internal/utils"
 )
 
-type Store interface {
-	ListLatestThreadsPaginated(offset, limit int) ([]*db.Thread, error)
-	ListMessagesByThreadIDPaginated(threadID string, offset, limit int) ([]*db.Message, error)
-	UpsertThread(thread *db.Thread) error
-	DeleteThread(threadID string) error
-	DeleteAllThreads() error
-	CreateMessage(message *db.Message) error
-}
+// Store is the store of the ui
+type Store = store.Store
 
+// Mock is an in-memory Store that behaves like db.Store, it keeps copies
+// so that changes to the returned threads and messages are not stored
 type Mock struct {
+	mu       sync.Mutex
 	threads  []*db.Thread
 	messages map[string][]*db.Message
 }
 
+// NewMock returns a Mock with the threads and the messages of those
+// threads, later duplicates of a thread or message are dropped
 func NewMock(threads []*db.Thread, messages []*db.Message) *Mock {
-	msgs := make(map[string][]*db.Message)
+	m := &Mock{messages: make(map[string][]*db.Message)}
+	for _, thread := range threads {
+		if m.thread(thread.ID) == nil {
+			t := *thread
+			m.threads = append(m.threads, &t)
+		}
+	}
 	for _, message := range messages {
-		msgs[message.ThreadID] = append(msgs[message.ThreadID], message)
+		if m.thread(message.ThreadID) != nil && m.message(message.ID) == nil {
+			msg := *message
+			m.messages[message.ThreadID] = append(m.messages[message.ThreadID], &msg)
+		}
+	}
+	return m
+}
+
+// thread returns the stored thread or nil
+func (m *Mock) thread(threadID string) *db.Thread {
+	for _, thread := range m.threads {
+		if thread.ID == threadID {
+			return thread
+		}
+	}
+	return nil
+}
+
+// message returns the stored message or nil
+func (m *Mock) message(messageID string) *db.Message {
+	for _, msgs := range m.messages {
+		for _, message := range msgs {
+			if message.ID == messageID {
+				return message
+			}
+		}
+	}
+	return nil
+}
+
+// latestThreads returns the threads newest first
+func (m *Mock) latestThreads() []*db.Thread {
+	threads := slices.Clone(m.threads)
+	slices.SortStableFunc(threads, func(a, b *db.Thread) int {
+		return strings.Compare(b.CreatedAt, a.CreatedAt)
+	})
+	return threads
+}
+
+// paginate returns copies of the items in the page, a negative limit
+// returns all items after the offset like in sqlite
+func paginate[T any](items []*T, offset, limit int) []*T {
+	offset = min(max(offset, 0), len(items))
+	items = items[offset:]
+	if limit >= 0 && limit < len(items) {
+		items = items[:limit]
 	}
-	return &Mock{
-		threads:  threads,
-		messages: msgs,
+	page := make([]*T, len(items))
+	for i, item := range items {
+		c := *item
+		page[i] = &c
 	}
+	return page
 }
 
 func (m *Mock) ListLatestThreadsPaginated(offset, limit int) ([]*db.Thread, error) {
-	return m.threads, nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return paginate(m.latestThreads(), offset, limit), nil
 }
 
+// ListMessagesByThreadIDPaginated returns the messages oldest first,
+// messages of the same second in the order they were stored
 func (m *Mock) ListMessagesByThreadIDPaginated(threadID string, offset, limit int) ([]*db.Message, error) {
-	messages, _ := m.messages[threadID]
-	return messages, nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	messages := slices.Clone(m.messages[threadID])
+	slices.SortStableFunc(messages, func(a, b *db.Message) int {
+		return strings.Compare(a.CreatedAt, b.CreatedAt)
+	})
+	return paginate(messages, offset, limit), nil
 }
 
-func (m *Mock) UpsertThread(thread *db.Thread) error {
-	for i, t := range m.threads {
-		if t.ID == thread.ID {
-			m.threads[i] = thread
-			return nil
+func (m *Mock) GetThread(threadID string) (*db.Thread, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	thread := m.thread(threadID)
+	if thread == nil {
+		return nil, db.ErrThreadNotFound
+	}
+	t := *thread
+	return &t, nil
+}
+
+// words splits s into lower case words like the fts5 tokenizer
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// matches is a small part of the fts5 query syntax, a quoted term matches
+// the words in order and otherwise all words have to be in the text
+func matches(text, term string) bool {
+	term = strings.TrimSpace(term)
+	phrase := len(term) >= 2 && strings.HasPrefix(term, `"`) && strings.HasSuffix(term, `"`)
+	if phrase {
+		term = strings.ReplaceAll(term[1:len(term)-1], `""`, `"`)
+	}
+	want, have := words(term), words(text)
+	if len(want) == 0 {
+		return false
+	}
+	if phrase {
+		for i := 0; i+len(want) <= len(have); i++ {
+			if slices.Equal(have[i:i+len(want)], want) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, w := range want {
+		if !slices.Contains(have, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchThreadNamesPaginated returns the matching threads newest first
+func (m *Mock) SearchThreadNamesPaginated(term string, offset, limit int) ([]*db.Thread, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var threads []*db.Thread
+	for _, thread := range m.latestThreads() {
+		if matches(thread.Name, term) {
+			threads = append(threads, thread)
 		}
 	}
-	m.threads = append(m.threads, thread)
-	m.messages[thread.ID] = []*db.Message{}
+	return paginate(threads, offset, limit), nil
+}
+
+// SearchMessageContentPaginated returns the matching messages of the
+// newest threads first and oldest first within a thread
+func (m *Mock) SearchMessageContentPaginated(term string, offset, limit int) ([]*db.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var messages []*db.Message
+	for _, thread := range m.latestThreads() {
+		msgs := slices.Clone(m.messages[thread.ID])
+		slices.SortStableFunc(msgs, func(a, b *db.Message) int {
+			return strings.Compare(a.CreatedAt, b.CreatedAt)
+		})
+		for _, message := range msgs {
+			if matches(message.Content, term) {
+				messages = append(messages, message)
+			}
+		}
+	}
+	return paginate(messages, offset, limit), nil
+}
+
+// UpsertThread keeps the params and model of an existing thread like
+// db.Store
+func (m *Mock) UpsertThread(thread *db.Thread) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t := m.thread(thread.ID); t != nil {
+		t.Name = thread.Name
+		t.UpdatedAt = thread.UpdatedAt
+		t.Metadata = thread.Metadata
+		return nil
+	}
+	t := *thread
+	m.threads = append(m.threads, &t)
 	return nil
 }
 
 func (m *Mock) UpdateThreadName(threadID, name string) error {
-	for _, thread := range m.threads {
-		if thread.ID == threadID {
-			thread.Name = name
-			return nil
-		}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	thread := m.thread(threadID)
+	if thread == nil {
+		return db.ErrThreadNotFound
 	}
+	thread.Name = name
 	return nil
 }
 
-func (m *Mock) DeleteThread(threadID string) error {
-	for i, thread := range m.threads {
-		if thread.ID == threadID {
-			m.threads = append(m.threads[:i], m.threads[i+1:]...)
-			return nil
-		}
+func (m *Mock) UpdateThreadParams(threadID, params string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	thread := m.thread(threadID)
+	if thread == nil {
+		return db.ErrThreadNotFound
+	}
+	thread.Params = params
+	return nil
+}
+
+func (m *Mock) UpdateThreadModel(threadID, model string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	thread := m.thread(threadID)
+	if thread == nil {
+		return db.ErrThreadNotFound
 	}
+	thread.Model = model
+	return nil
+}
+
+// DeleteThread deletes the thread with its messages
+func (m *Mock) DeleteThread(threadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.threads = slices.DeleteFunc(m.threads, func(thread *db.Thread) bool {
+		return thread.ID == threadID
+	})
+	delete(m.messages, threadID)
 	return nil
 }
 
 func (m *Mock) DeleteAllThreads() error {
-	m.threads = []*db.Thread{}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.threads = nil
+	m.messages = make(map[string][]*db.Message)
 	return nil
 }
 
+// CreateMessage stores a copy of the message, an empty ID is generated
+// and the thread is marked as updated like in db.Store
 func (m *Mock) CreateMessage(message *db.Message) error {
-	if msgs, ok := m.messages[message.ThreadID]; ok {
-		m.messages[message.ThreadID] = append(msgs, message)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if message.ID == "" {
+		messageID, err := utils.RandomID()
+		if err != nil {
+			return fmt.Errorf("could not generate random id, utils.RandomID: %w", err)
+		}
+		message.ID = messageID
+	}
+	thread := m.thread(message.ThreadID)
+	if thread == nil {
+		return db.ErrThreadNotFound
 	}
+	if m.message(message.ID) != nil {
+		return db.ErrMessageExists
+	}
+	msg := *message
+	m.messages[message.ThreadID] = append(m.messages[message.ThreadID], &msg)
+	thread.UpdatedAt = time.Now().UTC().Format(time.DateTime)
+	return nil
+}
+
+func (m *Mock) DeleteMessages(messageIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for threadID, msgs := range m.messages {
+		m.messages[threadID] = slices.DeleteFunc(msgs, func(message *db.Message) bool {
+			return slices.Contains(messageIDs, message.ID)
+		})
+	}
+	return nil
+}
+
+func (m *Mock) DeleteMessagesByThreadID(threadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.messages, threadID)
 	return nil
 }
@@ -0,0 +1,37 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/store/storetest"
+	"github.com/aavshr/panda/internal/ui/store"
+)
+
+func TestMockConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.NewMock(nil, nil)
+	})
+}
+
+func TestNewMock(t *testing.T) {
+	threads := []*db.Thread{
+		{ID: "1", Name: "first", CreatedAt: "2024-01-01"},
+		{ID: "1", Name: "duplicate", CreatedAt: "2024-01-02"},
+	}
+	messages := []*db.Message{
+		{ID: "1", ThreadID: "1", Content: "kept"},
+		{ID: "1", ThreadID: "1", Content: "duplicate"},
+		{ID: "2", ThreadID: "unknown", Content: "dropped"},
+	}
+	m := store.NewMock(threads, messages)
+
+	latest, _ := m.ListLatestThreadsPaginated(0, 10)
+	if len(latest) != 1 || latest[0].Name != "first" {
+		t.Errorf("expected the first thread once, got %+v", latest)
+	}
+	stored, _ := m.ListMessagesByThreadIDPaginated("1", 0, 10)
+	if len(stored) != 1 || stored[0].Content != "kept" {
+		t.Errorf("expected the first message once, got %+v", stored)
+	}
+}
@@ -4,18 +4,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
-const (
-	TitleColor             = lipgloss.Color("#eb5e55")
-	TitleSecondaryColor    = lipgloss.Color("#f9c784")
-	ActiveContainerColor   = lipgloss.Color("#f9c784")
-	ListItemColor          = lipgloss.Color("#c6d8d3")
-	ListItemSecondaryColor = lipgloss.Color("#fdf0d5")
-	DescriptionColor       = lipgloss.Color("#d81e5b")
-	UserMessageColor       = lipgloss.Color("#5fafaf")
-	AIMessageColor         = lipgloss.Color("#00afff")
-	MetadataColor          = lipgloss.Color("#626262")
-	messagesLeftPadding    = 2
-)
+const messagesLeftPadding = 2
 
 func MainContainerStyle() lipgloss.Style {
 	s := lipgloss.NewStyle().
@@ -45,11 +34,11 @@ func SetNormalBorder(s *lipgloss.Style) {
 }
 
 func SetSelectedBorder(s *lipgloss.Style) {
-	s.BorderForeground(ActiveContainerColor)
+	s.BorderForeground(active.ActiveContainer)
 }
 
 func SetFocusedBorder(s *lipgloss.Style) {
-	s.BorderForeground(TitleColor)
+	s.BorderForeground(active.Title)
 }
 
 func SidebarContainerStyle(width int) lipgloss.Style {
@@ -67,26 +56,26 @@ func MessagesContainerStyle(width int) lipgloss.Style {
 func DefaultListStyle() lipgloss.Style {
 	s := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(TitleSecondaryColor)
+		Foreground(active.TitleSecondary)
 	return s
 }
 
 func DefaultListSelectedStyle() lipgloss.Style {
 	s := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(TitleColor)
+		Foreground(active.Title)
 	return s
 }
 
 func DefaultListItemStyle() lipgloss.Style {
 	s := lipgloss.NewStyle().
-		Foreground(ListItemColor)
+		Foreground(active.ListItem)
 	return s
 }
 
 func DefaultListItemSecondaryStyle() lipgloss.Style {
 	s := lipgloss.NewStyle().
-		Foreground(ListItemSecondaryColor)
+		Foreground(active.ListItemSecondary)
 	return s
 }
 
@@ -96,19 +85,58 @@ func leftPaddedStyle(padding int) lipgloss.Style {
 
 func UserMessageStyle() lipgloss.Style {
 	s := leftPaddedStyle(messagesLeftPadding).
-		Foreground(UserMessageColor)
+		Foreground(active.UserMessage)
 	return s
 }
 
 func AIMessageStyle() lipgloss.Style {
 	s := leftPaddedStyle(messagesLeftPadding).
-		Foreground(AIMessageColor)
+		Foreground(active.AIMessage)
+	return s
+}
+
+func ToolMessageStyle() lipgloss.Style {
+	s := leftPaddedStyle(messagesLeftPadding).
+		Foreground(active.TitleSecondary)
 	return s
 }
 
 func MetadataStyle() lipgloss.Style {
 	s := leftPaddedStyle(messagesLeftPadding).
-		Foreground(MetadataColor).
+		Foreground(active.Metadata).
 		Italic(true)
 	return s
 }
+
+func CodeBlockLabelStyle() lipgloss.Style {
+	s := lipgloss.NewStyle().
+		Foreground(active.TitleSecondary).
+		Bold(true)
+	return s
+}
+
+func HintStyle() lipgloss.Style {
+	s := lipgloss.NewStyle().
+		Foreground(active.Metadata)
+	return s
+}
+
+func ErrorStyle() lipgloss.Style {
+	s := lipgloss.NewStyle().
+		Foreground(active.Error)
+	return s
+}
+
+func SettingsContainerStyle() lipgloss.Style {
+	s := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder(), true).
+		Padding(1, 2)
+	return s
+}
+
+// SetSelectedListItem colors the title and description of the selected
+// item of a list
+func SetSelectedListItem(title, desc *lipgloss.Style) {
+	*title = title.Foreground(active.Title).BorderForeground(active.Title)
+	*desc = desc.Foreground(active.Description).BorderForeground(active.Title)
+}
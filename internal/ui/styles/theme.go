@@ -0,0 +1,233 @@
+package styles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// ThemeAuto picks the dark or light theme for the terminal background
+	ThemeAuto         = "auto"
+	ThemeDark         = "dark"
+	ThemeLight        = "light"
+	ThemeHighContrast = "high-contrast"
+)
+
+var ErrUnknownTheme = errors.New("unknown theme")
+
+// Theme are the colors the styles are built from, user themes are read
+// from json or toml files with the same keys
+type Theme struct {
+	Name string `json:"name,omitempty" toml:"name"`
+	// Base is the built-in theme the colors that are not set come from,
+	// dark if not set
+	Base string `json:"base,omitempty" toml:"base"`
+
+	Title             lipgloss.Color `json:"title,omitempty" toml:"title"`
+	TitleSecondary    lipgloss.Color `json:"title_secondary,omitempty" toml:"title_secondary"`
+	ActiveContainer   lipgloss.Color `json:"active_container,omitempty" toml:"active_container"`
+	ListItem          lipgloss.Color `json:"list_item,omitempty" toml:"list_item"`
+	ListItemSecondary lipgloss.Color `json:"list_item_secondary,omitempty" toml:"list_item_secondary"`
+	Description       lipgloss.Color `json:"description,omitempty" toml:"description"`
+	UserMessage       lipgloss.Color `json:"user_message,omitempty" toml:"user_message"`
+	AIMessage         lipgloss.Color `json:"ai_message,omitempty" toml:"ai_message"`
+	Metadata          lipgloss.Color `json:"metadata,omitempty" toml:"metadata"`
+	Error             lipgloss.Color `json:"error,omitempty" toml:"error"`
+}
+
+// builtinThemes are the themes that are always available, in the order
+// they are offered
+var builtinThemes = []Theme{
+	{
+		Name:              ThemeDark,
+		Title:             "#eb5e55",
+		TitleSecondary:    "#f9c784",
+		ActiveContainer:   "#f9c784",
+		ListItem:          "#c6d8d3",
+		ListItemSecondary: "#fdf0d5",
+		Description:       "#d81e5b",
+		UserMessage:       "#5fafaf",
+		AIMessage:         "#00afff",
+		Metadata:          "#626262",
+		Error:             "#ff5f5f",
+	},
+	{
+		Name:              ThemeLight,
+		Title:             "#c0392b",
+		TitleSecondary:    "#a35200",
+		ActiveContainer:   "#a35200",
+		ListItem:          "#2f4f4f",
+		ListItemSecondary: "#5c4b37",
+		Description:       "#a3154a",
+		UserMessage:       "#00727a",
+		AIMessage:         "#005f9e",
+		Metadata:          "#767676",
+		Error:             "#c62828",
+	},
+	{
+		// high-contrast sticks to the brightest colors for dark terminals
+		Name:              ThemeHighContrast,
+		Title:             "#ffff00",
+		TitleSecondary:    "#00ffff",
+		ActiveContainer:   "#00ffff",
+		ListItem:          "#ffffff",
+		ListItemSecondary: "#ffffff",
+		Description:       "#ff00ff",
+		UserMessage:       "#00ff00",
+		AIMessage:         "#00ffff",
+		Metadata:          "#c0c0c0",
+		Error:             "#ff0000",
+	},
+}
+
+// active is the theme the styles are built from
+var active = builtinThemes[0]
+
+// Active returns the theme the styles are built from
+func Active() Theme {
+	return active
+}
+
+// SetTheme changes the theme of the styles returned from now on, styles
+// that were already built keep their colors
+func SetTheme(theme Theme) {
+	active = theme
+}
+
+// Builtin returns a built-in theme by name
+func Builtin(name string) (Theme, bool) {
+	for _, theme := range builtinThemes {
+		if theme.Name == name {
+			return theme, true
+		}
+	}
+	return Theme{}, false
+}
+
+// Detect returns the dark or light theme for the background of the
+// terminal, lipgloss asks the terminal once and caches the answer
+func Detect() Theme {
+	if lipgloss.HasDarkBackground() {
+		theme, _ := Builtin(ThemeDark)
+		return theme
+	}
+	theme, _ := Builtin(ThemeLight)
+	return theme
+}
+
+// Find returns the theme with the name, user themes come before the
+// built-in ones and auto or an empty name detect the background
+func Find(name string, themes []Theme) (Theme, error) {
+	if name == "" || name == ThemeAuto {
+		return Detect(), nil
+	}
+	for _, theme := range themes {
+		if theme.Name == name {
+			return theme, nil
+		}
+	}
+	if theme, ok := Builtin(name); ok {
+		return theme, nil
+	}
+	return Theme{}, fmt.Errorf("%w: %s, use one of %s", ErrUnknownTheme, name, strings.Join(Names(themes), ", "))
+}
+
+// Names returns auto, the built-in and the user theme names in the order
+// they are offered
+func Names(themes []Theme) []string {
+	names := []string{ThemeAuto}
+	for _, theme := range builtinThemes {
+		names = append(names, theme.Name)
+	}
+	for _, theme := range themes {
+		if _, ok := Builtin(theme.Name); !ok {
+			names = append(names, theme.Name)
+		}
+	}
+	return names
+}
+
+// LoadThemes reads the .json and .toml themes in dir sorted by name, the
+// file name is the theme name if the file has none. A missing dir has no
+// themes, invalid files are returned as an error with the valid themes.
+func LoadThemes(dir string) ([]Theme, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("os.ReadDir: %w", err)
+	}
+	var themes []Theme
+	var errs []error
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".json" && ext != ".toml") {
+			continue
+		}
+		theme, err := loadTheme(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if theme.Name == "" {
+			theme.Name = strings.TrimSuffix(entry.Name(), ext)
+		}
+		themes = append(themes, theme)
+	}
+	sort.Slice(themes, func(i, j int) bool { return themes[i].Name < themes[j].Name })
+	return themes, errors.Join(errs...)
+}
+
+func loadTheme(path string) (Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Theme{}, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	var theme Theme
+	if filepath.Ext(path) == ".toml" {
+		err = toml.Unmarshal(data, &theme)
+	} else {
+		err = json.Unmarshal(data, &theme)
+	}
+	if err != nil {
+		return Theme{}, fmt.Errorf("invalid theme %s: %w", path, err)
+	}
+	if theme.Base == "" {
+		theme.Base = ThemeDark
+	}
+	base, ok := Builtin(theme.Base)
+	if !ok {
+		return Theme{}, fmt.Errorf("invalid theme %s: %w: %s", path, ErrUnknownTheme, theme.Base)
+	}
+	theme.inherit(base)
+	return theme, nil
+}
+
+// inherit sets the colors that are not set from base
+func (t *Theme) inherit(base Theme) {
+	for _, c := range []struct{ color, base *lipgloss.Color }{
+		{&t.Title, &base.Title},
+		{&t.TitleSecondary, &base.TitleSecondary},
+		{&t.ActiveContainer, &base.ActiveContainer},
+		{&t.ListItem, &base.ListItem},
+		{&t.ListItemSecondary, &base.ListItemSecondary},
+		{&t.Description, &base.Description},
+		{&t.UserMessage, &base.UserMessage},
+		{&t.AIMessage, &base.AIMessage},
+		{&t.Metadata, &base.Metadata},
+		{&t.Error, &base.Error},
+	} {
+		if *c.color == "" {
+			*c.color = *c.base
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package styles
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestLoadThemes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"solarized.json": `{"title": "#b58900", "base": "light"}`,
+		"mono.toml":      "name = \"monochrome\"\n# only the errors stand out\nerror = \"#ff0000\"\n",
+		"broken.toml":    `title = `,
+		"unknown.json":   `{"base": "sepia"}`,
+		"notes.txt":      `not a theme`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	themes, err := LoadThemes(dir)
+	if err == nil {
+		t.Error("expected the invalid themes to be reported")
+	}
+	if !errors.Is(err, ErrUnknownTheme) {
+		t.Errorf("expected the unknown base to be reported, got %v", err)
+	}
+	if len(themes) != 2 {
+		t.Fatalf("expected the valid themes, got %+v", themes)
+	}
+
+	light, _ := Builtin(ThemeLight)
+	dark, _ := Builtin(ThemeDark)
+	mono, solarized := themes[0], themes[1]
+	if mono.Name != "monochrome" || mono.Error != "#ff0000" || mono.Title != dark.Title {
+		t.Errorf("expected the toml theme on top of dark, got %+v", mono)
+	}
+	if solarized.Name != "solarized" || solarized.Title != "#b58900" || solarized.AIMessage != light.AIMessage {
+		t.Errorf("expected the json theme named after its file on top of light, got %+v", solarized)
+	}
+
+	if themes, err := LoadThemes(filepath.Join(dir, "missing")); err != nil || themes != nil {
+		t.Errorf("expected no themes without the dir, got %v, %v", themes, err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	user := []Theme{{Name: "dark", Title: "#000000"}, {Name: "solarized"}}
+
+	if theme, err := Find("dark", user); err != nil || theme.Title != "#000000" {
+		t.Errorf("expected the user theme to override the built-in one, got %+v, %v", theme, err)
+	}
+	if theme, err := Find(ThemeHighContrast, user); err != nil || theme.Name != ThemeHighContrast {
+		t.Errorf("expected the built-in theme, got %+v, %v", theme, err)
+	}
+	if _, err := Find("sepia", user); !errors.Is(err, ErrUnknownTheme) {
+		t.Errorf("expected an unknown theme, got %v", err)
+	}
+
+	defer lipgloss.SetHasDarkBackground(lipgloss.HasDarkBackground())
+	lipgloss.SetHasDarkBackground(false)
+	if theme, _ := Find(ThemeAuto, nil); theme.Name != ThemeLight {
+		t.Errorf("expected the light theme on a light background, got %s", theme.Name)
+	}
+	lipgloss.SetHasDarkBackground(true)
+	if theme, _ := Find("", nil); theme.Name != ThemeDark {
+		t.Errorf("expected the dark theme on a dark background, got %s", theme.Name)
+	}
+
+	names := Names(user)
+	if len(names) != 5 || names[0] != ThemeAuto || names[4] != "solarized" {
+		t.Errorf("expected auto, the built-in and the new user themes, got %v", names)
+	}
+}
+
+func TestSetTheme(t *testing.T) {
+	defer SetTheme(Active())
+	theme, _ := Builtin(ThemeHighContrast)
+	SetTheme(theme)
+	if got := ErrorStyle().GetForeground(); got != theme.Error {
+		t.Errorf("expected the styles to use the active theme, got %v", got)
+	}
+}
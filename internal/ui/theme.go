@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aavshr/panda/internal/config"
+	"github.com/aavshr/panda/internal/ui/components"
+	"github.com/aavshr/panda/internal/ui/styles"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// loadThemes reads the user themes and offers them on the settings screen,
+// invalid theme files are returned as an error with the valid themes
+func (m *Model) loadThemes() error {
+	themes, err := styles.LoadThemes(config.GetThemesDir())
+	m.themes = themes
+	m.settingsModel.SetThemes(styles.Names(themes))
+	if err != nil {
+		return fmt.Errorf("styles.LoadThemes: %w", err)
+	}
+	return nil
+}
+
+// setTheme switches the theme and rebuilds the styles of every section,
+// the theme is not saved
+func (m *Model) setTheme(name string) error {
+	theme, err := styles.Find(name, m.themes)
+	if err != nil {
+		return err
+	}
+	styles.SetTheme(theme)
+	m.historyModel.RefreshStyles()
+	m.messagesModel.RefreshStyles()
+	m.settingsModel.RefreshStyles()
+	m.paramsModel.RefreshStyles()
+	m.modelPicker.RefreshStyles()
+	m.palette.RefreshStyles()
+	m.keyHelp.RefreshStyles()
+	m.statusBar.RefreshStyles()
+	return nil
+}
+
+// commandTheme switches and saves the theme, without a name it shows the
+// themes to pick from
+func (m *Model) commandTheme(msg components.ChatInputCommandMsg) tea.Cmd {
+	if len(msg.Args) > 1 {
+		m.chatInputModel.SetError(fmt.Errorf("usage: /theme [name]"))
+		return nil
+	}
+	if len(msg.Args) == 0 {
+		m.chatInputModel.SetHint(fmt.Sprintf("theme: %s, use one of %s", m.themeName(), strings.Join(styles.Names(m.themes), ", ")))
+		return nil
+	}
+	if err := m.setTheme(msg.Args[0]); err != nil {
+		m.chatInputModel.SetError(err)
+		return nil
+	}
+	if m.userConfig != nil {
+		newConfig := *m.userConfig
+		newConfig.Theme = msg.Args[0]
+		savedConfig, err := config.Save(newConfig)
+		if err != nil {
+			return m.cmdError(fmt.Errorf("config.Save: %w", err))
+		}
+		m.userConfig = savedConfig
+	}
+	m.chatInputModel.SetHint("theme: " + msg.Args[0])
+	return nil
+}
+
+// themeName is the theme of the config, auto if none is set
+func (m *Model) themeName() string {
+	if m.userConfig == nil || m.userConfig.Theme == "" {
+		return styles.ThemeAuto
+	}
+	return m.userConfig.Theme
+}
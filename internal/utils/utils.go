@@ -1,9 +1,49 @@
 package utils
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aymanbagabas/go-osc52/v2"
 	"github.com/matoous/go-nanoid/v2"
 )
 
 func RandomID() (string, error) {
 	return gonanoid.New()
 }
+
+// CopyToClipboard writes an OSC52 sequence to w which asks the terminal
+// to put s on the system clipboard, this also works over ssh
+func CopyToClipboard(w io.Writer, s string) error {
+	seq := osc52.New(s)
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	}
+	_, err := seq.WriteTo(w)
+	return err
+}
+
+// ExpandPath expands a leading ~ to the user's home directory
+func ExpandPath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path, err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// ThreadName derives a short thread name from the first prompt of a thread
+func ThreadName(prompt string) string {
+	const n = 25
+	name := []rune(strings.Join(strings.Fields(prompt), " "))
+	if len(name) < n {
+		return fmt.Sprintf("%s..", string(name))
+	}
+	return fmt.Sprintf("%s..", string(name[:n]))
+}
@@ -1,20 +1,25 @@
 package main
 
 import (
-	_ "embed"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/aavshr/panda/internal/cli"
 	"github.com/aavshr/panda/internal/config"
 	"github.com/aavshr/panda/internal/db"
+	"github.com/aavshr/panda/internal/db/schema"
 	"github.com/aavshr/panda/internal/llm/openai"
+	"github.com/aavshr/panda/internal/mcp"
 	"github.com/aavshr/panda/internal/ui"
+	"github.com/aavshr/panda/internal/ui/llm"
 	"github.com/aavshr/panda/internal/ui/store"
 	tea "github.com/charmbracelet/bubbletea"
-	"golang.org/x/term"
 )
 
 // build information injected by goreleaser
@@ -24,16 +29,21 @@ var (
 	date    = "unknown"
 )
 
-//go:embed internal/db/schema/init.sql
-var dbSchemaInit string
-
-//go:embed internal/db/schema/migrations.sql
-var dbSchemaMigrations string
-
 const (
 	DefaultDatabaseName = "panda.db"
+
+	providerOpenAI = "openai"
+	// providerMock answers offline by echoing the messages, for demos
+	providerMock = "mock"
 )
 
+// mockConfig makes the mock provider look like a streaming backend
+var mockConfig = llm.MockConfig{
+	Echo:      true,
+	ChunkSize: 4,
+	Latency:   20 * time.Millisecond,
+}
+
 func initMockStore() *store.Mock {
 	testThreads := []*db.Thread{
 		{
@@ -83,53 +93,171 @@ func initMockStore() *store.Mock {
 	return store.NewMock(testThreads, testMessages)
 }
 
-func main() {
-	if len(os.Args) > 1 && (os.Args[1] == "--version" || os.Args[1] == "-v") {
-		fmt.Printf("panda %s\ncommit: %s\nbuilt at: %s\n", version, commit, date)
-		os.Exit(0)
-	}
+type options struct {
+	dataDirPath    string
+	databaseName   string
+	configFilePath string
+	provider       string
+	showVersion    bool
+}
 
-	isDev := strings.ToLower(os.Getenv("PANDA_ENV")) == "dev"
-	dataDirPath := config.GetDataDir()
-	databaseName := DefaultDatabaseName
-	if isDev {
-		devDataDirPath := os.Getenv("PANDA_DATA_DIR_PATH")
-		devDatabaseName := os.Getenv("PANDA_DATABASE_NAME")
-		if devDataDirPath != "" {
-			dataDirPath = devDataDirPath
+// defaultOptions returns the default paths, dev mode allows overriding
+// them with environment variables
+func defaultOptions() *options {
+	opts := &options{
+		dataDirPath:  config.GetDataDir(),
+		databaseName: DefaultDatabaseName,
+		provider:     providerOpenAI,
+	}
+	if strings.ToLower(os.Getenv("PANDA_ENV")) == "dev" {
+		if devDataDirPath := os.Getenv("PANDA_DATA_DIR_PATH"); devDataDirPath != "" {
+			opts.dataDirPath = devDataDirPath
 		}
-		if devDatabaseName != "" {
-			databaseName = devDatabaseName
+		if devDatabaseName := os.Getenv("PANDA_DATABASE_NAME"); devDatabaseName != "" {
+			opts.databaseName = devDatabaseName
 		}
 	}
+	return opts
+}
+
+func parseOptions(args []string) (*options, []string, error) {
+	opts := defaultOptions()
+	flags := flag.NewFlagSet("panda", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: panda [flags] [command]\n\nflags:\n")
+		flags.PrintDefaults()
+		fmt.Fprintln(flags.Output())
+		cli.Usage(flags.Output())
+	}
+	flags.StringVar(&opts.dataDirPath, "data-dir", opts.dataDirPath, "directory of the database")
+	dbPath := flags.String("db", "", "path of the database file, overrides -data-dir")
+	flags.StringVar(&opts.configFilePath, "config", "", "path of the config file")
+	flags.StringVar(&opts.provider, "provider", opts.provider, "llm backend, openai or mock to answer offline")
+	flags.BoolVar(&opts.showVersion, "version", false, "print the version")
+	flags.BoolVar(&opts.showVersion, "v", false, "print the version")
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if *dbPath != "" {
+		opts.dataDirPath = filepath.Dir(*dbPath)
+		opts.databaseName = filepath.Base(*dbPath)
+	}
+	if opts.provider != providerOpenAI && opts.provider != providerMock {
+		err := fmt.Errorf("unknown provider %s, use %s or %s", opts.provider, providerOpenAI, providerMock)
+		fmt.Fprintln(flags.Output(), err)
+		return nil, nil, err
+	}
+	return opts, flags.Args(), nil
+}
 
-	dbStore, err := db.New(db.Config{
-		DataDirPath:  dataDirPath,
-		DatabaseName: databaseName,
-	}, &dbSchemaInit, &dbSchemaMigrations)
-	if err != nil {
-		log.Fatal("failed to initialize db: ", err)
+func (o *options) openStore() (*db.Store, error) {
+	return db.New(db.Config{
+		DataDirPath:  o.dataDirPath,
+		DatabaseName: o.databaseName,
+	}, &schema.Init, &schema.Migrations)
+}
+
+// newUILLM returns the backend of the terminal ui, the settings set its
+// api key and base url
+func (o *options) newUILLM() llm.LLM {
+	if o.provider == providerMock {
+		mock := llm.NewMock()
+		mock.SetConfig(mockConfig)
+		return mock
 	}
+	return openai.New("")
+}
 
+func (o *options) newLLM(userConfig *config.Config) (cli.LLM, error) {
+	if o.provider == providerMock {
+		return o.newUILLM(), nil
+	}
+	apiKey, err := userConfig.APIKey()
+	if err != nil {
+		return nil, fmt.Errorf("userConfig.APIKey: %w", err)
+	}
 	openaiLLM := openai.New("")
+	if err := openaiLLM.SetAPIKey(apiKey); err != nil {
+		return nil, fmt.Errorf("llm.SetAPIKey: %w", err)
+	}
+	if err := openaiLLM.SetBaseURL(userConfig.LLMBaseURL); err != nil {
+		return nil, fmt.Errorf("llm.SetBaseURL: %w", err)
+	}
+	return llm.NewRetry(openaiLLM, llm.DefaultRetryConfig()), nil
+}
+
+// migrateAPIKey moves the plaintext api key of older versions out of the
+// config file, it is tried again on the next start if it fails
+func migrateAPIKey() {
+	userConfig, err := config.Load()
+	if err != nil {
+		return
+	}
+	migrated, err := config.MigrateAPIKey(userConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not move the api key out of %s: %v\n", config.GetFilePath(), err)
+		return
+	}
+	if migrated {
+		fmt.Fprintf(os.Stderr, "moved the api key out of %s, it is referenced as %s\n", config.GetFilePath(), userConfig.LLMAPIKeyRef)
+	}
+}
+
+func main() {
+	opts, args, err := parseOptions(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if opts.showVersion {
+		fmt.Printf("panda %s\ncommit: %s\nbuilt at: %s\n", version, commit, date)
+		os.Exit(0)
+	}
+	mcp.ClientVersion = version
+	if opts.configFilePath != "" {
+		config.SetFilePath(opts.configFilePath)
+	}
+	migrateAPIKey()
 
-	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if len(args) > 0 {
+		app := cli.New(&cli.Config{
+			OpenStore: opts.openStore,
+			NewLLM:    opts.newLLM,
+		})
+		if err := app.Run(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				os.Exit(0)
+			}
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	dbStore, err := opts.openStore()
 	if err != nil {
-		log.Fatalf("failed to get terminal size: %v", err)
+		log.Fatal("failed to initialize db: ", err)
 	}
 
+	backend := llm.NewRetry(opts.newUILLM(), llm.DefaultRetryConfig())
 	m, err := ui.New(&ui.Config{
 		InitThreadsLimit: 10,
 		MaxThreadsLimit:  100,
 		MessagesLimit:    50,
-		Width:            width - 8,
-		Height:           height - 10,
-	}, dbStore, openaiLLM)
+	}, dbStore, backend)
 	if err != nil {
 		log.Fatal("ui.New: ", err)
 	}
 	p := tea.NewProgram(m)
-	if _, err := p.Run(); err != nil {
+	// retries happen while the answer is read in the background
+	backend.OnRetry(func(e llm.RetryEvent) {
+		p.Send(ui.RetryMsg(e))
+	})
+	_, err = p.Run()
+	m.Close()
+	if err != nil {
 		log.Println("error: ", err)
 		os.Exit(1)
 	}